@@ -1,19 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 
+	"github.com/netobserv/network-observability-console-plugin/pkg/handler"
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/permalinks"
+	"github.com/netobserv/network-observability-console-plugin/pkg/preferences"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedfilters"
 	"github.com/netobserv/network-observability-console-plugin/pkg/server"
+	"github.com/netobserv/network-observability-console-plugin/pkg/tracing"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 )
 
 var (
@@ -22,30 +36,108 @@ var (
 	app          = "netobserv-console-plugin"
 	port         = flag.Int("port", 9001, "server port to listen on (default: 9001)")
 	metricsPort  = flag.Int("metrics-port", 9002, "Metrics (prometheus) server port to listen on (default: 9002)")
-	cert         = flag.String("cert", "", "cert file path to enable TLS (disabled by default)")
+	cert         = flag.String("cert", "", "cert file path to enable TLS, reloaded on change (disabled by default)")
 	key          = flag.String("key", "", "private key file path to enable TLS (disabled by default)")
-	corsOrigin   = flag.String("cors-origin", "*", "CORS allowed origin (default: *)")
-	corsMethods  = flag.String("cors-methods", "", "CORS allowed methods (default: unset)")
-	corsHeaders  = flag.String("cors-headers", "Origin, X-Requested-With, Content-Type, Accept", "CORS allowed headers (default: Origin, X-Requested-With, Content-Type, Accept)")
+	corsOrigin   = flag.String("cors-origin", "*", "CORS allowed origins, comma-separated, * allowing any (default: *)")
+	corsMethods  = flag.String("cors-methods", "GET, POST, PUT, DELETE", "CORS allowed methods (default: GET, POST, PUT, DELETE)")
+	corsHeaders  = flag.String("cors-headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization, X-Request-ID", "CORS allowed headers (default: Origin, X-Requested-With, Content-Type, Accept, Authorization, X-Request-ID)")
+	corsExpose   = flag.String("cors-expose-headers", "Retry-After, Warning, X-Request-ID", "CORS headers exposed to the client (default: Retry-After, Warning, X-Request-ID)")
+	corsCreds    = flag.Bool("cors-credentials", false, "CORS allow credentials to the origins listed in cors-origin, which must not include * (default: false)")
 	corsMaxAge   = flag.String("cors-max-age", "", "CORS allowed max age (default: unset)")
+	rateLimit    = flag.Float64("rate-limit", 0, "Number of flows, topology, cardinality and values requests per second allowed per client (user token or address), 0 meaning no limit (default: 0)")
+	rateBurst    = flag.Int("rate-limit-burst", 10, "Number of flows, topology, cardinality and values requests a client can send at once above the rate limit (default: 10)")
 	// todo: default value temporarily kept to make it work with older versions of the NOO. Remove default and force setup of loki url
 	lokiURL                = flag.String("loki", "http://localhost:3100", "URL of the loki querier host")
 	lokiStatusURL          = flag.String("loki-status", "", "URL for loki /ready /metrics /config endpoints. (default: loki flag value)")
 	lokiLabels             = flag.String("loki-labels", "SrcK8S_Namespace,SrcK8S_OwnerName,DstK8S_Namespace,DstK8S_OwnerName,FlowDirection", "Loki labels, comma separated")
 	lokiTimeout            = flag.Duration("loki-timeout", 30*time.Second, "Timeout of the Loki query to retrieve logs")
 	lokiTenantID           = flag.String("loki-tenant-id", "netobserv", "Tenant organization ID for multi-tenant-loki (submitted as the X-Scope-OrgID HTTP header)")
+	lokiTenantMapping      = flag.String("loki-tenant-mapping", "", "Comma separated mapping of namespaces to Loki tenants, e.g. team-a=tenant-a,team-b=tenant-b, applied to the users restricted by namespace-authorization to namespaces of a same tenant; other users use loki-tenant-id (default: unset)")
 	lokiTokenPath          = flag.String("loki-token-path", "", "Path to Bearer authorization header for loki gateway")
 	lokiForwardUserToken   = flag.Bool("loki-forward-user-token", false, "Forward the user Bearer authorization header for loki gateway, this override loki-token-path option")
 	lokiCAPath             = flag.String("loki-ca-path", "", "Path to loki CA certificate")
 	lokiSkipTLS            = flag.Bool("loki-skip-tls", false, "Skip TLS checks for loki HTTPS connection")
+	lokiUserCertPath       = flag.String("loki-user-cert-path", "", "Path to loki user cert for mTLS, reloaded on change")
+	lokiUserKeyPath        = flag.String("loki-user-key-path", "", "Path to loki user key for mTLS, reloaded on change")
 	lokiStatusCAPath       = flag.String("loki-status-ca-path", "", "Path to loki status CA certificate")
-	lokiStatusUserCertPath = flag.String("loki-status-user-cert-path", "", "Path to loki status user cert for mTLS")
-	lokiStatusUserKeyPath  = flag.String("loki-status-user-key-path", "", "Path to loki status user key for mTLS")
+	lokiStatusUserCertPath = flag.String("loki-status-user-cert-path", "", "Path to loki status user cert for mTLS, reloaded on change")
+	lokiStatusUserKeyPath  = flag.String("loki-status-user-key-path", "", "Path to loki status user key for mTLS, reloaded on change")
 	lokiStatusSkipTLS      = flag.Bool("loki-status-skip-tls", false, "Skip TLS checks for loki status HTTPS connection")
 	lokiMock               = flag.Bool("loki-mock", false, "Fake loki results using saved mocks")
+	lokiForwardedHeaders   = flag.String("loki-forwarded-headers", strings.Join(constants.DefaultForwardedHeaders, ","), "Comma separated list of inbound headers forwarded to Loki, when not already set by other options, except the Loki tenant header (default: tracing headers)")
+	lokiOverloadBackoff    = flag.Duration("loki-overload-backoff", 0, "Delay before retrying once a query rejected by Loki with 'too many outstanding requests', 0 meaning no retry (default: 0)")
+	maxRequestTimeout      = flag.Duration("max-request-timeout", 2*time.Minute, "Maximum duration of a request querying Loki, which can be shortened per request with the timeout parameter, 0 meaning no limit (default: 2m). The server write timeout is derived from it, leaving 10s to write the response")
+	lokiMaxIdleConns       = flag.Int("loki-max-idle-conns-per-host", 10, "Maximum number of idle connections kept open to each Loki host, e.g. to reuse connections of parallel queries (default: 10)")
+	lokiIdleConnTimeout    = flag.Duration("loki-idle-conn-timeout", 0, "Duration after which idle connections to Loki are closed, 0 meaning loki-timeout (default: 0)")
+	lokiTLSHandshakeTO     = flag.Duration("loki-tls-handshake-timeout", 10*time.Second, "Timeout of the TLS handshake with Loki, 0 meaning no timeout (default: 10s)")
+	lokiHTTP2              = flag.Bool("loki-http2", false, "Attempt HTTP/2 connections to Loki (default: false)")
+	lokiRetryMaxAttempts   = flag.Int("loki-retry-max-attempts", 1, "Maximum number of attempts of Loki calls failing with a retryable status, 1 meaning no retry (default: 1)")
+	lokiRetryBackoff       = flag.Duration("loki-retry-backoff", 500*time.Millisecond, "Delay before the first retry of a Loki call, doubled at each attempt unless Loki sends Retry-After (default: 500ms)")
+	lokiRetryMaxBackoff    = flag.Duration("loki-retry-max-backoff", 5*time.Second, "Maximum delay between two attempts of a Loki call (default: 5s)")
+	lokiRetryCodes         = flag.String("loki-retry-codes", "429,502,503", "Comma separated HTTP statuses of Loki calls that are retried (default: 429,502,503)")
+	lokiMaxInFlight        = flag.Int("loki-max-inflight-queries", 0, "Maximum number of Loki queries running at once, for all requests, 0 meaning no limit (default: 0)")
+	lokiMaxQueued          = flag.Int("loki-max-queued-queries", 100, "Maximum number of Loki queries waiting for their turn when loki-max-inflight-queries is reached, beyond which requests fail with a server busy error (default: 100)")
+	lokiBreakerFailures    = flag.Int("loki-circuit-breaker-failures", 0, "Number of consecutive Loki failures (connection errors or 5xx) after which queries fail fast, without calling Loki, 0 meaning no circuit breaker (default: 0)")
+	lokiBreakerOpenFor     = flag.Duration("loki-circuit-breaker-open-duration", 30*time.Second, "Duration during which queries fail fast once the circuit breaker opened, before trying Loki again (default: 30s)")
+	lokiQueryCacheTTL      = flag.Duration("loki-query-cache-ttl", 0, "Duration during which identical Loki queries are served from memory, their time range being rounded to this duration, 0 meaning no caching (default: 0)")
+	lokiQueryCacheSize     = flag.Int("loki-query-cache-size", 100, "Maximum number of Loki responses kept in the query cache (default: 100)")
+	lokiNegativeCacheTTL   = flag.Duration("loki-negative-cache-ttl", 0, "Duration during which queries that returned no result are short-circuited, for the same query and time range duration, 0 meaning no negative caching (default: 0)")
+	lokiNegativeCacheSize  = flag.Int("loki-negative-cache-size", 1000, "Maximum number of empty query signatures kept in the negative cache (default: 1000)")
+	lokiSlowQueries        = flag.Duration("loki-slow-query-threshold", 0, "Duration above which Loki queries are logged with their LogQL and listed at /api/admin/slow-queries, 0 meaning no slow query log (default: 0)")
+	lokiSlowQueriesSize    = flag.Int("loki-slow-query-log-size", 100, "Maximum number of the most recent slow queries listed at /api/admin/slow-queries (default: 100)")
+	lokiPartialResults     = flag.Bool("loki-partial-results", false, "Return the results of the successful parallel Loki queries when others failed, listing the failures in the response (default: false)")
+	lokiMaxParallelQueries = flag.Int("loki-max-parallel-queries", 10, "Maximum number of Loki queries run in parallel for a single request, 0 meaning unlimited (default: 10)")
+	queryShardThreshold    = flag.Duration("query-shard-threshold", 0, "Time range above which flows queries are split into contiguous sub-ranges queried in parallel, 0 meaning no split (default: 0)")
+	queryShards            = flag.Int("query-shards", 4, "Number of sub-ranges of flows queries split by time range (default: 4)")
+	ingestionDelay         = flag.Duration("ingestion-delay", 0, "Shift back the end of flows and topology queries to now minus this delay, to exclude data still being ingested, 0 meaning no shift (default: 0)")
+	maxQuerySpan           = flag.Duration("max-query-span", 0, "Maximum time span allowed in flows and topology queries, 0 meaning unlimited (default: 0)")
+	maxQueryLookback       = flag.Duration("max-query-lookback", 0, "Maximum lookback allowed for the start time of flows and topology queries, 0 meaning unlimited (default: 0)")
+	valueListsPath         = flag.String("value-lists-path", "", "Directory containing named value lists that can be referenced in filters as @name, one value per line (disabled by default)")
+	maxValueListSize       = flag.Int("max-value-list-size", 500, "Maximum number of values allowed in a referenced value list (default: 500)")
+	regexSubstringMatch    = flag.Bool("regex-substring-match", false, "Match substrings with regular expression filters (key=~regex) instead of anchoring them to the whole value (default: false)")
+	portNames              = flag.String("port-names", "", "Comma separated port=name list used to annotate flows with service names, replacing the default well-known ports (default: unset)")
+	servicePorts           = flag.String("service-ports", "", "Comma separated service=port[:port...] list used to filter flows by service name, replacing the default well-known services (default: unset)")
+	inferAppProtocol       = flag.Bool("infer-app-protocol", false, "Attach to flows an application protocol inferred from well-known ports (default: false)")
+	appProtocols           = flag.String("app-protocols", "", "Comma separated port[/tcp|/udp]=protocol list used to infer flows application protocol, replacing the default well-known ports (default: unset)")
+	autoFieldSelection     = flag.Bool("auto-field-selection", false, "Exclude from flows the fields of features found disabled, such as DNS tracking, packet drops or RTT (default: false)")
+	trimFilters            = flag.Bool("trim-filters", true, "Remove surrounding whitespace from filter keys and values (default: true)")
+	lowercaseFilters       = flag.Bool("lowercase-filters", false, "Lowercase filter values of fields known to be lowercase, such as Kubernetes names (default: false)")
+	maxStringFieldLength   = flag.Int("max-string-field-length", 0, "Truncate string fields longer than this number of characters in flows responses, 0 meaning no truncation (default: 0)")
+	flowReconciliation     = flag.String("flow-reconciliation", "keep-both", "Strategy to merge a same flow reported by both source and destination: keep-both, max, sum, source-wins or destination-wins (default: keep-both)")
+	enforcedFilters        = flag.String("enforced-filters", "", "Filters added to every flows and topology query, that requests cannot override, e.g. SrcK8S_Namespace=\"app\" (default: unset)")
+	defaultExclusions      = flag.String("default-exclusions", "", "Filters added to every flows and topology query, unless the request filters on the same fields, e.g. SrcK8S_Namespace!=\"openshift-monitoring\" (default: unset)")
+	queryDefaults          = flag.String("query-defaults", "", "Default query parameters, URL-encoded, applied when missing from flows and topology requests, e.g. limit=100&reporter=destination (default: unset)")
+	trustedCallerTokenPath = flag.String("trusted-caller-token-path", "", "Path to a token that exempts callers from the max span / lookback caps when provided in the X-Trusted-Caller-Token header")
+	savedFiltersNamespace  = flag.String("saved-filters-namespace", "", "Namespace of the ConfigMap storing users saved filters, enabling the saved filters API (disabled by default)")
+	savedFiltersConfigMap  = flag.String("saved-filters-configmap", "netobserv-saved-filters", "Name of the ConfigMap storing users saved filters (default: netobserv-saved-filters)")
+	preferencesNamespace   = flag.String("preferences-namespace", "", "Namespace of the ConfigMaps storing users preferences, one per user, enabling the preferences API (disabled by default)")
+	preferencesConfigMap   = flag.String("preferences-configmap", "netobserv-preferences", "Name prefix of the ConfigMaps storing users preferences, labelled netobserv.io/user-preferences (default: netobserv-preferences)")
+	permalinksNamespace    = flag.String("permalinks-namespace", "", "Namespace of the ConfigMap storing permalinks, enabling the permalinks API (disabled by default)")
+	permalinksConfigMap    = flag.String("permalinks-configmap", "netobserv-permalinks", "Name of the ConfigMap storing permalinks (default: netobserv-permalinks)")
+	permalinksTTL          = flag.Duration("permalinks-ttl", 30*24*time.Hour, "Duration after which permalinks expire, 0 meaning never (default: 720h)")
+	permalinksMax          = flag.Int("permalinks-max", 1000, "Maximum number of permalinks, the oldest being evicted, 0 meaning no cap other than the ConfigMap size (default: 1000)")
 	logLevel               = flag.String("loglevel", "info", "log level (default: info)")
 	frontendConfig         = flag.String("frontend-config", "", "path to the console plugin config file")
 	authCheck              = flag.String("auth-check", "auto", "type of authentication check: authenticated, admin, auto or none (default is auto, based on loki auth mode)")
+	auditLog               = flag.String("audit-log", "", "File receiving the audit log of flows queries, in JSON lines, or - for the standard output (disabled by default)")
+	otlpEndpoint           = flag.String("otlp-endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry collector receiving the traces of requests and Loki calls, e.g. http://otel-collector:4318 (disabled by default)")
+	tracingServiceName     = flag.String("tracing-service-name", "netobserv-plugin", "Service name of the exported traces (default: netobserv-plugin)")
+	tracingSamplingRatio   = flag.Float64("tracing-sampling-ratio", 1, "Ratio of the new traces recorded, from 0 to 1, inbound traces following their parent sampling (default: 1)")
+	otlpTokenPath          = flag.String("otlp-token-path", "", "Path to the bearer token authenticating to the OTLP endpoint")
+	otlpCAPath             = flag.String("otlp-ca-path", "", "Path to the OTLP endpoint CA certificate")
+	otlpSkipTLS            = flag.Bool("otlp-skip-tls", false, "Skip TLS checks for the OTLP endpoint HTTPS connection")
+	otlpUserCertPath       = flag.String("otlp-user-cert-path", "", "Path to the OTLP endpoint user cert for mTLS")
+	otlpUserKeyPath        = flag.String("otlp-user-key-path", "", "Path to the OTLP endpoint user key for mTLS")
+	authCacheTTL           = flag.Duration("auth-cache-ttl", 30*time.Second, "Duration during which successful token reviews are cached, 0 disabling the cache (default: 30s)")
+	namespaceAuthz         = flag.Bool("namespace-authorization", false, "Restrict users without cluster-wide access to the flows of the namespaces where they can read pods (default: false)")
+	namespaceAuthzCacheTTL = flag.Duration("namespace-authorization-cache-ttl", time.Minute, "Duration during which users namespaces access is cached (default: 1m)")
+	debugEndpoints         = flag.Bool("debug-endpoints", false, "Serve the pprof profiles and an expvar snapshot of goroutines and in-flight Loki queries at /api/admin/debug, for admins (default: false)")
+	readinessCacheTTL      = flag.Duration("readiness-cache-ttl", 10*time.Second, "Duration during which the Loki probe result of the /readyz endpoint is reused, 0 probing Loki at each check (default: 10s)")
+	shutdownDelay          = flag.Duration("shutdown-delay", 5*time.Second, "Time during which requests are still served on SIGTERM with readiness failing, so that the pod is removed from the service endpoints (default: 5s)")
+	shutdownTimeout        = flag.Duration("shutdown-timeout", 20*time.Second, "Time left to in-flight requests to complete after the shutdown delay, before they are cancelled; the delay, timeout and metrics flush delay must fit in the pod termination grace period (default: 20s)")
+	metricsFlushDelay      = flag.Duration("shutdown-metrics-flush-delay", 0, "Time during which the metrics are still served after draining requests, for a last scrape (default: 0)")
+	configFile             = flag.String("config-file", "", "YAML file of flag values, e.g. loki-timeout: 1m, overriding the command line and reloaded on change, except for the listening, authentication, shared caches and queue settings (disabled by default)")
+	configReloadInterval   = flag.Duration("config-reload-interval", 10*time.Second, "Interval at which config-file and frontend-config are checked for changes, 0 disabling reloads (default: 10s)")
 	versionFlag            = flag.Bool("v", false, "print version")
 	log                    = logrus.WithField("module", "main")
 )
@@ -59,6 +151,217 @@ func main() {
 		os.Exit(0)
 	}
 
+	cmdLine := flagValues()
+	if *configFile != "" {
+		if err := applyConfigFile(*configFile, cmdLine); err != nil {
+			log.WithError(err).Fatal("wrong config file")
+		}
+	}
+
+	applyLogLevel()
+	logrus.AddHook(httpclient.RequestIDHook{})
+	log.Infof("Starting %s at log level %s", appVersion, *logLevel)
+
+	var checkType auth.CheckType
+	if *authCheck == "auto" {
+		if *lokiForwardUserToken {
+			// FORWARD lokiAuth mode
+			checkType = auth.CheckAuthenticated
+		} else {
+			// HOST or DISABLED lokiAuth mode
+			checkType = auth.CheckAdmin
+		}
+		log.Info(fmt.Sprintf("auth-check 'auto' resolved to '%s'", checkType))
+	} else {
+		checkType = auth.CheckType(*authCheck)
+	}
+	if checkType == auth.CheckNone {
+		log.Warn("INSECURE: auth checker is disabled")
+	}
+	checker, err := auth.NewChecker(checkType, client.NewInCluster, *authCacheTTL)
+	if err != nil {
+		log.WithError(err).Fatal("auth checker error")
+	}
+	// admin endpoints require cluster admins, unless auth checks are disabled
+	adminChecker := checker
+	if checkType != auth.CheckNone && checkType != auth.CheckAdmin {
+		adminChecker, err = auth.NewChecker(auth.CheckAdmin, client.NewInCluster, *authCacheTTL)
+		if err != nil {
+			log.WithError(err).Fatal("admin auth checker error")
+		}
+	}
+
+	var namespaceAuthorizer auth.NamespaceAuthorizer
+	if *namespaceAuthz {
+		namespaceAuthorizer = auth.NewNamespaceAuthorizer(client.NewInCluster, *namespaceAuthzCacheTTL)
+	}
+
+	go server.StartMetrics(&server.MetricsConfig{
+		Port:           *metricsPort,
+		CertFile:       *cert,
+		PrivateKeyFile: *key,
+	})
+
+	lokiConfig, err := buildLokiConfig(nil)
+	if err != nil {
+		log.WithError(err).Fatal("wrong Loki configuration")
+	}
+	if err := checkCORS(); err != nil {
+		log.WithError(err).Fatal("wrong CORS configuration")
+	}
+
+	var savedFiltersStore savedfilters.Store
+	if *savedFiltersNamespace != "" {
+		configMaps, err := client.NewInClusterConfigMaps(*savedFiltersNamespace)
+		if err != nil {
+			log.WithError(err).Fatal("cannot create saved filters client")
+		}
+		savedFiltersStore = savedfilters.NewConfigMapStore(configMaps, *savedFiltersConfigMap)
+	}
+
+	var preferencesStore preferences.Store
+	if *preferencesNamespace != "" {
+		configMaps, err := client.NewInClusterConfigMaps(*preferencesNamespace)
+		if err != nil {
+			log.WithError(err).Fatal("cannot create preferences client")
+		}
+		preferencesStore = preferences.NewConfigMapStore(configMaps, *preferencesConfigMap)
+	}
+
+	var permalinksStore permalinks.Store
+	if *permalinksNamespace != "" {
+		configMaps, err := client.NewInClusterConfigMaps(*permalinksNamespace)
+		if err != nil {
+			log.WithError(err).Fatal("cannot create permalinks client")
+		}
+		permalinksStore = permalinks.NewConfigMapStore(configMaps, *permalinksConfigMap, *permalinksTTL, *permalinksMax)
+	}
+
+	var auditOut io.Writer
+	switch *auditLog {
+	case "":
+	case "-":
+		auditOut = os.Stdout
+	default:
+		f, err := os.OpenFile(*auditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			log.WithError(err).Fatal("cannot open audit log")
+		}
+		auditOut = f
+	}
+
+	tracer, err := buildTracer()
+	if err != nil {
+		log.WithError(err).Fatal("wrong tracing configuration")
+	}
+
+	// settings and components which are not reloaded
+	base := server.Config{
+		Version:             buildVersion,
+		Port:                *port,
+		CertFile:            *cert,
+		PrivateKeyFile:      *key,
+		AuditLog:            auditOut,
+		NamespaceAuthorizer: namespaceAuthorizer,
+		AdminChecker:        adminChecker,
+		Tracer:              tracer,
+		FrontendConfig:      *frontendConfig,
+		SavedFilters:        savedFiltersStore,
+		Permalinks:          permalinksStore,
+		Preferences:         preferencesStore,
+		ShutdownDelay:       *shutdownDelay,
+		ShutdownTimeout:     *shutdownTimeout,
+		MetricsFlushDelay:   *metricsFlushDelay,
+	}
+	watched := utils.NonEmpty([]string{*configFile, *frontendConfig})
+	var reloads chan *server.Config
+	if len(watched) > 0 && *configReloadInterval > 0 {
+		reloads = make(chan *server.Config)
+		base.Reloads = reloads
+	}
+	cfg := serverConfig(&base, lokiConfig)
+	if reloads != nil {
+		go utils.WatchFiles(context.Background(), watched, *configReloadInterval, func() {
+			reloaded, err := reloadConfig(&base, &lokiConfig, cmdLine)
+			if err != nil {
+				log.WithError(err).Error("cannot reload configuration, keeping the current one")
+				return
+			}
+			reloads <- reloaded
+		})
+	}
+
+	server.Start(cfg, checker)
+}
+
+// flagValues returns the command line value of each flag
+func flagValues() map[string]string {
+	values := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// applyConfigFile sets the flags listed in the YAML config file, e.g. "loki-timeout: 1m", the other reloadable flags
+// getting back their command line value
+func applyConfigFile(path string, cmdLine map[string]string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return err
+	}
+	for name, value := range values {
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q", name)
+		}
+		if staticFlags[name] {
+			return fmt.Errorf("%s can't be set in the config file, changing it requires a restart", name)
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			return fmt.Errorf("invalid %s: expected a single value", name)
+		}
+	}
+	for name, value := range cmdLine {
+		if !staticFlags[name] {
+			_ = flag.Set(name, value)
+		}
+	}
+	for name, value := range values {
+		if err := flag.Set(name, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// reloadConfig reads the config file again, keeping the components shared by requests, such as caches
+func reloadConfig(base *server.Config, shared *loki.Config, cmdLine map[string]string) (*server.Config, error) {
+	previousLogLevel := *logLevel
+	if *configFile != "" {
+		if err := applyConfigFile(*configFile, cmdLine); err != nil {
+			return nil, err
+		}
+	}
+	lokiConfig, err := buildLokiConfig(shared)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCORS(); err != nil {
+		return nil, err
+	}
+	// keep the log level changed at runtime, unless the config file changes it
+	if *logLevel != previousLogLevel {
+		applyLogLevel()
+	}
+	return serverConfig(base, lokiConfig), nil
+}
+
+func applyLogLevel() {
 	lvl, err := logrus.ParseLevel(*logLevel)
 	if err != nil {
 		log.Errorf("Log level %s not recognized, using info", *logLevel)
@@ -66,18 +369,71 @@ func main() {
 		lvl = logrus.InfoLevel
 	}
 	logrus.SetLevel(lvl)
-	log.Infof("Starting %s at log level %s", appVersion, *logLevel)
+}
+
+func buildTracer() (*tracing.Tracer, error) {
+	cfg := tracing.Config{
+		Endpoint:      *otlpEndpoint,
+		ServiceName:   *tracingServiceName,
+		SamplingRatio: *tracingSamplingRatio,
+		SkipTLS:       *otlpSkipTLS,
+		CAPath:        *otlpCAPath,
+		UserCertPath:  *otlpUserCertPath,
+		UserKeyPath:   *otlpUserKeyPath,
+	}
+	if *otlpTokenPath != "" {
+		token, err := os.ReadFile(*otlpTokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the OTLP token: %w", err)
+		}
+		cfg.Headers = map[string]string{"Authorization": "Bearer " + strings.TrimSpace(string(token))}
+	}
+	return tracing.NewTracer(&cfg)
+}
 
+// checkCORS rejects credentials allowed to any origin: any website could then query the API on behalf of the user
+func checkCORS() error {
+	if !*corsCreds {
+		return nil
+	}
+	for _, origin := range strings.Split(*corsOrigin, ",") {
+		if strings.TrimSpace(origin) == "*" {
+			return errors.New("cors-credentials requires an explicit cors-origin list, without *")
+		}
+	}
+	return nil
+}
+
+// serverConfig completes base with the reloadable settings
+func serverConfig(base *server.Config, lokiConfig loki.Config) *server.Config {
+	cfg := *base
+	cfg.CORSAllowOrigin = *corsOrigin
+	cfg.CORSAllowMethods = *corsMethods
+	cfg.CORSAllowHeaders = *corsHeaders
+	cfg.CORSExposeHeaders = *corsExpose
+	cfg.CORSAllowCredentials = *corsCreds
+	cfg.CORSMaxAge = *corsMaxAge
+	cfg.RateLimit = *rateLimit
+	cfg.RateLimitBurst = *rateBurst
+	cfg.Debug = *debugEndpoints
+	cfg.ReadinessCacheTTL = *readinessCacheTTL
+	cfg.Loki = lokiConfig
+	return &cfg
+}
+
+// buildLokiConfig reads the Loki settings from the flags. The admission queue, circuit breaker, caches and slow
+// query log are taken from shared, unless nil.
+func buildLokiConfig(shared *loki.Config) (loki.Config, error) {
 	lURL, err := url.Parse(*lokiURL)
 	if err != nil {
-		log.WithError(err).Fatal("wrong Loki URL")
+		return loki.Config{}, fmt.Errorf("wrong Loki URL: %w", err)
 	}
 
 	var lStatusURL *url.URL
 	if *lokiStatusURL != "" {
 		lStatusURL, err = url.Parse(*lokiStatusURL)
 		if err != nil {
-			log.WithError(err).Fatal("wrong Loki status URL")
+			return loki.Config{}, fmt.Errorf("wrong Loki status URL: %w", err)
 		}
 	} else {
 		lStatusURL = lURL
@@ -85,45 +441,210 @@ func main() {
 
 	lLabels := *lokiLabels
 	if len(lLabels) == 0 {
-		log.Fatal("labels cannot be empty")
+		return loki.Config{}, errors.New("labels cannot be empty")
 	}
 
-	var checkType auth.CheckType
-	if *authCheck == "auto" {
-		if *lokiForwardUserToken {
-			// FORWARD lokiAuth mode
-			checkType = auth.CheckAuthenticated
-		} else {
-			// HOST or DISABLED lokiAuth mode
-			checkType = auth.CheckAdmin
+	lokiConfig := loki.NewConfig(lURL, lStatusURL, *lokiTimeout, *lokiTenantID, *lokiTokenPath, *lokiForwardUserToken, *lokiSkipTLS, *lokiCAPath, *lokiStatusSkipTLS, *lokiStatusCAPath, *lokiStatusUserCertPath, *lokiStatusUserKeyPath, *lokiMock, strings.Split(lLabels, ","))
+	lokiConfig.UserCertPath = *lokiUserCertPath
+	lokiConfig.UserKeyPath = *lokiUserKeyPath
+	lokiConfig.MaxQuerySpan = *maxQuerySpan
+	lokiConfig.MaxQueryLookback = *maxQueryLookback
+	lokiConfig.IngestionDelay = *ingestionDelay
+	lokiConfig.MaxParallelQueries = *lokiMaxParallelQueries
+	lokiConfig.PartialResults = *lokiPartialResults
+	lokiConfig.MaxRequestTimeout = *maxRequestTimeout
+	lokiConfig.QueryShardThreshold = *queryShardThreshold
+	lokiConfig.QueryShards = *queryShards
+	lokiConfig.TrustedCallerTokenPath = *trustedCallerTokenPath
+	lokiConfig.ValueListsPath = *valueListsPath
+	lokiConfig.MaxValueListSize = *maxValueListSize
+	lokiConfig.RegexSubstringMatch = *regexSubstringMatch
+	lokiConfig.OverloadBackoff = *lokiOverloadBackoff
+	lokiConfig.Transport = httpclient.TransportConfig{
+		MaxIdleConnsPerHost: *lokiMaxIdleConns,
+		IdleConnTimeout:     *lokiIdleConnTimeout,
+		TLSHandshakeTimeout: *lokiTLSHandshakeTO,
+		EnableHTTP2:         *lokiHTTP2,
+	}
+	lokiConfig.Retry = httpclient.RetryPolicy{
+		MaxAttempts: *lokiRetryMaxAttempts,
+		Backoff:     *lokiRetryBackoff,
+		MaxBackoff:  *lokiRetryMaxBackoff,
+	}
+	if *lokiRetryCodes != "" {
+		lokiConfig.Retry.RetryableCodes, err = parseStatusCodes(*lokiRetryCodes)
+		if err != nil {
+			return loki.Config{}, fmt.Errorf("wrong Loki retry codes: %w", err)
 		}
-		log.Info(fmt.Sprintf("auth-check 'auto' resolved to '%s'", checkType))
-	} else {
-		checkType = auth.CheckType(*authCheck)
 	}
-	if checkType == auth.CheckNone {
-		log.Warn("INSECURE: auth checker is disabled")
+	if shared == nil {
+		shared = &loki.Config{
+			AdmissionQueue: httpclient.NewAdmissionQueue(*lokiMaxInFlight, *lokiMaxQueued),
+			CircuitBreaker: httpclient.NewCircuitBreaker(*lokiBreakerFailures, *lokiBreakerOpenFor),
+			QueryCache:     httpclient.NewCache(*lokiQueryCacheTTL, *lokiQueryCacheSize),
+			NegativeCache:  httpclient.NewNegativeCache(*lokiNegativeCacheTTL, *lokiNegativeCacheSize),
+			SlowQueries:    httpclient.NewSlowQueryLog(*lokiSlowQueries, *lokiSlowQueriesSize),
+		}
+	}
+	lokiConfig.AdmissionQueue = shared.AdmissionQueue
+	lokiConfig.CircuitBreaker = shared.CircuitBreaker
+	lokiConfig.QueryCache = shared.QueryCache
+	lokiConfig.NegativeCache = shared.NegativeCache
+	lokiConfig.SlowQueries = shared.SlowQueries
+	lokiConfig.AutoFieldSelection = *autoFieldSelection
+	lokiConfig.TrimFilters = *trimFilters
+	lokiConfig.MaxStringFieldLength = *maxStringFieldLength
+	lokiConfig.FlowReconciliation = *flowReconciliation
+	lokiConfig.LowercaseFilters = *lowercaseFilters
+	lokiConfig.PortNames = constants.DefaultPortNames
+	if *lokiTenantMapping != "" {
+		if !*namespaceAuthz {
+			return loki.Config{}, errors.New("loki-tenant-mapping requires namespace-authorization")
+		}
+		lokiConfig.TenantMapping, err = parseTenantMapping(*lokiTenantMapping)
+		if err != nil {
+			return loki.Config{}, fmt.Errorf("wrong tenant mapping: %w", err)
+		}
+	}
+	if *lokiForwardedHeaders != "" {
+		lokiConfig.ForwardedHeaders = strings.Split(*lokiForwardedHeaders, ",")
+	}
+	if *portNames != "" {
+		lokiConfig.PortNames, err = parsePortNames(*portNames)
+		if err != nil {
+			return loki.Config{}, fmt.Errorf("wrong port names: %w", err)
+		}
 	}
-	checker, err := auth.NewChecker(checkType, client.NewInCluster)
+	lokiConfig.FilterPresets, err = handler.ReadFilterPresets(*frontendConfig)
 	if err != nil {
-		log.WithError(err).Fatal("auth checker error")
+		return loki.Config{}, fmt.Errorf("wrong filter presets: %w", err)
+	}
+	restrictedAccess, err := handler.ReadRestrictedAccess(*frontendConfig)
+	if err != nil {
+		return loki.Config{}, fmt.Errorf("wrong restricted access: %w", err)
 	}
+	lokiConfig.RestrictedMaxLimit = restrictedAccess.MaxLimit
+	lokiConfig.RestrictedDisableExport = restrictedAccess.DisableExport
+	lokiConfig.EnforcedFilters = *enforcedFilters
+	lokiConfig.DefaultExclusions = *defaultExclusions
+	for _, f := range []string{*enforcedFilters, *defaultExclusions} {
+		if _, err := filters.Parse(f); err != nil {
+			return loki.Config{}, fmt.Errorf("wrong enforced filters or default exclusions: %w", err)
+		}
+	}
+	lokiConfig.QueryDefaults, err = url.ParseQuery(*queryDefaults)
+	if err != nil {
+		return loki.Config{}, fmt.Errorf("wrong query defaults: %w", err)
+	}
+	lokiConfig.InferAppProtocol = *inferAppProtocol
+	lokiConfig.AppProtocols = constants.DefaultAppProtocols
+	if *appProtocols != "" {
+		lokiConfig.AppProtocols, err = parseAppProtocols(*appProtocols)
+		if err != nil {
+			return loki.Config{}, fmt.Errorf("wrong app protocols: %w", err)
+		}
+	}
+	lokiConfig.ServicePorts = constants.DefaultServicePorts
+	if *servicePorts != "" {
+		lokiConfig.ServicePorts, err = parseServicePorts(*servicePorts)
+		if err != nil {
+			return loki.Config{}, fmt.Errorf("wrong service ports: %w", err)
+		}
+	}
+	return lokiConfig, nil
+}
 
-	go server.StartMetrics(&server.MetricsConfig{
-		Port:           *metricsPort,
-		CertFile:       *cert,
-		PrivateKeyFile: *key,
-	})
+// staticFlags can't be reloaded, being used at startup or by components shared by all requests
+var staticFlags = map[string]bool{
+	"port": true, "metrics-port": true, "cert": true, "key": true, "frontend-config": true,
+	"auth-check": true, "auth-cache-ttl": true, "loki-forward-user-token": true, "audit-log": true,
+	"namespace-authorization": true, "namespace-authorization-cache-ttl": true,
+	"otlp-endpoint": true, "tracing-service-name": true, "tracing-sampling-ratio": true,
+	"otlp-token-path": true, "otlp-ca-path": true, "otlp-skip-tls": true, "otlp-user-cert-path": true, "otlp-user-key-path": true,
+	"max-request-timeout": true, "shutdown-delay": true, "shutdown-timeout": true, "shutdown-metrics-flush-delay": true,
+	"saved-filters-namespace": true, "saved-filters-configmap": true,
+	"preferences-namespace": true, "preferences-configmap": true,
+	"permalinks-namespace": true, "permalinks-configmap": true, "permalinks-ttl": true, "permalinks-max": true,
+	"loki-max-inflight-queries": true, "loki-max-queued-queries": true,
+	"loki-circuit-breaker-failures": true, "loki-circuit-breaker-open-duration": true,
+	"loki-query-cache-ttl": true, "loki-query-cache-size": true,
+	"loki-negative-cache-ttl": true, "loki-negative-cache-size": true,
+	"loki-slow-query-threshold": true, "loki-slow-query-log-size": true,
+	"config-file": true, "config-reload-interval": true, "v": true,
+}
+
+func parsePortNames(raw string) (map[string]string, error) {
+	names := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("invalid port name definition: %q", pair)
+		}
+		if _, err := strconv.Atoi(kv[0]); err != nil {
+			return nil, fmt.Errorf("invalid port in %q: %w", pair, err)
+		}
+		names[kv[0]] = kv[1]
+	}
+	return names, nil
+}
 
-	server.Start(&server.Config{
-		Port:             *port,
-		CertFile:         *cert,
-		PrivateKeyFile:   *key,
-		CORSAllowOrigin:  *corsOrigin,
-		CORSAllowMethods: *corsMethods,
-		CORSAllowHeaders: *corsHeaders,
-		CORSMaxAge:       *corsMaxAge,
-		Loki:             loki.NewConfig(lURL, lStatusURL, *lokiTimeout, *lokiTenantID, *lokiTokenPath, *lokiForwardUserToken, *lokiSkipTLS, *lokiCAPath, *lokiStatusSkipTLS, *lokiStatusCAPath, *lokiStatusUserCertPath, *lokiStatusUserKeyPath, *lokiMock, strings.Split(lLabels, ",")),
-		FrontendConfig:   *frontendConfig,
-	}, checker)
+func parseTenantMapping(raw string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("invalid tenant mapping: %q", pair)
+		}
+		mapping[kv[0]] = kv[1]
+	}
+	return mapping, nil
+}
+
+func parseStatusCodes(raw string) ([]int, error) {
+	var codes []int
+	for _, c := range strings.Split(raw, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid HTTP status: %q", c)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func parseServicePorts(raw string) (map[string][]string, error) {
+	services := map[string][]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("invalid service ports definition: %q", pair)
+		}
+		ports := strings.Split(kv[1], ":")
+		for _, p := range ports {
+			if _, err := strconv.Atoi(p); err != nil {
+				return nil, fmt.Errorf("invalid port in %q: %w", pair, err)
+			}
+		}
+		services[strings.ToLower(kv[0])] = ports
+	}
+	return services, nil
+}
+
+func parseAppProtocols(raw string) (map[string]string, error) {
+	protocols := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("invalid app protocol definition: %q", pair)
+		}
+		port, transport, hasTransport := strings.Cut(kv[0], "/")
+		if _, err := strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid port in %q: %w", pair, err)
+		}
+		if hasTransport && transport != "tcp" && transport != "udp" && transport != "sctp" {
+			return nil, fmt.Errorf("invalid transport in %q: expected tcp, udp or sctp", pair)
+		}
+		protocols[kv[0]] = kv[1]
+	}
+	return protocols, nil
 }
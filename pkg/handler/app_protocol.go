@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+// privilegedPortMax is the highest privileged port: services usually listen on such ports, clients rarely use them
+const privilegedPortMax = 1023
+
+var transportNames = map[string]string{
+	"6":   "tcp",
+	"17":  "udp",
+	"132": "sctp",
+}
+
+// inferAppProtocol returns a processor that attaches a best-effort application protocol, inferred from well-known
+// ports. This is a heuristic, not L7 inspection: inferred values are flagged as such.
+// When both ports are known, the privileged side is preferred, then the lowest port, then the destination.
+func inferAppProtocol(protocols map[string]string) flowProcessor {
+	return func(_ *model.Entry, record *flowRecord) {
+		transport := transportNames[record.getString(fields.Proto)]
+		lookup := func(portField string) (string, int, bool) {
+			port := record.getString(portField)
+			n, err := strconv.Atoi(port)
+			if err != nil {
+				return "", 0, false
+			}
+			if transport != "" {
+				if p, ok := protocols[port+"/"+transport]; ok {
+					return p, n, true
+				}
+			}
+			p, ok := protocols[port]
+			return p, n, ok
+		}
+		srcProto, srcPort, srcOK := lookup(fields.SrcPort)
+		dstProto, dstPort, dstOK := lookup(fields.DstPort)
+		var proto string
+		switch {
+		case srcOK && dstOK:
+			srcPrivileged, dstPrivileged := srcPort <= privilegedPortMax, dstPort <= privilegedPortMax
+			if srcPrivileged != dstPrivileged {
+				proto = dstProto
+				if srcPrivileged {
+					proto = srcProto
+				}
+			} else if srcPort < dstPort {
+				proto = srcProto
+			} else {
+				proto = dstProto
+			}
+		case srcOK:
+			proto = srcProto
+		case dstOK:
+			proto = dstProto
+		default:
+			return
+		}
+		record.fields[fields.AppProtocol] = proto
+		record.fields[fields.AppProtocolInferred] = true
+	}
+}
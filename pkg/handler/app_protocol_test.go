@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+func TestGetFlows_InferAppProtocol(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			// client to server
+			{Timestamp: time.Now(), Line: `{"SrcPort":42000,"DstPort":443,"Proto":6}`},
+			// server to client
+			{Timestamp: time.Now(), Line: `{"SrcPort":53,"DstPort":51000,"Proto":17}`},
+			// transport specific
+			{Timestamp: time.Now(), Line: `{"SrcPort":42000,"DstPort":443,"Proto":17}`},
+			// both known: privileged side wins
+			{Timestamp: time.Now(), Line: `{"SrcPort":8080,"DstPort":22,"Proto":6}`},
+			// both known and privileged: lowest port wins
+			{Timestamp: time.Now(), Line: `{"SrcPort":443,"DstPort":80,"Proto":6}`},
+			// unknown
+			{Timestamp: time.Now(), Line: `{"SrcPort":42000,"DstPort":42001,"Proto":6}`},
+		},
+	}})
+	cfg := testLokiConfig
+	cfg.InferAppProtocol = true
+	cfg.AppProtocols = constants.DefaultAppProtocols
+
+	qr, _, err := getFlows(context.Background(), &cfg, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 6)
+	assert.Equal(t, "HTTPS", records[0][fields.AppProtocol])
+	assert.Equal(t, true, records[0][fields.AppProtocolInferred])
+	assert.Equal(t, "DNS", records[1][fields.AppProtocol])
+	assert.Equal(t, "QUIC", records[2][fields.AppProtocol])
+	assert.Equal(t, "SSH", records[3][fields.AppProtocol])
+	assert.Equal(t, "HTTP", records[4][fields.AppProtocol])
+	assert.NotContains(t, records[5], fields.AppProtocol)
+	assert.NotContains(t, records[5], fields.AppProtocolInferred)
+
+	// disabled by default
+	qr, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	assert.NotContains(t, getRecords(t, qr)[0], fields.AppProtocol)
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+const (
+	fieldsKey = "fields"
+	// maxCardinalityFields limits the number of Loki queries of a single request
+	maxCardinalityFields = 20
+)
+
+// GetCardinality returns the approximate number of distinct values per field over the requested time range
+func GetCardinality(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("GetCardinality", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		writeWarningHeaders(w, migrateDeprecatedParams(params))
+		hlog.WithContext(r.Context()).Debugf("GetCardinality query params: %s", params)
+
+		cardinality, code, err := getCardinality(r.Context(), cfg, lokiClient, params)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+
+		code = http.StatusOK
+		writeJSON(w, code, cardinality)
+	}
+}
+
+// getCardinality counts distinct values: labels are counted exactly from the label sets of the Loki series API, while
+// other fields are counted in a sample of flows, which gives a lower bound unless the sample covers the whole time
+// range. When there are mandatory filters, see mandatoryFilters, each of their groups is queried: labels are then
+// sampled too if a group can't be applied by the series API.
+func getCardinality(ctx context.Context, cfg *loki.Config, client httpclient.Caller, params url.Values) ([]model.FieldCardinality, int, error) {
+	fieldNames, err := parseFieldNames(params.Get(fieldsKey))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if len(fieldNames) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("missing %s parameter", fieldsKey)
+	}
+	if len(fieldNames) > maxCardinalityFields {
+		return nil, http.StatusBadRequest, fmt.Errorf("too many fields: %d, max is %d", len(fieldNames), maxCardinalityFields)
+	}
+	start, err := getStartTime(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	end, err := getEndTime(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	mandatory, code, err := mandatoryFilters(ctx, cfg)
+	if err != nil {
+		return nil, code, err
+	}
+	groups := filters.MultiQueries{{}}
+	if mandatory != nil {
+		groups = mandatory
+	}
+	var labels, others []string
+	for _, f := range fieldNames {
+		if cfg.IsLabel(f) {
+			labels = append(labels, f)
+		} else {
+			others = append(others, f)
+		}
+	}
+	var labelValues map[string]map[string]struct{}
+	if len(labels) > 0 {
+		var ok bool
+		labelValues, ok, code, err = getSeriesLabelValues(ctx, cfg, client, groups, labels, start, end)
+		if err != nil {
+			return nil, code, err
+		}
+		if !ok {
+			others = fieldNames
+		}
+	}
+	var sample *cardinalitySample
+	if len(others) > 0 {
+		sample, code, err = sampleCardinality(ctx, cfg, client, groups, others, start, end)
+		if err != nil {
+			return nil, code, err
+		}
+	}
+
+	cardinality := make([]model.FieldCardinality, 0, len(fieldNames))
+	for _, f := range fieldNames {
+		fc := model.FieldCardinality{Field: f, IsLabel: cfg.IsLabel(f)}
+		if values, ok := sample.valuesOf(f); ok {
+			fc.Count = len(values)
+			fc.Sampled = sample.truncated
+			fc.SampleSize = sample.size
+		} else {
+			fc.Count = len(labelValues[f])
+		}
+		cardinality = append(cardinality, fc)
+	}
+	return cardinality, http.StatusOK, nil
+}
+
+// getSeriesLabelValues collects the distinct values of labels from the label sets of the streams of each group.
+// It returns false when a group has filters that the series API can't apply.
+func getSeriesLabelValues(ctx context.Context, cfg *loki.Config, client httpclient.Caller, groups filters.MultiQueries, labels []string, start, end string) (map[string]map[string]struct{}, bool, int, error) {
+	var queries []string
+	for _, group := range groups {
+		qb := loki.NewFlowQueryBuilder(cfg, start, end, "", constants.ReporterBoth, constants.RecordTypeLog)
+		if err := qb.Filters(group); err != nil {
+			return nil, false, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+		}
+		query, ok := qb.BuildSeries()
+		if !ok {
+			return nil, false, http.StatusOK, nil
+		}
+		queries = append(queries, query)
+	}
+	values := map[string]map[string]struct{}{}
+	for _, l := range labels {
+		values[l] = map[string]struct{}{}
+	}
+	for _, query := range queries {
+		resp, code, err := executeLokiQuery(ctx, query, client)
+		if err != nil {
+			return nil, false, code, fmt.Errorf("Loki series query failed: %w", err)
+		}
+		var sr model.SeriesResponse
+		if err := json.Unmarshal(resp, &sr); err != nil {
+			return nil, false, http.StatusInternalServerError, errors.New("Failed to unmarshal Loki series response: " + err.Error())
+		}
+		for _, series := range sr.Data {
+			for _, l := range labels {
+				if v := series[l]; v != "" {
+					values[l][v] = struct{}{}
+				}
+			}
+		}
+	}
+	return values, true, http.StatusOK, nil
+}
+
+// cardinalitySample holds the distinct values of fields in the most recent flows of each group
+type cardinalitySample struct {
+	values map[string]map[string]struct{}
+	// size is the number of flows read
+	size int
+	// truncated is set when a group had more flows than read
+	truncated bool
+}
+
+func (s *cardinalitySample) valuesOf(field string) (map[string]struct{}, bool) {
+	if s == nil {
+		return nil, false
+	}
+	values, ok := s.values[field]
+	return values, ok
+}
+
+// sampleCardinality reads a single sample of flows per group, in which the values of all fields are counted
+func sampleCardinality(ctx context.Context, cfg *loki.Config, client httpclient.Caller, groups filters.MultiQueries, fieldNames []string, start, end string) (*cardinalitySample, int, error) {
+	sample := cardinalitySample{values: map[string]map[string]struct{}{}}
+	for _, f := range fieldNames {
+		sample.values[f] = map[string]struct{}{}
+	}
+	for _, group := range groups {
+		qb := loki.NewFlowQueryBuilder(cfg, start, end, strconv.Itoa(valuesSampleSize), constants.ReporterBoth, constants.RecordTypeLog)
+		if err := qb.Filters(group); err != nil {
+			return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+		}
+		resp, code, err := executeLokiQuery(ctx, qb.Build(), client)
+		if err != nil {
+			return nil, code, fmt.Errorf("Loki query failed: %w", err)
+		}
+		var qr model.QueryResponse
+		if err := json.Unmarshal(resp, &qr); err != nil {
+			return nil, http.StatusInternalServerError, errors.New("Failed to unmarshal Loki response: " + err.Error())
+		}
+		streams, ok := qr.Data.Result.(model.Streams)
+		if !ok {
+			return nil, http.StatusInternalServerError, errors.New("Loki returned unexpected type: " + string(qr.Data.ResultType))
+		}
+		read := 0
+		for _, s := range streams {
+			read += len(s.Entries)
+		}
+		sample.size += read
+		sample.truncated = sample.truncated || read >= valuesSampleSize
+		for _, f := range fieldNames {
+			for _, v := range extractDistinctValues(f, streams) {
+				sample.values[f][v] = struct{}{}
+			}
+		}
+	}
+	return &sample, http.StatusOK, nil
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func TestGetCardinality(t *testing.T) {
+	series, err := json.Marshal(model.SeriesResponse{Status: "success", Data: []map[string]string{
+		{"app": "netobserv-flowcollector", "SrcK8S_Namespace": "a", "DstK8S_Namespace": "b"},
+		{"app": "netobserv-flowcollector", "SrcK8S_Namespace": "b", "DstK8S_Namespace": "b"},
+		{"app": "netobserv-flowcollector", "SrcK8S_Namespace": "c"},
+	}})
+	require.NoError(t, err)
+	flows, err := json.Marshal(model.QueryResponse{Status: "success", Data: model.QueryResponseData{
+		ResultType: model.ResultTypeStream,
+		Result: model.Streams{{
+			Labels:  map[string]string{"app": "netobserv-flowcollector"},
+			Entries: []model.Entry{{Line: `{"DstPort":53}`}, {Line: `{"SrcK8S_Name":"x","DstPort":80}`}, {Line: `{"SrcK8S_Name":"y"}`}, {Line: `{"SrcK8S_Name":"x"}`}},
+		}},
+	}})
+	require.NoError(t, err)
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool { return strings.Contains(u, "/series?") })).Return(series, 200, nil)
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool { return strings.Contains(u, "/query_range") })).Return(flows, 200, nil)
+
+	params := url.Values{}
+	params.Set(fieldsKey, "SrcK8S_Namespace, SrcK8S_Name, DstK8S_Namespace, DstPort")
+	params.Set(timeRangeKey, "300")
+	cardinality, _, err := getCardinality(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	// the sample didn't reach its size: it covers all the flows of the time range
+	assert.Equal(t, []model.FieldCardinality{
+		{Field: "SrcK8S_Namespace", Count: 3, IsLabel: true},
+		{Field: "SrcK8S_Name", Count: 2, SampleSize: 4},
+		{Field: "DstK8S_Namespace", Count: 1, IsLabel: true},
+		{Field: "DstPort", Count: 2, SampleSize: 4},
+	}, cardinality)
+	// a single query for all labels, another for all the other fields
+	require.Len(t, lokiClientMock.Calls, 2)
+	for _, call := range lokiClientMock.Calls {
+		assert.Contains(t, call.Arguments.String(0), "start=")
+	}
+
+	_, code, err := getCardinality(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.Error(t, err)
+	assert.Equal(t, 400, code)
+	params.Set(fieldsKey, "SrcK8S_Namespace}")
+	_, code, err = getCardinality(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.Error(t, err)
+	assert.Equal(t, 400, code)
+}
+
+func TestGetCardinality_Sampled(t *testing.T) {
+	entries := make([]model.Entry, 0, valuesSampleSize)
+	for i := 0; i < valuesSampleSize; i++ {
+		entries = append(entries, model.Entry{Line: `{"DstPort":` + strconv.Itoa(i%10) + `}`})
+	}
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{Labels: map[string]string{"app": "netobserv-flowcollector"}, Entries: entries}})
+
+	params := url.Values{}
+	params.Set(fieldsKey, "DstPort")
+	cardinality, _, err := getCardinality(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	// more flows than read: lower bound
+	assert.Equal(t, []model.FieldCardinality{{Field: "DstPort", Count: 10, Sampled: true, SampleSize: valuesSampleSize}}, cardinality)
+}
+
+func TestGetCardinality_EnforcedFilters(t *testing.T) {
+	series, err := json.Marshal(model.SeriesResponse{Status: "success", Data: []map[string]string{
+		{"app": "netobserv-flowcollector", "SrcK8S_Namespace": "team-a", "DstK8S_Namespace": "b"},
+	}})
+	require.NoError(t, err)
+	flows, err := json.Marshal(model.QueryResponse{Status: "success", Data: model.QueryResponseData{
+		ResultType: model.ResultTypeStream,
+		Result: model.Streams{{
+			Labels:  map[string]string{"app": "netobserv-flowcollector", "SrcK8S_Namespace": "team-a"},
+			Entries: []model.Entry{{Line: `{"SrcPort":8080}`}},
+		}},
+	}})
+	require.NoError(t, err)
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool { return strings.Contains(u, "/series?") })).Return(series, 200, nil)
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool { return strings.Contains(u, "/query_range") })).Return(flows, 200, nil)
+	cfg := testLokiConfig
+	cfg.EnforcedFilters = `SrcK8S_Namespace="team-a"|DstK8S_Namespace="team-a"`
+
+	params := url.Values{}
+	params.Set(fieldsKey, "SrcK8S_Namespace")
+	cardinality, _, err := getCardinality(context.Background(), &cfg, lokiClientMock, params)
+	require.NoError(t, err)
+	// the series API applies the enforced label filters
+	assert.Equal(t, []model.FieldCardinality{{Field: "SrcK8S_Namespace", Count: 1, IsLabel: true}}, cardinality)
+	require.Len(t, lokiClientMock.Calls, 2)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), `/series?match[]={app="netobserv-flowcollector",_RecordType="flowLog",SrcK8S_Namespace="team-a"}`)
+	assert.Contains(t, lokiClientMock.Calls[1].Arguments.String(0), `/series?match[]={app="netobserv-flowcollector",_RecordType="flowLog",DstK8S_Namespace="team-a"}`)
+
+	// labels are sampled when the enforced filters aren't only on labels
+	cfg.EnforcedFilters = `SrcPort=8080`
+	cardinality, _, err = getCardinality(context.Background(), &cfg, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Equal(t, []model.FieldCardinality{{Field: "SrcK8S_Namespace", Count: 1, IsLabel: true, SampleSize: 1}}, cardinality)
+	require.Len(t, lokiClientMock.Calls, 3)
+	assert.Contains(t, lokiClientMock.Calls[2].Arguments.String(0), "/query_range")
+	assert.Contains(t, lokiClientMock.Calls[2].Arguments.String(0), "SrcPort")
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// column is a flows table column defined in the frontend config, e.g. for a field added by custom enrichment
+type column struct {
+	// ID defaults to Field
+	ID string `yaml:"id" json:"id"`
+	// Name is the column header, defaulting to Field
+	Name  string `yaml:"name" json:"name"`
+	Field string `yaml:"field" json:"field"`
+	// Width is relative to the other columns, 0 letting the frontend choose
+	Width int `yaml:"width,omitempty" json:"width,omitempty"`
+	// Default columns are shown until users select their own
+	Default bool `yaml:"default" json:"default"`
+}
+
+// readColumns reads the columns section of the frontend config file, empty meaning the frontend built-in columns
+func readColumns(filename string) ([]column, error) {
+	config := struct {
+		Columns []column `yaml:"columns"`
+	}{}
+	if len(filename) == 0 {
+		return []column{}, nil
+	}
+	yamlFile, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(yamlFile, &config); err != nil {
+		return nil, err
+	}
+	columns := make([]column, 0, len(config.Columns))
+	ids := map[string]bool{}
+	for i, c := range config.Columns {
+		if c.Field == "" {
+			return nil, fmt.Errorf("column %d: missing field", i)
+		}
+		if !fieldNameValidation.MatchString(c.Field) {
+			return nil, fmt.Errorf("column %d: invalid field %q", i, c.Field)
+		}
+		if c.Width < 0 {
+			return nil, fmt.Errorf("column %d: negative width", i)
+		}
+		if c.ID == "" {
+			c.ID = c.Field
+		}
+		if c.Name == "" {
+			c.Name = c.Field
+		}
+		if ids[c.ID] {
+			return nil, fmt.Errorf("column %d: duplicated id %q", i, c.ID)
+		}
+		ids[c.ID] = true
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// GetColumns serves the flows table columns defined in the frontend config
+func GetColumns(filename string) func(w http.ResponseWriter, r *http.Request) {
+	columns, err := readColumns(filename)
+	if err != nil {
+		hlog.Errorf("Could not read columns config: %v", err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("invalid columns config: %w", err))
+			return
+		}
+		writeJSON(w, http.StatusOK, columns)
+	}
+}
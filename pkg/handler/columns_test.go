@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeColumnsConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestGetColumns(t *testing.T) {
+	path := writeColumnsConfig(t, `
+sampling: 50
+columns:
+  - field: SrcK8S_Name
+    name: Source
+    width: 15
+    default: true
+  - id: Zone
+    field: SrcK8S_Zone
+`)
+	w := httptest.NewRecorder()
+	GetColumns(path)(w, httptest.NewRequest(http.MethodGet, "/api/frontend-config/columns", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var columns []column
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &columns))
+	assert.Equal(t, []column{
+		{ID: "SrcK8S_Name", Name: "Source", Field: "SrcK8S_Name", Width: 15, Default: true},
+		{ID: "Zone", Name: "SrcK8S_Zone", Field: "SrcK8S_Zone"},
+	}, columns)
+
+	// none defined
+	w = httptest.NewRecorder()
+	GetColumns("")(w, httptest.NewRequest(http.MethodGet, "/api/frontend-config/columns", nil))
+	assert.Equal(t, "[]", w.Body.String())
+}
+
+func TestReadColumns_Invalid(t *testing.T) {
+	for _, content := range []string{
+		"columns:\n  - name: Missing field\n",
+		"columns:\n  - field: 'Src\"}'\n",
+		"columns:\n  - field: Bytes\n    width: -1\n",
+		"columns:\n  - field: Bytes\n  - field: Bytes\n",
+	} {
+		_, err := readColumns(writeColumnsConfig(t, content))
+		assert.Error(t, err, content)
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// deprecatedParams maps deprecated query parameters to their replacement. Deprecated parameters keep working,
+// but emit a warning telling clients to migrate.
+var deprecatedParams = map[string]string{
+	"filter":    filtersKey,
+	"timerange": timeRangeKey,
+}
+
+// migrateDeprecatedParams rewrites deprecated parameters into their replacement, unless already provided,
+// and returns the related warnings
+func migrateDeprecatedParams(params url.Values) []string {
+	var warnings []string
+	for deprecated, current := range deprecatedParams {
+		values, ok := params[deprecated]
+		if !ok {
+			continue
+		}
+		if _, exists := params[current]; exists {
+			warnings = append(warnings, fmt.Sprintf("parameter %q is deprecated and ignored since %q is provided", deprecated, current))
+		} else {
+			params[current] = values
+			warnings = append(warnings, fmt.Sprintf("parameter %q is deprecated, use %q instead", deprecated, current))
+		}
+		delete(params, deprecated)
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// EffectiveParams returns the query parameters as seen by the handlers, the deprecated ones being migrated
+func EffectiveParams(params url.Values) url.Values {
+	effective := make(url.Values, len(params))
+	for name, values := range params {
+		effective[name] = values
+	}
+	migrateDeprecatedParams(effective)
+	return effective
+}
+
+// writeWarningHeaders adds the warnings as Warning headers (RFC 7234, code 299: miscellaneous persistent warning)
+func writeWarningHeaders(w http.ResponseWriter, warnings []string) {
+	for _, warning := range warnings {
+		w.Header().Add("Warning", fmt.Sprintf("299 - %q", warning))
+	}
+}
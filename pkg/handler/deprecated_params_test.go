@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateDeprecatedParams(t *testing.T) {
+	params := url.Values{"filter": {"SrcK8S_Namespace=a"}, "timerange": {"300"}, timeRangeKey: {"600"}}
+	warnings := migrateDeprecatedParams(params)
+	assert.Equal(t, []string{
+		`parameter "filter" is deprecated, use "filters" instead`,
+		`parameter "timerange" is deprecated and ignored since "timeRange" is provided`,
+	}, warnings)
+	assert.Equal(t, url.Values{filtersKey: {"SrcK8S_Namespace=a"}, timeRangeKey: {"600"}}, params)
+
+	assert.Empty(t, migrateDeprecatedParams(url.Values{filtersKey: {"SrcK8S_Namespace=a"}}))
+}
+
+func TestGetFlows_DeprecatedParam(t *testing.T) {
+	var lokiQuery string
+	lokiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lokiQuery = r.URL.Query().Get("query")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+	}))
+	defer lokiServer.Close()
+	lokiURL, err := url.Parse(lokiServer.URL)
+	require.NoError(t, err)
+	cfg := testLokiConfig
+	cfg.URL = lokiURL
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/loki/flows?filter="+url.QueryEscape(`SrcK8S_Namespace="ns"`), nil)
+	GetFlows(&cfg)(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	// deprecated param still works
+	assert.True(t, strings.Contains(lokiQuery, `SrcK8S_Namespace="ns"`), lokiQuery)
+	// and emits a warning
+	assert.Equal(t, `299 - "parameter \"filter\" is deprecated, use \"filters\" instead"`, rec.Header().Get("Warning"))
+	var resp struct {
+		Warnings []string `json:"warnings"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []string{`parameter "filter" is deprecated, use "filters" instead`}, resp.Warnings)
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+)
+
+// ErrorCode is a stable and machine-readable class of error, independent from the HTTP status,
+// allowing clients to react (e.g. retry vs fix the query) without parsing messages
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidRequest      ErrorCode = "INVALID_REQUEST"
+	ErrorCodeInvalidFilter       ErrorCode = "INVALID_FILTER"
+	ErrorCodeQueryTooLarge       ErrorCode = "QUERY_TOO_LARGE"
+	ErrorCodeUnauthorized        ErrorCode = "UNAUTHORIZED"
+	ErrorCodeForbidden           ErrorCode = "FORBIDDEN"
+	ErrorCodeNotFound            ErrorCode = "NOT_FOUND"
+	ErrorCodeRateLimited         ErrorCode = "RATE_LIMITED"
+	ErrorCodeUpstreamTimeout     ErrorCode = "UPSTREAM_TIMEOUT"
+	ErrorCodeUpstreamUnavailable ErrorCode = "UPSTREAM_UNAVAILABLE"
+	ErrorCodeUpstreamRejected    ErrorCode = "UPSTREAM_REJECTED"
+	ErrorCodeUpstreamError       ErrorCode = "UPSTREAM_ERROR"
+	// ErrorCodeCancelled is set when the client went away before the response, e.g. after changing filters
+	ErrorCodeCancelled ErrorCode = "CANCELLED"
+	// ErrorCodeServerBusy is returned without querying Loki, when too many queries are already in progress
+	ErrorCodeServerBusy ErrorCode = "SERVER_BUSY"
+	// ErrorCodeDatasourceUnavailable is returned without querying Loki, after repeated failures
+	ErrorCodeDatasourceUnavailable ErrorCode = "DATASOURCE_UNAVAILABLE"
+	ErrorCodeInternal              ErrorCode = "INTERNAL"
+)
+
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// withErrorCode classifies an error; the innermost classification wins when wrapped several times
+func withErrorCode(code ErrorCode, err error) error {
+	var ce *codedError
+	if errors.As(err, &ce) {
+		return err
+	}
+	return &codedError{code: code, err: err}
+}
+
+// getErrorCode returns the error class, falling back on the HTTP status when it wasn't explicitly classified
+func getErrorCode(status int, err error) ErrorCode {
+	var ce *codedError
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case http.StatusServiceUnavailable:
+		return ErrorCodeUpstreamUnavailable
+	case http.StatusGatewayTimeout:
+		return ErrorCodeUpstreamTimeout
+	}
+	return ErrorCodeInternal
+}
+
+// statusClientClosedRequest is the non-standard status (from nginx) of requests cancelled by the client
+const statusClientClosedRequest = 499
+
+// lokiCallErrorStatus is the status of requests whose Loki call failed without response
+func lokiCallErrorStatus(err error) int {
+	if errors.Is(err, context.Canceled) {
+		return statusClientClosedRequest
+	}
+	return http.StatusServiceUnavailable
+}
+
+// classifyLokiCallError distinguishes timeouts and cancellations from other connection failures to Loki
+func classifyLokiCallError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return withErrorCode(ErrorCodeCancelled, err)
+	}
+	var circuitErr *httpclient.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		return withErrorCode(ErrorCodeDatasourceUnavailable, err)
+	}
+	var busyErr *httpclient.ServerBusyError
+	if errors.As(err, &busyErr) {
+		return withErrorCode(ErrorCodeServerBusy, err)
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return withErrorCode(ErrorCodeUpstreamTimeout, err)
+	}
+	return withErrorCode(ErrorCodeUpstreamUnavailable, err)
+}
+
+// lokiError is an error response from Loki
+type lokiError struct {
+	status int
+	// message is the Loki error text
+	message string
+}
+
+func (e *lokiError) Error() string { return fmt.Sprintf("[%d] %s", e.status, e.message) }
+
+// newLokiError classifies an error response from Loki, returning the status of the backend response
+func newLokiError(resp []byte, status int) (int, error) {
+	newCode, msg := getLokiError(resp, status)
+	return newCode, withErrorCode(getLokiErrorCode(status, msg), &lokiError{status: status, message: msg})
+}
+
+// lokiLimitMessages identify the errors of Loki queries exceeding the configured limits
+var lokiLimitMessages = []string{"limit", "maximum of series", "too many bytes", "exceeds"}
+
+// lokiErrorMessages are actionable messages for the errors returned by Loki, whose text goes to the details
+var lokiErrorMessages = map[ErrorCode]string{
+	ErrorCodeUpstreamRejected:    "Loki rejected the query, please check the filters",
+	ErrorCodeQueryTooLarge:       "The query exceeds Loki limits, please narrow down the time range or add filters",
+	ErrorCodeUnauthorized:        "Loki rejected the credentials",
+	ErrorCodeForbidden:           "Loki access is forbidden",
+	ErrorCodeRateLimited:         "Loki is overloaded, please retry later",
+	ErrorCodeUpstreamTimeout:     "Loki query timed out, please narrow down the time range or add filters",
+	ErrorCodeUpstreamUnavailable: "Loki is unavailable, please retry later",
+	ErrorCodeUpstreamError:       "Loki failed to run the query",
+}
+
+// getLokiErrorCode classifies an error status returned by Loki
+func getLokiErrorCode(status int, message string) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		lower := strings.ToLower(message)
+		for _, m := range lokiLimitMessages {
+			if strings.Contains(lower, m) {
+				return ErrorCodeQueryTooLarge
+			}
+		}
+		return ErrorCodeUpstreamRejected
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case http.StatusGatewayTimeout:
+		return ErrorCodeUpstreamTimeout
+	case http.StatusServiceUnavailable:
+		return ErrorCodeUpstreamUnavailable
+	}
+	return ErrorCodeUpstreamError
+}
+
+// getRetryAfter returns the delay after which a failed request can be retried, when known
+func getRetryAfter(err error) (time.Duration, bool) {
+	var hint interface{ RetryAfter() time.Duration }
+	if errors.As(err, &hint) {
+		return hint.RetryAfter(), true
+	}
+	return 0, false
+}
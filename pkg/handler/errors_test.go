@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorCodes(t *testing.T) {
+	failingClient := func(resp []byte, code int, err error) *httpclienttest.HTTPClientMock {
+		m := new(httpclienttest.HTTPClientMock)
+		m.On("Get", mock.Anything).Return(resp, code, err)
+		return m
+	}
+	okClient := mockStreamsResponse(t, model.Streams{})
+
+	for _, tc := range []struct {
+		name     string
+		client   *httpclienttest.HTTPClientMock
+		params   url.Values
+		code     int
+		expected ErrorCode
+		// lokiStatus is set for errors returned by Loki
+		lokiStatus int
+	}{{
+		name:     "bad filter",
+		client:   okClient,
+		params:   url.Values{filtersKey: {"SrcK8S_Namespace=a`b"}},
+		code:     http.StatusBadRequest,
+		expected: ErrorCodeInvalidFilter,
+	}, {
+		name:     "bad ports",
+		client:   okClient,
+		params:   url.Values{portsKey: {"web"}},
+		code:     http.StatusBadRequest,
+		expected: ErrorCodeInvalidFilter,
+	}, {
+		name:     "bad limit",
+		client:   okClient,
+		params:   url.Values{limitKey: {"many"}},
+		code:     http.StatusBadRequest,
+		expected: ErrorCodeInvalidRequest,
+	}, {
+		name:     "timeout",
+		client:   failingClient(nil, 0, &url.Error{Op: "Get", URL: "http://loki", Err: timeoutError{}}),
+		code:     http.StatusServiceUnavailable,
+		expected: ErrorCodeUpstreamTimeout,
+	}, {
+		name:     "loki down",
+		client:   failingClient(nil, 0, errors.New("connection refused")),
+		code:     http.StatusServiceUnavailable,
+		expected: ErrorCodeUpstreamUnavailable,
+	}, {
+		name:     "loki overloaded",
+		client:   failingClient([]byte("too many outstanding requests"), http.StatusTooManyRequests, nil),
+		code:     http.StatusServiceUnavailable,
+		expected: ErrorCodeRateLimited,
+	}, {
+		name:     "circuit open",
+		client:   failingClient(nil, 0, &httpclient.CircuitOpenError{Retry: 30 * time.Second}),
+		code:     http.StatusServiceUnavailable,
+		expected: ErrorCodeDatasourceUnavailable,
+	}, {
+		name:     "server busy",
+		client:   failingClient(nil, 0, &httpclient.ServerBusyError{}),
+		code:     http.StatusServiceUnavailable,
+		expected: ErrorCodeServerBusy,
+	}, {
+		name:       "loki rejected query",
+		client:     failingClient([]byte("parse error"), http.StatusBadRequest, nil),
+		code:       http.StatusBadRequest,
+		expected:   ErrorCodeUpstreamRejected,
+		lokiStatus: 400,
+	}, {
+		name:       "loki limits exceeded",
+		client:     failingClient([]byte("max entries limit per query exceeded, limit > max_entries_limit (5000 > 1000)"), http.StatusBadRequest, nil),
+		code:       http.StatusBadRequest,
+		expected:   ErrorCodeQueryTooLarge,
+		lokiStatus: 400,
+	}, {
+		name:       "loki unauthorized",
+		client:     failingClient([]byte("invalid token"), http.StatusUnauthorized, nil),
+		code:       http.StatusUnauthorized,
+		expected:   ErrorCodeUnauthorized,
+		lokiStatus: 401,
+	}, {
+		name:       "loki forbidden",
+		client:     failingClient([]byte("no"), http.StatusForbidden, nil),
+		code:       http.StatusForbidden,
+		expected:   ErrorCodeForbidden,
+		lokiStatus: 403,
+	}, {
+		name:       "loki internal error",
+		client:     failingClient([]byte(`{"message":"boom"}`), http.StatusInternalServerError, nil),
+		code:       http.StatusBadRequest,
+		expected:   ErrorCodeUpstreamError,
+		lokiStatus: 500,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, code, err := getFlows(context.Background(), &testLokiConfig, tc.client, tc.params)
+			require.Error(t, err)
+			assert.Equal(t, tc.code, code)
+
+			// the code is independent from the HTTP status
+			rec := httptest.NewRecorder()
+			writeError(rec, code, err)
+			assert.Equal(t, tc.code, rec.Code)
+			var resp errorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tc.expected, resp.Code)
+			assert.Equal(t, tc.lokiStatus, resp.LokiStatus)
+			if tc.lokiStatus != 0 {
+				// the Loki text is kept as details of an actionable message
+				assert.Equal(t, lokiErrorMessages[tc.expected], resp.Message)
+				assert.Equal(t, err.Error(), resp.Details)
+			} else {
+				assert.Equal(t, err.Error(), resp.Message)
+				assert.Empty(t, resp.Details)
+			}
+		})
+	}
+}
+
+func TestGetErrorCode_Fallback(t *testing.T) {
+	assert.Equal(t, ErrorCodeInternal, getErrorCode(http.StatusInternalServerError, errors.New("oops")))
+	assert.Equal(t, ErrorCodeNotFound, getErrorCode(http.StatusNotFound, errors.New("oops")))
+	// innermost classification is kept
+	err := withErrorCode(ErrorCodeInternal, withErrorCode(ErrorCodeInvalidFilter, errors.New("oops")))
+	assert.Equal(t, ErrorCodeInvalidFilter, getErrorCode(http.StatusInternalServerError, err))
+}
+
+func TestWriteError_RetryAfter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, http.StatusServiceUnavailable, classifyLokiCallError(&httpclient.CircuitOpenError{Retry: 12500 * time.Millisecond}))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "13", rec.Header().Get("Retry-After"))
+	var resp errorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, ErrorCodeDatasourceUnavailable, resp.Code)
+	assert.Equal(t, 13, resp.RetryAfter)
+}
+
+func TestGetFlows_ClientClosed(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.Anything).Return([]byte(nil), 0, context.Canceled)
+	_, code, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.Error(t, err)
+	assert.Equal(t, statusClientClosedRequest, code)
+	assert.Equal(t, ErrorCodeCancelled, getErrorCode(code, err))
+
+	// nothing is written to the gone client
+	rec := httptest.NewRecorder()
+	writeError(rec, code, err)
+	assert.Empty(t, rec.Body.Bytes())
+}
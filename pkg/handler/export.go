@@ -11,14 +11,17 @@ import (
 )
 
 const (
-	exportCSVFormat  = "csv"
-	exportFormatKey  = "format"
-	exportcolumnsKey = "columns"
+	exportCSVFormat     = "csv"
+	exportGeoJSONFormat = "geojson"
+	// exportPrometheusFormat applies to topology (metrics) queries
+	exportPrometheusFormat = "prometheus"
+	exportFormatKey        = "format"
+	exportcolumnsKey       = "columns"
 )
 
 func ExportFlows(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, false)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
 		var code int
 		startTime := time.Now()
 		defer func() {
@@ -26,13 +29,32 @@ func ExportFlows(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request)
 		}()
 
 		params := r.URL.Query()
-		hlog.Debugf("ExportFlows query params: %s", params)
+		warnings := migrateDeprecatedParams(params)
+		writeWarningHeaders(w, warnings)
+		hlog.WithContext(r.Context()).Debugf("ExportFlows query params: %s", params)
+		if err := checkExportAllowed(r.Context(), cfg); err != nil {
+			code = http.StatusForbidden
+			writeError(w, code, err)
+			return
+		}
+
+		code, err := checkTimeWindow(cfg, params, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		reqCfg, code, err := withLabelsOverride(cfg, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
 
-		flows, code, err := getFlows(cfg, lokiClient, params)
+		flows, code, err := getFlows(r.Context(), reqCfg, lokiClient, params)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
+		flows.Warnings = append(warnings, flows.Warnings...)
 
 		exportFormat := params.Get(exportFormatKey)
 		var exportColumns []string
@@ -44,9 +66,12 @@ func ExportFlows(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request)
 		case exportCSVFormat:
 			code = http.StatusOK
 			writeCSV(w, code, flows, exportColumns)
+		case exportGeoJSONFormat:
+			code = http.StatusOK
+			writeGeoJSON(w, code, flows)
 		default:
 			code = http.StatusBadRequest
-			writeError(w, code, fmt.Sprintf("export format %q is not valid", exportFormat))
+			writeError(w, code, fmt.Errorf("export format %q is not valid", exportFormat))
 		}
 	}
 }
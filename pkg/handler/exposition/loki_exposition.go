@@ -0,0 +1,96 @@
+package exposition
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	pmodel "github.com/prometheus/common/model"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+const metricPrefix = "netobserv_"
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// GetMetricName returns the exposed metric name for a topology metric type
+func GetMetricName(metricType string) string {
+	switch metricType {
+	case "count":
+		return metricPrefix + "flows_count"
+	case "packets":
+		return metricPrefix + "packets_rate"
+	case "retransmits":
+		return metricPrefix + "tcp_retransmits_rate"
+	default:
+		return metricPrefix + "bytes_rate"
+	}
+}
+
+// GetExposition transforms a matrix result into the Prometheus text exposition format, one line per sample,
+// with timestamps in milliseconds. This allows feeding flow-derived metrics into existing metrics pipelines.
+func GetExposition(qr *model.AggregatedQueryResponse, metricName string) (string, error) {
+	matrix, ok := qr.Result.(model.Matrix)
+	if !ok {
+		return "", fmt.Errorf("loki returned an unexpected type: %T", qr.Result)
+	}
+	name := sanitizeName(metricName)
+	var series []string
+	for _, ss := range matrix {
+		labels := formatLabels(ss.Metric)
+		for _, sample := range ss.Values {
+			series = append(series, fmt.Sprintf("%s%s %s %d\n", name, labels, formatValue(float64(sample.Value)), int64(sample.Timestamp)))
+		}
+	}
+	sb := strings.Builder{}
+	sb.WriteString("# TYPE " + name + " gauge\n")
+	for _, s := range series {
+		sb.WriteString(s)
+	}
+	return sb.String(), nil
+}
+
+func formatLabels(metric pmodel.Metric) string {
+	if len(metric) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(metric))
+	for name := range metric {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		value := string(metric[pmodel.LabelName(name)])
+		parts = append(parts, sanitizeName(name)+`="`+labelValueEscaper.Replace(value)+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// sanitizeName replaces characters that are not allowed in metric and label names
+func sanitizeName(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
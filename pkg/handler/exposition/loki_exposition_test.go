@@ -0,0 +1,37 @@
+package exposition
+
+import (
+	"testing"
+
+	pmodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func TestGetExposition(t *testing.T) {
+	qr := model.AggregatedQueryResponse{
+		ResultType: model.ResultTypeMatrix,
+		Result: model.Matrix{{
+			Metric: pmodel.Metric{"SrcK8S_Namespace": "ns", "DstK8S_Name": `we"ird\name`, "K8S.Dot": "x"},
+			Values: []pmodel.SamplePair{{Timestamp: 1680000000000, Value: 12.5}, {Timestamp: 1680000030000, Value: 0}},
+		}, {
+			Values: []pmodel.SamplePair{{Timestamp: 1680000000000, Value: 3}},
+		}},
+	}
+
+	text, err := GetExposition(&qr, GetMetricName("bytes"))
+	require.NoError(t, err)
+	assert.Equal(t, `# TYPE netobserv_bytes_rate gauge
+netobserv_bytes_rate{DstK8S_Name="we\"ird\\name",K8S_Dot="x",SrcK8S_Namespace="ns"} 12.5 1680000000000
+netobserv_bytes_rate{DstK8S_Name="we\"ird\\name",K8S_Dot="x",SrcK8S_Namespace="ns"} 0 1680000030000
+netobserv_bytes_rate 3 1680000000000
+`, text)
+}
+
+func TestGetExposition_UnexpectedType(t *testing.T) {
+	_, err := GetExposition(&model.AggregatedQueryResponse{Result: model.Streams{}}, GetMetricName("count"))
+	require.Error(t, err)
+	assert.Equal(t, "netobserv_flows_count", GetMetricName("count"))
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+const (
+	autoFieldsKey = "autoFields"
+	probeLookback = time.Hour
+	probeCacheTTL = 10 * time.Minute
+	// probeFailureTTL avoids probing again for each request while Loki fails
+	probeFailureTTL = 30 * time.Second
+)
+
+// featureFields are groups of fields only populated when the related agent feature is enabled
+var featureFields = []struct {
+	feature string
+	fields  []string
+}{
+	{feature: "dnsTracking", fields: []string{fields.DNSID, fields.DNSFlags, fields.DNSFlagsRCode, fields.DNSLatency, fields.DNSErrNo}},
+	{feature: "packetDrop", fields: []string{fields.PktDropBytes, fields.PktDropPackets, fields.PktDropLatestState, fields.PktDropLatestCause, fields.PktDropLatestFlags}},
+	{feature: "flowRTT", fields: []string{fields.TimeFlowRtt}},
+}
+
+// fieldProbe detects, and caches, which feature fields are populated in recent flows. Results are kept per scope of
+// the Loki client, see httpclient.WithScope, as tenants or credentials may not see the same flows.
+type fieldProbe struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	results map[string]*probeResult
+}
+
+// probeResult is the result of a probe, available once done is closed
+type probeResult struct {
+	done      chan struct{}
+	populated map[string]bool
+	code      int
+	err       error
+	checkedAt time.Time
+}
+
+var featuresProbe = newFieldProbe(time.Now)
+
+func newFieldProbe(now func() time.Time) *fieldProbe {
+	return &fieldProbe{now: now, results: map[string]*probeResult{}}
+}
+
+// expired tells whether a completed probe must be run again; failures are retried sooner
+func (p *fieldProbe) expired(r *probeResult) bool {
+	ttl := probeCacheTTL
+	if r.err != nil {
+		ttl = probeFailureTTL
+	}
+	return p.now().Sub(r.checkedAt) >= ttl
+}
+
+// populatedFeatures returns the cached result of the client scope, or probes Loki. Concurrent requests of the same
+// scope wait for the running probe, which is made without holding the lock.
+func (p *fieldProbe) populatedFeatures(ctx context.Context, cfg *loki.Config, client httpclient.Caller) (map[string]bool, int, error) {
+	scope := httpclient.ScopeOf(client)
+	p.mu.Lock()
+	r, ok := p.results[scope]
+	if ok {
+		select {
+		case <-r.done:
+			if p.expired(r) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		p.removeExpired()
+		r = &probeResult{done: make(chan struct{})}
+		p.results[scope] = r
+		p.mu.Unlock()
+		r.populated, r.code, r.err = probeFeatures(ctx, cfg, client, p.now())
+		p.mu.Lock()
+		r.checkedAt = p.now()
+		if r.err != nil && ctx.Err() != nil {
+			// cancelled by the caller: not a Loki failure, the next request probes again
+			delete(p.results, scope)
+		}
+		close(r.done)
+	}
+	p.mu.Unlock()
+	select {
+	case <-r.done:
+		return r.populated, r.code, r.err
+	case <-ctx.Done():
+		return nil, http.StatusServiceUnavailable, ctx.Err()
+	}
+}
+
+// removeExpired must be called with the mutex held
+func (p *fieldProbe) removeExpired() {
+	for scope, r := range p.results {
+		select {
+		case <-r.done:
+			if p.expired(r) {
+				delete(p.results, scope)
+			}
+		default:
+		}
+	}
+}
+
+func probeFeatures(ctx context.Context, cfg *loki.Config, client httpclient.Caller, now time.Time) (map[string]bool, int, error) {
+	start := strconv.FormatInt(now.Add(-probeLookback).Unix(), 10)
+	populated := map[string]bool{}
+	for _, ff := range featureFields {
+		qb := loki.NewFlowQueryBuilder(cfg, start, "", "1", constants.ReporterBoth, constants.RecordTypeLog)
+		qb.HasField(ff.fields[0])
+		resp, code, err := executeLokiQuery(ctx, qb.Build(), client)
+		if err != nil {
+			return nil, code, err
+		}
+		var qr model.QueryResponse
+		if err := json.Unmarshal(resp, &qr); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		streams, _ := qr.Data.Result.(model.Streams)
+		for _, s := range streams {
+			if len(s.Entries) > 0 {
+				populated[ff.feature] = true
+				break
+			}
+		}
+	}
+	return populated, http.StatusOK, nil
+}
+
+// isAutoFields reads the autoFields parameter, falling back to the configured default
+func isAutoFields(cfg *loki.Config, params url.Values) (bool, error) {
+	if v, ok := params[autoFieldsKey]; ok && len(v) > 0 {
+		return strconv.ParseBool(v[0])
+	}
+	return cfg.AutoFieldSelection, nil
+}
+
+// getExcludedFields returns the fields of features that are not populated in recent flows
+func getExcludedFields(ctx context.Context, cfg *loki.Config, client httpclient.Caller) []string {
+	populated, _, err := featuresProbe.populatedFeatures(ctx, cfg, client)
+	if err != nil {
+		// don't exclude anything if we can't know
+		if ctx.Err() == nil {
+			hlog.WithError(err).Warn("cannot probe feature fields")
+		}
+		return nil
+	}
+	var excluded []string
+	for _, ff := range featureFields {
+		if !populated[ff.feature] {
+			excluded = append(excluded, ff.fields...)
+		}
+	}
+	return excluded
+}
+
+// excludeFields returns a processor that removes fields from the records
+func excludeFields(keys []string) flowProcessor {
+	return func(_ *model.Entry, record *flowRecord) {
+		for _, k := range keys {
+			delete(record.fields, k)
+		}
+	}
+}
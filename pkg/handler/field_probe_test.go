@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+func TestGetFlows_AutoFields(t *testing.T) {
+	now := time.Now()
+	featuresProbe = newFieldProbe(func() time.Time { return now })
+	defer func() { featuresProbe = newFieldProbe(time.Now) }()
+
+	marshal := func(lines ...string) []byte {
+		stream := model.Stream{Labels: map[string]string{"app": "netobserv-flowcollector"}}
+		for _, l := range lines {
+			stream.Entries = append(stream.Entries, model.Entry{Timestamp: now, Line: l})
+		}
+		resp, err := json.Marshal(model.QueryResponse{Data: model.QueryResponseData{ResultType: model.ResultTypeStream, Result: model.Streams{stream}}})
+		require.NoError(t, err)
+		return resp
+	}
+	isProbe := func(field string) interface{} {
+		return mock.MatchedBy(func(u string) bool { return strings.Contains(u, "|~`\""+field+"\":`") })
+	}
+	line := `{"SrcAddr":"10.0.0.1","DnsId":0,"DnsLatencyMs":0,"PktDropBytes":0,"TimeFlowRttNs":1000}`
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	// only RTT is populated in recent flows
+	lokiClientMock.On("Get", isProbe(fields.DNSID)).Return(marshal(), 200, nil)
+	lokiClientMock.On("Get", isProbe(fields.PktDropBytes)).Return(marshal(), 200, nil)
+	lokiClientMock.On("Get", isProbe(fields.TimeFlowRtt)).Return(marshal(line), 200, nil)
+	lokiClientMock.On("Get", mock.Anything).Return(marshal(line), 200, nil)
+
+	params := url.Values{}
+	params.Set(autoFieldsKey, "true")
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 1)
+	assert.NotContains(t, records[0], fields.DNSID)
+	assert.NotContains(t, records[0], fields.DNSLatency)
+	assert.NotContains(t, records[0], fields.PktDropBytes)
+	assert.Equal(t, 1000.0, records[0][fields.TimeFlowRtt])
+	assert.Equal(t, "10.0.0.1", records[0][fields.SrcAddr])
+	// 3 probes + 1 query
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 4)
+
+	// probe result is cached
+	_, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 5)
+
+	// and refreshed after expiry
+	now = now.Add(probeCacheTTL)
+	_, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 9)
+
+	// overridden per request
+	params.Set(autoFieldsKey, "false")
+	qr, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Contains(t, getRecords(t, qr)[0], fields.DNSID)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 10)
+
+	params.Set(autoFieldsKey, "maybe")
+	_, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.Error(t, err)
+}
+
+// probeCaller answers the probe queries, once released when not nil
+type probeCaller struct {
+	release chan struct{}
+	calls   chan string
+	body    []byte
+	code    int
+}
+
+func (c *probeCaller) Get(_ context.Context, url string) ([]byte, int, error) {
+	c.calls <- url
+	if c.release != nil {
+		<-c.release
+	}
+	if c.code != 200 {
+		return nil, c.code, errors.New("loki failure")
+	}
+	return c.body, c.code, nil
+}
+
+func TestFieldProbe_Scopes(t *testing.T) {
+	now := time.Now()
+	probe := newFieldProbe(func() time.Time { return now })
+	populated, err := json.Marshal(model.QueryResponse{Data: model.QueryResponseData{ResultType: model.ResultTypeStream, Result: model.Streams{{
+		Entries: []model.Entry{{Timestamp: now, Line: "{}"}},
+	}}}})
+	require.NoError(t, err)
+	empty, err := json.Marshal(model.QueryResponse{Data: model.QueryResponseData{ResultType: model.ResultTypeStream, Result: model.Streams{}}})
+	require.NoError(t, err)
+
+	// the probe of tenant A is pending, without blocking tenant B
+	tenantA := &probeCaller{release: make(chan struct{}), calls: make(chan string, 10), body: populated, code: 200}
+	clientA := httpclient.WithScope(tenantA, map[string][]string{"X-Scope-OrgID": {"a"}})
+	resultsA := make(chan map[string]bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			features, _, _ := probe.populatedFeatures(context.Background(), &testLokiConfig, clientA)
+			resultsA <- features
+		}()
+	}
+	<-tenantA.calls
+
+	tenantB := &probeCaller{calls: make(chan string, 10), body: empty, code: 200}
+	clientB := httpclient.WithScope(tenantB, map[string][]string{"X-Scope-OrgID": {"b"}})
+	features, _, err := probe.populatedFeatures(context.Background(), &testLokiConfig, clientB)
+	require.NoError(t, err)
+	assert.Empty(t, features)
+	assert.Len(t, tenantB.calls, len(featureFields))
+
+	// concurrent requests of tenant A share the same probe, with its own result
+	close(tenantA.release)
+	assert.Len(t, <-resultsA, len(featureFields))
+	assert.Len(t, <-resultsA, len(featureFields))
+	assert.Len(t, tenantA.calls, len(featureFields)-1)
+
+	// failures are cached briefly
+	failing := &probeCaller{calls: make(chan string, 10), code: 500}
+	clientC := httpclient.WithScope(failing, map[string][]string{"X-Scope-OrgID": {"c"}})
+	_, _, err = probe.populatedFeatures(context.Background(), &testLokiConfig, clientC)
+	require.Error(t, err)
+	_, _, err = probe.populatedFeatures(context.Background(), &testLokiConfig, clientC)
+	require.Error(t, err)
+	assert.Len(t, failing.calls, 1)
+	now = now.Add(probeFailureTTL)
+	_, _, _ = probe.populatedFeatures(context.Background(), &testLokiConfig, clientC)
+	assert.Len(t, failing.calls, 2)
+}
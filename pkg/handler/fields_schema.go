@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+// fieldSchema is the description of a flow field served to the frontend and external tooling
+type fieldSchema struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// IsLabel is set for indexed Loki labels, for which filters are the most efficient
+	IsLabel   bool     `json:"isLabel"`
+	Computed  bool     `json:"computed"`
+	Operators []string `json:"operators"`
+}
+
+var (
+	stringOperators  = []string{"=", "!=", "=~", "!~", "=i~", "=*"}
+	numberOperators  = []string{"=", "!=", ">", ">=", "<", "<=", "=*"}
+	addressOperators = []string{"=", "!=", "=*"}
+)
+
+// GetFieldsSchema describes all the flow fields, with the filter operators they support
+func GetFieldsSchema(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	schema := getFieldsSchema(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, schema)
+	}
+}
+
+func getFieldsSchema(cfg *loki.Config) []fieldSchema {
+	schema := make([]fieldSchema, 0, len(fields.Schema))
+	for _, f := range fields.Schema {
+		schema = append(schema, fieldSchema{
+			Name:        f.Name,
+			Type:        f.Type,
+			Description: f.Description,
+			IsLabel:     cfg.IsLabel(f.Name),
+			Computed:    f.Computed,
+			Operators:   getFilterOperators(f),
+		})
+	}
+	return schema
+}
+
+// getFilterOperators mirrors the filters supported by the query builder: comparisons only apply to numeric
+// fields and regular expressions aren't allowed on IPs
+func getFilterOperators(f fields.FieldInfo) []string {
+	switch {
+	case f.Computed:
+		return []string{}
+	case fields.IsIP(f.Name):
+		return addressOperators
+	case fields.IsNumeric(f.Name):
+		return numberOperators
+	}
+	// other fields, including numbers without comparison support, are filtered as text
+	return stringOperators
+}
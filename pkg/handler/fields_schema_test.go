@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+func TestGetFieldsSchema(t *testing.T) {
+	schema := getFieldsSchema(&testLokiConfig)
+	byName := map[string]fieldSchema{}
+	for _, f := range schema {
+		_, duplicate := byName[f.Name]
+		require.False(t, duplicate, f.Name)
+		byName[f.Name] = f
+	}
+
+	ns := byName[fields.SrcNamespace]
+	assert.True(t, ns.IsLabel)
+	assert.Equal(t, fields.TypeString, ns.Type)
+	assert.Contains(t, ns.Operators, "=~")
+
+	port := byName[fields.DstPort]
+	assert.False(t, port.IsLabel)
+	assert.Contains(t, port.Operators, ">=")
+
+	addr := byName[fields.SrcAddr]
+	assert.Equal(t, fields.TypeIP, addr.Type)
+	assert.NotContains(t, addr.Operators, "=~")
+
+	id := byName[fields.FlowID]
+	assert.True(t, id.Computed)
+	assert.Empty(t, id.Operators)
+}
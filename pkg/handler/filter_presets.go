@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+// presetKey references filter presets in the filters parameter, e.g. preset=Ingress traffic&SrcK8S_Namespace=app
+const presetKey = "preset"
+
+// FilterPreset is a named filter defined by administrators, in the filters parameter syntax
+type FilterPreset struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Filters     string `yaml:"filters" json:"filters"`
+}
+
+// ReadFilterPresets reads the filterPresets section of the frontend config file, keyed by name
+func ReadFilterPresets(filename string) (map[string]string, error) {
+	presets := map[string]string{}
+	if len(filename) == 0 {
+		return presets, nil
+	}
+	yamlFile, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := struct {
+		FilterPresets []FilterPreset `yaml:"filterPresets"`
+	}{}
+	if err := yaml.Unmarshal(yamlFile, &config); err != nil {
+		return nil, err
+	}
+	for _, p := range config.FilterPresets {
+		if p.Name == "" || strings.Contains(p.Name, ",") {
+			return nil, fmt.Errorf("invalid filter preset name: %q", p.Name)
+		}
+		if _, ok := presets[p.Name]; ok {
+			return nil, fmt.Errorf("duplicated filter preset %q", p.Name)
+		}
+		groups, err := filters.Parse(p.Filters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter preset %q: %w", p.Name, err)
+		}
+		if findPresetMatch(groups) {
+			return nil, fmt.Errorf("invalid filter preset %q: presets can't reference other presets", p.Name)
+		}
+		presets[p.Name] = p.Filters
+	}
+	return presets, nil
+}
+
+func findPresetMatch(groups filters.MultiQueries) bool {
+	for _, group := range groups {
+		for _, m := range group {
+			if m.Key == presetKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandPresets replaces the preset references of each group by the preset filters, ANDed with the other matches
+// of the group. Referencing several presets at once, e.g. preset=Ingress traffic,Egress traffic, matches any of them.
+func expandPresets(cfg *loki.Config, groups filters.MultiQueries) (filters.MultiQueries, error) {
+	if !findPresetMatch(groups) {
+		return groups, nil
+	}
+	var expanded filters.MultiQueries
+	for _, group := range groups {
+		result := filters.MultiQueries{{}}
+		for _, m := range group {
+			if m.Key != presetKey {
+				for i := range result {
+					result[i] = append(result[i], m)
+				}
+				continue
+			}
+			if m.Not || m.Regex || m.Exists || m.Comparison != "" {
+				return nil, fmt.Errorf("presets only support the = operator")
+			}
+			var union filters.MultiQueries
+			for _, name := range strings.Split(m.Values, ",") {
+				name = strings.Trim(strings.TrimSpace(name), `"`)
+				raw, ok := cfg.FilterPresets[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown filter preset: %q", name)
+				}
+				preset, err := filters.Parse(raw)
+				if err != nil {
+					return nil, fmt.Errorf("invalid filter preset %q: %w", name, err)
+				}
+				union = append(union, preset...)
+			}
+			result = andGroups(result, union)
+		}
+		expanded = append(expanded, result...)
+	}
+	return expanded, nil
+}
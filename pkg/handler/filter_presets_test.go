@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func TestReadFilterPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+filterPresets:
+  - name: Ingress traffic
+    filters: 'FlowDirection=0'
+  - name: Pods
+    filters: 'SrcK8S_Type=Pod|DstK8S_Type=Pod'
+`), 0600))
+	presets, err := ReadFilterPresets(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Ingress traffic": "FlowDirection=0", "Pods": "SrcK8S_Type=Pod|DstK8S_Type=Pod"}, presets)
+
+	for _, content := range []string{
+		"filterPresets:\n  - filters: 'FlowDirection=0'\n",
+		"filterPresets:\n  - name: a\n    filters: 'preset=b'\n",
+		"filterPresets:\n  - name: a\n    filters: 'Bytes>1'\n  - name: a\n    filters: 'Bytes>2'\n",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		_, err := ReadFilterPresets(path)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestParseFilters_Presets(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.FilterPresets = map[string]string{
+		"Ingress traffic": "FlowDirection=0",
+		"Pods":            "SrcK8S_Type=Pod|DstK8S_Type=Pod",
+	}
+
+	groups, _, err := parseFilters(&cfg, "preset=Ingress traffic&SrcK8S_Namespace=app|Bytes>100")
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("FlowDirection", "0"), filters.NewMatch("SrcK8S_Namespace", "app")},
+		{filters.NewComparisonMatch("Bytes", ">", "100")},
+	}, groups)
+
+	// multi-groups preset, distributed over the other matches
+	groups, _, err = parseFilters(&cfg, "preset=Pods&FlowDirection=1")
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("SrcK8S_Type", "Pod"), filters.NewMatch("FlowDirection", "1")},
+		{filters.NewMatch("DstK8S_Type", "Pod"), filters.NewMatch("FlowDirection", "1")},
+	}, groups)
+
+	// union of presets
+	groups, _, err = parseFilters(&cfg, `preset="Ingress traffic",Pods`)
+	require.NoError(t, err)
+	assert.Len(t, groups, 3)
+
+	_, _, err = parseFilters(&cfg, "preset=Unknown")
+	assert.Error(t, err)
+	_, _, err = parseFilters(&cfg, "preset!=Pods")
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+// lowercaseFields are always lowercase in Kubernetes (RFC 1123 names), so that a different casing can only
+// result in an empty result. Fields where case matters (e.g. DNS names, kinds) must not be listed here.
+var lowercaseFields = map[string]struct{}{
+	fields.SrcNamespace: {},
+	fields.DstNamespace: {},
+	fields.Namespace:    {},
+	fields.SrcName:      {},
+	fields.DstName:      {},
+	fields.Name:         {},
+	fields.SrcOwnerName: {},
+	fields.DstOwnerName: {},
+	fields.OwnerName:    {},
+	fields.SrcHostName:  {},
+	fields.DstHostName:  {},
+	fields.HostName:     {},
+}
+
+// symmetricKeys are the fields that can be filtered without Src/Dst prefix, to match either side
+var symmetricKeys = map[string]struct{}{
+	fields.Namespace: {},
+	fields.Name:      {},
+	fields.Type:      {},
+	fields.OwnerName: {},
+	fields.OwnerType: {},
+	fields.Addr:      {},
+	fields.HostIP:    {},
+	fields.HostName:  {},
+	fields.Mac:       {},
+}
+
+// expandSymmetricKeys splits every group having unprefixed keys (e.g. K8S_Namespace) into a group where they
+// apply to the source and a group where they apply to the destination, each group running as a distinct query.
+// This is only needed when a prefixed variant is an indexed label or an IP, as other fields text filters
+// already match both sides (e.g. K8S_Name":"x matches SrcK8S_Name":"x). All the unprefixed keys of
+// an expanded group apply to the same side, e.g. K8S_Namespace=a&K8S_Name=b matches the traffic from or
+// to the pod b of namespace a. Negations must hold on both sides, e.g. K8S_Namespace!=a excludes the traffic from
+// or to a: they apply to the source and the destination within the same group instead.
+func expandSymmetricKeys(cfg *loki.Config, groups filters.MultiQueries) filters.MultiQueries {
+	var expanded filters.MultiQueries
+	for _, group := range groups {
+		expand := false
+		g := make(filters.SingleQuery, 0, len(group))
+		for _, m := range group {
+			if !isSymmetricKey(m.Key) || !hasSideSpecificFilter(cfg, m.Key) {
+				g = append(g, m)
+				continue
+			}
+			if m.Not {
+				for _, prefix := range []string{fields.Src, fields.Dst} {
+					sided := m
+					sided.Key = prefix + m.Key
+					g = append(g, sided)
+				}
+				continue
+			}
+			expand = true
+			g = append(g, m)
+		}
+		if !expand {
+			expanded = append(expanded, g)
+			continue
+		}
+		for _, prefix := range []string{fields.Src, fields.Dst} {
+			sided := make(filters.SingleQuery, len(g))
+			copy(sided, g)
+			for i := range sided {
+				// remaining negations are text filters, already excluding both sides
+				if isSymmetricKey(sided[i].Key) && !sided[i].Not {
+					sided[i].Key = prefix + sided[i].Key
+				}
+			}
+			expanded = append(expanded, sided)
+		}
+	}
+	return expanded
+}
+
+func isSymmetricKey(key string) bool {
+	_, ok := symmetricKeys[key]
+	return ok
+}
+
+// hasSideSpecificFilter tells whether the prefixed variants of a symmetric key are filtered as labels or IPs,
+// which unprefixed filters can't match
+func hasSideSpecificFilter(cfg *loki.Config, key string) bool {
+	for _, prefix := range []string{fields.Src, fields.Dst} {
+		if cfg.IsLabel(prefix+key) || fields.IsIP(prefix+key) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilters parses the raw filters, normalizes and expands them as configured
+func parseFilters(cfg *loki.Config, raw string) (filters.MultiQueries, int, error) {
+	filterGroups, err := filters.Parse(raw)
+	if err != nil {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+	}
+	filterGroups, err = expandPresets(cfg, filterGroups)
+	if err != nil {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+	}
+	filterGroups = expandSymmetricKeys(cfg, filterGroups)
+	normalizeFilters(cfg, filterGroups)
+	if err := normalizeMACFilters(filterGroups); err != nil {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+	}
+	if code, err := expandValueLists(cfg, filterGroups); err != nil {
+		return nil, code, withErrorCode(ErrorCodeInvalidFilter, err)
+	}
+	return filterGroups, http.StatusOK, nil
+}
+
+// normalizeFilters trims surrounding whitespace from keys and values (including inside exact match quotes), and lowercases values of fields
+// known to be lowercase. Regular expressions values are left untouched.
+func normalizeFilters(cfg *loki.Config, groups filters.MultiQueries) {
+	if !cfg.TrimFilters && !cfg.LowercaseFilters {
+		return
+	}
+	for _, group := range groups {
+		for i := range group {
+			m := &group[i]
+			if cfg.TrimFilters {
+				m.Key = strings.TrimSpace(m.Key)
+			}
+			if m.Regex {
+				continue
+			}
+			_, lower := lowercaseFields[m.Key]
+			lower = lower && cfg.LowercaseFilters
+			values := strings.Split(m.Values, ",")
+			for j, v := range values {
+				if cfg.TrimFilters {
+					v = strings.TrimSpace(v)
+					if isQuoted(v) {
+						// spaces are never allowed in values, trimming inside quotes is safe
+						v = exact(strings.TrimSpace(v[1 : len(v)-1]))
+					}
+				}
+				if lower {
+					v = strings.ToLower(v)
+				}
+				values[j] = v
+			}
+			m.Values = strings.Join(values, ",")
+		}
+	}
+}
+
+// normalizeMACFilters rewrites MAC addresses to the format used in flows: uppercase, colon separated.
+// Complete addresses are accepted in any format supported by net.ParseMAC (e.g. aa-bb-cc-dd-ee-ff);
+// partial ones only get their case and separators normalized.
+func normalizeMACFilters(groups filters.MultiQueries) error {
+	for _, group := range groups {
+		for i := range group {
+			m := &group[i]
+			if m.Key != fields.SrcMac && m.Key != fields.DstMac && m.Key != fields.Mac || m.Regex || m.Exists {
+				continue
+			}
+			values := strings.Split(m.Values, ",")
+			for j, v := range values {
+				quoted := isQuoted(v)
+				raw := v
+				if quoted {
+					raw = v[1 : len(v)-1]
+				}
+				var normalized string
+				if mac, err := net.ParseMAC(raw); err == nil && len(mac) == 6 {
+					normalized = strings.ToUpper(mac.String())
+				} else if quoted {
+					return fmt.Errorf("invalid MAC address in %s filter: %q", m.Key, raw)
+				} else {
+					normalized = strings.ToUpper(strings.ReplaceAll(raw, "-", ":"))
+				}
+				if quoted {
+					normalized = exact(normalized)
+				}
+				values[j] = normalized
+			}
+			m.Values = strings.Join(values, ",")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func TestParseFilters_Trim(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.TrimFilters = true
+	groups, _, err := parseFilters(&cfg, url.QueryEscape(` SrcK8S_Namespace = My-NS , other &DstK8S_Name=~ web-.* `))
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{
+		filters.NewMatch("SrcK8S_Namespace", "My-NS,other"),
+		filters.NewRegexMatch("DstK8S_Name", " web-.* "),
+	}}, groups)
+}
+
+func TestParseFilters_Lowercase(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.TrimFilters = true
+	cfg.LowercaseFilters = true
+	groups, _, err := parseFilters(&cfg, url.QueryEscape(`SrcK8S_Namespace="My-NS"&SrcK8S_OwnerType=Deployment&DstK8S_OwnerName= Front,API|DnsName=Example.COM&DstK8S_Name=~Web-.*`))
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{
+		filters.NewMatch("SrcK8S_Namespace", `"my-ns"`),
+		// kinds are case sensitive
+		filters.NewMatch("SrcK8S_OwnerType", "Deployment"),
+		filters.NewMatch("DstK8S_OwnerName", "front,api"),
+	}, {
+		// not a kubernetes name: case matters
+		filters.NewMatch("DnsName", "Example.COM"),
+		filters.NewRegexMatch("DstK8S_Name", "Web-.*"),
+	}}, groups)
+}
+
+func TestParseFilters_Disabled(t *testing.T) {
+	groups, _, err := parseFilters(&testLokiConfig, url.QueryEscape(`SrcK8S_Namespace= My-NS`))
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{filters.NewMatch("SrcK8S_Namespace", " My-NS")}}, groups)
+}
+
+func TestGetFlows_NormalizedFilters(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.TrimFilters = true
+	cfg.LowercaseFilters = true
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	params := url.Values{}
+	params.Set(filtersKey, `SrcK8S_Namespace="My-NS "`)
+	_, _, err := getFlows(context.Background(), &cfg, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), `SrcK8S_Namespace="my-ns"`)
+}
+
+func TestParseFilters_MAC(t *testing.T) {
+	groups, _, err := parseFilters(&testLokiConfig, url.QueryEscape(`SrcMac="0a-58-0a-80-00-01"&DstMac=0a58.0a80.0002,AA:BB:CC:DD:EE:FF|SrcMac=0a:58`))
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{
+		filters.NewMatch("SrcMac", `"0A:58:0A:80:00:01"`),
+		filters.NewMatch("DstMac", "0A:58:0A:80:00:02,AA:BB:CC:DD:EE:FF"),
+	}, {
+		// partial
+		filters.NewMatch("SrcMac", "0A:58"),
+	}}, groups)
+
+	_, _, err = parseFilters(&testLokiConfig, url.QueryEscape(`SrcMac="0a:58"`))
+	require.Error(t, err)
+
+	// unprefixed
+	groups, _, err = parseFilters(&testLokiConfig, url.QueryEscape(`Mac=0a-58-0a-80-00-01`))
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{filters.NewMatch("Mac", "0A:58:0A:80:00:01")}}, groups)
+}
+
+func TestParseFilters_Symmetric(t *testing.T) {
+	groups, _, err := parseFilters(&testLokiConfig, url.QueryEscape(`K8S_Namespace=ns&K8S_Name=pod|Addr=10.0.0.1|K8S_Name=other`))
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{
+		filters.NewMatch("SrcK8S_Namespace", "ns"),
+		filters.NewMatch("SrcK8S_Name", "pod"),
+	}, {
+		filters.NewMatch("DstK8S_Namespace", "ns"),
+		filters.NewMatch("DstK8S_Name", "pod"),
+	}, {
+		filters.NewMatch("SrcAddr", "10.0.0.1"),
+	}, {
+		filters.NewMatch("DstAddr", "10.0.0.1"),
+	}, {
+		// text filters already match both sides
+		filters.NewMatch("K8S_Name", "other"),
+	}}, groups)
+}
+
+func TestParseFilters_SymmetricNegation(t *testing.T) {
+	groups, _, err := parseFilters(&testLokiConfig, url.QueryEscape(`K8S_Namespace!=ns&Proto=6|K8S_Namespace=a&Addr!=10.0.0.1&K8S_Name!=pod`))
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{
+		// neither side matches
+		filters.NewNotMatch("SrcK8S_Namespace", "ns"),
+		filters.NewNotMatch("DstK8S_Namespace", "ns"),
+		filters.NewMatch("Proto", "6"),
+	}, {
+		filters.NewMatch("SrcK8S_Namespace", "a"),
+		filters.NewNotMatch("SrcAddr", "10.0.0.1"),
+		filters.NewNotMatch("DstAddr", "10.0.0.1"),
+		// text filters already exclude both sides
+		filters.NewNotMatch("K8S_Name", "pod"),
+	}, {
+		filters.NewMatch("DstK8S_Namespace", "a"),
+		filters.NewNotMatch("SrcAddr", "10.0.0.1"),
+		filters.NewNotMatch("DstAddr", "10.0.0.1"),
+		filters.NewNotMatch("K8S_Name", "pod"),
+	}}, groups)
+}
+
+func TestGetFlows_NestedFilters(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	params := url.Values{}
+	params.Set(filtersKey, `{"and":["SrcK8S_Namespace=a",{"or":["DstK8S_Namespace=b","DstPort=53"]}]}`)
+	_, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+
+	params.Set(filtersKey, `{"and":["SrcK8S_Namespace=a"]`)
+	_, code, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.Error(t, err)
+	assert.Equal(t, 400, code)
+}
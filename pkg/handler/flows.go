@@ -1,28 +1,35 @@
 package handler
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
 	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/tracing"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 )
 
 const (
-	startTimeKey  = "startTime"
-	endTimeKey    = "endTime"
-	timeRangeKey  = "timeRange"
-	limitKey      = "limit"
-	reporterKey   = "reporter"
-	recordTypeKey = "recordType"
-	filtersKey    = "filters"
+	startTimeKey        = "startTime"
+	endTimeKey          = "endTime"
+	timeRangeKey        = "timeRange"
+	limitKey            = "limit"
+	reporterKey         = "reporter"
+	recordTypeKey       = "recordType"
+	filtersKey          = "filters"
+	excludeZeroBytesKey = "excludeZeroBytes"
+	truncateKey         = "truncate"
 )
 
 type errorWithCode struct {
@@ -42,11 +49,11 @@ func getStartTime(params url.Values) (string, error) {
 			start = strconv.FormatInt(time.Now().Unix()-r, 10)
 		}
 	} else {
-		// Make sure it is a valid int
-		_, err := strconv.ParseInt(start, 10, 64)
+		s, err := parseTime(start, time.Now())
 		if err != nil {
 			return "", errors.New("Could not parse start time: " + err.Error())
 		}
+		start = strconv.FormatInt(s, 10)
 	}
 	return start, nil
 }
@@ -55,15 +62,74 @@ func getStartTime(params url.Values) (string, error) {
 func getEndTime(params url.Values) (string, error) {
 	end := params.Get(endTimeKey)
 	if len(end) > 0 {
-		r, err := strconv.ParseInt(end, 10, 64)
+		r, err := parseTime(end, time.Now())
 		if err != nil {
 			return "", errors.New("Could not parse end time: " + err.Error())
 		}
-		end = strconv.Itoa(int(r) + 1)
+		end = strconv.FormatInt(r+1, 10)
 	}
 	return end, nil
 }
 
+// relativeTimeUnits are the units allowed in relative time expressions, in addition to Go durations ones
+var relativeTimeUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// parseTime returns the time in unix seconds given either as unix seconds, as RFC3339 (e.g. 2023-03-28T10:00:00+02:00)
+// or as an expression relative to now, such as now, now-15m or now-1h30m; days and weeks are also accepted alone,
+// e.g. now-2d. Time windows are handled in seconds, so the fractional part of an RFC3339 time is truncated: the start
+// is rounded down and the end rounded up by getEndTime, so that the window never misses the requested range
+func parseTime(value string, now time.Time) (int64, error) {
+	// a non URL-encoded plus sign is decoded as a space
+	value = strings.ReplaceAll(value, " ", "+")
+	if !strings.HasPrefix(value, "now") {
+		if strings.Contains(value, "T") {
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return 0, err
+			}
+			return t.Unix(), nil
+		}
+		return strconv.ParseInt(value, 10, 64)
+	}
+	rel := strings.TrimPrefix(value, "now")
+	if rel == "" {
+		return now.Unix(), nil
+	}
+	sign := time.Duration(1)
+	switch rel[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("invalid relative time: %s", value)
+	}
+	offset, err := parseRelativeDuration(rel[1:])
+	if err != nil {
+		return 0, fmt.Errorf("invalid relative time: %s", value)
+	}
+	return now.Add(sign * offset).Unix(), nil
+}
+
+func parseRelativeDuration(value string) (time.Duration, error) {
+	for unit, d := range relativeTimeUnits {
+		if strings.HasSuffix(value, unit) {
+			count, err := strconv.ParseInt(strings.TrimSuffix(value, unit), 10, 64)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid duration: %s", value)
+			}
+			return time.Duration(count) * d, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	return d, nil
+}
+
 // getLimit returns limit as string (used for logQL) and as int (used to check if reached)
 func getLimit(params url.Values) (string, int, error) {
 	limit := params.Get(limitKey)
@@ -78,9 +144,39 @@ func getLimit(params url.Values) (string, int, error) {
 	return limit, reqLimit, nil
 }
 
+// getIngestionLag estimates how far Loki content is behind the query end, based on the newest returned flow.
+// It returns nil when there is no flow to compare with.
+func getIngestionLag(end string, streams model.Streams) *int64 {
+	var newest time.Time
+	for _, stream := range streams {
+		for _, entry := range stream.Entries {
+			if entry.Timestamp.After(newest) {
+				newest = entry.Timestamp
+			}
+		}
+	}
+	if newest.IsZero() {
+		return nil
+	}
+	queryEnd := time.Now()
+	if len(end) > 0 {
+		if e, err := strconv.ParseInt(end, 10, 64); err == nil {
+			// end has been ceiled to the next second in getEndTime
+			if requested := time.Unix(e-1, 0); requested.Before(queryEnd) {
+				queryEnd = requested
+			}
+		}
+	}
+	lag := queryEnd.Sub(newest).Milliseconds()
+	if lag < 0 {
+		lag = 0
+	}
+	return &lag
+}
+
 func GetFlows(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, false)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
 		var code int
 		startTime := time.Now()
 		defer func() {
@@ -88,20 +184,37 @@ func GetFlows(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 		}()
 
 		params := r.URL.Query()
-		hlog.Debugf("GetFlows query params: %s", params)
+		warnings := migrateDeprecatedParams(params)
+		writeWarningHeaders(w, warnings)
+		hlog.WithContext(r.Context()).Debugf("GetFlows query params: %s", params)
 
-		flows, code, err := getFlows(cfg, lokiClient, params)
+		code, err := checkTimeWindow(cfg, params, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		reqCfg, code, err := withLabelsOverride(cfg, r.Header)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
 
+		flows, code, err := getFlows(r.Context(), reqCfg, lokiClient, params)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		flows.Warnings = append(warnings, flows.Warnings...)
+
 		code = http.StatusOK
-		writeJSON(w, code, flows)
+		_, span := tracing.Start(r.Context(), "encode", tracing.KindInternal)
+		writeJSONStreams(w, code, flows)
+		span.End()
 	}
 }
 
-func getFlows(cfg *loki.Config, client httpclient.Caller, params url.Values) (*model.AggregatedQueryResponse, int, error) {
+func getFlows(ctx context.Context, cfg *loki.Config, client httpclient.Caller, params url.Values) (*model.AggregatedQueryResponse, int, error) {
+	params = withQueryDefaults(cfg, params)
 	start, err := getStartTime(params)
 	if err != nil {
 		return nil, http.StatusBadRequest, err
@@ -110,51 +223,227 @@ func getFlows(cfg *loki.Config, client httpclient.Caller, params url.Values) (*m
 	if err != nil {
 		return nil, http.StatusBadRequest, err
 	}
+	end, effectiveEnd := applyIngestionDelay(cfg, end)
 	limit, reqLimit, err := getLimit(params)
 	if err != nil {
 		return nil, http.StatusBadRequest, err
 	}
 	reporter := constants.Reporter(params.Get(reporterKey))
 	recordType := constants.RecordType(params.Get(recordTypeKey))
-	rawFilters := params.Get(filtersKey)
-	filterGroups, err := filters.Parse(rawFilters)
+	_, span := tracing.Start(ctx, "parse filters", tracing.KindInternal)
+	filterGroups, code, err := preprocessFilters(ctx, cfg, params)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		return nil, code, err
+	}
+	namespaceLimit, err := getNamespaceLimit(params.Get(namespaceLimitKey))
 	if err != nil {
 		return nil, http.StatusBadRequest, err
 	}
+	cursor, err := parseCursor(params.Get(cursorKey))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	pageEnd := end
+	if cursor != nil {
+		if namespaceLimit > 0 || params.Get(sortByKey) != "" {
+			return nil, http.StatusBadRequest, fmt.Errorf("%s parameter cannot be used with %s or %s", cursorKey, namespaceLimitKey, sortByKey)
+		}
+		pageEnd = cursor.queryEnd()
+	}
+	if namespaceLimit > 0 {
+		// each namespace gets its own query and limit, merged without global trim
+		filterGroups = splitByNamespace(filterGroups)
+		limit, reqLimit = strconv.Itoa(namespaceLimit), namespaceLimit
+	}
+	limit, reqLimit = restrictedLimit(ctx, cfg, limit, reqLimit)
+	var crowdedBoundary bool
+	if cursor != nil && cursor.skipCrowdedBoundary(reqLimit) {
+		crowdedBoundary = true
+		pageEnd = cursor.queryEnd()
+	}
 
-	merger := loki.NewStreamMerger(reqLimit)
-	if len(filterGroups) > 1 {
-		// match any, and multiple filters => run in parallel then aggregate
-		var queries []string
-		for _, group := range filterGroups {
-			qb := loki.NewFlowQueryBuilder(cfg, start, end, limit, reporter, recordType)
-			err := qb.Filters(group)
-			if err != nil {
-				return nil, http.StatusBadRequest, errors.New("Can't build query: " + err.Error())
-			}
-			queries = append(queries, qb.Build())
+	autoFields, err := isAutoFields(cfg, params)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("Could not parse autoFields: " + err.Error())
+	}
+	projection, err := parseFieldNames(params.Get(fieldsKey))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	excludeZeroBytes := params.Get(excludeZeroBytesKey) == "true"
+	rawStrategy := params.Get(reconcileKey)
+	// the both reporters mode collapses the observations of a same flow into one, unless asked otherwise
+	collapse := reporter == constants.ReporterBoth
+	if rawStrategy == "" {
+		rawStrategy = cfg.FlowReconciliation
+		if collapse && (rawStrategy == "" || rawStrategy == string(ReconcileKeepBoth)) {
+			rawStrategy = string(ReconcileSourceWins)
 		}
-		code, err := fetchParallel(client, queries, merger)
+	}
+	strategy, err := parseReconcileStrategy(rawStrategy)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	// truncation can be disabled per request, e.g. to get full values of a single flow
+	truncate := cfg.MaxStringFieldLength > 0 && params.Get(truncateKey) != "false"
+	var anomalyField string
+	var sortOrder *loki.SortOrder
+	ascending, err := isAscendingOrder(params.Get(orderKey))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	switch sortBy := params.Get(sortByKey); sortBy {
+	case "":
+	case timestampSort:
+		sortOrder = &loki.SortOrder{Ascending: ascending}
+	case anomalySort:
+		anomalyField, err = getAnomalyField(params.Get(anomalyFieldKey))
 		if err != nil {
-			return nil, code, err
+			return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, err)
 		}
+		if reqLimit > 0 {
+			// over-fetch, the result is trimmed to the requested limit once sorted
+			limit = strconv.Itoa(reqLimit * anomalyOverfetch)
+		}
+	default:
+		if !fields.IsNumeric(sortBy) && !fields.IsComputedNumeric(sortBy) {
+			return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("invalid %s parameter: %s", sortByKey, sortBy))
+		}
+		sortOrder = &loki.SortOrder{Field: sortBy, Ascending: ascending}
+	}
+
+	mergerLimit := reqLimit
+	if anomalyField != "" {
+		mergerLimit = reqLimit * anomalyOverfetch
+	}
+	merger := loki.NewStreamMerger(mergerLimit)
+	merger.SetSortOrder(sortOrder)
+	shards := []timeShard{{start: start, end: pageEnd}}
+	if namespaceLimit == 0 && anomalyField == "" {
+		// long ranges are split so that each query stays within Loki limits; the merger page boundary
+		// then ensures that only the most recent flows are returned when the limit is reached
+		shards = shardTimeRange(cfg, start, pageEnd)
+	}
+	var failures []queryFailure
+	_, buildSpan := tracing.Start(ctx, "build queries", tracing.KindInternal)
+	queries, err := buildFlowQueries(cfg, filterGroups, shards, limit, reporter, recordType, excludeZeroBytes)
+	buildSpan.SetError(err)
+	buildSpan.End()
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if len(queries) == 1 {
+		// single filter group and time shard => run all at once
+		code, err = fetchSingle(ctx, client, queries[0], merger)
 	} else {
-		// else, run all at once
-		qb := loki.NewFlowQueryBuilder(cfg, start, end, limit, reporter, recordType)
-		if len(filterGroups) > 0 {
-			err := qb.Filters(filterGroups[0])
+		// match any, multiple filters or time shards => run in parallel then aggregate
+		failures, code, err = fetchPartial(ctx, cfg, client, queries, merger)
+	}
+	if err != nil {
+		return nil, code, err
+	}
+
+	qr := merger.Get()
+	if len(failures) > 0 {
+		qr.FailedQueries = failedQueries(queries, failures)
+		qr.MissingRanges = missingRanges(shards, failures)
+	}
+	qr.EffectiveEndTime = effectiveEnd
+	qr.Warnings = lintQuery(cfg, filterGroups, start, end, nil)
+	if crowdedBoundary {
+		qr.Warnings = append(qr.Warnings, fmt.Sprintf("more than %d flows share the previous page boundary timestamp, some of them were skipped: increase the limit to get them all", reqLimit))
+	}
+	if streams, ok := qr.Result.(model.Streams); ok {
+		if cursor != nil {
+			streams = cursor.skipSeen(streams)
+		}
+		if boundary, ok := merger.PageBoundary(); ok && namespaceLimit == 0 && anomalyField == "" {
+			page, next := cutPage(streams, boundary)
+			if cursor != nil && next.End == cursor.End {
+				// still on the same timestamp, keep skipping the entries of the previous pages
+				next.Seen = append(next.Seen, cursor.Seen...)
+			}
+			streams = page
+			qr.NextCursor = next.encode()
+		}
+		qr.Result = streams
+		// reconciliation only applies when both reporters are queried
+		if reporter != constants.ReporterSource && reporter != constants.ReporterDestination {
+			reconciled, count, err := reconcileFlows(streams, strategy, collapse)
 			if err != nil {
-				return nil, http.StatusBadRequest, err
+				return nil, http.StatusInternalServerError, err
+			}
+			streams = reconciled
+			qr.Result = streams
+			qr.Stats.Reconciled = count
+		}
+		qr.IngestionLagMs = getIngestionLag(end, streams)
+		processors := []flowProcessor{addFlowID, addBitsPerSecond, addRetransmitRate, annotateDSCPNames}
+		if len(cfg.PortNames) > 0 {
+			processors = append(processors, annotatePortNames(cfg.PortNames))
+		}
+		if cfg.InferAppProtocol && len(cfg.AppProtocols) > 0 {
+			processors = append(processors, inferAppProtocol(cfg.AppProtocols))
+		}
+		if truncate {
+			processors = append(processors, truncateStrings(cfg.MaxStringFieldLength))
+		}
+		if autoFields {
+			if excluded := getExcludedFields(ctx, cfg, client); len(excluded) > 0 {
+				processors = append(processors, excludeFields(excluded))
 			}
 		}
-		query := qb.Build()
-		code, err := fetchSingle(client, query, merger)
+		if len(projection) > 0 && anomalyField == "" {
+			// last, as the other processors may need any field
+			processors = append(processors, projectFields(projection))
+		}
+		_, span := tracing.Start(ctx, "post-process", tracing.KindInternal)
+		err := postProcessFlows(streams, processors...)
+		span.End()
 		if err != nil {
-			return nil, code, err
+			return nil, http.StatusInternalServerError, err
+		}
+		if anomalyField != "" {
+			sorted, err := sortByAnomaly(streams, anomalyField, reqLimit)
+			if err != nil {
+				return nil, http.StatusInternalServerError, err
+			}
+			if len(projection) > 0 {
+				// the anomaly score needs all fields, projection comes after
+				if err := postProcessFlows(sorted, projectFields(projection)); err != nil {
+					return nil, http.StatusInternalServerError, err
+				}
+			}
+			qr.Result = sorted
 		}
 	}
-
-	qr := merger.Get()
 	hlog.Tracef("GetFlows response: %v", qr)
 	return qr, http.StatusOK, nil
 }
+
+// buildFlowQueries returns one query per filter group and time shard, ordered by filter group then time shard
+func buildFlowQueries(cfg *loki.Config, filterGroups filters.MultiQueries, shards []timeShard, limit string, reporter constants.Reporter, recordType constants.RecordType, excludeZeroBytes bool) ([]string, error) {
+	groups := filterGroups
+	if len(groups) == 0 {
+		groups = filters.MultiQueries{nil}
+	}
+	var queries []string
+	for _, group := range groups {
+		for _, shard := range shards {
+			qb := loki.NewFlowQueryBuilder(cfg, shard.start, shard.end, limit, reporter, recordType)
+			if excludeZeroBytes {
+				qb.ExcludeZeroBytes()
+			}
+			if err := qb.Filters(group); err != nil {
+				if len(filterGroups) > 1 {
+					return nil, withErrorCode(ErrorCodeInvalidFilter, errors.New("Can't build query: "+err.Error()))
+				}
+				return nil, withErrorCode(ErrorCodeInvalidFilter, err)
+			}
+			queries = append(queries, qb.Build())
+		}
+	}
+	return queries, nil
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+const (
+	sortByKey       = "sortBy"
+	orderKey        = "order"
+	anomalyFieldKey = "anomalyField"
+	anomalySort     = "anomaly"
+	timestampSort   = "timestamp"
+	// anomalyOverfetch multiplies the requested limit, so that the deviation is computed over a meaningful set
+	anomalyOverfetch = 5
+)
+
+// getAnomalyField returns the numeric field used to sort by anomaly, Bytes by default
+func getAnomalyField(raw string) (string, error) {
+	if raw == "" {
+		return fields.Bytes, nil
+	}
+	if !fields.IsNumeric(raw) && !fields.IsComputedNumeric(raw) {
+		return "", fmt.Errorf("cannot sort by anomaly on non-numeric field: %s", raw)
+	}
+	return raw, nil
+}
+
+// isAscendingOrder parses the order of the timestamp or field sorts, descending by default.
+// The anomaly sort is always descending, most anomalous first.
+func isAscendingOrder(raw string) (bool, error) {
+	switch raw {
+	case "", "desc":
+		return false, nil
+	case "asc":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid %s parameter: %s", orderKey, raw)
+}
+
+type scoredEntry struct {
+	labels map[string]string
+	entry  model.Entry
+	record flowRecord
+	score  float64
+	scored bool
+}
+
+// sortByAnomaly orders flows by the deviation of a numeric field from the mean (absolute z-score), largest first,
+// and keeps at most limit flows (0 means no limit). Each flow is returned in its own stream to preserve the order,
+// with the score attached. Note that the deviation is relative to the returned window only.
+// Flows without the field are kept last, unscored.
+func sortByAnomaly(streams model.Streams, field string, limit int) (model.Streams, error) {
+	var entries []scoredEntry
+	var sum, sumSquares float64
+	var count int
+	for _, stream := range streams {
+		for _, entry := range stream.Entries {
+			decoder := json.NewDecoder(bytes.NewReader([]byte(entry.Line)))
+			decoder.UseNumber()
+			se := scoredEntry{labels: stream.Labels, entry: entry, record: flowRecord{labels: stream.Labels}}
+			if err := decoder.Decode(&se.record.fields); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal line %s: %w", entry.Line, err)
+			}
+			if v, ok := se.record.getFloat(field); ok {
+				se.score, se.scored = v, true
+				sum += v
+				sumSquares += v * v
+				count++
+			}
+			entries = append(entries, se)
+		}
+	}
+	if count > 0 {
+		mean := sum / float64(count)
+		stddev := math.Sqrt(math.Max(sumSquares/float64(count)-mean*mean, 0))
+		for i := range entries {
+			if !entries[i].scored {
+				continue
+			}
+			if stddev == 0 {
+				entries[i].score = 0
+			} else {
+				entries[i].score = math.Abs(entries[i].score-mean) / stddev
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].scored != entries[j].scored {
+			return entries[i].scored
+		}
+		return entries[i].score > entries[j].score
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	sorted := make(model.Streams, 0, len(entries))
+	for _, se := range entries {
+		if se.scored {
+			se.record.fields[fields.AnomalyScore] = math.Round(se.score*1000) / 1000
+			line, err := json.Marshal(se.record.fields)
+			if err != nil {
+				return nil, fmt.Errorf("cannot marshal line: %w", err)
+			}
+			se.entry.Line = string(line)
+		}
+		sorted = append(sorted, model.Stream{Labels: se.labels, Entries: []model.Entry{se.entry}})
+	}
+	return sorted, nil
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+func TestGetFlows_SortByAnomaly(t *testing.T) {
+	ts := time.Now()
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"FlowDirection": "0"},
+		Entries: []model.Entry{
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.1","Bytes":100}`},
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.2","Bytes":120}`},
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.3"}`},
+		},
+	}, {
+		Labels: map[string]string{"FlowDirection": "1"},
+		Entries: []model.Entry{
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.4","Bytes":110}`},
+			// the outlier
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.5","Bytes":50000}`},
+		},
+	}})
+
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{
+		sortByKey: {anomalySort},
+		limitKey:  {"3"},
+	})
+	require.NoError(t, err)
+	// over-fetched
+	lokiClientMock.AssertCalled(t, "Get", "http://loki/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\"}&limit=15")
+
+	streams := qr.Result.(model.Streams)
+	require.Len(t, streams, 3)
+	records := getRecords(t, qr)
+	assert.Equal(t, "10.0.0.5", records[0]["SrcAddr"])
+	assert.Equal(t, "1", streams[0].Labels["FlowDirection"])
+	assert.Greater(t, records[0][fields.AnomalyScore], records[1][fields.AnomalyScore])
+	assert.GreaterOrEqual(t, records[1][fields.AnomalyScore], records[2][fields.AnomalyScore])
+	// the flow without bytes is left out by the limit
+	for _, r := range records {
+		assert.NotEqual(t, "10.0.0.3", r["SrcAddr"])
+	}
+}
+
+func TestSortByAnomaly_Unscored(t *testing.T) {
+	sorted, err := sortByAnomaly(model.Streams{{
+		Entries: []model.Entry{
+			{Line: `{"SrcAddr":"10.0.0.1"}`},
+			{Line: `{"SrcAddr":"10.0.0.2","Bytes":100}`},
+			{Line: `{"SrcAddr":"10.0.0.3","Bytes":100}`},
+		},
+	}}, fields.Bytes, 0)
+	require.NoError(t, err)
+	require.Len(t, sorted, 3)
+	// no deviation => zero score, unscored last
+	assert.Equal(t, `{"Bytes":100,"SrcAddr":"10.0.0.2","_AnomalyScore":0}`, sorted[0].Entries[0].Line)
+	assert.Equal(t, `{"SrcAddr":"10.0.0.1"}`, sorted[2].Entries[0].Line)
+}
+
+func TestGetFlows_SortByAnomaly_Invalid(t *testing.T) {
+	_, code, err := getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, model.Streams{}), url.Values{sortByKey: {"foo"}})
+	require.Error(t, err)
+	assert.Equal(t, ErrorCodeInvalidRequest, getErrorCode(0, err))
+	assert.Equal(t, http.StatusBadRequest, code)
+	_, code, err = getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, model.Streams{}), url.Values{sortByKey: {anomalySort}, anomalyFieldKey: {"SrcAddr"}})
+	require.Error(t, err)
+	assert.Equal(t, ErrorCodeInvalidRequest, getErrorCode(0, err))
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGetFlows_SortByField(t *testing.T) {
+	ts := time.Now()
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"FlowDirection": "0"},
+		Entries: []model.Entry{
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.1","Bytes":100}`},
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.2","Bytes":300}`},
+		},
+	}, {
+		Labels: map[string]string{"FlowDirection": "1"},
+		Entries: []model.Entry{
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.3","Bytes":200}`},
+		},
+	}})
+
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{sortByKey: {fields.Bytes}})
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 3)
+	assert.Equal(t, []interface{}{"10.0.0.2", "10.0.0.3", "10.0.0.1"}, []interface{}{records[0]["SrcAddr"], records[1]["SrcAddr"], records[2]["SrcAddr"]})
+
+	qr, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{sortByKey: {fields.Bytes}, orderKey: {"asc"}})
+	require.NoError(t, err)
+	records = getRecords(t, qr)
+	assert.Equal(t, []interface{}{"10.0.0.1", "10.0.0.3", "10.0.0.2"}, []interface{}{records[0]["SrcAddr"], records[1]["SrcAddr"], records[2]["SrcAddr"]})
+
+	_, code, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{sortByKey: {timestampSort}, orderKey: {"up"}})
+	require.Error(t, err)
+	assert.Equal(t, 400, code)
+}
+
+func TestGetFlows_SortByComputedField(t *testing.T) {
+	ts := time.Now()
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"FlowDirection": "0"},
+		Entries: []model.Entry{
+			// 8kbps, the most bytes, 10% retransmitted
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.1","Bytes":1000,"Packets":10,"TcpRetransmits":1,"TimeFlowStartMs":1000,"TimeFlowEndMs":2000}`},
+			// without rates
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.2","Bytes":500}`},
+		},
+	}, {
+		Labels: map[string]string{"FlowDirection": "1"},
+		Entries: []model.Entry{
+			// 80kbps, 50% retransmitted
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.3","Bytes":100,"Packets":2,"TcpRetransmits":1,"TimeFlowStartMs":1000,"TimeFlowEndMs":1010}`},
+		},
+	}})
+
+	// flows without the field last
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{sortByKey: {fields.BitsPerSecond}})
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 3)
+	assert.Equal(t, []interface{}{"10.0.0.3", "10.0.0.1", "10.0.0.2"}, []interface{}{records[0]["SrcAddr"], records[1]["SrcAddr"], records[2]["SrcAddr"]})
+	assert.Equal(t, 80000.0, records[0][fields.BitsPerSecond])
+
+	qr, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{sortByKey: {fields.TCPRetransmitRate}, orderKey: {"asc"}})
+	require.NoError(t, err)
+	records = getRecords(t, qr)
+	require.Len(t, records, 3)
+	assert.Equal(t, []interface{}{"10.0.0.1", "10.0.0.3", "10.0.0.2"}, []interface{}{records[0]["SrcAddr"], records[1]["SrcAddr"], records[2]["SrcAddr"]})
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+const cursorKey = "cursor"
+
+// flowsCursor is the continuation of a flows query whose limit was reached: the next page ends at the
+// boundary of the previous one, inclusive, the entries already returned at this exact timestamp being skipped.
+// A single boundary is kept rather than a last timestamp per stream: the limit applies to the merged streams, so
+// that all of them are complete down to the boundary, and the cursor size doesn't grow with the number of streams.
+// Entries are identified by a hash as they have no ID.
+type flowsCursor struct {
+	// End is the next page end, in nanoseconds, exclusive
+	End int64 `json:"e"`
+	// Seen are the keys of the entries already returned at the boundary timestamp
+	Seen []string `json:"s,omitempty"`
+}
+
+func parseCursor(raw string) (*flowsCursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	js, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", cursorKey)
+	}
+	var c flowsCursor
+	if err := json.Unmarshal(js, &c); err != nil || c.End <= 0 {
+		return nil, fmt.Errorf("invalid %s parameter", cursorKey)
+	}
+	return &c, nil
+}
+
+func (c *flowsCursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// queryEnd is the end parameter of the next page Loki queries
+func (c *flowsCursor) queryEnd() string {
+	return strconv.FormatInt(c.End, 10)
+}
+
+func entryKey(e *model.Entry) string {
+	sum := sha1.Sum([]byte(strconv.FormatInt(e.Timestamp.UnixNano(), 10) + e.Line))
+	return hex.EncodeToString(sum[:8])
+}
+
+// skipCrowdedBoundary moves the page end before the boundary timestamp when the entries already returned there fill
+// a page: Loki could then keep returning them only, the cursor never advancing. The unseen entries of this
+// timestamp are skipped, which is reported by returning true.
+func (c *flowsCursor) skipCrowdedBoundary(limit int) bool {
+	if limit <= 0 || len(c.Seen) < limit {
+		return false
+	}
+	c.End--
+	c.Seen = nil
+	return true
+}
+
+// skipSeen removes the entries already returned by the previous page
+func (c *flowsCursor) skipSeen(streams model.Streams) model.Streams {
+	if len(c.Seen) == 0 {
+		return streams
+	}
+	seen := map[string]struct{}{}
+	for _, k := range c.Seen {
+		seen[k] = struct{}{}
+	}
+	return filterEntries(streams, func(e *model.Entry) bool {
+		_, skip := seen[entryKey(e)]
+		return !skip
+	})
+}
+
+// cutPage removes the entries older than the page boundary, which will come with the next page,
+// and returns the cursor of this next page
+func cutPage(streams model.Streams, boundary time.Time) (model.Streams, *flowsCursor) {
+	next := flowsCursor{End: boundary.UnixNano() + 1}
+	page := filterEntries(streams, func(e *model.Entry) bool {
+		if e.Timestamp.Equal(boundary) {
+			next.Seen = append(next.Seen, entryKey(e))
+		}
+		return !e.Timestamp.Before(boundary)
+	})
+	return page, &next
+}
+
+func filterEntries(streams model.Streams, keep func(e *model.Entry) bool) model.Streams {
+	filtered := model.Streams{}
+	for _, s := range streams {
+		var entries []model.Entry
+		for i := range s.Entries {
+			if keep(&s.Entries[i]) {
+				entries = append(entries, s.Entries[i])
+			}
+		}
+		if len(entries) > 0 {
+			filtered = append(filtered, model.Stream{Labels: s.Labels, Entries: entries})
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func cursorTestEntry(sec int64, addr string) model.Entry {
+	return model.Entry{Timestamp: time.Unix(sec, 0), Line: `{"SrcAddr":"` + addr + `"}`}
+}
+
+func TestGetFlows_Cursor(t *testing.T) {
+	labels := map[string]string{"app": "netobserv-flowcollector"}
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: labels,
+		Entries: []model.Entry{
+			cursorTestEntry(1680000102, "10.0.0.1"),
+			cursorTestEntry(1680000101, "10.0.0.2"),
+			cursorTestEntry(1680000100, "10.0.0.3"),
+		},
+	}})
+	params := url.Values{}
+	params.Set(limitKey, "3")
+	qr, code, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	require.NotEmpty(t, qr.NextCursor)
+	assert.Len(t, qr.Result.(model.Streams)[0].Entries, 3)
+
+	// next page ends right after the boundary, skipping the entry already returned there
+	lokiClientMock = mockStreamsResponse(t, model.Streams{{
+		Labels: labels,
+		Entries: []model.Entry{
+			cursorTestEntry(1680000100, "10.0.0.3"),
+			cursorTestEntry(1680000100, "10.0.0.4"),
+		},
+	}})
+	params.Set(cursorKey, qr.NextCursor)
+	qr, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), "&end="+strconv.FormatInt(time.Unix(1680000100, 1).UnixNano(), 10))
+	streams := qr.Result.(model.Streams)
+	require.Len(t, streams, 1)
+	require.Len(t, streams[0].Entries, 1)
+	assert.Contains(t, streams[0].Entries[0].Line, "10.0.0.4")
+	assert.Empty(t, qr.NextCursor)
+}
+
+func TestCutPage(t *testing.T) {
+	// first group reached the limit at 1680000101, the older entries of the second group come with the next page
+	merged := model.Streams{
+		{Labels: map[string]string{"SrcK8S_Namespace": "a"}, Entries: []model.Entry{
+			cursorTestEntry(1680000102, "10.0.0.1"),
+			cursorTestEntry(1680000101, "10.0.0.2"),
+		}},
+		{Labels: map[string]string{"SrcK8S_Namespace": "b"}, Entries: []model.Entry{
+			cursorTestEntry(1680000101, "10.0.0.3"),
+			cursorTestEntry(1680000050, "10.0.0.4"),
+		}},
+	}
+	page, next := cutPage(merged, time.Unix(1680000101, 0))
+	require.Len(t, page, 2)
+	assert.Len(t, page[0].Entries, 2)
+	assert.Len(t, page[1].Entries, 1)
+	assert.Equal(t, time.Unix(1680000101, 1).UnixNano(), next.End)
+	assert.Len(t, next.Seen, 2)
+
+	decoded, err := parseCursor(next.encode())
+	require.NoError(t, err)
+	assert.Equal(t, next, decoded)
+	assert.Empty(t, decoded.skipSeen(page[1:]))
+}
+
+func TestGetFlows_CursorCrowdedBoundary(t *testing.T) {
+	labels := map[string]string{"app": "netobserv-flowcollector"}
+	sameTime := model.Streams{{
+		Labels: labels,
+		Entries: []model.Entry{
+			cursorTestEntry(1680000100, "10.0.0.1"),
+			cursorTestEntry(1680000100, "10.0.0.2"),
+		},
+	}}
+	lokiClientMock := mockStreamsResponse(t, sameTime)
+	params := url.Values{}
+	params.Set(limitKey, "2")
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	require.NotEmpty(t, qr.NextCursor)
+
+	// the whole page shares the boundary timestamp: the next page moves before it rather than looping
+	lokiClientMock = mockStreamsResponse(t, model.Streams{{
+		Labels:  labels,
+		Entries: []model.Entry{cursorTestEntry(1680000099, "10.0.0.3")},
+	}})
+	params.Set(cursorKey, qr.NextCursor)
+	qr, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), "&end="+strconv.FormatInt(time.Unix(1680000100, 0).UnixNano(), 10))
+	streams := qr.Result.(model.Streams)
+	require.Len(t, streams, 1)
+	assert.Contains(t, streams[0].Entries[0].Line, "10.0.0.3")
+	require.Len(t, qr.Warnings, 1)
+	assert.Contains(t, qr.Warnings[0], "skipped")
+}
+
+func TestGetFlows_CursorInvalid(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+
+	params := url.Values{}
+	params.Set(cursorKey, "not a cursor")
+	_, code, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.Error(t, err)
+	assert.Equal(t, 400, code)
+
+	params.Set(cursorKey, (&flowsCursor{End: 1680000100000000001}).encode())
+	params.Set(namespaceLimitKey, "10")
+	_, code, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.Error(t, err)
+	assert.Equal(t, 400, code)
+	lokiClientMock.AssertNotCalled(t, "Get")
+}
+
+func TestGetFlows_CursorThroughCache(t *testing.T) {
+	labels := map[string]string{"app": "netobserv-flowcollector"}
+	pages := map[string][]model.Entry{
+		"1680000100":          {cursorTestEntry(1680000095, "10.0.0.1"), cursorTestEntry(1680000090, "10.0.0.2")},
+		"1680000090000000001": {cursorTestEntry(1680000090, "10.0.0.2"), cursorTestEntry(1680000080, "10.0.0.3")},
+		"1680000080000000001": {cursorTestEntry(1680000080, "10.0.0.3"), cursorTestEntry(1680000070, "10.0.0.4")},
+	}
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	for end, entries := range pages {
+		resp, err := json.Marshal(model.QueryResponse{
+			Status: "success",
+			Data:   model.QueryResponseData{ResultType: model.ResultTypeStream, Result: model.Streams{{Labels: labels, Entries: entries}}},
+		})
+		require.NoError(t, err)
+		end := end
+		lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool { return strings.Contains(u, "&end="+end) })).Return(resp, 200, nil)
+	}
+	// the ends of the next pages fall in the same cache time bucket
+	client := httpclient.WithCache(
+		httpclient.WithCache(lokiClientMock, httpclient.NewNegativeCache(time.Minute, 10), nil),
+		httpclient.NewCache(time.Minute, 10), nil)
+
+	params := url.Values{}
+	params.Set(limitKey, "2")
+	params.Set(startTimeKey, "1680000000")
+	params.Set(endTimeKey, "1680000099")
+	var lines []string
+	for i := 0; i < 3; i++ {
+		qr, _, err := getFlows(context.Background(), &testLokiConfig, client, params)
+		require.NoError(t, err)
+		for _, e := range qr.Result.(model.Streams)[0].Entries {
+			lines = append(lines, e.Line)
+		}
+		require.NotEmpty(t, qr.NextCursor)
+		params.Set(cursorKey, qr.NextCursor)
+	}
+	require.Len(t, lines, 4)
+	for i, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		assert.Contains(t, lines[i], addr)
+	}
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 3)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+const estimateOverlapWarning = "filter groups may select the same streams, counted once per group: the estimate is an upper bound"
+
+// EstimateFlows returns the expected cost of a flows request, from the Loki index stats of its stream selectors,
+// without running it
+func EstimateFlows(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("EstimateFlows", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		warnings := migrateDeprecatedParams(params)
+		writeWarningHeaders(w, warnings)
+		hlog.WithContext(r.Context()).Debugf("EstimateFlows query params: %s", params)
+
+		reqCfg, code, err := withLabelsOverride(cfg, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+
+		estimate, code, err := estimateFlows(r.Context(), reqCfg, lokiClient, params)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		estimate.Warnings = append(warnings, estimate.Warnings...)
+
+		code = http.StatusOK
+		writeJSON(w, code, estimate)
+	}
+}
+
+func estimateFlows(ctx context.Context, cfg *loki.Config, client httpclient.Caller, params url.Values) (*model.FlowsEstimate, int, error) {
+	params = withQueryDefaults(cfg, params)
+	start, err := getStartTime(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	end, err := getEndTime(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	end, _ = applyIngestionDelay(cfg, end)
+	reporter := constants.Reporter(params.Get(reporterKey))
+	recordType := constants.RecordType(params.Get(recordTypeKey))
+	filterGroups, code, err := preprocessFilters(ctx, cfg, params)
+	if err != nil {
+		return nil, code, err
+	}
+
+	var queries []string
+	newBuilder := func() *loki.FlowQueryBuilder {
+		return loki.NewFlowQueryBuilder(cfg, start, end, "", reporter, recordType)
+	}
+	if len(filterGroups) == 0 {
+		queries = append(queries, newBuilder().BuildIndexStats())
+	}
+	for _, group := range filterGroups {
+		qb := newBuilder()
+		if err := qb.Filters(group); err != nil {
+			return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, errors.New("Can't build query: "+err.Error()))
+		}
+		queries = append(queries, qb.BuildIndexStats())
+	}
+
+	estimate := model.FlowsEstimate{NumQueries: len(queries)}
+	seen := map[string]struct{}{}
+	for _, query := range queries {
+		if _, ok := seen[query]; ok {
+			// groups differing only by line or JSON filters read the same streams
+			continue
+		}
+		seen[query] = struct{}{}
+		stats, code, err := getIndexStats(ctx, client, query)
+		if err != nil {
+			return nil, code, err
+		}
+		estimate.Streams += stats.Streams
+		estimate.Chunks += stats.Chunks
+		estimate.Entries += stats.Entries
+		estimate.Bytes += stats.Bytes
+	}
+	if len(seen) > 1 {
+		estimate.Warnings = append(estimate.Warnings, estimateOverlapWarning)
+	}
+	return &estimate, http.StatusOK, nil
+}
+
+func getIndexStats(ctx context.Context, client httpclient.Caller, query string) (*model.IndexStats, int, error) {
+	resp, code, err := executeLokiQuery(ctx, query, client)
+	if err != nil {
+		return nil, code, fmt.Errorf("Loki index stats query failed: %w", err)
+	}
+	var stats model.IndexStats
+	if err := json.Unmarshal(resp, &stats); err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Failed to unmarshal Loki index stats response: " + err.Error())
+	}
+	return &stats, http.StatusOK, nil
+}
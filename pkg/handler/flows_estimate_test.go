@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+)
+
+func TestEstimateFlows(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool { return strings.Contains(u, "/index/stats?") })).
+		Return([]byte(`{"streams":2,"chunks":10,"entries":1000,"bytes":50000}`), 200, nil)
+
+	params := url.Values{}
+	params.Set(startTimeKey, "1000")
+	params.Set(endTimeKey, "1600")
+	// the last two groups share the same stream selector
+	params.Set(filtersKey, "SrcK8S_Namespace=a|DstPort=53|DstPort=80")
+	estimate, _, err := estimateFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+	assert.Equal(t, 3, estimate.NumQueries)
+	assert.Equal(t, int64(4), estimate.Streams)
+	assert.Equal(t, int64(20), estimate.Chunks)
+	assert.Equal(t, int64(2000), estimate.Entries)
+	assert.Equal(t, int64(100000), estimate.Bytes)
+	assert.Equal(t, []string{estimateOverlapWarning}, estimate.Warnings)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), "&start=1000&end=1601")
+}
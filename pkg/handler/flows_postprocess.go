@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+// flowRecord gives access to a flow fields, either from the JSON line or from the stream labels
+type flowRecord struct {
+	fields map[string]interface{}
+	labels map[string]string
+}
+
+func (r *flowRecord) get(key string) (interface{}, bool) {
+	if v, ok := r.fields[key]; ok {
+		return v, true
+	}
+	if v, ok := r.labels[key]; ok {
+		return v, true
+	}
+	return nil, false
+}
+
+func (r *flowRecord) getString(key string) string {
+	if v, ok := r.get(key); ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func (r *flowRecord) getFloat(key string) (float64, bool) {
+	s := r.getString(key)
+	if s == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// flowProcessor transforms a flow record in place
+type flowProcessor func(entry *model.Entry, record *flowRecord)
+
+// postProcessFlows runs the processors on every flow record of the streams, updating entries lines
+func postProcessFlows(streams model.Streams, processors ...flowProcessor) error {
+	if len(processors) == 0 {
+		return nil
+	}
+	for i := range streams {
+		stream := &streams[i]
+		for j := range stream.Entries {
+			entry := &stream.Entries[j]
+			decoder := json.NewDecoder(bytes.NewReader([]byte(entry.Line)))
+			// keep numbers as they are, without float conversion
+			decoder.UseNumber()
+			record := flowRecord{labels: stream.Labels}
+			if err := decoder.Decode(&record.fields); err != nil {
+				return fmt.Errorf("cannot unmarshal line %s: %w", entry.Line, err)
+			}
+			for _, p := range processors {
+				p(entry, &record)
+			}
+			line, err := json.Marshal(record.fields)
+			if err != nil {
+				return fmt.Errorf("cannot marshal line: %w", err)
+			}
+			entry.Line = string(line)
+		}
+	}
+	return nil
+}
+
+// flowIdentityFields are used, with the entry timestamp, to compute the flow ID
+var flowIdentityFields = []string{
+	fields.SrcAddr,
+	fields.DstAddr,
+	fields.SrcPort,
+	fields.DstPort,
+	fields.Proto,
+	fields.FlowDirection,
+	fields.Interface,
+	fields.TimeFlowStart,
+	fields.TimeFlowEnd,
+}
+
+// addFlowID attaches a deterministic UUID to the record, stable across requests for the same underlying flow
+func addFlowID(entry *model.Entry, record *flowRecord) {
+	record.fields[fields.FlowID] = computeFlowID(entry, record)
+}
+
+func computeFlowID(entry *model.Entry, record *flowRecord) string {
+	h := sha1.New()
+	_, _ = h.Write([]byte(strconv.FormatInt(entry.Timestamp.UnixNano(), 10)))
+	for _, f := range flowIdentityFields {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(record.getString(f)))
+	}
+	sum := h.Sum(nil)
+	// format as a name-based UUID (version 5, RFC 4122 variant)
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// addBitsPerSecond attaches the flow throughput, computed from its bytes and duration
+func addBitsPerSecond(_ *model.Entry, record *flowRecord) {
+	if bps, ok := fields.ComputeNumeric(fields.BitsPerSecond, record.getFloat); ok {
+		record.fields[fields.BitsPerSecond] = bps
+	}
+}
+
+// addRetransmitRate attaches the ratio of retransmitted packets, when the flow carries the raw counter
+func addRetransmitRate(_ *model.Entry, record *flowRecord) {
+	if rate, ok := fields.ComputeNumeric(fields.TCPRetransmitRate, record.getFloat); ok {
+		record.fields[fields.TCPRetransmitRate] = rate
+	}
+}
+
+// annotateDSCPNames adds the class name of standard DSCP values, e.g. EF for 46
+func annotateDSCPNames(_ *model.Entry, record *flowRecord) {
+	dscp, err := strconv.Atoi(record.getString(fields.DSCP))
+	if err != nil {
+		return
+	}
+	if name, ok := fields.GetDSCPName(dscp); ok {
+		record.fields[fields.DSCPName] = name
+	}
+}
+
+const truncationSuffix = "…"
+
+// truncateStrings returns a processor that truncates string fields longer than max characters,
+// flagging the records where it happened. The flow ID is never truncated.
+func truncateStrings(max int) flowProcessor {
+	return func(_ *model.Entry, record *flowRecord) {
+		for k, v := range record.fields {
+			str, ok := v.(string)
+			if !ok || k == fields.FlowID || utf8.RuneCountInString(str) <= max {
+				continue
+			}
+			record.fields[k] = string([]rune(str)[:max]) + truncationSuffix
+			record.fields[fields.Truncated] = true
+		}
+	}
+}
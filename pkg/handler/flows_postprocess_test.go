@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+func getRecords(t *testing.T, qr *model.AggregatedQueryResponse) []map[string]interface{} {
+	streams, ok := qr.Result.(model.Streams)
+	require.True(t, ok)
+	var records []map[string]interface{}
+	for _, s := range streams {
+		for _, e := range s.Entries {
+			var r map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(e.Line), &r))
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+func TestGetFlows_StableFlowID(t *testing.T) {
+	ts := time.Unix(1680000000, 123)
+	streams := func() model.Streams {
+		return model.Streams{{
+			Labels: map[string]string{"FlowDirection": "0"},
+			Entries: []model.Entry{
+				{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","SrcPort":42000,"DstPort":443,"Proto":6,"Bytes":100,"TimeFlowStartMs":1680000000000}`},
+				{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","SrcPort":42001,"DstPort":443,"Proto":6,"Bytes":100,"TimeFlowStartMs":1680000000000}`},
+			},
+		}}
+	}
+
+	qr1, _, err := getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, streams()), url.Values{})
+	require.NoError(t, err)
+	qr2, _, err := getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, streams()), url.Values{})
+	require.NoError(t, err)
+
+	records1 := getRecords(t, qr1)
+	records2 := getRecords(t, qr2)
+	require.Len(t, records1, 2)
+	require.Len(t, records2, 2)
+
+	id := records1[0][fields.FlowID]
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	// same flow => same ID across requests
+	assert.Equal(t, id, records2[0][fields.FlowID])
+	assert.Equal(t, records1[1][fields.FlowID], records2[1][fields.FlowID])
+	// different flows => different IDs
+	assert.NotEqual(t, id, records1[1][fields.FlowID])
+	// original fields are preserved as is
+	assert.Equal(t, 1680000000000.0, records1[0]["TimeFlowStartMs"])
+}
+
+func TestGetFlows_BitsPerSecond(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			{Timestamp: time.Now(), Line: `{"Bytes":1000,"TimeFlowStartMs":1680000000000,"TimeFlowEndMs":1680000000500}`},
+			// zero duration => undefined
+			{Timestamp: time.Now(), Line: `{"Bytes":66,"TimeFlowStartMs":1680000000000,"TimeFlowEndMs":1680000000000}`},
+			// missing end => undefined
+			{Timestamp: time.Now(), Line: `{"Bytes":66,"TimeFlowStartMs":1680000000000}`},
+		},
+	}})
+
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 3)
+	// 1000 bytes in 500ms
+	assert.Equal(t, 16000.0, records[0][fields.BitsPerSecond])
+	assert.NotContains(t, records[1], fields.BitsPerSecond)
+	assert.NotContains(t, records[2], fields.BitsPerSecond)
+}
+
+func TestGetFlows_RetransmitRate(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			{Timestamp: time.Now(), Line: `{"Packets":200,"TcpRetransmits":5}`},
+			// no packet => undefined
+			{Timestamp: time.Now(), Line: `{"Packets":0,"TcpRetransmits":0}`},
+			// no counter => undefined
+			{Timestamp: time.Now(), Line: `{"Packets":10}`},
+		},
+	}})
+
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 3)
+	assert.Equal(t, 0.025, records[0][fields.TCPRetransmitRate])
+	assert.NotContains(t, records[1], fields.TCPRetransmitRate)
+	assert.NotContains(t, records[2], fields.TCPRetransmitRate)
+}
+
+func TestGetFlows_Truncation(t *testing.T) {
+	streams := model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			{Timestamp: time.Now(), Line: `{"DnsName":"a-very-long-name.example.com","SrcK8S_Name":"short","Bytes":123456789}`},
+			{Timestamp: time.Now(), Line: `{"DnsName":"ok.example","SrcK8S_Name":"éééééééééééé"}`},
+		},
+	}}
+	cfg := testLokiConfig
+	cfg.MaxStringFieldLength = 10
+
+	qr, _, err := getFlows(context.Background(), &cfg, mockStreamsResponse(t, streams), url.Values{})
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 2)
+	assert.Equal(t, "a-very-lon…", records[0]["DnsName"])
+	assert.Equal(t, "short", records[0]["SrcK8S_Name"])
+	// numbers are left untouched
+	assert.Equal(t, 123456789.0, records[0]["Bytes"])
+	assert.Equal(t, true, records[0][fields.Truncated])
+	// truncation is rune based
+	assert.Equal(t, "éééééééééé…", records[1]["SrcK8S_Name"])
+	assert.Equal(t, "ok.example", records[1]["DnsName"])
+
+	// untruncated when not needed
+	streams[0].Entries = streams[0].Entries[:1]
+	streams[0].Entries[0].Line = `{"DnsName":"ok.example"}`
+	qr, _, err = getFlows(context.Background(), &cfg, mockStreamsResponse(t, streams), url.Values{})
+	require.NoError(t, err)
+	records = getRecords(t, qr)
+	assert.Equal(t, "ok.example", records[0]["DnsName"])
+	assert.NotContains(t, records[0], fields.Truncated)
+
+	// full values on demand
+	streams[0].Entries[0].Line = `{"DnsName":"a-very-long-name.example.com"}`
+	qr, _, err = getFlows(context.Background(), &cfg, mockStreamsResponse(t, streams), url.Values{truncateKey: {"false"}})
+	require.NoError(t, err)
+	records = getRecords(t, qr)
+	assert.Equal(t, "a-very-long-name.example.com", records[0]["DnsName"])
+}
+
+func TestGetFlows_DSCPNames(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			{Timestamp: time.Now(), Line: `{"Dscp":46}`},
+			{Timestamp: time.Now(), Line: `{"Dscp":0}`},
+			// not a standard class
+			{Timestamp: time.Now(), Line: `{"Dscp":3}`},
+			{Timestamp: time.Now(), Line: `{"Bytes":10}`},
+		},
+	}})
+
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 4)
+	assert.Equal(t, "EF", records[0][fields.DSCPName])
+	assert.Equal(t, "CS0", records[1][fields.DSCPName])
+	assert.NotContains(t, records[2], fields.DSCPName)
+	assert.NotContains(t, records[3], fields.DSCPName)
+}
+
+func TestGetFlows_Projection(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			{Timestamp: time.Now(), Line: `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","Bytes":1000,"TimeFlowStartMs":1000,"TimeFlowEndMs":2000}`},
+		},
+	}})
+
+	params := url.Values{}
+	params.Set(fieldsKey, "SrcAddr, Bytes,"+fields.BitsPerSecond)
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	records := getRecords(t, qr)
+	require.Len(t, records, 1)
+	// computed fields can be projected, as processors run before
+	assert.Equal(t, map[string]interface{}{"SrcAddr": "10.0.0.1", "Bytes": 1000.0, fields.BitsPerSecond: 8000.0}, records[0])
+
+	params.Set(fieldsKey, "SrcAddr}")
+	_, code, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.Error(t, err)
+	assert.Equal(t, 400, code)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+// parseFieldNames parses a comma-separated list of field names
+func parseFieldNames(raw string) ([]string, error) {
+	var names []string
+	for _, f := range strings.Split(raw, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		if !fieldNameValidation.MatchString(f) {
+			return nil, fmt.Errorf("invalid field in %s parameter: %q", fieldsKey, f)
+		}
+		names = append(names, f)
+	}
+	return names, nil
+}
+
+// projectFields returns a processor that keeps only the given fields in the records,
+// to reduce the payload of views displaying a few columns
+func projectFields(keys []string) flowProcessor {
+	keep := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		keep[k] = struct{}{}
+	}
+	return func(_ *model.Entry, record *flowRecord) {
+		for k := range record.fields {
+			if _, ok := keep[k]; !ok {
+				delete(record.fields, k)
+			}
+		}
+	}
+}
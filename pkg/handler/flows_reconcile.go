@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+const (
+	reconcileKey = "reconcile"
+
+	// ingress (destination reporter) and egress (source reporter) flow directions
+	directionIngress = "0"
+	directionEgress  = "1"
+)
+
+// ReconcileStrategy defines how to merge the same flow reported by both source and destination,
+// when byte counts differ (e.g. due to drops between them)
+type ReconcileStrategy string
+
+const (
+	ReconcileKeepBoth        ReconcileStrategy = "keep-both"
+	ReconcileMax             ReconcileStrategy = "max"
+	ReconcileSum             ReconcileStrategy = "sum"
+	ReconcileSourceWins      ReconcileStrategy = "source-wins"
+	ReconcileDestinationWins ReconcileStrategy = "destination-wins"
+)
+
+func parseReconcileStrategy(s string) (ReconcileStrategy, error) {
+	switch st := ReconcileStrategy(s); st {
+	case ReconcileKeepBoth, ReconcileMax, ReconcileSum, ReconcileSourceWins, ReconcileDestinationWins:
+		return st, nil
+	case "":
+		return ReconcileKeepBoth, nil
+	}
+	return "", fmt.Errorf("unknown reconciliation strategy: %q", s)
+}
+
+// reconciliationFields identify the same flow observed by both reporters
+var reconciliationFields = []string{
+	fields.SrcAddr,
+	fields.DstAddr,
+	fields.SrcPort,
+	fields.DstPort,
+	fields.Proto,
+}
+
+type observation struct {
+	entry     *model.Entry
+	record    flowRecord
+	direction string
+}
+
+// reconcileFlows merges the observations of a same flow from the source and destination reporters,
+// according to the strategy. Observation start times are compared at the second, since reporters clocks and
+// capture times differ slightly. When dedupInterfaces is set, only the first observation of each side is kept, the
+// others being the same flow seen on other interfaces of the node (e.g. veth and physical interfaces).
+// It returns the streams without the discarded observations and their count.
+func reconcileFlows(streams model.Streams, strategy ReconcileStrategy, dedupInterfaces bool) (model.Streams, int, error) {
+	if strategy == ReconcileKeepBoth && !dedupInterfaces {
+		return streams, 0, nil
+	}
+	groups := map[string][]*observation{}
+	var order []string
+	for i := range streams {
+		stream := &streams[i]
+		for j := range stream.Entries {
+			entry := &stream.Entries[j]
+			decoder := json.NewDecoder(bytes.NewReader([]byte(entry.Line)))
+			decoder.UseNumber()
+			obs := observation{entry: entry, record: flowRecord{labels: stream.Labels}}
+			if err := decoder.Decode(&obs.record.fields); err != nil {
+				return nil, 0, fmt.Errorf("cannot unmarshal line %s: %w", entry.Line, err)
+			}
+			obs.direction = obs.record.getString(fields.FlowDirection)
+			if obs.direction != directionIngress && obs.direction != directionEgress {
+				continue
+			}
+			key := reconciliationKey(&obs.record)
+			if _, exists := groups[key]; !exists {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], &obs)
+		}
+	}
+
+	discarded := map[*model.Entry]struct{}{}
+	for _, key := range order {
+		group := groups[key]
+		winner, losers, dropped := pickReconciled(group, strategy, dedupInterfaces)
+		for _, d := range dropped {
+			discarded[d.entry] = struct{}{}
+		}
+		if winner == nil || len(losers) == 0 {
+			continue
+		}
+		if strategy == ReconcileMax || strategy == ReconcileSum {
+			for _, f := range []string{fields.Bytes, fields.Packets} {
+				total, _ := winner.record.getFloat(f)
+				for _, l := range losers {
+					v, _ := l.record.getFloat(f)
+					if strategy == ReconcileSum {
+						total += v
+					} else if v > total {
+						total = v
+					}
+				}
+				winner.record.fields[f] = json.Number(strconv.FormatFloat(total, 'f', -1, 64))
+			}
+			line, err := json.Marshal(winner.record.fields)
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot marshal line: %w", err)
+			}
+			winner.entry.Line = string(line)
+		}
+		for _, l := range losers {
+			discarded[l.entry] = struct{}{}
+		}
+	}
+	if len(discarded) == 0 {
+		return streams, 0, nil
+	}
+
+	reconciled := model.Streams{}
+	for i := range streams {
+		stream := streams[i]
+		entries := make([]model.Entry, 0, len(stream.Entries))
+		for j := range stream.Entries {
+			if _, ok := discarded[&streams[i].Entries[j]]; !ok {
+				entries = append(entries, stream.Entries[j])
+			}
+		}
+		if len(entries) > 0 {
+			stream.Entries = entries
+			reconciled = append(reconciled, stream)
+		}
+	}
+	return reconciled, len(discarded), nil
+}
+
+func reconciliationKey(record *flowRecord) string {
+	sb := strings.Builder{}
+	for _, f := range reconciliationFields {
+		sb.WriteString(record.getString(f))
+		sb.WriteByte(',')
+	}
+	if start, ok := record.getFloat(fields.TimeFlowStart); ok {
+		sb.WriteString(strconv.FormatInt(int64(start)/1000, 10))
+	}
+	return sb.String()
+}
+
+// pickReconciled returns the observation to keep, those of the other side to merge into it and discard, and
+// the interface duplicates to discard. Groups not observed from both sides are left untouched, apart from
+// interface duplicates.
+func pickReconciled(group []*observation, strategy ReconcileStrategy, dedupInterfaces bool) (*observation, []*observation, []*observation) {
+	var egress, ingress, dropped []*observation
+	for _, o := range group {
+		if o.direction == directionEgress {
+			egress = append(egress, o)
+		} else {
+			ingress = append(ingress, o)
+		}
+	}
+	if dedupInterfaces {
+		if len(egress) > 1 {
+			dropped = append(dropped, egress[1:]...)
+			egress = egress[:1]
+		}
+		if len(ingress) > 1 {
+			dropped = append(dropped, ingress[1:]...)
+			ingress = ingress[:1]
+		}
+	}
+	if len(egress) == 0 || len(ingress) == 0 || strategy == ReconcileKeepBoth {
+		return nil, nil, dropped
+	}
+	winners, losers := egress, ingress
+	if strategy == ReconcileDestinationWins {
+		winners, losers = ingress, egress
+	}
+	// other observations from the winning side are kept as is
+	return winners[0], losers, dropped
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func conflictingStreams() model.Streams {
+	ts := time.Unix(1680000000, 0)
+	return model.Streams{{
+		Labels: map[string]string{"FlowDirection": "1"},
+		Entries: []model.Entry{
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","SrcPort":42000,"DstPort":443,"Proto":6,"Bytes":1000,"Packets":10,"TimeFlowStartMs":1680000000100}`},
+			// not seen by the destination
+			{Timestamp: ts, Line: `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.3","SrcPort":42001,"DstPort":443,"Proto":6,"Bytes":50,"Packets":1,"TimeFlowStartMs":1680000000100}`},
+		},
+	}, {
+		Labels: map[string]string{"FlowDirection": "0"},
+		Entries: []model.Entry{
+			{Timestamp: ts.Add(time.Millisecond), Line: `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","SrcPort":42000,"DstPort":443,"Proto":6,"Bytes":800,"Packets":8,"TimeFlowStartMs":1680000000150}`},
+		},
+	}}
+}
+
+func TestGetFlows_Reconcile(t *testing.T) {
+	for _, tc := range []struct {
+		strategy   string
+		records    int
+		reconciled int
+		bytes      float64
+		packets    float64
+	}{
+		{strategy: "keep-both", records: 3, reconciled: 0, bytes: 1000, packets: 10},
+		{strategy: "max", records: 2, reconciled: 1, bytes: 1000, packets: 10},
+		{strategy: "sum", records: 2, reconciled: 1, bytes: 1800, packets: 18},
+		{strategy: "source-wins", records: 2, reconciled: 1, bytes: 1000, packets: 10},
+		{strategy: "destination-wins", records: 2, reconciled: 1, bytes: 800, packets: 8},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			params := url.Values{}
+			params.Set(reconcileKey, tc.strategy)
+			qr, _, err := getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, conflictingStreams()), params)
+			require.NoError(t, err)
+			assert.Equal(t, tc.reconciled, qr.Stats.Reconciled)
+
+			records := getRecords(t, qr)
+			require.Len(t, records, tc.records)
+			var found bool
+			for _, r := range records {
+				if r["DstAddr"] == "10.0.0.2" {
+					found = true
+					assert.Equal(t, tc.bytes, r["Bytes"])
+					assert.Equal(t, tc.packets, r["Packets"])
+					break
+				}
+			}
+			assert.True(t, found)
+		})
+	}
+}
+
+func TestGetFlows_ReconcileSingleReporter(t *testing.T) {
+	params := url.Values{}
+	params.Set(reconcileKey, "sum")
+	params.Set(reporterKey, "source")
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, conflictingStreams()), params)
+	require.NoError(t, err)
+	assert.Zero(t, qr.Stats.Reconciled)
+	assert.Len(t, getRecords(t, qr), 3)
+
+	params.Set(reconcileKey, "average")
+	_, _, err = getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, conflictingStreams()), params)
+	require.Error(t, err)
+}
+
+func TestGetFlows_ReporterBoth(t *testing.T) {
+	streams := conflictingStreams()
+	// same flow seen on another interface of the source node
+	streams[0].Entries = append(streams[0].Entries, model.Entry{
+		Timestamp: streams[0].Entries[0].Timestamp,
+		Line:      `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","SrcPort":42000,"DstPort":443,"Proto":6,"Bytes":1000,"Packets":10,"TimeFlowStartMs":1680000000110,"Interface":"br-ex"}`,
+	})
+
+	params := url.Values{}
+	params.Set(reporterKey, "both")
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, streams), params)
+	require.NoError(t, err)
+	// the interface duplicate and the destination observation are collapsed into the first source observation,
+	// the flow only seen by the source is kept
+	assert.Equal(t, 2, qr.Stats.Reconciled)
+	records := getRecords(t, qr)
+	require.Len(t, records, 2)
+	assert.Equal(t, "10.0.0.2", records[0]["DstAddr"])
+	assert.Equal(t, 1000.0, records[0]["Bytes"])
+	assert.NotContains(t, records[0], "Interface")
+	assert.Equal(t, "10.0.0.3", records[1]["DstAddr"])
+
+	// both sides kept when asked, interface duplicates still collapsed
+	params.Set(reconcileKey, "keep-both")
+	qr, _, err = getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, streams), params)
+	require.NoError(t, err)
+	assert.Equal(t, 1, qr.Stats.Reconciled)
+	assert.Len(t, getRecords(t, qr), 3)
+
+	// all observations without reporter
+	qr, _, err = getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, streams), url.Values{})
+	require.NoError(t, err)
+	assert.Zero(t, qr.Stats.Reconciled)
+	assert.Len(t, getRecords(t, qr), 4)
+}
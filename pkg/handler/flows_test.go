@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func mockStreamsResponse(t *testing.T, streams model.Streams) *httpclienttest.HTTPClientMock {
+	resp, err := json.Marshal(model.QueryResponse{
+		Status: "success",
+		Data: model.QueryResponseData{
+			ResultType: model.ResultTypeStream,
+			Result:     streams,
+		},
+	})
+	require.NoError(t, err)
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.Anything).Return(resp, 200, nil)
+	return lokiClientMock
+}
+
+func TestGetFlows_IngestionLag(t *testing.T) {
+	end := time.Now().Add(-time.Minute).Truncate(time.Second)
+	newest := end.Add(-42 * time.Second)
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			{Timestamp: newest.Add(-10 * time.Second), Line: "{}"},
+			{Timestamp: newest, Line: "{}"},
+		},
+	}})
+
+	params := url.Values{}
+	params.Set(endTimeKey, strconv.FormatInt(end.Unix(), 10))
+	qr, code, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	require.NotNil(t, qr.IngestionLagMs)
+	assert.Equal(t, int64(42000), *qr.IngestionLagMs)
+}
+
+func TestGetFlows_IngestionLagNoData(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	assert.Nil(t, qr.IngestionLagMs)
+}
+
+func TestGetFlows_GroupStats(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	respond := func(ns string, count int) []byte {
+		stream := model.Stream{Labels: map[string]string{"SrcK8S_Namespace": ns}}
+		for i := 0; i < count; i++ {
+			stream.Entries = append(stream.Entries, model.Entry{Timestamp: time.Unix(int64(1680000000+i), 0), Line: `{"Bytes":` + strconv.Itoa(i) + `}`})
+		}
+		resp, err := json.Marshal(model.QueryResponse{Data: model.QueryResponseData{ResultType: model.ResultTypeStream, Result: model.Streams{stream}}})
+		require.NoError(t, err)
+		return resp
+	}
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool {
+		return strings.Contains(u, "SrcK8S_Namespace=~\"(?i).*big.*\"")
+	})).Return(respond("big", 5), 200, nil)
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool {
+		return strings.Contains(u, "SrcK8S_Namespace=~\"(?i).*small.*\"")
+	})).Return(respond("small", 1), 200, nil)
+
+	params := url.Values{}
+	params.Set(limitKey, "5")
+	params.Set(filtersKey, "SrcK8S_Namespace=small|SrcK8S_Namespace=big")
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+
+	// groups are reported in the order of the filters
+	assert.Equal(t, []model.GroupStats{
+		{Returned: 1, Included: 1, LimitReached: false},
+		{Returned: 5, Included: 5, LimitReached: true},
+	}, qr.Stats.Groups)
+}
+
+func TestGetFlows_ExcludeZeroBytes(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	params := url.Values{}
+	params.Set(excludeZeroBytesKey, "true")
+	params.Set(filtersKey, "SrcK8S_Namespace=a|DstK8S_Namespace=b")
+	_, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+	for _, call := range lokiClientMock.Calls {
+		assert.Contains(t, call.Arguments.String(0), "|json|Bytes>0")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Unix(1680000000, 0)
+	for value, expected := range map[string]int64{
+		"1679990000": 1679990000,
+		"now":        1680000000,
+		"now-15m":    1680000000 - 15*60,
+		"now-1h30m":  1680000000 - 90*60,
+		"now+30s":    1680000030,
+		"now-2d":     1680000000 - 2*86400,
+		"now-1w":     1680000000 - 7*86400,
+		"now-0m":     1680000000,
+		"now-1500ms": 1679999998,
+		// RFC3339, with offsets and fractional seconds
+		"2023-03-28T10:40:00Z":             1680000000,
+		"2023-03-28T12:40:00+02:00":        1680000000,
+		"2023-03-28T12:40:00.999999+02:00": 1680000000,
+		"2023-03-28T12:40:00 02:00":        1680000000,
+	} {
+		actual, err := parseTime(value, now)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, actual, value)
+	}
+	for _, value := range []string{"", "yesterday", "now-", "now15m", "now-15", "now-1.5d", "now--1h", "nowish", "2023-03-28T10:40:00", "2023-03-28"} {
+		_, err := parseTime(value, now)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestGetTimeWindow_RFC3339Rounding(t *testing.T) {
+	params := url.Values{startTimeKey: {"2023-03-28T10:40:00.5Z"}, endTimeKey: {"2023-03-28T10:50:00.5Z"}}
+	start, err := getStartTime(params)
+	require.NoError(t, err)
+	assert.Equal(t, "1680000000", start)
+	end, err := getEndTime(params)
+	require.NoError(t, err)
+	assert.Equal(t, "1680000601", end)
+}
+
+func TestGetTimeRelative(t *testing.T) {
+	params := url.Values{}
+	params.Set(startTimeKey, "now-1h")
+	params.Set(endTimeKey, "now")
+	start, err := getStartTime(params)
+	require.NoError(t, err)
+	end, err := getEndTime(params)
+	require.NoError(t, err)
+	s, _ := strconv.ParseInt(start, 10, 64)
+	e, _ := strconv.ParseInt(end, 10, 64)
+	// end is ceiled to the next second
+	assert.Equal(t, int64(3601), e-s)
+}
+
+func TestWriteJSONStreams(t *testing.T) {
+	var streams model.Streams
+	for i := 0; i < 3; i++ {
+		stream := model.Stream{Labels: map[string]string{"SrcK8S_Namespace": "ns" + strconv.Itoa(i)}}
+		for j := 0; j < streamFlushEntries; j++ {
+			stream.Entries = append(stream.Entries, model.Entry{Timestamp: time.Unix(int64(1680000000+j), 0), Line: `{"Bytes":` + strconv.Itoa(j) + `}`})
+		}
+		streams = append(streams, stream)
+	}
+	for _, qr := range []*model.AggregatedQueryResponse{
+		{ResultType: model.ResultTypeStream, Result: streams, Warnings: []string{"deprecated"}, NextCursor: "abc"},
+		{ResultType: model.ResultTypeStream, Result: model.Streams{}},
+	} {
+		expected, err := json.Marshal(qr)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		writeJSONStreams(rec, 200, qr)
+		assert.Equal(t, 200, rec.Code)
+		// flushed when large enough
+		assert.Equal(t, len(qr.Result.(model.Streams)) > 0, rec.Flushed)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, string(expected), rec.Body.String())
+	}
+}
+
+func TestGetFlows_RestrictedMaxLimit(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.RestrictedMaxLimit = 100
+	restricted := auth.WithAllowedNamespaces(context.Background(), []string{"a"})
+
+	for _, limit := range []string{"", "500"} {
+		lokiClientMock := mockStreamsResponse(t, model.Streams{})
+		params := url.Values{}
+		params.Set(limitKey, limit)
+		_, _, err := getFlows(restricted, &cfg, lokiClientMock, params)
+		require.NoError(t, err)
+		for _, call := range lokiClientMock.Calls {
+			assert.Contains(t, call.Arguments.String(0), "limit=100")
+		}
+	}
+
+	// lower limits and unrestricted users are kept
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	_, _, err := getFlows(restricted, &cfg, lokiClientMock, url.Values{limitKey: {"50"}})
+	require.NoError(t, err)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), "limit=50")
+	lokiClientMock = mockStreamsResponse(t, model.Streams{})
+	_, _, err = getFlows(context.Background(), &cfg, lokiClientMock, url.Values{limitKey: {"500"}})
+	require.NoError(t, err)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), "limit=500")
+}
+
+func TestExportFlows_DisabledForRestricted(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.RestrictedDisableExport = true
+
+	req := httptest.NewRequest(http.MethodGet, "/api/loki/export?format=csv", nil)
+	req = req.WithContext(auth.WithAllowedNamespaces(req.Context(), []string{"a"}))
+	w := httptest.NewRecorder()
+	ExportFlows(&cfg)(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), string(ErrorCodeForbidden))
+}
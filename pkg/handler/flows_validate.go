@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+// ValidateFlows is a dry-run of GetFlows: it returns the Loki queries the request would run, or the reasons
+// why it is invalid, without querying Loki
+func ValidateFlows(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("ValidateFlows", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		warnings := migrateDeprecatedParams(params)
+		writeWarningHeaders(w, warnings)
+		hlog.WithContext(r.Context()).Debugf("ValidateFlows query params: %s", params)
+
+		reqCfg, code, err := withLabelsOverride(cfg, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+
+		validation := validateFlows(r.Context(), reqCfg, params)
+		if code, err := checkTimeWindow(cfg, params, r.Header); err != nil {
+			validation.Valid = false
+			validation.Errors = append(validation.Errors, validationError(code, err, "", nil, ""))
+		}
+		validation.Warnings = append(warnings, validation.Warnings...)
+
+		code = http.StatusOK
+		writeJSON(w, code, validation)
+	}
+}
+
+func validateFlows(ctx context.Context, cfg *loki.Config, params url.Values) *model.FlowsValidation {
+	validation := model.FlowsValidation{Valid: true, Queries: []string{}}
+	fail := func(code int, err error, param string, group *int, filter string) *model.FlowsValidation {
+		validation.Valid = false
+		validation.Errors = append(validation.Errors, validationError(code, err, param, group, filter))
+		return &validation
+	}
+
+	params = withQueryDefaults(cfg, params)
+	start, err := getStartTime(params)
+	if err != nil {
+		fail(http.StatusBadRequest, err, startTimeKey, nil, "")
+	}
+	end, err := getEndTime(params)
+	if err != nil {
+		fail(http.StatusBadRequest, err, endTimeKey, nil, "")
+	}
+	end, _ = applyIngestionDelay(cfg, end)
+	limit, _, err := getLimit(params)
+	if err != nil {
+		fail(http.StatusBadRequest, err, limitKey, nil, "")
+	}
+	filterGroups, code, err := preprocessFilters(ctx, cfg, params)
+	if err != nil {
+		return fail(code, err, filtersKey, nil, "")
+	}
+	namespaceLimit, err := getNamespaceLimit(params.Get(namespaceLimitKey))
+	if err != nil {
+		return fail(http.StatusBadRequest, err, namespaceLimitKey, nil, "")
+	}
+	if namespaceLimit > 0 {
+		filterGroups = splitByNamespace(filterGroups)
+		limit = strconv.Itoa(namespaceLimit)
+	}
+	if !validation.Valid {
+		return &validation
+	}
+
+	reporter := constants.Reporter(params.Get(reporterKey))
+	recordType := constants.RecordType(params.Get(recordTypeKey))
+	excludeZeroBytes := params.Get(excludeZeroBytesKey) == "true"
+	newBuilder := func() *loki.FlowQueryBuilder {
+		qb := loki.NewFlowQueryBuilder(cfg, start, end, limit, reporter, recordType)
+		if excludeZeroBytes {
+			qb.ExcludeZeroBytes()
+		}
+		return qb
+	}
+	if len(filterGroups) == 0 {
+		validation.Queries = append(validation.Queries, newBuilder().Build())
+	}
+	for i, group := range filterGroups {
+		qb := newBuilder()
+		if err := qb.Filters(group); err != nil {
+			index := i
+			fail(http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err), filtersKey, &index, findInvalidFilter(newBuilder, group))
+			continue
+		}
+		validation.Queries = append(validation.Queries, qb.Build())
+	}
+	if validation.Valid {
+		validation.Warnings = lintQuery(cfg, filterGroups, start, end, nil)
+	} else {
+		validation.Queries = []string{}
+	}
+	return &validation
+}
+
+// findInvalidFilter returns the key of the first filter of the group that can't be built on its own
+func findInvalidFilter(newBuilder func() *loki.FlowQueryBuilder, group filters.SingleQuery) string {
+	for _, m := range group {
+		if err := newBuilder().Filters(filters.SingleQuery{m}); err != nil {
+			return m.Key
+		}
+	}
+	return ""
+}
+
+func validationError(code int, err error, param string, group *int, filter string) model.ValidationError {
+	return model.ValidationError{
+		Code:    string(getErrorCode(code, err)),
+		Message: err.Error(),
+		Param:   param,
+		Group:   group,
+		Filter:  filter,
+	}
+}
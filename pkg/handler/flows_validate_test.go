@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFlows(t *testing.T) {
+	params := url.Values{}
+	params.Set(startTimeKey, "1000")
+	params.Set(limitKey, "50")
+	params.Set(filtersKey, "SrcK8S_Namespace=a|DstPort=53")
+	validation := validateFlows(context.Background(), &testLokiConfig, params)
+	assert.True(t, validation.Valid)
+	assert.Empty(t, validation.Errors)
+	require.Len(t, validation.Queries, 2)
+	assert.Contains(t, validation.Queries[0], `SrcK8S_Namespace=~`)
+	assert.Contains(t, validation.Queries[1], `DstPort`)
+	assert.Contains(t, validation.Queries[1], "limit=50")
+}
+
+func TestValidateFlows_Errors(t *testing.T) {
+	// invalid parameters are all reported
+	params := url.Values{}
+	params.Set(startTimeKey, "yesterday")
+	params.Set(limitKey, "many")
+	validation := validateFlows(context.Background(), &testLokiConfig, params)
+	assert.False(t, validation.Valid)
+	assert.Empty(t, validation.Queries)
+	require.Len(t, validation.Errors, 2)
+	assert.Equal(t, startTimeKey, validation.Errors[0].Param)
+	assert.Equal(t, limitKey, validation.Errors[1].Param)
+	assert.Equal(t, string(ErrorCodeInvalidRequest), validation.Errors[0].Code)
+
+	// invalid filter is located
+	params = url.Values{}
+	params.Set(filtersKey, "SrcK8S_Namespace=a|DstK8S_Name=b&Bytes>lots")
+	validation = validateFlows(context.Background(), &testLokiConfig, params)
+	assert.False(t, validation.Valid)
+	require.Len(t, validation.Errors, 1)
+	assert.Equal(t, string(ErrorCodeInvalidFilter), validation.Errors[0].Code)
+	require.NotNil(t, validation.Errors[0].Group)
+	assert.Equal(t, 1, *validation.Errors[0].Group)
+	assert.Equal(t, "Bytes", validation.Errors[0].Filter)
+	assert.Contains(t, validation.Errors[0].Message, "invalid number")
+}
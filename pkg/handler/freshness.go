@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+func GetLatestFlowTime(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("GetLatestFlowTime", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		writeWarningHeaders(w, migrateDeprecatedParams(params))
+		hlog.WithContext(r.Context()).Debugf("GetLatestFlowTime query params: %s", params)
+
+		freshness, code, err := getLatestFlowTime(r.Context(), cfg, lokiClient, params)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+
+		code = http.StatusOK
+		writeJSON(w, code, freshness)
+	}
+}
+
+// getLatestFlowTime runs a minimal query (limit=1, backward) to find the most recent flow, optionally filtered
+func getLatestFlowTime(ctx context.Context, cfg *loki.Config, client httpclient.Caller, params url.Values) (*model.FreshnessResponse, int, error) {
+	start, err := getStartTime(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	end, err := getEndTime(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	reporter := constants.Reporter(params.Get(reporterKey))
+	recordType := constants.RecordType(params.Get(recordTypeKey))
+	filterGroups, code, err := preprocessFilters(ctx, cfg, params)
+	if err != nil {
+		return nil, code, err
+	}
+
+	var queries []string
+	for _, group := range filterGroups {
+		qb := loki.NewFlowQueryBuilder(cfg, start, end, "1", reporter, recordType)
+		qb.Backward()
+		if err := qb.Filters(group); err != nil {
+			return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, errors.New("Can't build query: "+err.Error()))
+		}
+		queries = append(queries, qb.Build())
+	}
+	merger := loki.NewStreamMerger(1)
+	if len(queries) > 1 {
+		if code, err := fetchParallel(ctx, cfg, client, queries, merger); err != nil {
+			return nil, code, err
+		}
+	} else if code, err := fetchSingle(ctx, client, queries[0], merger); err != nil {
+		return nil, code, err
+	}
+
+	var latest time.Time
+	if streams, ok := merger.Get().Result.(model.Streams); ok {
+		for _, stream := range streams {
+			for _, entry := range stream.Entries {
+				if entry.Timestamp.After(latest) {
+					latest = entry.Timestamp
+				}
+			}
+		}
+	}
+	if latest.IsZero() {
+		return nil, http.StatusNotFound, errors.New("no flow found in the requested time window")
+	}
+	return &model.FreshnessResponse{
+		Timestamp: latest.UnixMilli(),
+		AgeMs:     time.Since(latest).Milliseconds(),
+	}, http.StatusOK, nil
+}
+
+const (
+	lookbackKey = "lookback"
+	maxLagKey   = "maxLag"
+	// defaultIngestionLookback is how far back the most recent flow is searched
+	defaultIngestionLookback = time.Hour
+	// defaultIngestionMaxLag is the age of the most recent flow above which ingestion is considered stale
+	defaultIngestionMaxLag = 2 * time.Minute
+)
+
+func GetIngestionStatus(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("GetIngestionStatus", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		hlog.WithContext(r.Context()).Debugf("GetIngestionStatus query params: %s", params)
+
+		status, code, err := getIngestionStatus(r.Context(), cfg, lokiClient, params)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+
+		code = http.StatusOK
+		writeJSON(w, code, status)
+	}
+}
+
+// getIngestionStatus looks for the most recent flow within the lookback, to tell apart a stopped pipeline from
+// filters matching nothing
+func getIngestionStatus(ctx context.Context, cfg *loki.Config, client httpclient.Caller, params url.Values) (*model.IngestionStatus, int, error) {
+	lookback, err := getDurationParam(params, lookbackKey, defaultIngestionLookback)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	maxLag, err := getDurationParam(params, maxLagKey, defaultIngestionMaxLag)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	status := model.IngestionStatus{
+		MaxLagMs:   maxLag.Milliseconds(),
+		LookbackMs: lookback.Milliseconds(),
+	}
+
+	latestParams := url.Values{}
+	latestParams.Set(timeRangeKey, strconv.FormatInt(int64(lookback.Seconds()), 10))
+	freshness, code, err := getLatestFlowTime(ctx, cfg, client, latestParams)
+	if code == http.StatusNotFound {
+		status.Status = model.IngestionNone
+		return &status, http.StatusOK, nil
+	} else if err != nil {
+		return nil, code, err
+	}
+	status.LatestTimestamp = freshness.Timestamp
+	status.LagMs = freshness.AgeMs
+	if time.Duration(freshness.AgeMs)*time.Millisecond > maxLag {
+		status.Status = model.IngestionStale
+	} else {
+		status.Status = model.IngestionOK
+	}
+	return &status, http.StatusOK, nil
+}
+
+func getDurationParam(params url.Values, key string, defaultValue time.Duration) (time.Duration, error) {
+	raw := params.Get(key)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s parameter: %q", key, raw)
+	}
+	return d, nil
+}
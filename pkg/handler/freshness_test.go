@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func TestGetLatestFlowTime(t *testing.T) {
+	latest := time.Now().Add(-10 * time.Second).Truncate(time.Millisecond)
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels:  map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{{Timestamp: latest, Line: `{"Bytes":10}`}},
+	}})
+
+	params := url.Values{}
+	params.Set(filtersKey, `SrcK8S_Namespace="ns"`)
+	freshness, code, err := getLatestFlowTime(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, latest.UnixMilli(), freshness.Timestamp)
+	assert.GreaterOrEqual(t, freshness.AgeMs, int64(10000))
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+	assert.Equal(t,
+		`http://loki/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace="ns"}&limit=1&direction=backward`,
+		lokiClientMock.Calls[0].Arguments.String(0))
+}
+
+func TestGetLatestFlowTime_NoData(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	_, code, err := getLatestFlowTime(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestGetIngestionStatus(t *testing.T) {
+	latest := time.Now().Add(-5 * time.Minute).Truncate(time.Millisecond)
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels:  map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{{Timestamp: latest, Line: `{"Bytes":10}`}},
+	}})
+
+	status, code, err := getIngestionStatus(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, model.IngestionStale, status.Status)
+	assert.Equal(t, latest.UnixMilli(), status.LatestTimestamp)
+	assert.GreaterOrEqual(t, status.LagMs, int64(300000))
+	assert.Equal(t, int64(120000), status.MaxLagMs)
+	assert.Equal(t, int64(3600000), status.LookbackMs)
+
+	params := url.Values{}
+	params.Set(maxLagKey, "10m")
+	status, _, err = getIngestionStatus(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Equal(t, model.IngestionOK, status.Status)
+
+	params.Set(lookbackKey, "-1h")
+	_, code, err = getIngestionStatus(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGetIngestionStatus_NoData(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	status, code, err := getIngestionStatus(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, model.IngestionNone, status.Status)
+	assert.Zero(t, status.LatestTimestamp)
+}
@@ -1,10 +1,19 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 )
 
 type QuickFilter struct {
@@ -22,38 +31,170 @@ type frontendConfig struct {
 	QuickFilters    []QuickFilter `yaml:"quickFilters" json:"quickFilters"`
 	AlertNamespaces []string      `yaml:"alertNamespaces" json:"alertNamespaces"`
 	Sampling        int           `yaml:"sampling" json:"sampling"`
+	// FilterPresets can be applied by name, the backend expanding them, see expandPresets
+	FilterPresets []FilterPreset `yaml:"filterPresets" json:"filterPresets"`
+	// Features lists the collected features, e.g. dnsTracking, packetDrop or flowRTT. The backend adds
+	// conversationTracking when connection records are enabled and multiCluster when flows are labelled by cluster.
+	Features []string `yaml:"features" json:"features"`
+	// DefaultLimit is the number of flows shown by default, overridden by the backend query defaults
+	DefaultLimit int `yaml:"defaultLimit,omitempty" json:"defaultLimit,omitempty"`
+	// MaxQuerySpanSeconds and MaxQueryLookbackSeconds are the backend caps of the time range, 0 meaning no cap
+	MaxQuerySpanSeconds     int64 `yaml:"-" json:"maxQuerySpanSeconds,omitempty"`
+	MaxQueryLookbackSeconds int64 `yaml:"-" json:"maxQueryLookbackSeconds,omitempty"`
+	// RestrictedAccess tailors the config served to the users restricted to some namespaces
+	RestrictedAccess RestrictedAccess `yaml:"restrictedAccess,omitempty" json:"-"`
+	// Role, AllowedNamespaces, HiddenScopes, MaxLimit and DisableExport depend on the requesting user, see forUser
+	Role              string   `yaml:"-" json:"role"`
+	AllowedNamespaces []string `yaml:"-" json:"allowedNamespaces,omitempty"`
+	HiddenScopes      []string `yaml:"-" json:"hiddenScopes"`
+	MaxLimit          int      `yaml:"-" json:"maxLimit,omitempty"`
+	DisableExport     bool     `yaml:"-" json:"disableExport"`
 }
 
-func readConfigFile(filename string) (*frontendConfig, error) {
-	cfg := frontendConfig{
-		QuickFilters: []QuickFilter{},
-	}
+// RestrictedAccess reduces the features offered to the users who can only see the flows of some namespaces, so that
+// they aren't shown views they can't use
+type RestrictedAccess struct {
+	// HiddenScopes are the topology scopes hidden in addition to the cluster-wide ones
+	HiddenScopes []string `yaml:"hiddenScopes,omitempty"`
+	// MaxLimit caps the number of flows, 0 meaning no cap, and DisableExport forbids exports. Both are enforced by
+	// the flows, topology and export endpoints, see ReadRestrictedAccess.
+	MaxLimit      int  `yaml:"maxLimit,omitempty"`
+	DisableExport bool `yaml:"disableExport,omitempty"`
+}
+
+// ReadRestrictedAccess reads the restrictedAccess section of the frontend config file, enforced by the backend too
+func ReadRestrictedAccess(filename string) (RestrictedAccess, error) {
 	if len(filename) == 0 {
-		return &cfg, nil
+		return RestrictedAccess{}, nil
 	}
 	yamlFile, err := os.ReadFile(filename)
 	if err != nil {
-		return nil, err
+		return RestrictedAccess{}, err
+	}
+	config := struct {
+		RestrictedAccess RestrictedAccess `yaml:"restrictedAccess"`
+	}{}
+	if err := yaml.Unmarshal(yamlFile, &config); err != nil {
+		return RestrictedAccess{}, err
+	}
+	if config.RestrictedAccess.MaxLimit < 0 {
+		return RestrictedAccess{}, fmt.Errorf("invalid restricted access max limit: %d", config.RestrictedAccess.MaxLimit)
+	}
+	return config.RestrictedAccess, nil
+}
+
+const (
+	// roleAdmin users have cluster-wide access, roleViewer users are restricted to their namespaces
+	roleAdmin  = "admin"
+	roleViewer = "viewer"
+)
+
+// clusterWideScopes are the topology scopes aggregating flows across namespaces, hidden to restricted users
+var clusterWideScopes = []string{"app", "host"}
+
+func readConfigFile(filename string, lokiCfg *loki.Config) (*frontendConfig, error) {
+	cfg := frontendConfig{
+		QuickFilters:  []QuickFilter{},
+		Features:      []string{},
+		FilterPresets: []FilterPreset{},
+	}
+	if len(filename) != 0 {
+		yamlFile, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(yamlFile, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	addBackendSettings(&cfg, lokiCfg)
+	return &cfg, nil
+}
+
+// addBackendSettings completes the frontend config with what the backend configuration tells about the collected
+// flows and the allowed queries
+func addBackendSettings(cfg *frontendConfig, lokiCfg *loki.Config) {
+	for _, rt := range cfg.RecordTypes {
+		if utils.Contains(constants.AnyConnectionType, rt) {
+			cfg.Features = appendFeature(cfg.Features, "conversationTracking")
+			break
+		}
+	}
+	if lokiCfg.IsLabel(fields.ClusterName) {
+		cfg.Features = appendFeature(cfg.Features, "multiCluster")
+	}
+	if raw := lokiCfg.QueryDefaults.Get(limitKey); raw != "" {
+		if limit, err := strconv.Atoi(raw); err == nil {
+			cfg.DefaultLimit = limit
+		}
+	}
+	cfg.MaxQuerySpanSeconds = int64(lokiCfg.MaxQuerySpan.Seconds())
+	cfg.MaxQueryLookbackSeconds = int64(lokiCfg.MaxQueryLookback.Seconds())
+}
+
+func appendFeature(features []string, feature string) []string {
+	if utils.Contains(features, feature) {
+		return features
+	}
+	return append(features, feature)
+}
+
+// forUser returns the config tailored to the RBAC of the request: users restricted to some namespaces don't get
+// the cluster-wide scopes and features, and get the restricted access limits
+func forUser(ctx context.Context, cfg *frontendConfig) *frontendConfig {
+	tailored := *cfg
+	namespaces, restricted := auth.GetAllowedNamespaces(ctx)
+	if !restricted {
+		tailored.Role = roleAdmin
+		tailored.HiddenScopes = []string{}
+		return &tailored
+	}
+	tailored.Role = roleViewer
+	tailored.AllowedNamespaces = namespaces
+	tailored.HiddenScopes = append([]string{}, clusterWideScopes...)
+	for _, scope := range cfg.RestrictedAccess.HiddenScopes {
+		if !utils.Contains(tailored.HiddenScopes, scope) {
+			tailored.HiddenScopes = append(tailored.HiddenScopes, scope)
+		}
+	}
+	tailored.Features = []string{}
+	for _, feature := range cfg.Features {
+		if feature != "multiCluster" {
+			tailored.Features = append(tailored.Features, feature)
+		}
+	}
+	// alerts of other namespaces can't be read
+	tailored.AlertNamespaces = []string{}
+	for _, ns := range cfg.AlertNamespaces {
+		if utils.Contains(namespaces, ns) {
+			tailored.AlertNamespaces = append(tailored.AlertNamespaces, ns)
+		}
+	}
+	if limit := cfg.RestrictedAccess.MaxLimit; limit > 0 {
+		tailored.MaxLimit = limit
+		if tailored.DefaultLimit == 0 || tailored.DefaultLimit > limit {
+			tailored.DefaultLimit = limit
+		}
 	}
-	err = yaml.Unmarshal(yamlFile, &cfg)
-	return &cfg, err
+	tailored.DisableExport = cfg.RestrictedAccess.DisableExport
+	return &tailored
 }
 
-func GetConfig(filename string) func(w http.ResponseWriter, r *http.Request) {
-	resp, err := readConfigFile(filename)
+func GetConfig(filename string, lokiCfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	resp, err := readConfigFile(filename, lokiCfg)
 	if err != nil {
 		hlog.Errorf("Could not read config file: %v", err)
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
-			resp, err = readConfigFile(filename)
+			resp, err = readConfigFile(filename, lokiCfg)
 			if err != nil {
-				writeError(w, http.StatusInternalServerError, err.Error())
+				writeError(w, http.StatusInternalServerError, err)
 			} else {
-				writeJSON(w, http.StatusOK, resp)
+				writeJSON(w, http.StatusOK, forUser(r.Context(), resp))
 			}
 		} else {
-			writeJSON(w, http.StatusOK, resp)
+			writeJSON(w, http.StatusOK, forUser(r.Context(), resp))
 		}
 	}
 }
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils"
+)
+
+func TestGetConfig_Features(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+recordTypes: [flowLog, newConnection, endConnection]
+features: [dnsTracking, packetDrop]
+sampling: 50
+defaultLimit: 50
+`), 0600))
+	lokiCfg := loki.Config{
+		Labels:        utils.GetMapInterface([]string{"SrcK8S_Namespace", "K8S_ClusterName"}),
+		QueryDefaults: url.Values{"limit": {"100"}},
+		MaxQuerySpan:  24 * time.Hour,
+	}
+
+	w := httptest.NewRecorder()
+	GetConfig(path, &lokiCfg)(w, httptest.NewRequest(http.MethodGet, "/api/frontend-config", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var cfg map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, []interface{}{"dnsTracking", "packetDrop", "conversationTracking", "multiCluster"}, cfg["features"])
+	assert.Equal(t, float64(50), cfg["sampling"])
+	assert.Equal(t, float64(100), cfg["defaultLimit"])
+	assert.Equal(t, float64(86400), cfg["maxQuerySpanSeconds"])
+	assert.NotContains(t, cfg, "maxQueryLookbackSeconds")
+}
+
+func TestGetConfig_NoFile(t *testing.T) {
+	w := httptest.NewRecorder()
+	GetConfig("", &loki.Config{})(w, httptest.NewRequest(http.MethodGet, "/api/frontend-config", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var cfg map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, []interface{}{}, cfg["features"])
+	assert.Equal(t, []interface{}{}, cfg["quickFilters"])
+}
+
+func TestGetConfig_RestrictedUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+alertNamespaces: [netobserv, team-a]
+features: [dnsTracking]
+defaultLimit: 500
+restrictedAccess:
+  hiddenScopes: [owner, host]
+  maxLimit: 100
+  disableExport: true
+`), 0600))
+	lokiCfg := loki.Config{Labels: utils.GetMapInterface([]string{"K8S_ClusterName"})}
+	getConfig := GetConfig(path, &lokiCfg)
+
+	// cluster-wide access
+	w := httptest.NewRecorder()
+	getConfig(w, httptest.NewRequest(http.MethodGet, "/api/frontend-config", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var cfg map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, "admin", cfg["role"])
+	assert.Equal(t, []interface{}{}, cfg["hiddenScopes"])
+	assert.Equal(t, []interface{}{"dnsTracking", "multiCluster"}, cfg["features"])
+	assert.Equal(t, []interface{}{"netobserv", "team-a"}, cfg["alertNamespaces"])
+	assert.Equal(t, float64(500), cfg["defaultLimit"])
+	assert.Equal(t, false, cfg["disableExport"])
+	assert.NotContains(t, cfg, "maxLimit")
+	assert.NotContains(t, cfg, "allowedNamespaces")
+	assert.NotContains(t, cfg, "restrictedAccess")
+
+	// namespaces restriction
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/frontend-config", nil)
+	getConfig(w, req.WithContext(auth.WithAllowedNamespaces(req.Context(), []string{"team-a", "team-b"})))
+	require.Equal(t, http.StatusOK, w.Code)
+	cfg = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, "viewer", cfg["role"])
+	assert.Equal(t, []interface{}{"team-a", "team-b"}, cfg["allowedNamespaces"])
+	assert.Equal(t, []interface{}{"app", "host", "owner"}, cfg["hiddenScopes"])
+	assert.Equal(t, []interface{}{"dnsTracking"}, cfg["features"])
+	assert.Equal(t, []interface{}{"team-a"}, cfg["alertNamespaces"])
+	assert.Equal(t, float64(100), cfg["defaultLimit"])
+	assert.Equal(t, float64(100), cfg["maxLimit"])
+	assert.Equal(t, true, cfg["disableExport"])
+
+	// the shared config isn't altered
+	w = httptest.NewRecorder()
+	getConfig(w, httptest.NewRequest(http.MethodGet, "/api/frontend-config", nil))
+	cfg = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, []interface{}{"dnsTracking", "multiCluster"}, cfg["features"])
+	assert.Equal(t, float64(500), cfg["defaultLimit"])
+}
@@ -0,0 +1,128 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string     `json:"type"`
+	Geometry   Geometry   `json:"geometry"`
+	Properties Properties `json:"properties"`
+}
+
+type Geometry struct {
+	Type string `json:"type"`
+	// Coordinates follow the GeoJSON order: longitude, latitude
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type Properties struct {
+	Addr    string  `json:"addr"`
+	Bytes   float64 `json:"bytes"`
+	Packets float64 `json:"packets"`
+	Flows   int     `json:"flows"`
+}
+
+type endpoint struct {
+	addr string
+	lat  float64
+	lon  float64
+}
+
+// GetGeoJSON transforms flows into a GeoJSON FeatureCollection of endpoints, with aggregated
+// byte and packet counts. Endpoints without coordinates (not enriched by GeoIP) are skipped.
+func GetGeoJSON(qr *model.AggregatedQueryResponse) (*FeatureCollection, error) {
+	streams, ok := qr.Result.(model.Streams)
+	if !ok {
+		return nil, fmt.Errorf("loki returned an unexpected type: %T", qr.Result)
+	}
+	aggregated := map[endpoint]*Properties{}
+	for _, stream := range streams {
+		for _, entry := range stream.Entries {
+			var line map[string]interface{}
+			if err := json.Unmarshal([]byte(entry.Line), &line); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal line %s", entry.Line)
+			}
+			bytes := getNumber(line[fields.Bytes])
+			packets := getNumber(line[fields.Packets])
+			for _, prefix := range []string{fields.Src, fields.Dst} {
+				ep, ok := getEndpoint(line, prefix)
+				if !ok {
+					continue
+				}
+				props, exists := aggregated[ep]
+				if !exists {
+					props = &Properties{Addr: ep.addr}
+					aggregated[ep] = props
+				}
+				props.Bytes += bytes
+				props.Packets += packets
+				props.Flows++
+			}
+		}
+	}
+
+	endpoints := make([]endpoint, 0, len(aggregated))
+	for ep := range aggregated {
+		endpoints = append(endpoints, ep)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].addr != endpoints[j].addr {
+			return endpoints[i].addr < endpoints[j].addr
+		}
+		if endpoints[i].lat != endpoints[j].lat {
+			return endpoints[i].lat < endpoints[j].lat
+		}
+		return endpoints[i].lon < endpoints[j].lon
+	})
+
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, ep := range endpoints {
+		fc.Features = append(fc.Features, Feature{
+			Type: "Feature",
+			Geometry: Geometry{
+				Type:        "Point",
+				Coordinates: []float64{ep.lon, ep.lat},
+			},
+			Properties: *aggregated[ep],
+		})
+	}
+	return &fc, nil
+}
+
+func getEndpoint(line map[string]interface{}, prefix string) (endpoint, bool) {
+	lat, okLat := toFloat(line[prefix+fields.GeoLatitude])
+	lon, okLon := toFloat(line[prefix+fields.GeoLongitude])
+	if !okLat || !okLon {
+		return endpoint{}, false
+	}
+	addr, _ := line[prefix+fields.Addr].(string)
+	return endpoint{addr: addr, lat: lat, lon: lon}, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch c := v.(type) {
+	case float64:
+		return c, true
+	case string:
+		f, err := strconv.ParseFloat(c, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func getNumber(v interface{}) float64 {
+	n, _ := toFloat(v)
+	return n
+}
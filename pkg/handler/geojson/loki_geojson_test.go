@@ -0,0 +1,59 @@
+package geojson
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func TestGetGeoJSON(t *testing.T) {
+	qr := model.AggregatedQueryResponse{
+		ResultType: model.ResultTypeStream,
+		Result: model.Streams{{
+			Labels: map[string]string{"app": "netobserv-flowcollector"},
+			Entries: []model.Entry{{
+				Timestamp: time.Now(),
+				Line:      `{"Bytes":100,"Packets":2,"SrcAddr":"10.0.0.1","DstAddr":"8.8.8.8","DstGeo_Latitude":37.751,"DstGeo_Longitude":-97.822}`,
+			}, {
+				Timestamp: time.Now(),
+				Line:      `{"Bytes":50,"Packets":1,"SrcAddr":"10.0.0.2","DstAddr":"8.8.8.8","DstGeo_Latitude":"37.751","DstGeo_Longitude":"-97.822"}`,
+			}, {
+				Timestamp: time.Now(),
+				Line:      `{"Bytes":10,"Packets":1,"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2"}`,
+			}},
+		}},
+	}
+
+	fc, err := GetGeoJSON(&qr)
+	require.NoError(t, err)
+
+	js, err := json.Marshal(fc)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "FeatureCollection",
+		"features": [{
+			"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [-97.822, 37.751]},
+			"properties": {"addr": "8.8.8.8", "bytes": 150, "packets": 3, "flows": 2}
+		}]
+	}`, string(js))
+}
+
+func TestGetGeoJSON_NoCoordinates(t *testing.T) {
+	qr := model.AggregatedQueryResponse{
+		ResultType: model.ResultTypeStream,
+		Result: model.Streams{{
+			Entries: []model.Entry{{Timestamp: time.Now(), Line: `{"Bytes":10,"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2"}`}},
+		}},
+	}
+
+	fc, err := GetGeoJSON(&qr)
+	require.NoError(t, err)
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	assert.Empty(t, fc.Features)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+)
+
+// Healthz reports that the server is alive, without checking its dependencies
+func Healthz(w http.ResponseWriter, _ *http.Request) {
+	writeText(w, http.StatusOK, []byte("ok"))
+}
+
+// readiness caches the result of the last Loki probe
+type readiness struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	checked time.Time
+	err     error
+	now     func() time.Time
+}
+
+// Readyz reports whether Loki can be queried, probing it at most once per ttl. The probe lists the labels of the
+// last minutes, checking the URL, tenant, token and TLS settings of queries. When user tokens are forwarded, the probe
+// has no credentials and checks the Loki ready endpoint instead.
+func Readyz(cfg *loki.Config, ttl time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	ready := &readiness{ttl: ttl, now: time.Now}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := ready.check(func() error { return probeLoki(r, cfg) }); err != nil {
+			hlog.WithContext(r.Context()).WithError(err).Warn("not ready")
+			writeText(w, http.StatusServiceUnavailable, []byte(err.Error()))
+			return
+		}
+		writeText(w, http.StatusOK, []byte("ready"))
+	}
+}
+
+func (rd *readiness) check(probe func() error) error {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	now := rd.now()
+	if rd.checked.IsZero() || now.Sub(rd.checked) >= rd.ttl {
+		rd.err = probe()
+		rd.checked = now
+	}
+	return rd.err
+}
+
+func probeLoki(r *http.Request, cfg *loki.Config) error {
+	if cfg.UseMocks {
+		return nil
+	}
+	var url string
+	if cfg.ForwardUserToken {
+		url = strings.TrimRight(cfg.StatusURL.String(), "/") + "/ready"
+	} else {
+		url = fmt.Sprintf("%s/loki/api/v1/labels?start=%d", strings.TrimRight(cfg.URL.String(), "/"), time.Now().Add(-5*time.Minute).UnixNano())
+	}
+	// no inbound header: probes come from the kubelet. The probe skips the circuit breaker, admission queue and
+	// caches, so that it reports the actual Loki state rather than an open breaker or a cached result.
+	lokiClient := newBareLokiClient(cfg, lokiHeaders(r.Context(), cfg, http.Header{}), cfg.ForwardUserToken)
+	if _, _, err := executeLokiQuery(r.Context(), url, lokiClient); err != nil {
+		return fmt.Errorf("Loki probe failed: %w", err)
+	}
+	return nil
+}
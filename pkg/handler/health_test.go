@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+)
+
+func TestReadyz(t *testing.T) {
+	var paths []string
+	code := http.StatusOK
+	lokiSvc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(`{"status":"success","data":["app"]}`))
+	}))
+	defer lokiSvc.Close()
+	lokiURL, err := url.Parse(lokiSvc.URL)
+	require.NoError(t, err)
+	cfg := loki.Config{URL: lokiURL, StatusURL: lokiURL, Timeout: time.Second}
+
+	readyz := func(h func(w http.ResponseWriter, r *http.Request)) int {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+
+	// cached probe
+	h := Readyz(&cfg, time.Minute)
+	assert.Equal(t, http.StatusOK, readyz(h))
+	code = http.StatusUnauthorized
+	assert.Equal(t, http.StatusOK, readyz(h))
+	assert.Equal(t, []string{"/loki/api/v1/labels"}, paths)
+
+	// uncached probe
+	h = Readyz(&cfg, 0)
+	assert.Equal(t, http.StatusServiceUnavailable, readyz(h))
+
+	// the probe skips the circuit breaker and caches
+	paths = nil
+	cfg.QueryCache = httpclient.NewCache(time.Minute, 10)
+	cfg.CircuitBreaker = httpclient.NewCircuitBreaker(1, time.Minute)
+	h = Readyz(&cfg, 0)
+	assert.Equal(t, http.StatusServiceUnavailable, readyz(h))
+	code = http.StatusOK
+	assert.Equal(t, http.StatusOK, readyz(h))
+	code = http.StatusUnauthorized
+	assert.Equal(t, http.StatusServiceUnavailable, readyz(h))
+	assert.Len(t, paths, 3)
+	cfg.QueryCache = nil
+	cfg.CircuitBreaker = nil
+
+	// ready endpoint without user credentials
+	paths = nil
+	code = http.StatusOK
+	cfg.ForwardUserToken = true
+	assert.Equal(t, http.StatusOK, readyz(Readyz(&cfg, 0)))
+	assert.Equal(t, []string{"/ready"}, paths)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils"
+)
+
+const (
+	labelsOverrideHeader = "X-Loki-Labels-Override"
+)
+
+var labelNameValidation = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// withLabelsOverride returns the config to use for the request, with the indexed labels replaced
+// by those provided in the override header. This is meant for debugging and restricted to trusted callers.
+func withLabelsOverride(cfg *loki.Config, header http.Header) (*loki.Config, int, error) {
+	raw := header.Get(labelsOverrideHeader)
+	if raw == "" {
+		return cfg, http.StatusOK, nil
+	}
+	if !isTrustedCaller(cfg, header) {
+		return nil, http.StatusForbidden, withErrorCode(ErrorCodeForbidden, errors.New("labels override is restricted to trusted callers"))
+	}
+	labels := strings.Split(raw, ",")
+	for i := range labels {
+		labels[i] = strings.TrimSpace(labels[i])
+		if !labelNameValidation.MatchString(labels[i]) {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid label name in %s header: %q", labelsOverrideHeader, labels[i])
+		}
+	}
+	hlog.Debugf("labels overridden for request: %v", labels)
+	overridden := *cfg
+	overridden.Labels = utils.GetMapInterface(labels)
+	return &overridden, http.StatusOK, nil
+}
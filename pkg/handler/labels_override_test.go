@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func TestLabelsOverride_Routing(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.TrustedCallerTokenPath = prepareTrustedToken(t)
+	params := url.Values{filtersKey: {`SrcK8S_Namespace="ns"&SrcK8S_Name="pod"`}}
+
+	query := func(header http.Header) string {
+		reqCfg, code, err := withLabelsOverride(&cfg, header)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, code)
+		lokiClientMock := mockStreamsResponse(t, model.Streams{})
+		_, _, err = getFlows(context.Background(), reqCfg, lokiClientMock, params)
+		require.NoError(t, err)
+		return lokiClientMock.Calls[0].Arguments.String(0)
+	}
+
+	// default routing: namespace is a label, name is filtered in the pipeline
+	assert.Contains(t, query(http.Header{}), "{app=\"netobserv-flowcollector\",SrcK8S_Namespace=\"ns\"}|~`SrcK8S_Name\":\"pod\"`")
+
+	// overridden routing: the other way around
+	header := http.Header{}
+	header.Set(trustedCallerHeader, "s3cr3t")
+	header.Set(labelsOverrideHeader, "SrcK8S_Name, _RecordType")
+	assert.Contains(t, query(header), "{app=\"netobserv-flowcollector\",SrcK8S_Name=\"pod\"}|~`SrcK8S_Namespace\":\"ns\"`")
+
+	// the shared config is unchanged
+	assert.True(t, cfg.IsLabel("SrcK8S_Namespace"))
+	assert.False(t, cfg.IsLabel("SrcK8S_Name"))
+}
+
+func TestLabelsOverride_Gated(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.TrustedCallerTokenPath = prepareTrustedToken(t)
+
+	header := http.Header{}
+	header.Set(labelsOverrideHeader, "SrcK8S_Name")
+	_, code, err := withLabelsOverride(&cfg, header)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	header.Set(trustedCallerHeader, "wrong")
+	_, code, err = withLabelsOverride(&cfg, header)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	header.Set(trustedCallerHeader, "s3cr3t")
+	header.Set(labelsOverrideHeader, `SrcK8S_Name,bad"label`)
+	_, code, err = withLabelsOverride(&cfg, header)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
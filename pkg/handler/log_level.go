@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxLogLevelBody limits the size of log level requests body
+const maxLogLevelBody = 1024
+
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// LogLevel returns (GET) or changes (POST, e.g. {"level":"trace"}) the log level, without restarting
+func LogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req logLevel
+		if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxLogLevelBody)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("cannot decode log level: %w", err)))
+			return
+		}
+		lvl, err := logrus.ParseLevel(req.Level)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, err))
+			return
+		}
+		previous := logrus.GetLevel()
+		logrus.SetLevel(lvl)
+		// logged at warning level so that it shows up whatever the new level
+		hlog.Warnf("log level changed from %s to %s", previous, lvl)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, withErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("method not allowed: %s", r.Method)))
+		return
+	}
+	writeJSON(w, http.StatusOK, logLevel{Level: logrus.GetLevel().String()})
+}
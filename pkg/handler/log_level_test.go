@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLevel(t *testing.T) {
+	previous := logrus.GetLevel()
+	defer logrus.SetLevel(previous)
+	logrus.SetLevel(logrus.InfoLevel)
+
+	w := httptest.NewRecorder()
+	LogLevel(w, httptest.NewRequest(http.MethodGet, "/api/admin/loglevel", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"info"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	LogLevel(w, httptest.NewRequest(http.MethodPost, "/api/admin/loglevel", strings.NewReader(`{"level":"trace"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"trace"}`, w.Body.String())
+	assert.Equal(t, logrus.TraceLevel, logrus.GetLevel())
+
+	w = httptest.NewRecorder()
+	LogLevel(w, httptest.NewRequest(http.MethodPost, "/api/admin/loglevel", strings.NewReader(`{"level":"verbose"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, logrus.TraceLevel, logrus.GetLevel())
+
+	w = httptest.NewRecorder()
+	LogLevel(w, httptest.NewRequest(http.MethodDelete, "/api/admin/loglevel", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +20,7 @@ import (
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
 	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/tracing"
 )
 
 type LokiError struct {
@@ -31,10 +34,31 @@ const (
 	lokiOrgIDHeader = "X-Scope-OrgID"
 )
 
-func newLokiClient(cfg *loki.Config, requestHeader http.Header, useStatusConfig bool) httpclient.Caller {
+func newLokiClient(ctx context.Context, cfg *loki.Config, requestHeader http.Header, useStatusConfig bool) httpclient.Caller {
+	headers := lokiHeaders(ctx, cfg, requestHeader)
+	if cfg.UseMocks {
+		hlog.Debug("Mocking Loki Client")
+		return new(lokiclientmock.LokiClientMock)
+	}
+	client := newBareLokiClient(cfg, headers, useStatusConfig)
+	// slow queries are timed per Loki call, excluding retries and waiting in the admission queue
+	client = httpclient.WithRetries(httpclient.WithSlowQueryLog(client, cfg.SlowQueries), cfg.Retry)
+	client = withOverloadBackoff(client, cfg.OverloadBackoff)
+	if useStatusConfig {
+		return client
+	}
+	client = httpclient.WithCircuitBreaker(client, cfg.CircuitBreaker)
+	client = httpclient.WithAdmissionQueue(client, cfg.AdmissionQueue)
+	client = httpclient.WithCache(client, cfg.NegativeCache, headers)
+	return httpclient.WithScope(httpclient.WithCache(client, cfg.QueryCache, headers), headers)
+}
+
+// lokiHeaders returns the headers of the Loki requests: tenant, credentials and forwarded request headers
+func lokiHeaders(ctx context.Context, cfg *loki.Config, requestHeader http.Header) map[string][]string {
 	headers := map[string][]string{}
-	if cfg.TenantID != "" {
-		headers[lokiOrgIDHeader] = []string{cfg.TenantID}
+	tenantID := getTenantID(ctx, cfg)
+	if tenantID != "" {
+		headers[lokiOrgIDHeader] = []string{tenantID}
 	}
 
 	if cfg.ForwardUserToken {
@@ -52,15 +76,20 @@ func newLokiClient(cfg *loki.Config, requestHeader http.Header, useStatusConfig
 		headers[auth.AuthHeader] = []string{"Bearer " + string(bytes)}
 	}
 
-	if cfg.UseMocks {
-		hlog.Debug("Mocking Loki Client")
-		return new(lokiclientmock.LokiClientMock)
+	httpclient.ForwardHeaders(headers, requestHeader, cfg.ForwardedHeaders)
+	if tenantID == "" {
+		// the tenant is never selected by the client, even when the header is configured as forwarded
+		delete(headers, http.CanonicalHeaderKey(lokiOrgIDHeader))
 	}
+	return headers
+}
 
+// newBareLokiClient returns the Loki HTTP client, without retries, circuit breaker, admission queue nor caches
+func newBareLokiClient(cfg *loki.Config, headers map[string][]string, useStatusConfig bool) httpclient.Caller {
 	skipTLS := cfg.SkipTLS
 	caPath := cfg.CAPath
-	userCertPath := ""
-	userKeyPath := ""
+	userCertPath := cfg.UserCertPath
+	userKeyPath := cfg.UserKeyPath
 	if useStatusConfig {
 		skipTLS = cfg.StatusSkipTLS
 		caPath = cfg.StatusCAPath
@@ -69,7 +98,29 @@ func newLokiClient(cfg *loki.Config, requestHeader http.Header, useStatusConfig
 	}
 
 	// TODO: loki with auth
-	return httpclient.NewHTTPClient(cfg.Timeout, headers, skipTLS, caPath, userCertPath, userKeyPath)
+	return httpclient.NewHTTPClient(cfg.Timeout, headers, skipTLS, caPath, userCertPath, userKeyPath, cfg.Transport)
+}
+
+// getTenantID returns the Loki tenant mapped to the namespaces the user is restricted to, when they all map to the
+// same tenant, falling back to the configured tenant. The tenant is never selected by the client.
+func getTenantID(ctx context.Context, cfg *loki.Config) string {
+	namespaces, restricted := auth.GetAllowedNamespaces(ctx)
+	if !restricted || len(cfg.TenantMapping) == 0 {
+		return cfg.TenantID
+	}
+	var tenantID string
+	for _, ns := range namespaces {
+		mapped, ok := cfg.TenantMapping[ns]
+		if !ok || (tenantID != "" && mapped != tenantID) {
+			hlog.Debugf("No single Loki tenant mapped to namespaces %v, using the default tenant", namespaces)
+			return cfg.TenantID
+		}
+		tenantID = mapped
+	}
+	if tenantID == "" {
+		return cfg.TenantID
+	}
+	return tenantID
 }
 
 /* loki query will fail if spaces or quotes are not encoded
@@ -86,6 +137,10 @@ func getLokiError(resp []byte, code int) (int, string) {
 	if code == http.StatusBadRequest {
 		return code, fmt.Sprintf("Loki message: %s", resp)
 	}
+	if code == http.StatusUnauthorized {
+		// e.g. the forwarded user token was rejected by the Loki gateway
+		return code, fmt.Sprintf("Unauthorized: %s", resp)
+	}
 	if code == http.StatusForbidden {
 		return code, fmt.Sprintf("Forbidden: %s", resp)
 	}
@@ -101,107 +156,202 @@ func getLokiError(resp []byte, code int) (int, string) {
 	return http.StatusBadRequest, fmt.Sprintf("Loki message: %s", message)
 }
 
-func executeLokiQuery(flowsURL string, lokiClient httpclient.Caller) ([]byte, int, error) {
-	hlog.Debugf("executeLokiQuery URL: %s", flowsURL)
+func executeLokiQuery(ctx context.Context, flowsURL string, lokiClient httpclient.Caller) ([]byte, int, error) {
+	hlog.WithContext(ctx).Debugf("executeLokiQuery URL: %s", flowsURL)
 	var code int
+	var resp []byte
+	var err error
 	startTime := time.Now()
+	endpoint := metrics.LokiEndpoint(flowsURL)
+	ctx, span := tracing.Start(ctx, "loki query", tracing.KindInternal)
 	defer func() {
 		metrics.ObserveLokiUnitCall(code, startTime)
+		metrics.ObserveLokiRequest(endpoint, code, len(resp), startTime)
+		span.SetAttribute("loki.status_code", code)
+		span.End()
 	}()
 
-	resp, code, err := lokiClient.Get(flowsURL)
+	resp, code, err = lokiClient.Get(ctx, flowsURL)
 	if err != nil {
+		span.SetError(err)
+		status := lokiCallErrorStatus(err)
+		err = classifyLokiCallError(err)
+		metrics.CountLokiError(endpoint, string(getErrorCode(status, err)))
+		return nil, status, err
+	}
+	span.SetAttribute("loki.response_size", len(resp))
+	if isLokiOverloaded(resp, code) {
+		// shed the request rather than reporting a client error
+		err = withErrorCode(ErrorCodeRateLimited, fmt.Errorf("[%d] Loki is overloaded (%s), please retry later", code, lokiOverloadMessage))
+		span.SetError(err)
+		metrics.CountLokiError(endpoint, string(ErrorCodeRateLimited))
 		return nil, http.StatusServiceUnavailable, err
 	}
 	if code != http.StatusOK {
-		newCode, msg := getLokiError(resp, code)
-		return nil, newCode, fmt.Errorf("[%d] %s", code, msg)
+		newCode, err := newLokiError(resp, code)
+		span.SetError(err)
+		metrics.CountLokiError(endpoint, string(getErrorCode(newCode, err)))
+		return nil, newCode, err
 	}
 	return resp, http.StatusOK, nil
 }
 
-func fetchSingle(lokiClient httpclient.Caller, flowsURL string, merger loki.Merger) (int, error) {
+func fetchSingle(ctx context.Context, lokiClient httpclient.Caller, flowsURL string, merger loki.Merger) (int, error) {
 	var code int
 	startTime := time.Now()
 	defer func() {
 		metrics.ObserveLokiParallelCall(fmt.Sprintf("%T", merger), code, 1, startTime)
 	}()
 
-	resp, code, err := executeLokiQuery(flowsURL, lokiClient)
+	resp, code, err := executeLokiQuery(ctx, flowsURL, lokiClient)
 	if err != nil {
 		return code, err
 	}
 	var qr model.QueryResponse
 	if err := json.Unmarshal(resp, &qr); err != nil {
-		hlog.WithError(err).Errorf("cannot unmarshal, response was: %v", string(resp))
+		hlog.WithContext(ctx).WithError(err).Errorf("cannot unmarshal, response was: %v", string(resp))
 		return http.StatusInternalServerError, err
 	}
+	_, span := tracing.Start(ctx, "merge", tracing.KindInternal)
+	defer span.End()
 	if _, err := merger.Add(qr.Data); err != nil {
 		return http.StatusInternalServerError, err
 	}
 	return code, nil
 }
 
-func fetchParallel(lokiClient httpclient.Caller, queries []string, merger loki.Merger) (int, error) {
+func fetchParallel(ctx context.Context, cfg *loki.Config, lokiClient httpclient.Caller, queries []string, merger loki.Merger) (int, error) {
+	_, code, err := fetchQueries(ctx, cfg, lokiClient, queries, merger, false)
+	return code, err
+}
+
+// queryFailure is a failed query of a partial result, index being its position in the queries
+type queryFailure struct {
+	index int
+	errorWithCode
+}
+
+// fetchPartial runs the queries in parallel as fetchParallel, but returns the failed queries instead of an error
+// when partial results are allowed, as long as one query succeeded
+func fetchPartial(ctx context.Context, cfg *loki.Config, lokiClient httpclient.Caller, queries []string, merger loki.Merger) ([]queryFailure, int, error) {
+	return fetchQueries(ctx, cfg, lokiClient, queries, merger, cfg.PartialResults)
+}
+
+func fetchQueries(ctx context.Context, cfg *loki.Config, lokiClient httpclient.Caller, queries []string, merger loki.Merger, allowPartial bool) ([]queryFailure, int, error) {
 	var codeOut int
 	startTime := time.Now()
 	defer func() {
 		metrics.ObserveLokiParallelCall(fmt.Sprintf("%T", merger), codeOut, len(queries), startTime)
 	}()
 
-	// Run queries in parallel, then aggregate them
-	resChan := make(chan model.QueryResponse, len(queries))
-	errChan := make(chan errorWithCode, len(queries))
+	// Run queries in parallel, at most MaxParallelQueries at a time, then aggregate them in the queries order,
+	// so that results and stats are deterministic. After a failure, running queries are cancelled and queries not
+	// started yet are skipped, unless partial results are allowed.
+	workers := len(queries)
+	if cfg.MaxParallelQueries > 0 && cfg.MaxParallelQueries < workers {
+		workers = cfg.MaxParallelQueries
+	}
+	queriesCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	semaphore := make(chan struct{}, workers)
+	results := make([]*model.QueryResponse, len(queries))
+	errChan := make(chan queryFailure, len(queries))
 	var wg sync.WaitGroup
 	wg.Add(len(queries))
 
-	for _, q := range queries {
-		go func(query string) {
+	for i, q := range queries {
+		go func(index int, query string) {
 			defer wg.Done()
-			resp, code, err := executeLokiQuery(query, lokiClient)
-			if err != nil {
-				errChan <- errorWithCode{err: err, code: code}
-			} else {
-				var qr model.QueryResponse
-				err := json.Unmarshal(resp, &qr)
-				if err != nil {
-					hlog.WithError(err).Errorf("cannot unmarshal, response was: %v", string(resp))
-					errChan <- errorWithCode{err: err, code: http.StatusInternalServerError}
-				} else {
-					resChan <- qr
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-queriesCtx.Done():
+				return
+			}
+			if queriesCtx.Err() != nil {
+				return
+			}
+			fail := func(code int, err error) {
+				// sent before cancelling, so that the first error is the one reported
+				errChan <- queryFailure{index: index, errorWithCode: errorWithCode{err: err, code: code}}
+				if !allowPartial {
+					cancel()
 				}
 			}
-		}(q)
+			resp, code, err := executeLokiQuery(queriesCtx, query, lokiClient)
+			if err != nil {
+				fail(code, err)
+				return
+			}
+			var qr model.QueryResponse
+			if err := json.Unmarshal(resp, &qr); err != nil {
+				hlog.WithContext(ctx).WithError(err).Errorf("cannot unmarshal, response was: %v", string(resp))
+				fail(http.StatusInternalServerError, err)
+				return
+			}
+			results[index] = &qr
+		}(i, q)
 	}
 
 	wg.Wait()
-	close(resChan)
 	close(errChan)
 
-	for errWithCode := range errChan {
-		codeOut = errWithCode.code
-		return errWithCode.code, errWithCode.err
+	var failures []queryFailure
+	for failure := range errChan {
+		failures = append(failures, failure)
+	}
+	if err := ctx.Err(); err != nil {
+		// e.g. the client went away, or the request timed out
+		codeOut = lokiCallErrorStatus(err)
+		return nil, codeOut, classifyLokiCallError(err)
+	}
+	if len(failures) > 0 && (!allowPartial || len(failures) == len(queries)) {
+		codeOut = failures[0].code
+		return nil, codeOut, failures[0].err
 	}
 
 	// Aggregate results
-	for r := range resChan {
+	_, span := tracing.Start(ctx, "merge", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("queries", len(queries))
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
 		if _, err := merger.Add(r.Data); err != nil {
 			codeOut = http.StatusInternalServerError
-			return codeOut, err
+			return nil, codeOut, err
 		}
 	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].index < failures[j].index })
+	if len(failures) > 0 {
+		hlog.WithContext(ctx).Warnf("%d queries out of %d failed, returning partial results", len(failures), len(queries))
+	}
 	codeOut = http.StatusOK
-	return codeOut, nil
+	return failures, codeOut, nil
+}
+
+// failedQueries describes the failures of a partial result
+func failedQueries(queries []string, failures []queryFailure) []model.FailedQuery {
+	var failed []model.FailedQuery
+	for _, f := range failures {
+		failed = append(failed, model.FailedQuery{
+			Query:   queries[f.index],
+			Code:    string(getErrorCode(f.code, f.err)),
+			Message: f.err.Error(),
+		})
+	}
+	return failed
 }
 
 func LokiReady(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, true)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, true)
 		baseURL := strings.TrimRight(cfg.StatusURL.String(), "/")
 
-		resp, code, err := executeLokiQuery(fmt.Sprintf("%s/%s", baseURL, "ready"), lokiClient)
+		resp, code, err := executeLokiQuery(r.Context(), fmt.Sprintf("%s/%s", baseURL, "ready"), lokiClient)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
 
@@ -212,18 +362,18 @@ func LokiReady(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		writeError(w, code, fmt.Sprintf("Loki returned a non ready status: %s", status))
+		writeError(w, code, fmt.Errorf("Loki returned a non ready status: %s", status))
 	}
 }
 
 func LokiMetrics(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, true)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, true)
 		baseURL := strings.TrimRight(cfg.StatusURL.String(), "/")
 
-		resp, code, err := executeLokiQuery(fmt.Sprintf("%s/%s", baseURL, "metrics"), lokiClient)
+		resp, code, err := executeLokiQuery(r.Context(), fmt.Sprintf("%s/%s", baseURL, "metrics"), lokiClient)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
 
@@ -233,12 +383,12 @@ func LokiMetrics(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request)
 
 func LokiBuildInfos(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, true)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, true)
 		baseURL := strings.TrimRight(cfg.StatusURL.String(), "/")
 
-		resp, code, err := executeLokiQuery(fmt.Sprintf("%s/%s", baseURL, "loki/api/v1/status/buildinfo"), lokiClient)
+		resp, code, err := executeLokiQuery(r.Context(), fmt.Sprintf("%s/%s", baseURL, "loki/api/v1/status/buildinfo"), lokiClient)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
 
@@ -248,12 +398,12 @@ func LokiBuildInfos(cfg *loki.Config) func(w http.ResponseWriter, r *http.Reques
 
 func LokiConfig(cfg *loki.Config, param string) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, true)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, true)
 		baseURL := strings.TrimRight(cfg.StatusURL.String(), "/")
 
-		resp, code, err := executeLokiQuery(fmt.Sprintf("%s/%s", baseURL, "config"), lokiClient)
+		resp, code, err := executeLokiQuery(r.Context(), fmt.Sprintf("%s/%s", baseURL, "config"), lokiClient)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
 
@@ -261,9 +411,16 @@ func LokiConfig(cfg *loki.Config, param string) func(w http.ResponseWriter, r *h
 		err = yaml.Unmarshal(resp, &cfg)
 		if err != nil {
 			hlog.WithError(err).Errorf("cannot unmarshal, response was: %v", string(resp))
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
 		writeJSON(w, code, cfg[param])
 	}
 }
+
+// GetSlowQueries lists the most recent Loki queries slower than the slow query threshold, most recent first
+func GetSlowQueries(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, cfg.SlowQueries.Entries())
+	}
+}
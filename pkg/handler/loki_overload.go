@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+)
+
+// lokiOverloadMessage is the error returned by Loki query frontend when its queue is full
+const lokiOverloadMessage = "too many outstanding requests"
+
+func isLokiOverloaded(resp []byte, code int) bool {
+	return code == http.StatusTooManyRequests && strings.Contains(string(resp), lokiOverloadMessage)
+}
+
+// overloadBackoffClient retries once, after a dedicated backoff, the queries rejected because Loki is overloaded.
+// Retrying immediately would only feed the queue that caused the rejection.
+type overloadBackoffClient struct {
+	httpclient.Caller
+	backoff time.Duration
+	sleep   func(context.Context, time.Duration) error
+}
+
+func withOverloadBackoff(client httpclient.Caller, backoff time.Duration) httpclient.Caller {
+	if backoff <= 0 {
+		return client
+	}
+	return &overloadBackoffClient{Caller: client, backoff: backoff, sleep: httpclient.Sleep}
+}
+
+func (c *overloadBackoffClient) Get(ctx context.Context, url string) ([]byte, int, error) {
+	resp, code, err := c.Caller.Get(ctx, url)
+	if err != nil || !isLokiOverloaded(resp, code) {
+		return resp, code, err
+	}
+	hlog.WithContext(ctx).Debugf("Loki is overloaded, retrying in %v", c.backoff)
+	if err := c.sleep(ctx, c.backoff); err != nil {
+		return nil, 0, err
+	}
+	return c.Caller.Get(ctx, url)
+}
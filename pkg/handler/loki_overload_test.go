@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+)
+
+var overloadedResponse = []byte("too many outstanding requests\n")
+
+func TestExecuteLokiQuery_OverloadShed(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.Anything).Return(overloadedResponse, http.StatusTooManyRequests, nil)
+
+	_, code, err := executeLokiQuery(context.Background(), "http://loki", withOverloadBackoff(lokiClientMock, 0))
+	require.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, err.Error(), "Loki is overloaded")
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestExecuteLokiQuery_OtherTooManyRequests(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.Anything).Return([]byte(`{"message":"rate limited"}`), http.StatusTooManyRequests, nil)
+
+	_, code, err := executeLokiQuery(context.Background(), "http://loki", withOverloadBackoff(lokiClientMock, time.Second))
+	require.Error(t, err)
+	// generic 429 keeps the usual error handling, without dedicated backoff
+	assert.Equal(t, http.StatusBadRequest, code)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestOverloadBackoffClient(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.Anything).Return(overloadedResponse, http.StatusTooManyRequests, nil).Once()
+	lokiClientMock.On("Get", mock.Anything).Return([]byte("ok"), http.StatusOK, nil).Once()
+
+	var slept []time.Duration
+	client := &overloadBackoffClient{
+		Caller:  lokiClientMock,
+		backoff: 5 * time.Second,
+		sleep: func(_ context.Context, d time.Duration) error {
+			slept = append(slept, d)
+			return nil
+		},
+	}
+	resp, code, err := executeLokiQuery(context.Background(), "http://loki", client)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", string(resp))
+	assert.Equal(t, []time.Duration{5 * time.Second}, slept)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+
+	// still overloaded after backoff: shed
+	lokiClientMock = new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.Anything).Return(overloadedResponse, http.StatusTooManyRequests, nil)
+	client.Caller = lokiClientMock
+	_, code, err = executeLokiQuery(context.Background(), "http://loki", client)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+}
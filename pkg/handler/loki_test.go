@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+type concurrencyCaller struct {
+	mutex    sync.Mutex
+	inFlight int
+	max      int
+	calls    int
+	code     int
+}
+
+func (c *concurrencyCaller) Get(_ context.Context, _ string) ([]byte, int, error) {
+	c.mutex.Lock()
+	c.calls++
+	c.inFlight++
+	if c.inFlight > c.max {
+		c.max = c.inFlight
+	}
+	c.mutex.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	c.mutex.Lock()
+	c.inFlight--
+	c.mutex.Unlock()
+	return []byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`), c.code, nil
+}
+
+func TestFetchParallel_MaxParallelQueries(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.MaxParallelQueries = 2
+	client := &concurrencyCaller{code: 200}
+	var queries []string
+	for i := 0; i < 6; i++ {
+		queries = append(queries, testLokiBaseURL+"query_range?query="+strconv.Itoa(i))
+	}
+	code, err := fetchParallel(context.Background(), &cfg, client, queries, loki.NewStreamMerger(0))
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, 6, client.calls)
+	assert.Equal(t, 2, client.max)
+}
+
+func TestFetchParallel_FailFast(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.MaxParallelQueries = 1
+	client := &concurrencyCaller{code: 400}
+	queries := []string{testLokiBaseURL + "query_range?query=a", testLokiBaseURL + "query_range?query=b", testLokiBaseURL + "query_range?query=c"}
+	code, err := fetchParallel(context.Background(), &cfg, client, queries, loki.NewStreamMerger(0))
+	require.Error(t, err)
+	assert.Equal(t, 400, code)
+	// queries waiting for a worker are skipped after the failure
+	assert.Equal(t, 1, client.calls)
+}
+
+// blockingCaller fails the first query and blocks the others until they are cancelled
+type blockingCaller struct {
+	mutex     sync.Mutex
+	calls     int
+	cancelled int
+}
+
+func (c *blockingCaller) Get(ctx context.Context, _ string) ([]byte, int, error) {
+	c.mutex.Lock()
+	c.calls++
+	first := c.calls == 1
+	c.mutex.Unlock()
+	if first {
+		time.Sleep(10 * time.Millisecond)
+		return []byte("parse error"), 400, nil
+	}
+	<-ctx.Done()
+	c.mutex.Lock()
+	c.cancelled++
+	c.mutex.Unlock()
+	return nil, 0, ctx.Err()
+}
+
+func TestFetchParallel_CancelRunning(t *testing.T) {
+	client := &blockingCaller{}
+	queries := []string{testLokiBaseURL + "query_range?query=a", testLokiBaseURL + "query_range?query=b", testLokiBaseURL + "query_range?query=c"}
+	code, err := fetchParallel(context.Background(), &testLokiConfig, client, queries, loki.NewStreamMerger(0))
+	require.Error(t, err)
+	// the first failure is reported, running queries are cancelled
+	assert.Equal(t, 400, code)
+	assert.Equal(t, client.calls-1, client.cancelled)
+}
+
+func TestFetchParallel_Deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	client := &blockingCaller{calls: 1}
+	_, code, err := getFlows(ctx, &testLokiConfig, client, nil)
+	require.Error(t, err)
+	assert.Equal(t, 503, code)
+	assert.Equal(t, ErrorCodeUpstreamTimeout, getErrorCode(code, err))
+}
+
+// failingCaller fails the queries containing a given string
+type failingCaller struct {
+	failing string
+}
+
+func (c *failingCaller) Get(_ context.Context, url string) ([]byte, int, error) {
+	if strings.Contains(url, c.failing) {
+		return []byte("too many outstanding requests"), 429, nil
+	}
+	return []byte(`{"status":"success","data":{"resultType":"streams","result":[{"stream":{"SrcK8S_Namespace":"ns"},"values":[["1","{}"]]}]}}`), 200, nil
+}
+
+func TestFetchPartial(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.PartialResults = true
+	client := &failingCaller{failing: "query=b"}
+	queries := []string{testLokiBaseURL + "query_range?query=a", testLokiBaseURL + "query_range?query=b", testLokiBaseURL + "query_range?query=c"}
+	merger := loki.NewStreamMerger(0)
+	failures, code, err := fetchPartial(context.Background(), &cfg, client, queries, merger)
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	require.Len(t, failures, 1)
+	assert.Equal(t, 1, failures[0].index)
+	assert.Equal(t, []model.FailedQuery{{Query: queries[1], Code: string(ErrorCodeRateLimited), Message: failures[0].err.Error()}}, failedQueries(queries, failures))
+	// results of the other queries are kept
+	assert.Len(t, merger.Get().Result, 1)
+
+	// disabled partial results fail the whole request
+	cfg.PartialResults = false
+	_, code, err = fetchPartial(context.Background(), &cfg, client, queries, loki.NewStreamMerger(0))
+	require.Error(t, err)
+	assert.Equal(t, 503, code)
+}
+
+func TestFetchPartial_AllFailed(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.PartialResults = true
+	client := &failingCaller{failing: "query="}
+	queries := []string{testLokiBaseURL + "query_range?query=a", testLokiBaseURL + "query_range?query=b"}
+	_, code, err := fetchPartial(context.Background(), &cfg, client, queries, loki.NewStreamMerger(0))
+	require.Error(t, err)
+	assert.Equal(t, 503, code)
+}
@@ -1,6 +1,7 @@
 package lokiclientmock
 
 import (
+	"context"
 	"os"
 	"strings"
 )
@@ -8,7 +9,7 @@ import (
 type LokiClientMock struct {
 }
 
-func (o *LokiClientMock) Get(url string) ([]byte, int, error) {
+func (o *LokiClientMock) Get(_ context.Context, url string) ([]byte, int, error) {
 	var path string
 
 	isLabel := strings.Contains(url, "/label/")
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+const namespaceLimitKey = "namespaceLimit"
+
+// getNamespaceLimit parses the per-namespace limit, 0 meaning not set
+func getNamespaceLimit(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s parameter: %q", namespaceLimitKey, raw)
+	}
+	return n, nil
+}
+
+// splitByNamespace splits every group filtering on several namespaces into one group per namespace,
+// so that each namespace is queried separately, with its own limit, and can't be crowded out by busier ones.
+// Only the first namespace match with several values of each group is split.
+func splitByNamespace(groups filters.MultiQueries) filters.MultiQueries {
+	var split filters.MultiQueries
+	for _, group := range groups {
+		idx := -1
+		for i, m := range group {
+			isNamespace := m.Key == fields.SrcNamespace || m.Key == fields.DstNamespace || m.Key == fields.Namespace
+			if isNamespace && !m.Not && !m.Regex && !m.Exists && strings.Contains(m.Values, ",") {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			split = append(split, group)
+			continue
+		}
+		for _, ns := range strings.Split(group[idx].Values, ",") {
+			g := make(filters.SingleQuery, len(group))
+			copy(g, group)
+			g[idx].Values = ns
+			split = append(split, g)
+		}
+	}
+	return split
+}
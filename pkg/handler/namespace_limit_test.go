@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func TestSplitByNamespace(t *testing.T) {
+	groups, err := filters.Parse(`SrcK8S_Namespace="a","b"&DstPort=443|Proto=6`)
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("SrcK8S_Namespace", `"a"`), filters.NewMatch("DstPort", "443")},
+		{filters.NewMatch("SrcK8S_Namespace", `"b"`), filters.NewMatch("DstPort", "443")},
+		{filters.NewMatch("Proto", "6")},
+	}, splitByNamespace(groups))
+}
+
+func TestGetFlows_NamespaceLimit(t *testing.T) {
+	namespaceResponse := func(ns string, count int) []byte {
+		stream := model.Stream{Labels: map[string]string{"SrcK8S_Namespace": ns}}
+		for i := 0; i < count; i++ {
+			stream.Entries = append(stream.Entries, model.Entry{Timestamp: time.Now(), Line: fmt.Sprintf(`{"SrcPort":%d}`, i)})
+		}
+		resp, err := json.Marshal(model.QueryResponse{Data: model.QueryResponseData{ResultType: model.ResultTypeStream, Result: model.Streams{stream}}})
+		require.NoError(t, err)
+		return resp
+	}
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	// Loki applies the limit: the busy namespace fills it, the quiet one has less
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool {
+		return strings.Contains(u, `SrcK8S_Namespace="busy"`) && strings.Contains(u, "limit=3")
+	})).Return(namespaceResponse("busy", 3), 200, nil)
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool {
+		return strings.Contains(u, `SrcK8S_Namespace="quiet"`) && strings.Contains(u, "limit=3")
+	})).Return(namespaceResponse("quiet", 1), 200, nil)
+
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{
+		filtersKey:        {`SrcK8S_Namespace="busy","quiet"`},
+		limitKey:          {"3"},
+		namespaceLimitKey: {"3"},
+	})
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+
+	perNamespace := map[string]int{}
+	for _, s := range qr.Result.(model.Streams) {
+		perNamespace[s.Labels["SrcK8S_Namespace"]] += len(s.Entries)
+	}
+	// no global trim: each namespace is represented up to its own limit
+	assert.Equal(t, map[string]int{"busy": 3, "quiet": 1}, perNamespace)
+	assert.Equal(t, 4, qr.Stats.TotalEntries)
+	require.Len(t, qr.Stats.Groups, 2)
+	assert.True(t, qr.Stats.Groups[0].LimitReached)
+	assert.False(t, qr.Stats.Groups[1].LimitReached)
+
+	_, _, err = getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{namespaceLimitKey: {"0"}})
+	require.Error(t, err)
+}
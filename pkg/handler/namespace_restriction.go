@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+// namespaceRestriction returns the filter groups limiting flows to those having their source or destination in
+// an allowed namespace, when the request is restricted. Groups are disjoint, so that flows aren't returned twice.
+func namespaceRestriction(ctx context.Context) (filters.MultiQueries, bool) {
+	namespaces, restricted := auth.GetAllowedNamespaces(ctx)
+	if !restricted {
+		return nil, false
+	}
+	values := exactValues(namespaces)
+	return filters.MultiQueries{
+		{filters.NewMatch(fields.SrcNamespace, values)},
+		{filters.NewNotMatch(fields.SrcNamespace, values), filters.NewMatch(fields.DstNamespace, values)},
+	}, true
+}
+
+// exactValues formats the values of a filter exactly matching any of them
+func exactValues(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, exact(v))
+	}
+	return strings.Join(quoted, ",")
+}
+
+// restrictedLimit caps the limit of the requests restricted to some namespaces, no limit being capped too
+func restrictedLimit(ctx context.Context, cfg *loki.Config, limit string, reqLimit int) (string, int) {
+	if _, restricted := auth.GetAllowedNamespaces(ctx); !restricted || cfg.RestrictedMaxLimit <= 0 {
+		return limit, reqLimit
+	}
+	if reqLimit <= 0 || reqLimit > cfg.RestrictedMaxLimit {
+		return strconv.Itoa(cfg.RestrictedMaxLimit), cfg.RestrictedMaxLimit
+	}
+	return limit, reqLimit
+}
+
+// checkExportAllowed rejects the exports of the requests restricted to some namespaces, when disabled for them
+func checkExportAllowed(ctx context.Context, cfg *loki.Config) error {
+	if _, restricted := auth.GetAllowedNamespaces(ctx); restricted && cfg.RestrictedDisableExport {
+		return withErrorCode(ErrorCodeForbidden, errors.New("export is disabled for users restricted to some namespaces"))
+	}
+	return nil
+}
+
+// isNamespaceAllowed tells whether the request can access the namespace flows
+func isNamespaceAllowed(ctx context.Context, namespace string) bool {
+	namespaces, restricted := auth.GetAllowedNamespaces(ctx)
+	if !restricted {
+		return true
+	}
+	for _, ns := range namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteForbidden responds to requests that failed the authorization checks
+func WriteForbidden(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusForbidden, withErrorCode(ErrorCodeForbidden, err))
+}
@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	pmodel "github.com/prometheus/common/model"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/permalinks"
+	"github.com/netobserv/network-observability-console-plugin/pkg/preferences"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedfilters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+// apiParameter is a query, path or header parameter of an API operation
+type apiParameter struct {
+	Name        string
+	In          string
+	Description string
+	// Type is string, integer, number or boolean
+	Type     string
+	Enum     []string
+	Required bool
+}
+
+// apiOperation documents a route of the API. The OpenAPI specification is generated from these definitions, the
+// request and response schemas being read from sample values of the Go types.
+type apiOperation struct {
+	Method     string
+	Path       string
+	Tag        string
+	Summary    string
+	Parameters []apiParameter
+	// Request is a sample of the JSON body, nil when there is none
+	Request interface{}
+	// Response is a sample of the JSON response, nil when ContentType isn't JSON or there is no body
+	Response interface{}
+	// ContentType of the response, application/json by default
+	ContentType string
+	// Status of successful responses, 200 by default
+	Status int
+}
+
+func queryParam(name, typ, description string, enum ...string) apiParameter {
+	return apiParameter{Name: name, In: "query", Type: typ, Description: description, Enum: enum}
+}
+
+func pathParam(name, description string) apiParameter {
+	return apiParameter{Name: name, In: "path", Type: "string", Description: description, Required: true}
+}
+
+var (
+	timeRangeParams = []apiParameter{
+		queryParam(startTimeKey, "string", "Start of the time range, in seconds since epoch, as RFC3339 (e.g. 2023-03-28T10:00:00+02:00) or relative to now (e.g. now-1h), overriding timeRange; sub-second precision is rounded down to the second"),
+		queryParam(endTimeKey, "string", "End of the time range, in seconds since epoch, as RFC3339 or relative to now, now by default; rounded up to the next second"),
+		queryParam(timeRangeKey, "integer", "Duration of the time range in seconds, ending at endTime"),
+	}
+	filterParams = []apiParameter{
+		queryParam(filtersKey, "string", "URL encoded filter groups: groups are separated by |, filters of a group by &, e.g. SrcK8S_Namespace=netobserv&Proto=6; preset=<name> references a filter preset"),
+		queryParam(portsKey, "string", "Comma-separated ports, matching the source or destination port"),
+		queryParam(serviceKey, "string", "Service name, e.g. dns, matching its well-known ports"),
+		queryParam(workloadKey, "string", "Workload as namespace/kind/name, matching the source or destination"),
+		queryParam(reporterKey, "string", "Observation point of the flows",
+			string(constants.ReporterSource), string(constants.ReporterDestination), string(constants.ReporterBoth)),
+		queryParam(recordTypeKey, "string", "Type of the records", append([]string{string(constants.RecordTypeLog)}, constants.AnyConnectionType...)...),
+		queryParam(namespaceLimitKey, "integer", "Maximum number of flows per namespace, querying each namespace separately"),
+		queryParam(timeoutKey, "string", "Duration after which the request is cancelled, bounded by the backend timeout, e.g. 30s"),
+	}
+	flowsParams = concatParams(timeRangeParams, filterParams, []apiParameter{
+		queryParam(limitKey, "integer", "Maximum number of flows"),
+		queryParam(cursorKey, "string", "Cursor of the next page, as returned in nextCursor"),
+		queryParam(sortByKey, "string", "Order of the flows, the most recent first by default: timestamp, anomaly or a numeric field, including the computed BitsPerSecond and TcpRetransmitRate"),
+		queryParam(orderKey, "string", "Order of the timestamp sort", "asc", "desc"),
+		queryParam(anomalyFieldKey, "string", "Numeric field of the anomaly sort, Bytes by default"),
+		queryParam(fieldsKey, "string", "Comma-separated fields kept in the returned flows, all by default"),
+		queryParam(autoFieldsKey, "boolean", "Removes the fields of the features not populated in recent flows"),
+		queryParam(excludeZeroBytesKey, "boolean", "Excludes the flows without bytes"),
+		queryParam(reconcileKey, "string", "Merging of the flows observed by both the source and the destination reporters",
+			string(ReconcileKeepBoth), string(ReconcileMax), string(ReconcileSum), string(ReconcileSourceWins), string(ReconcileDestinationWins)),
+		queryParam(truncateKey, "boolean", "Truncates the long string fields, true by default"),
+	})
+	topologyParams = concatParams(timeRangeParams, filterParams, []apiParameter{
+		queryParam(limitKey, "integer", "Maximum number of series"),
+		queryParam(metricTypeKey, "string", "Aggregated metric, e.g. bytes, packets or count"),
+		queryParam(scopeKey, "string", "Aggregation level of the sources and destinations, resource by default", "app", "host", "namespace", "owner", "resource"),
+		queryParam(groupsKey, "string", "Additional grouping, e.g. hosts, namespaces or owners"),
+		queryParam(rateIntervalKey, "string", "Rate interval of the metric, e.g. 1m"),
+		queryParam(stepKey, "string", "Step of the series, e.g. 30s"),
+		queryParam(comparePreviousKey, "boolean", "Compares the series with the previous period of the same duration"),
+		queryParam(exportFormatKey, "string", "Export format of the series, JSON by default", exportPrometheusFormat),
+	})
+	labelsOverrideParam = apiParameter{Name: labelsOverrideHeader, In: "header", Type: "string",
+		Description: "Comma-separated Loki labels replacing the configured ones, e.g. for a Loki instance with other labels"}
+)
+
+func concatParams(groups ...[]apiParameter) []apiParameter {
+	var params []apiParameter
+	for _, g := range groups {
+		params = append(params, g...)
+	}
+	return params
+}
+
+// apiOperations are the documented operations of the API. Routes registered in the server are checked to be part
+// of them, see TestOpenAPISpec_Routes.
+var apiOperations = []apiOperation{
+	{Method: http.MethodGet, Path: "/healthz", Tag: "status", Summary: "Liveness probe", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/readyz", Tag: "status", Summary: "Readiness probe, checking Loki", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/api/status", Tag: "status", Summary: "Backend status", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/api/status/ingestion", Tag: "status", Summary: "Lag of the latest flow ingested in Loki",
+		Parameters: []apiParameter{
+			queryParam(lookbackKey, "string", "Duration in which the latest flow is looked for, e.g. 1h"),
+			queryParam(maxLagKey, "string", "Lag beyond which the ingestion is stale, e.g. 2m"),
+		},
+		Response: model.IngestionStatus{}},
+	{Method: http.MethodGet, Path: "/api/loki/ready", Tag: "loki", Summary: "Loki readiness", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/api/loki/metrics", Tag: "loki", Summary: "Loki metrics, for cluster-wide users", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/api/loki/buildinfo", Tag: "loki", Summary: "Loki build information", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/api/loki/config/limits", Tag: "loki", Summary: "Loki limits configuration, for cluster-wide users", Response: map[string]interface{}{}},
+	{Method: http.MethodGet, Path: "/api/admin/slow-queries", Tag: "admin", Summary: "Most recent slow Loki queries", Response: []httpclient.SlowQuery{}},
+	{Method: http.MethodGet, Path: "/api/admin/loglevel", Tag: "admin", Summary: "Log level", Response: logLevel{}},
+	{Method: http.MethodPost, Path: "/api/admin/loglevel", Tag: "admin", Summary: "Change the log level", Request: logLevel{}, Response: logLevel{}},
+	{Method: http.MethodGet, Path: "/api/loki/flows", Tag: "flows", Summary: "Flows matching the filters",
+		Parameters: concatParams(flowsParams, []apiParameter{labelsOverrideParam}), Response: model.AggregatedQueryResponse{}},
+	{Method: http.MethodGet, Path: "/api/loki/export", Tag: "flows", Summary: "Export the flows matching the filters",
+		Parameters: concatParams(flowsParams, []apiParameter{
+			{Name: exportFormatKey, In: "query", Type: "string", Required: true, Description: "Export format", Enum: []string{exportCSVFormat, exportGeoJSONFormat}},
+			queryParam(exportcolumnsKey, "string", "Comma-separated CSV columns, all by default"),
+			labelsOverrideParam,
+		}),
+		ContentType: "text/csv"},
+	{Method: http.MethodGet, Path: "/api/loki/flows/estimate", Tag: "flows", Summary: "Estimated cost of a flows query, from the Loki index",
+		Parameters: concatParams(timeRangeParams, filterParams), Response: model.FlowsEstimate{}},
+	{Method: http.MethodGet, Path: "/api/loki/flows/validate", Tag: "flows", Summary: "Validation of a flows query, without running it",
+		Parameters: flowsParams, Response: model.FlowsValidation{}},
+	{Method: http.MethodGet, Path: "/api/loki/flows/latest", Tag: "flows", Summary: "Timestamp of the latest flow",
+		Parameters: concatParams(timeRangeParams, filterParams), Response: model.FreshnessResponse{}},
+	{Method: http.MethodGet, Path: "/api/loki/cardinality", Tag: "flows", Summary: "Number of distinct values per field: exact for labels, counted in a sample of flows for other fields",
+		Parameters: concatParams(timeRangeParams, filterParams, []apiParameter{
+			{Name: fieldsKey, In: "query", Type: "string", Required: true, Description: "Comma-separated fields"},
+		}),
+		Response: []model.FieldCardinality{}},
+	{Method: http.MethodGet, Path: "/api/loki/topology", Tag: "topology", Summary: "Metrics series between the sources and destinations of the flows",
+		Parameters: concatParams(topologyParams, []apiParameter{labelsOverrideParam}), Response: model.AggregatedQueryResponse{}},
+	{Method: http.MethodGet, Path: "/api/loki/topology/matrix", Tag: "topology", Summary: "Connection matrix between the sources and destinations of the flows",
+		Parameters: concatParams(topologyParams, []apiParameter{
+			queryParam(maxDimensionKey, "integer", "Maximum number of rows and columns"),
+		}),
+		Response: model.ConnectionMatrix{}},
+	{Method: http.MethodGet, Path: "/api/resources/values", Tag: "resources", Summary: "Candidate values of a field, for autocompletion",
+		Parameters: concatParams(timeRangeParams, []apiParameter{
+			{Name: fieldKey, In: "query", Type: "string", Required: true, Description: "Field name"},
+			queryParam(prefixKey, "string", "Prefix of the values, ignoring case"),
+			queryParam(limitKey, "integer", "Maximum number of values"),
+		}),
+		Response: []string{}},
+	{Method: http.MethodGet, Path: "/api/resources/namespaces", Tag: "resources", Summary: "Namespaces having flows", Response: []string{}},
+	{Method: http.MethodGet, Path: "/api/resources/namespace/{namespace}/kind/{kind}/names", Tag: "resources", Summary: "Names of the resources of a kind in a namespace",
+		Parameters: []apiParameter{pathParam("namespace", "Namespace"), pathParam("kind", "Resource kind, e.g. Pod")}, Response: []string{}},
+	{Method: http.MethodGet, Path: "/api/resources/kind/{kind}/names", Tag: "resources", Summary: "Names of the cluster-scoped resources of a kind",
+		Parameters: []apiParameter{pathParam("kind", "Resource kind, e.g. Node")}, Response: []string{}},
+	{Method: http.MethodGet, Path: "/api/frontend-config", Tag: "config", Summary: "Console plugin configuration, tailored to the user access", Response: frontendConfig{}},
+	{Method: http.MethodGet, Path: "/api/frontend-config/fields", Tag: "config", Summary: "Flow fields, with the filter operators they support", Response: []fieldSchema{}},
+	{Method: http.MethodGet, Path: "/api/frontend-config/columns", Tag: "config", Summary: "Flows table columns, empty meaning the built-in ones", Response: []column{}},
+	{Method: http.MethodGet, Path: "/api/filters", Tag: "filters", Summary: "Saved filters of the user, then the ones shared by others", Response: []savedfilters.SavedFilter{}},
+	{Method: http.MethodPost, Path: "/api/filters", Tag: "filters", Summary: "Save a new filter", Request: savedfilters.SavedFilter{}, Response: savedfilters.SavedFilter{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/filters/{name}", Tag: "filters", Summary: "Saved filter",
+		Parameters: []apiParameter{pathParam("name", "Filter name")}, Response: savedfilters.SavedFilter{}},
+	{Method: http.MethodPut, Path: "/api/filters/{name}", Tag: "filters", Summary: "Create or replace a saved filter",
+		Parameters: []apiParameter{pathParam("name", "Filter name")}, Request: savedfilters.SavedFilter{}, Response: savedfilters.SavedFilter{}},
+	{Method: http.MethodDelete, Path: "/api/filters/{name}", Tag: "filters", Summary: "Delete a saved filter",
+		Parameters: []apiParameter{pathParam("name", "Filter name")}, Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/preferences", Tag: "preferences", Summary: "Preferences of the user", Response: preferences.Preferences{}},
+	{Method: http.MethodPut, Path: "/api/preferences", Tag: "preferences", Summary: "Replace the preferences of the user", Request: preferences.Preferences{}, Response: preferences.Preferences{}},
+	{Method: http.MethodDelete, Path: "/api/preferences", Tag: "preferences", Summary: "Reset the preferences of the user", Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/permalinks", Tag: "permalinks", Summary: "Create a permalink of a query", Request: permalinkRequest{}, Response: permalinks.Permalink{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/permalinks/{id}", Tag: "permalinks", Summary: "Resolve a permalink",
+		Parameters: []apiParameter{pathParam("id", "Permalink ID")}, Response: permalinks.Permalink{}},
+	{Method: http.MethodGet, Path: "/api/openapi.json", Tag: "status", Summary: "This OpenAPI specification", Response: map[string]interface{}{}},
+}
+
+// GetOpenAPISpec serves the OpenAPI specification of the API, generated from apiOperations
+func GetOpenAPISpec(version string) func(w http.ResponseWriter, r *http.Request) {
+	spec := buildOpenAPISpec(apiOperations, version)
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spec)
+	}
+}
+
+func buildOpenAPISpec(operations []apiOperation, version string) map[string]interface{} {
+	schemas := openAPISchemas{names: map[reflect.Type]string{}, defs: map[string]interface{}{}}
+	errorSchema := schemas.schemaOf(reflect.TypeOf(errorResponse{}))
+	paths := map[string]map[string]interface{}{}
+	for i := range operations {
+		op := &operations[i]
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = schemas.operation(op, errorSchema)
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "NetObserv console plugin API",
+			"version": version,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.defs},
+	}
+}
+
+// openAPISchemas collects the component schemas of the named structs
+type openAPISchemas struct {
+	names map[reflect.Type]string
+	defs  map[string]interface{}
+}
+
+func (s *openAPISchemas) operation(op *apiOperation, errorSchema map[string]interface{}) map[string]interface{} {
+	status, contentType := op.Status, op.ContentType
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if status != http.StatusNoContent {
+		content := map[string]interface{}{}
+		if op.Response != nil {
+			content["schema"] = s.schemaOf(reflect.TypeOf(op.Response))
+		} else {
+			content["schema"] = map[string]interface{}{"type": "string"}
+		}
+		success["content"] = map[string]interface{}{contentType: content}
+	}
+	operation := map[string]interface{}{
+		"summary":     op.Summary,
+		"operationId": operationID(op),
+		"tags":        []string{op.Tag},
+		"responses": map[string]interface{}{
+			strconv.Itoa(status): success,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": errorSchema}},
+			},
+		},
+	}
+	if len(op.Parameters) > 0 {
+		params := make([]map[string]interface{}, 0, len(op.Parameters))
+		for _, p := range op.Parameters {
+			schema := map[string]interface{}{"type": p.Type}
+			if len(p.Enum) > 0 {
+				schema["enum"] = p.Enum
+			}
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"description": p.Description,
+				"required":    p.Required,
+				"schema":      schema,
+			})
+		}
+		operation["parameters"] = params
+	}
+	if op.Request != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": s.schemaOf(reflect.TypeOf(op.Request))}},
+		}
+	}
+	return operation
+}
+
+// operationID is the method followed by the path words, e.g. getLokiFlowsEstimate
+func operationID(op *apiOperation) string {
+	id := strings.ToLower(op.Method)
+	for _, word := range strings.FieldsFunc(op.Path, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		id += capitalize(word)
+	}
+	return id
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	entryType       = reflect.TypeOf(model.Entry{})
+	samplePairType  = reflect.TypeOf(pmodel.SamplePair{})
+	resultValueType = reflect.TypeOf((*model.ResultValue)(nil)).Elem()
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaOf returns the schema of the JSON encoding of a type, named structs being referenced in the components
+func (s *openAPISchemas) schemaOf(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case entryType:
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"},
+			"description": "Timestamp in nanoseconds, then the flow JSON"}
+	case samplePairType:
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{},
+			"description": "Timestamp in seconds, then the value as a string"}
+	case resultValueType:
+		return map[string]interface{}{"oneOf": []interface{}{
+			s.schemaOf(reflect.TypeOf(model.Streams{})),
+			s.schemaOf(reflect.TypeOf(model.Matrix{})),
+		}, "description": "Flows streams, or metrics series for topology queries"}
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		// custom encoding
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schemaOf(t.Elem())
+		if _, isRef := schema["$ref"]; !isRef {
+			schema["nullable"] = true
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + s.componentName(t)}
+	}
+	// interfaces
+	return map[string]interface{}{}
+}
+
+// componentName registers a named struct in the components, prefixing the name with the package when another
+// package has a struct of the same name
+func (s *openAPISchemas) componentName(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := capitalize(t.Name())
+	if _, taken := s.defs[name]; taken {
+		pkg := t.PkgPath()
+		name = capitalize(pkg[strings.LastIndexByte(pkg, '/')+1:]) + name
+	}
+	s.names[t] = name
+	// placeholder for recursive types
+	s.defs[name] = nil
+	s.defs[name] = s.structSchema(t)
+	return name
+}
+
+func (s *openAPISchemas) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	s.addFields(t, properties, &required)
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the properties of the exported fields, following the encoding/json rules for names, omitempty
+// fields being optional and embedded structs being inlined
+func (s *openAPISchemas) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			s.addFields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = s.schemaOf(f.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
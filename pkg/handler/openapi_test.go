@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	w := httptest.NewRecorder()
+	GetOpenAPISpec("1.2.3")(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	assert.Equal(t, "1.2.3", spec.Info.Version)
+
+	flows := spec.Paths["/api/loki/flows"]["get"]
+	require.NotNil(t, flows)
+	assert.Equal(t, "getApiLokiFlows", flows["operationId"])
+	var names []string
+	for _, p := range flows["parameters"].([]interface{}) {
+		names = append(names, p.(map[string]interface{})["name"].(string))
+	}
+	assert.Contains(t, names, "filters")
+	assert.Contains(t, names, "timeRange")
+	assert.Contains(t, names, "X-Loki-Labels-Override")
+	assert.Equal(t,
+		map[string]interface{}{"$ref": "#/components/schemas/AggregatedQueryResponse"},
+		flows["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+
+	// schemas follow the JSON encoding
+	response := spec.Components.Schemas["AggregatedQueryResponse"]
+	require.NotNil(t, response)
+	props := response["properties"].(map[string]interface{})
+	assert.Contains(t, props, "result")
+	assert.Contains(t, props, "nextCursor")
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/AggregatedStats"}, props["stats"])
+	assert.Contains(t, response["required"], "isMock")
+	assert.NotContains(t, response["required"], "nextCursor")
+	assert.Equal(t, map[string]interface{}{"type": "integer", "format": "int64", "nullable": true}, props["ingestionLagMs"])
+	assert.Contains(t, spec.Components.Schemas, "Stream")
+	assert.Contains(t, spec.Components.Schemas, "ErrorResponse")
+
+	config := spec.Components.Schemas["FrontendConfig"]["properties"].(map[string]interface{})
+	assert.Contains(t, config, "role")
+	assert.NotContains(t, config, "RestrictedAccess")
+
+	// path parameters and no content responses
+	del := spec.Paths["/api/filters/{name}"]["delete"]
+	require.NotNil(t, del)
+	assert.Contains(t, del["responses"], "204")
+	assert.NotContains(t, del["responses"].(map[string]interface{})["204"], "content")
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/permalinks"
+)
+
+type permalinkRequest struct {
+	// Query is the URL encoded query parameter set, e.g. filters=...&timeRange=300
+	Query string `json:"query"`
+}
+
+// Permalinks creates (POST) a permalink from a query parameter set, or resolves it (GET) when its ID is in the path
+func Permalinks(store permalinks.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("Permalinks", code, startTime)
+		}()
+
+		id, hasID := mux.Vars(r)["id"]
+		var link *permalinks.Permalink
+		var err error
+		switch {
+		case r.Method == http.MethodPost && !hasID:
+			link, code, err = createPermalink(r, store)
+		case r.Method == http.MethodGet && hasID:
+			link, err = store.Get(r.Context(), id)
+			code = http.StatusOK
+			if errors.Is(err, permalinks.ErrNotFound) {
+				code, err = http.StatusNotFound, withErrorCode(ErrorCodeNotFound, err)
+			} else if err != nil {
+				code, err = http.StatusInternalServerError, fmt.Errorf("cannot read permalink: %w", err)
+			}
+		default:
+			code, err = http.StatusMethodNotAllowed, withErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("method not allowed: %s", r.Method))
+		}
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		writeJSON(w, code, link)
+	}
+}
+
+func createPermalink(r *http.Request, store permalinks.Store) (*permalinks.Permalink, int, error) {
+	var req permalinkRequest
+	// leave room for JSON escaping
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 2*permalinks.MaxQueryLength)).Decode(&req); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("cannot decode permalink request: %w", err)
+	}
+	link, err := store.Create(r.Context(), req.Query)
+	if errors.Is(err, permalinks.ErrInvalid) {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, err)
+	} else if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot create permalink: %w", err)
+	}
+	return link, http.StatusCreated, nil
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/permalinks"
+)
+
+func TestPermalinks(t *testing.T) {
+	h := Permalinks(permalinks.NewMemoryStore(0, 0))
+	router := mux.NewRouter()
+	router.HandleFunc("/permalinks", h)
+	router.HandleFunc("/permalinks/{id}", h)
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := call(http.MethodPost, "/permalinks", `{"query":"filters=DstPort%3D53&limit=50"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var link permalinks.Permalink
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &link))
+
+	rec = call(http.MethodGet, "/permalinks/"+link.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resolved permalinks.Permalink
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resolved))
+	assert.Equal(t, "filters=DstPort%3D53&limit=50", resolved.Query)
+
+	assert.Equal(t, http.StatusNotFound, call(http.MethodGet, "/permalinks/nope", "").Code)
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/permalinks", `{"query":""}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/permalinks", `not json`).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, call(http.MethodDelete, "/permalinks/"+link.ID, "").Code)
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+const (
+	portsKey   = "ports"
+	serviceKey = "service"
+)
+
+// addPortsFilter restricts every filter group to flows having either source or destination port
+// in the comma-separated list provided in the ports parameter
+func addPortsFilter(rawPorts string, groups filters.MultiQueries) (filters.MultiQueries, error) {
+	if len(rawPorts) == 0 {
+		return groups, nil
+	}
+	ports := strings.Split(rawPorts, ",")
+	for _, p := range ports {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > 65535 {
+			return nil, fmt.Errorf("invalid port in %s parameter: %q", portsKey, p)
+		}
+	}
+	match := filters.NewMatch(fields.Port, strings.Join(ports, ","))
+	if len(groups) == 0 {
+		return filters.MultiQueries{{match}}, nil
+	}
+	for i := range groups {
+		groups[i] = append(groups[i], match)
+	}
+	return groups, nil
+}
+
+// addServiceFilter restricts every filter group to flows involving the given service on either endpoint,
+// as the ports filter would do with the ports configured for this service
+func addServiceFilter(servicePorts map[string][]string, service string, groups filters.MultiQueries) (filters.MultiQueries, error) {
+	if len(service) == 0 {
+		return groups, nil
+	}
+	ports, ok := servicePorts[strings.ToLower(service)]
+	if !ok || len(ports) == 0 {
+		return nil, fmt.Errorf("unknown service in %s parameter: %q", serviceKey, service)
+	}
+	return addPortsFilter(strings.Join(ports, ","), groups)
+}
+
+// annotatePortNames returns a processor that adds the service names of known source and destination ports
+func annotatePortNames(portNames map[string]string) flowProcessor {
+	return func(_ *model.Entry, record *flowRecord) {
+		if name, ok := portNames[record.getString(fields.SrcPort)]; ok {
+			record.fields[fields.SrcPortName] = name
+		}
+		if name, ok := portNames[record.getString(fields.DstPort)]; ok {
+			record.fields[fields.DstPortName] = name
+		}
+	}
+}
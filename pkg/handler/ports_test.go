@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func TestAddPortsFilter(t *testing.T) {
+	groups, err := filters.Parse("SrcK8S_Namespace=a|DstK8S_Namespace=b")
+	require.NoError(t, err)
+	groups, err = addPortsFilter("443,22", groups)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	for _, g := range groups {
+		assert.Contains(t, g, filters.NewMatch(fields.Port, "443,22"))
+	}
+
+	groups, err = addPortsFilter("53", nil)
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{filters.NewMatch(fields.Port, "53")}}, groups)
+
+	_, err = addPortsFilter("443,https", nil)
+	require.Error(t, err)
+	_, err = addPortsFilter("70000", nil)
+	require.Error(t, err)
+}
+
+func TestGetFlows_PortsAndNames(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{{
+			Timestamp: time.Now(),
+			Line:      `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","SrcPort":42000,"DstPort":443}`,
+		}, {
+			Timestamp: time.Now(),
+			Line:      `{"SrcAddr":"10.0.0.2","DstAddr":"10.0.0.1","SrcPort":22,"DstPort":42001}`,
+		}},
+	}})
+	cfg := testLokiConfig
+	cfg.PortNames = map[string]string{"443": "https", "22": "ssh"}
+
+	params := url.Values{}
+	params.Set(portsKey, "443,22")
+	qr, _, err := getFlows(context.Background(), &cfg, lokiClientMock, params)
+	require.NoError(t, err)
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), "|~`Port\":443[,}]|Port\":22[,}]`")
+
+	records := getRecords(t, qr)
+	require.Len(t, records, 2)
+	assert.Equal(t, "https", records[0][fields.DstPortName])
+	// unknown ports are not annotated
+	assert.NotContains(t, records[0], fields.SrcPortName)
+	assert.Equal(t, "ssh", records[1][fields.SrcPortName])
+	assert.NotContains(t, records[1], fields.DstPortName)
+}
+
+func TestGetFlows_Service(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.ServicePorts = map[string][]string{"dns": {"53", "853"}}
+
+	// DNS on either endpoint, over any of its ports
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	_, _, err := getFlows(context.Background(), &cfg, lokiClientMock, url.Values{serviceKey: {"DNS"}, filtersKey: {"Proto=17|Proto=6"}})
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+	for _, call := range lokiClientMock.Calls {
+		assert.Contains(t, call.Arguments.String(0), "|~`Port\":53[,}]|Port\":853[,}]`")
+	}
+
+	_, err = addServiceFilter(cfg.ServicePorts, "gopher", nil)
+	require.Error(t, err)
+}
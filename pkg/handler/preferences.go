@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/preferences"
+)
+
+// maxPreferencesBody limits the size of preferences requests body
+const maxPreferencesBody = 16 * 1024
+
+// Preferences serves the preferences of the current user: read (GET), replacement (PUT) and reset (DELETE)
+func Preferences(store preferences.Store, getUser UserGetter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("Preferences", code, startTime)
+		}()
+
+		user, err := getUser(r.Context(), r.Header)
+		if err != nil {
+			code = http.StatusUnauthorized
+			WriteUnauthorized(w, err)
+			return
+		}
+
+		var resp *preferences.Preferences
+		switch r.Method {
+		case http.MethodGet:
+			resp, code, err = getPreferences(r.Context(), store, user)
+		case http.MethodPut:
+			resp, code, err = savePreferences(r, store, user)
+		case http.MethodDelete:
+			code, err = deletePreferences(r.Context(), store, user)
+		default:
+			code, err = http.StatusMethodNotAllowed, withErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("method not allowed: %s", r.Method))
+		}
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		if resp == nil {
+			w.WriteHeader(code)
+			return
+		}
+		writeJSON(w, code, resp)
+	}
+}
+
+func getPreferences(ctx context.Context, store preferences.Store, user string) (*preferences.Preferences, int, error) {
+	prefs, err := store.Get(ctx, user)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot read preferences: %w", err)
+	}
+	return prefs, http.StatusOK, nil
+}
+
+func savePreferences(r *http.Request, store preferences.Store, user string) (*preferences.Preferences, int, error) {
+	var prefs preferences.Preferences
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxPreferencesBody)).Decode(&prefs); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("cannot decode preferences: %w", err)
+	}
+	saved, err := store.Save(r.Context(), user, prefs)
+	if errors.Is(err, preferences.ErrInvalid) {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, err)
+	} else if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot save preferences: %w", err)
+	}
+	return saved, http.StatusOK, nil
+}
+
+func deletePreferences(ctx context.Context, store preferences.Store, user string) (int, error) {
+	if err := store.Delete(ctx, user); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("cannot delete preferences: %w", err)
+	}
+	return http.StatusNoContent, nil
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/preferences"
+)
+
+func TestPreferences(t *testing.T) {
+	getUser := func(_ context.Context, header http.Header) (string, error) {
+		return header.Get("X-User"), nil
+	}
+	h := Preferences(preferences.NewMemoryStore(), getUser)
+	call := func(method, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/preferences", strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	rec := call(http.MethodPut, "alice", `{"columns":["StartTime","Bytes"],"timeRange":3600,"tableDensity":"l"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = call(http.MethodPut, "alice", `{"tableDensity":"xxl"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = call(http.MethodPatch, "alice", `{}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = call(http.MethodGet, "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var prefs preferences.Preferences
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &prefs))
+	assert.Equal(t, []string{"StartTime", "Bytes"}, prefs.Columns)
+	assert.Equal(t, 3600, prefs.TimeRange)
+	assert.Equal(t, "l", prefs.TableDensity)
+
+	// per user
+	rec = call(http.MethodGet, "bob", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var other preferences.Preferences
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &other))
+	assert.Empty(t, other.Columns)
+
+	rec = call(http.MethodDelete, "alice", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = call(http.MethodGet, "alice", "")
+	var reset preferences.Preferences
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reset))
+	assert.Empty(t, reset.Columns)
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+// Filters and parameters are preprocessed with the following precedence, from the strongest:
+//  1. enforced filters (e.g. RBAC restrictions) and the user namespaces restriction are ANDed to every filter group and can never be overridden
+//  2. request parameters and filters
+//  3. default exclusions apply unless the filter group explicitly filters on the same field
+//  4. query defaults (saved query parameters) only apply to parameters missing from the request
+
+// withQueryDefaults returns the request parameters completed with the configured query defaults
+func withQueryDefaults(cfg *loki.Config, params url.Values) url.Values {
+	if len(cfg.QueryDefaults) == 0 {
+		return params
+	}
+	merged := url.Values{}
+	for k, v := range cfg.QueryDefaults {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	return merged
+}
+
+// preprocessFilters builds the filter groups of a request: user filters with their convenience parameters
+// (ports, service, workload), then default exclusions, enforced filters and the namespaces restriction
+func preprocessFilters(ctx context.Context, cfg *loki.Config, params url.Values) (filters.MultiQueries, int, error) {
+	filterGroups, code, err := parseFilters(cfg, params.Get(filtersKey))
+	if err != nil {
+		return nil, code, err
+	}
+	filterGroups, err = addPortsFilter(params.Get(portsKey), filterGroups)
+	if err != nil {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+	}
+	filterGroups, err = addServiceFilter(cfg.ServicePorts, params.Get(serviceKey), filterGroups)
+	if err != nil {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+	}
+	filterGroups, err = addWorkloadFilter(params.Get(workloadKey), filterGroups)
+	if err != nil {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+	}
+	if cfg.DefaultExclusions != "" {
+		exclusions, _, err := parseFilters(cfg, cfg.DefaultExclusions)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("invalid default exclusions: %w", err)
+		}
+		filterGroups = applyDefaultExclusions(filterGroups, exclusions)
+	}
+	mandatory, code, err := mandatoryFilters(ctx, cfg)
+	if err != nil {
+		return nil, code, err
+	}
+	if mandatory != nil {
+		filterGroups = andGroups(filterGroups, mandatory)
+	}
+	return filterGroups, http.StatusOK, nil
+}
+
+// mandatoryFilters returns the filter groups that every query of the request must match: the enforced filters
+// ANDed with the namespaces restriction, or nil when there are none
+func mandatoryFilters(ctx context.Context, cfg *loki.Config) (filters.MultiQueries, int, error) {
+	var mandatory filters.MultiQueries
+	if cfg.EnforcedFilters != "" {
+		enforced, _, err := parseFilters(cfg, cfg.EnforcedFilters)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("invalid enforced filters: %w", err)
+		}
+		mandatory = enforced
+	}
+	if restriction, restricted := namespaceRestriction(ctx); restricted {
+		mandatory = andGroups(mandatory, restriction)
+	}
+	return mandatory, http.StatusOK, nil
+}
+
+// applyDefaultExclusions ANDs the exclusion groups to every group, except the matches overridden by an explicit
+// (non negated) filter on the same field in the group. An exclusion group left empty matches anything, so that
+// the group gets no exclusion.
+func applyDefaultExclusions(groups, exclusions filters.MultiQueries) filters.MultiQueries {
+	if len(groups) == 0 {
+		groups = filters.MultiQueries{{}}
+	}
+	var result filters.MultiQueries
+	for _, group := range groups {
+		explicit := map[string]struct{}{}
+		for _, m := range group {
+			if !m.Not {
+				explicit[m.Key] = struct{}{}
+			}
+		}
+		var applicable filters.MultiQueries
+		for _, exclusionGroup := range exclusions {
+			var kept filters.SingleQuery
+			for _, m := range exclusionGroup {
+				if _, overridden := explicit[m.Key]; !overridden {
+					kept = append(kept, m)
+				}
+			}
+			if len(kept) == 0 {
+				applicable = nil
+				break
+			}
+			applicable = append(applicable, kept)
+		}
+		if len(applicable) == 0 {
+			result = append(result, group)
+		} else {
+			result = append(result, andGroups(filters.MultiQueries{group}, applicable)...)
+		}
+	}
+	return result
+}
+
+// andGroups intersects two unions of groups, resulting in the cross product of their groups
+func andGroups(groups, other filters.MultiQueries) filters.MultiQueries {
+	if len(groups) == 0 {
+		return other
+	}
+	var result filters.MultiQueries
+	for _, g := range groups {
+		for _, o := range other {
+			merged := make(filters.SingleQuery, 0, len(g)+len(o))
+			merged = append(merged, g...)
+			merged = append(merged, o...)
+			result = append(result, merged)
+		}
+	}
+	return result
+}
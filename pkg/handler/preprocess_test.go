@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func TestPreprocessFilters_EnforcedWins(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.EnforcedFilters = `SrcK8S_Namespace="a"`
+
+	// the user tries to look at another namespace: both apply, hence nothing outside of "a"
+	groups, _, err := preprocessFilters(context.Background(), &cfg, url.Values{filtersKey: {`SrcK8S_Namespace="b"|DstPort=443`}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("SrcK8S_Namespace", `"b"`), filters.NewMatch("SrcK8S_Namespace", `"a"`)},
+		{filters.NewMatch("DstPort", "443"), filters.NewMatch("SrcK8S_Namespace", `"a"`)},
+	}, groups)
+
+	// without filters
+	groups, _, err = preprocessFilters(context.Background(), &cfg, url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{filters.NewMatch("SrcK8S_Namespace", `"a"`)}}, groups)
+}
+
+func TestPreprocessFilters_ExclusionsOverridable(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.DefaultExclusions = `SrcK8S_Namespace!="openshift-monitoring"&DstK8S_Namespace!="openshift-monitoring"`
+
+	groups, _, err := preprocessFilters(context.Background(), &cfg, url.Values{filtersKey: {`SrcK8S_Namespace="openshift-monitoring"|DstPort=443`}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		// explicitly requested: the related exclusion is dropped, the other one remains
+		{filters.NewMatch("SrcK8S_Namespace", `"openshift-monitoring"`), filters.NewNotMatch("DstK8S_Namespace", `"openshift-monitoring"`)},
+		{
+			filters.NewMatch("DstPort", "443"),
+			filters.NewNotMatch("SrcK8S_Namespace", `"openshift-monitoring"`),
+			filters.NewNotMatch("DstK8S_Namespace", `"openshift-monitoring"`),
+		},
+	}, groups)
+}
+
+func TestPreprocessFilters_ExclusionGroups(t *testing.T) {
+	cfg := testLokiConfig
+	// flows out of the namespace, or to the DNS port
+	cfg.DefaultExclusions = `SrcK8S_Namespace!="a"|DstPort=53`
+
+	groups, _, err := preprocessFilters(context.Background(), &cfg, url.Values{filtersKey: {`Proto=6|DstPort=80`}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("Proto", "6"), filters.NewNotMatch("SrcK8S_Namespace", `"a"`)},
+		{filters.NewMatch("Proto", "6"), filters.NewMatch("DstPort", "53")},
+		// an explicit filter overriding a whole exclusion group lifts the exclusion
+		{filters.NewMatch("DstPort", "80")},
+	}, groups)
+}
+
+func TestPreprocessFilters_EnforcedOverExclusions(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.EnforcedFilters = `SrcK8S_Namespace!="kube-system"`
+	cfg.DefaultExclusions = `SrcK8S_Namespace!="kube-system"`
+
+	// overriding the exclusion does not lift the enforced filter
+	groups, _, err := preprocessFilters(context.Background(), &cfg, url.Values{filtersKey: {`SrcK8S_Namespace="kube-system"`}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("SrcK8S_Namespace", `"kube-system"`), filters.NewNotMatch("SrcK8S_Namespace", `"kube-system"`)},
+	}, groups)
+}
+
+func TestGetFlows_QueryDefaults(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.QueryDefaults = url.Values{limitKey: {"100"}, reporterKey: {"destination"}}
+
+	// request params win over defaults
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	_, _, err := getFlows(context.Background(), &cfg, lokiClientMock, url.Values{limitKey: {"5"}})
+	require.NoError(t, err)
+	query := lokiClientMock.Calls[0].Arguments.String(0)
+	assert.Contains(t, query, "limit=5")
+	assert.Contains(t, query, `FlowDirection="0"`)
+
+	// defaults apply when missing
+	lokiClientMock = mockStreamsResponse(t, model.Streams{})
+	_, _, err = getFlows(context.Background(), &cfg, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), "limit=100")
+}
+
+func TestPreprocessFilters_NamespaceRestriction(t *testing.T) {
+	ctx := auth.WithAllowedNamespaces(context.Background(), []string{"a", "b"})
+
+	groups, _, err := preprocessFilters(ctx, &testLokiConfig, url.Values{filtersKey: {`DstPort=443`}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("DstPort", "443"), filters.NewMatch("SrcK8S_Namespace", `"a","b"`)},
+		{
+			filters.NewMatch("DstPort", "443"),
+			filters.NewNotMatch("SrcK8S_Namespace", `"a","b"`),
+			filters.NewMatch("DstK8S_Namespace", `"a","b"`),
+		},
+	}, groups)
+
+	// the restriction is injected in the Loki queries
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	_, _, err = getFlows(ctx, &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	require.Len(t, lokiClientMock.Calls, 2)
+	queries := []string{lokiClientMock.Calls[0].Arguments.String(0), lokiClientMock.Calls[1].Arguments.String(0)}
+	assert.Contains(t, queries, `http://loki/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace=~"^a$|^b$"}`)
+	assert.Contains(t, queries, `http://loki/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace!~"^a$|^b$",DstK8S_Namespace=~"^a$|^b$"}`)
+}
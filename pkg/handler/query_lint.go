@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+// broadTimeRange is the time span beyond which queries are considered expensive
+const broadTimeRange = 24 * time.Hour
+
+// lowCardinalityScopes are the topology scopes that do not group by individual resources or addresses
+var lowCardinalityScopes = map[string]struct{}{
+	"app":       {},
+	"host":      {},
+	"namespace": {},
+	"owner":     {},
+}
+
+// lintQuery statically looks for known expensive patterns in a query, returning non-fatal warnings.
+// It does not run anything: start and end are the parsed query bounds (seconds, possibly empty)
+// and topologyScope is only provided for topology queries.
+func lintQuery(cfg *loki.Config, groups filters.MultiQueries, start, end string, topologyScope *string) []string {
+	var warnings []string
+	for _, group := range groups {
+		hasLabel := false
+		for _, m := range group {
+			if m.Regex && strings.HasPrefix(m.Values, ".*") {
+				warnings = append(warnings, fmt.Sprintf("regular expression on %s starts with '.*': this prevents any optimization, consider a more specific pattern", m.Key))
+			}
+			if cfg.IsLabel(m.Key) && !m.Not && !m.Exists {
+				hasLabel = true
+			}
+		}
+		if len(group) > 0 && !hasLabel {
+			warnings = append(warnings, fmt.Sprintf("filters %s only apply on unindexed fields: consider adding a filter on an indexed label (%s)", formatGroup(group), strings.Join(sortedLabels(cfg), ", ")))
+		}
+	}
+	if start != "" {
+		if startSec, err := strconv.ParseInt(start, 10, 64); err == nil {
+			endSec := time.Now().Unix()
+			if e, err := strconv.ParseInt(end, 10, 64); err == nil && end != "" {
+				endSec = e
+			}
+			if span := time.Duration(endSec-startSec) * time.Second; span > broadTimeRange {
+				warnings = append(warnings, fmt.Sprintf("time range of %s is very broad: consider narrowing it", span))
+			}
+		}
+	}
+	if topologyScope != nil {
+		if _, ok := lowCardinalityScopes[*topologyScope]; !ok {
+			warnings = append(warnings, "grouping by individual resources has a high cardinality: consider a namespace, owner or host scope")
+		}
+	}
+	return warnings
+}
+
+func formatGroup(group filters.SingleQuery) string {
+	keys := make([]string, 0, len(group))
+	for _, m := range group {
+		keys = append(keys, m.Key)
+	}
+	return "[" + strings.Join(keys, ",") + "]"
+}
+
+func sortedLabels(cfg *loki.Config) []string {
+	labels := make([]string, 0, len(cfg.Labels))
+	for l := range cfg.Labels {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func TestLintQuery(t *testing.T) {
+	cfg := testLokiConfig
+	namespace := "namespace"
+
+	// selective query: no warning
+	groups, err := filters.Parse("SrcK8S_Namespace=a&SrcK8S_Name=~web-.*")
+	require.NoError(t, err)
+	assert.Empty(t, lintQuery(&cfg, groups, "", "", &namespace))
+
+	// leading .* regex
+	groups, err = filters.Parse("SrcK8S_Namespace=a&SrcK8S_Name=~.*-web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"regular expression on SrcK8S_Name starts with '.*': this prevents any optimization, consider a more specific pattern",
+	}, lintQuery(&cfg, groups, "", "", nil))
+
+	// unindexed fields only, in one of the groups
+	groups, err = filters.Parse("SrcK8S_Namespace=a|SrcPort=443&Proto=6")
+	require.NoError(t, err)
+	warnings := lintQuery(&cfg, groups, "", "", nil)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "filters [SrcPort,Proto] only apply on unindexed fields")
+
+	// broad time range
+	start := strconv.FormatInt(time.Now().Add(-48*time.Hour).Unix(), 10)
+	assert.Equal(t, []string{"time range of 48h0m0s is very broad: consider narrowing it"}, lintQuery(&cfg, nil, start, "", nil))
+	end := strconv.FormatInt(time.Now().Add(-47*time.Hour).Unix(), 10)
+	assert.Empty(t, lintQuery(&cfg, nil, start, end, nil))
+
+	// high cardinality group by
+	resource := "resource"
+	assert.Equal(t, []string{
+		"grouping by individual resources has a high cardinality: consider a namespace, owner or host scope",
+	}, lintQuery(&cfg, nil, "", "", &resource))
+}
+
+func TestGetFlows_LintWarnings(t *testing.T) {
+	qr, _, err := getFlows(context.Background(), &testLokiConfig, mockStreamsResponse(t, model.Streams{}), url.Values{filtersKey: {"DstPort=443"}})
+	require.NoError(t, err)
+	require.Len(t, qr.Warnings, 1)
+	assert.Contains(t, qr.Warnings[0], "only apply on unindexed fields")
+}
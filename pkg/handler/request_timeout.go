@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+)
+
+const timeoutKey = "timeout"
+
+// getRequestTimeout returns the timeout requested as a duration (e.g. 30s or 2m) or in seconds, capped to the
+// configured max. Without timeout parameter, the max applies.
+func getRequestTimeout(cfg *loki.Config, raw string) (time.Duration, error) {
+	if raw == "" {
+		return cfg.MaxRequestTimeout, nil
+	}
+	timeout, err := parseRelativeDuration(raw)
+	if err != nil {
+		seconds, errSec := strconv.ParseFloat(raw, 64)
+		if errSec != nil || seconds < 0 {
+			return 0, fmt.Errorf("invalid %s parameter: %q", timeoutKey, raw)
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid %s parameter: %q", timeoutKey, raw)
+	}
+	if cfg.MaxRequestTimeout > 0 && timeout > cfg.MaxRequestTimeout {
+		timeout = cfg.MaxRequestTimeout
+	}
+	return timeout, nil
+}
+
+// RequestTimeout bounds the request context, and so the Loki queries made for it, to the request timeout
+func RequestTimeout(cfg *loki.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout, err := getRequestTimeout(cfg, r.URL.Query().Get(timeoutKey))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, err))
+				return
+			}
+			if timeout > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), timeout)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
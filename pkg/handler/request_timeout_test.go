@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRequestTimeout(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.MaxRequestTimeout = time.Minute
+
+	for _, tc := range []struct {
+		raw      string
+		expected time.Duration
+	}{
+		{raw: "", expected: time.Minute},
+		{raw: "10s", expected: 10 * time.Second},
+		{raw: "15", expected: 15 * time.Second},
+		{raw: "0.5", expected: 500 * time.Millisecond},
+		// capped to the max
+		{raw: "1h", expected: time.Minute},
+	} {
+		timeout, err := getRequestTimeout(&cfg, tc.raw)
+		require.NoError(t, err, tc.raw)
+		assert.Equal(t, tc.expected, timeout, tc.raw)
+	}
+	for _, raw := range []string{"soon", "-5s", "0"} {
+		_, err := getRequestTimeout(&cfg, raw)
+		assert.Error(t, err, raw)
+	}
+
+	// no max
+	cfg.MaxRequestTimeout = 0
+	timeout, err := getRequestTimeout(&cfg, "")
+	require.NoError(t, err)
+	assert.Zero(t, timeout)
+	timeout, err = getRequestTimeout(&cfg, "1h")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, timeout)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.MaxRequestTimeout = time.Minute
+	var deadline time.Time
+	var hasDeadline bool
+	h := RequestTimeout(&cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/loki/flows?timeout=5s", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.True(t, hasDeadline)
+	assert.WithinDuration(t, time.Now().Add(5*time.Second), deadline, time.Second)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/loki/flows?timeout=never", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var resp errorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, ErrorCodeInvalidRequest, resp.Code)
+}
@@ -1,16 +1,20 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	neturl "net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
 	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
@@ -21,7 +25,7 @@ import (
 
 func GetNamespaces(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, false)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
 		var code int
 		startTime := time.Now()
 		defer func() {
@@ -32,37 +36,58 @@ func GetNamespaces(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request
 		values := []string{}
 
 		// Fetch and merge values for SrcK8S_Namespace and DstK8S_Namespace
-		values1, code, err := getLabelValues(cfg, lokiClient, fields.SrcNamespace)
+		values1, code, err := getLabelValues(r.Context(), cfg, lokiClient, fields.SrcNamespace)
 		if err != nil {
-			writeError(w, code, "Error while fetching label source namespace values from Loki: "+err.Error())
+			writeError(w, code, fmt.Errorf("Error while fetching label source namespace values from Loki: %w", err))
 			return
 		}
 		values = append(values, values1...)
 
-		values2, code, err := getLabelValues(cfg, lokiClient, fields.DstNamespace)
+		values2, code, err := getLabelValues(r.Context(), cfg, lokiClient, fields.DstNamespace)
 		if err != nil {
-			writeError(w, code, "Error while fetching label destination namespace values from Loki: "+err.Error())
+			writeError(w, code, fmt.Errorf("Error while fetching label destination namespace values from Loki: %w", err))
 			return
 		}
 		values = append(values, values2...)
 
+		allowed := []string{}
+		for _, ns := range utils.NonEmpty(utils.Dedup(values)) {
+			if isNamespaceAllowed(r.Context(), ns) {
+				allowed = append(allowed, ns)
+			}
+		}
 		code = http.StatusOK
-		writeJSON(w, code, utils.NonEmpty(utils.Dedup(values)))
+		writeJSON(w, code, allowed)
 	}
 }
 
-func getLabelValues(cfg *loki.Config, lokiClient httpclient.Caller, label string) ([]string, int, error) {
+func getLabelValues(ctx context.Context, cfg *loki.Config, lokiClient httpclient.Caller, label string) ([]string, int, error) {
+	return getLabelValuesInRange(ctx, cfg, lokiClient, label, "", "")
+}
+
+// getLabelValuesInRange gets the label values seen between start and end (in seconds), when provided
+func getLabelValuesInRange(ctx context.Context, cfg *loki.Config, lokiClient httpclient.Caller, label, start, end string) ([]string, int, error) {
 	baseURL := strings.TrimRight(cfg.URL.String(), "/")
 	url := fmt.Sprintf("%s/loki/api/v1/label/%s/values", baseURL, label)
-	hlog.Debugf("getLabelValues URL: %s", url)
+	timeRange := neturl.Values{}
+	if start != "" {
+		timeRange.Set("start", start)
+	}
+	if end != "" {
+		timeRange.Set("end", end)
+	}
+	if len(timeRange) > 0 {
+		url += "?" + timeRange.Encode()
+	}
+	hlog.WithContext(ctx).Debugf("getLabelValues URL: %s", url)
 
-	resp, code, err := lokiClient.Get(url)
+	resp, code, err := lokiClient.Get(ctx, url)
 	if err != nil {
-		return nil, http.StatusServiceUnavailable, err
+		return nil, lokiCallErrorStatus(err), classifyLokiCallError(err)
 	}
 	if code != http.StatusOK {
-		newCode, msg := getLokiError(resp, code)
-		return nil, newCode, errors.New(msg)
+		newCode, err := newLokiError(resp, code)
+		return nil, newCode, err
 	}
 	hlog.Tracef("GetFlows raw response: %s", resp)
 	var lvr model.LabelValuesResponse
@@ -75,7 +100,7 @@ func getLabelValues(cfg *loki.Config, lokiClient httpclient.Caller, label string
 
 func GetNames(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, false)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
 		var code int
 		startTime := time.Now()
 		defer func() {
@@ -89,16 +114,16 @@ func GetNames(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 		names := []string{}
 
 		// TODO: parallelize
-		names1, code, err := getNamesForPrefix(cfg, lokiClient, fields.Src, kind, namespace)
+		names1, code, err := getNamesForPrefix(r.Context(), cfg, lokiClient, fields.Src, kind, namespace)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
 		names = append(names, names1...)
 
-		names2, code, err := getNamesForPrefix(cfg, lokiClient, fields.Dst, kind, namespace)
+		names2, code, err := getNamesForPrefix(r.Context(), cfg, lokiClient, fields.Dst, kind, namespace)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
 		names = append(names, names2...)
@@ -108,10 +133,16 @@ func GetNames(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getNamesForPrefix(cfg *loki.Config, lokiClient httpclient.Caller, prefix, kind, namespace string) ([]string, int, error) {
+func getNamesForPrefix(ctx context.Context, cfg *loki.Config, lokiClient httpclient.Caller, prefix, kind, namespace string) ([]string, int, error) {
 	lokiParams := filters.SingleQuery{}
 	if namespace != "" {
+		if !isNamespaceAllowed(ctx, namespace) {
+			return nil, http.StatusForbidden, withErrorCode(ErrorCodeForbidden, fmt.Errorf("namespace %s is not allowed", namespace))
+		}
 		lokiParams = append(lokiParams, filters.NewMatch(prefix+fields.Namespace, exact(namespace)))
+	} else if namespaces, restricted := auth.GetAllowedNamespaces(ctx); restricted {
+		// only names of the allowed namespaces
+		lokiParams = append(lokiParams, filters.NewMatch(prefix+fields.Namespace, exactValues(namespaces)))
 	}
 	var fieldToExtract string
 	if utils.IsOwnerKind(kind) {
@@ -124,20 +155,20 @@ func getNamesForPrefix(cfg *loki.Config, lokiClient httpclient.Caller, prefix, k
 
 	queryBuilder := loki.NewFlowQueryBuilderWithDefaults(cfg)
 	if err := queryBuilder.Filters(lokiParams); err != nil {
-		return nil, http.StatusBadRequest, err
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
 	}
 
 	query := queryBuilder.Build()
-	resp, code, err := executeLokiQuery(query, lokiClient)
+	resp, code, err := executeLokiQuery(ctx, query, lokiClient)
 	if err != nil {
-		return nil, code, errors.New("Loki query failed: " + err.Error())
+		return nil, code, fmt.Errorf("Loki query failed: %w", err)
 	}
 	hlog.Tracef("GetNames raw response: %s", resp)
 
 	var qr model.QueryResponse
 	err = json.Unmarshal(resp, &qr)
 	if err != nil {
-		hlog.WithError(err).Errorf("cannot unmarshal, response was: %v", string(resp))
+		hlog.WithContext(ctx).WithError(err).Errorf("cannot unmarshal, response was: %v", string(resp))
 		return nil, http.StatusInternalServerError, errors.New("Failed to unmarshal Loki response: " + err.Error())
 	}
 
@@ -170,8 +201,13 @@ func extractDistinctValues(field string, streams model.Streams) []string {
 					continue
 				}
 				if v, ok := line[field]; ok {
-					if str, ok := v.(string); ok && len(str) > 0 {
-						values = append(values, str)
+					switch typed := v.(type) {
+					case string:
+						if len(typed) > 0 {
+							values = append(values, typed)
+						}
+					case float64:
+						values = append(values, strconv.FormatFloat(typed, 'f', -1, 64))
 					}
 				}
 			}
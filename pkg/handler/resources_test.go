@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"net/url"
 	"testing"
 
@@ -32,7 +33,7 @@ func TestGetSourceOwnerNames(t *testing.T) {
 			url,
 		)
 	})
-	_, _, _ = getNamesForPrefix(&testLokiConfig, lokiClientMock, "Src", "Deployment", "default")
+	_, _, _ = getNamesForPrefix(context.Background(), &testLokiConfig, lokiClientMock, "Src", "Deployment", "default")
 
 	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
 }
@@ -46,7 +47,7 @@ func TestGetDestPodNames(t *testing.T) {
 			url,
 		)
 	})
-	_, _, _ = getNamesForPrefix(&testLokiConfig, lokiClientMock, "Dst", "Pod", "default")
+	_, _, _ = getNamesForPrefix(context.Background(), &testLokiConfig, lokiClientMock, "Dst", "Pod", "default")
 
 	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
 }
@@ -60,7 +61,7 @@ func TestGetSourceNodeNames(t *testing.T) {
 			url,
 		)
 	})
-	_, _, _ = getNamesForPrefix(&testLokiConfig, lokiClientMock, "Src", "Node", "")
+	_, _, _ = getNamesForPrefix(context.Background(), &testLokiConfig, lokiClientMock, "Src", "Node", "")
 
 	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
 }
@@ -74,7 +75,7 @@ func TestGetLabelValues(t *testing.T) {
 			url,
 		)
 	})
-	_, _, _ = getLabelValues(&testLokiConfig, lokiClientMock, "DstK8S_Namespace")
+	_, _, _ = getLabelValues(context.Background(), &testLokiConfig, lokiClientMock, "DstK8S_Namespace")
 
 	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
 }
@@ -1,13 +1,19 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	csvdata "github.com/netobserv/network-observability-console-plugin/pkg/handler/csv"
+	"github.com/netobserv/network-observability-console-plugin/pkg/handler/exposition"
+	"github.com/netobserv/network-observability-console-plugin/pkg/handler/geojson"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
 )
 
@@ -24,7 +30,7 @@ func writeJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
 		hlog.Errorf("Marshalling error while responding JSON: %v", err)
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -36,10 +42,84 @@ func writeJSON(w http.ResponseWriter, code int, payload interface{}) {
 	}
 }
 
+// streamFlushEntries is the number of entries written between flushes of streamed responses
+const streamFlushEntries = 500
+
+// streamsPlaceholder stands for the streams in the envelope of streamed responses
+type streamsPlaceholder struct{}
+
+func (streamsPlaceholder) Type() model.ResultType { return model.ResultTypeStream }
+
+func (streamsPlaceholder) MarshalJSON() ([]byte, error) { return []byte("null"), nil }
+
+var streamsPlaceholderField = []byte(`"result":null`)
+
+// writeJSONStreams writes a streams response incrementally, one stream at a time with periodic flushes, so that
+// large results don't need to be fully encoded in memory and clients get the first bytes early.
+// The output is the same as writeJSON.
+func writeJSONStreams(w http.ResponseWriter, code int, qr *model.AggregatedQueryResponse) {
+	streams, ok := qr.Result.(model.Streams)
+	if !ok {
+		writeJSON(w, code, qr)
+		return
+	}
+	envelope := *qr
+	envelope.Result = streamsPlaceholder{}
+	encoded, err := json.Marshal(&envelope)
+	if err != nil {
+		hlog.Errorf("Marshalling error while responding JSON: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	idx := bytes.Index(encoded, streamsPlaceholderField)
+	if idx < 0 {
+		writeJSON(w, code, qr)
+		return
+	}
+	head, tail := encoded[:idx], encoded[idx+len(streamsPlaceholderField):]
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	flusher, _ := w.(http.Flusher)
+	write := func(b []byte) bool {
+		if _, err := w.Write(b); err != nil {
+			hlog.Errorf("Error while responding JSON: %v", err)
+			return false
+		}
+		return true
+	}
+	if !write(head) || !write([]byte(`"result":[`)) {
+		return
+	}
+	unflushed := 0
+	for i := range streams {
+		if i > 0 && !write([]byte{','}) {
+			return
+		}
+		stream, err := json.Marshal(&streams[i])
+		if err != nil {
+			// the status is already sent, the truncated body is invalid JSON
+			hlog.Errorf("Marshalling error while responding JSON: %v", err)
+			return
+		}
+		if !write(stream) {
+			return
+		}
+		unflushed += len(streams[i].Entries)
+		if flusher != nil && unflushed >= streamFlushEntries {
+			flusher.Flush()
+			unflushed = 0
+		}
+	}
+	if write([]byte{']'}) {
+		write(tail)
+	}
+}
+
 func writeCSV(w http.ResponseWriter, code int, qr *model.AggregatedQueryResponse, columns []string) {
 	data, err := csvdata.GetCSVData(qr, columns)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
 	hlog.Tracef("CSV data: %v", data)
@@ -55,17 +135,82 @@ func writeCSV(w http.ResponseWriter, code int, qr *model.AggregatedQueryResponse
 		//write csv row
 		err := writer.Write(row)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, fmt.Sprintf("Cannot write row %s", row))
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("Cannot write row %s", row))
 			return
 		}
 	}
 	writer.Flush()
 }
 
-type errorResponse struct{ Message string }
+func writeGeoJSON(w http.ResponseWriter, code int, qr *model.AggregatedQueryResponse) {
+	fc, err := geojson.GetGeoJSON(qr)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	response, err := json.Marshal(fc)
+	if err != nil {
+		hlog.Errorf("Marshalling error while responding GeoJSON: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(code)
+	_, err = w.Write(response)
+	if err != nil {
+		hlog.Errorf("Error while responding GeoJSON: %v", err)
+	}
+}
+
+func writeExposition(w http.ResponseWriter, code int, qr *model.AggregatedQueryResponse, metricName string) {
+	text, err := exposition.GetExposition(qr, metricName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(code)
+	_, err = w.Write([]byte(text))
+	if err != nil {
+		hlog.Errorf("Error while responding exposition: %v", err)
+	}
+}
+
+// errorResponse is the envelope of all error responses
+type errorResponse struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// Details holds the technical cause, e.g. the Loki error text, when Message is a generic explanation
+	Details string `json:"details,omitempty"`
+	// LokiStatus is the status of the Loki response, when the error comes from Loki
+	LokiStatus int `json:"lokiStatus,omitempty"`
+	// RetryAfter is the number of seconds after which the request can be retried, when known
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
 
-func writeError(w http.ResponseWriter, code int, message string) {
-	response, err := json.Marshal(errorResponse{Message: message})
+func writeError(w http.ResponseWriter, code int, e error) {
+	message := e.Error()
+	if code == statusClientClosedRequest {
+		// nobody is listening anymore
+		hlog.Debugf("Request cancelled by the client: %s", message)
+		return
+	}
+	resp := errorResponse{Code: getErrorCode(code, e), Message: message}
+	var lokiErr *lokiError
+	if errors.As(e, &lokiErr) {
+		resp.LokiStatus = lokiErr.status
+		if explanation, ok := lokiErrorMessages[resp.Code]; ok {
+			resp.Message = explanation
+			resp.Details = message
+		}
+	}
+	if retry, ok := getRetryAfter(e); ok {
+		resp.RetryAfter = int(math.Ceil(retry.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(resp.RetryAfter))
+	}
+	response, err := json.Marshal(resp)
 	if err != nil {
 		hlog.Errorf("Marshalling error while responding an error: %v (message was: %s)", err, message)
 		code = http.StatusInternalServerError
@@ -77,3 +222,23 @@ func writeError(w http.ResponseWriter, code int, message string) {
 		hlog.Errorf("Error while responding an error: %v (message was: %s)", err, message)
 	}
 }
+
+// WriteUnauthorized responds to requests that failed the authentication checks
+func WriteUnauthorized(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusUnauthorized, withErrorCode(ErrorCodeUnauthorized, err))
+}
+
+type retryAfterError struct {
+	error
+	retryAfter time.Duration
+}
+
+func (e *retryAfterError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// WriteTooManyRequests responds to requests rejected because the client sent too many of them
+func WriteTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	err := &retryAfterError{error: errors.New("too many requests, please slow down"), retryAfter: retryAfter}
+	writeError(w, http.StatusTooManyRequests, withErrorCode(ErrorCodeRateLimited, err))
+}
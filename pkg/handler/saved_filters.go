@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedfilters"
+)
+
+// maxSavedFilterBody limits the size of saved filters requests body
+const maxSavedFilterBody = 64 * 1024
+
+// UserGetter returns the name of the user making the request
+type UserGetter func(ctx context.Context, header http.Header) (string, error)
+
+// SavedFilters serves the saved filters of the current user: list (GET) and creation (POST) without name in the
+// path, read (GET), creation or replacement (PUT) and deletion (DELETE) when the name is in the path
+func SavedFilters(store savedfilters.Store, getUser UserGetter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("SavedFilters", code, startTime)
+		}()
+
+		user, err := getUser(r.Context(), r.Header)
+		if err != nil {
+			code = http.StatusUnauthorized
+			WriteUnauthorized(w, err)
+			return
+		}
+		name, hasName := mux.Vars(r)["name"]
+
+		var resp interface{}
+		switch {
+		case r.Method == http.MethodGet && !hasName:
+			resp, code, err = listSavedFilters(r.Context(), store, user)
+		case r.Method == http.MethodGet:
+			resp, code, err = getSavedFilter(r.Context(), store, user, name)
+		case r.Method == http.MethodPost && !hasName:
+			resp, code, err = saveFilter(r, store, user, "", true)
+		case r.Method == http.MethodPut && hasName:
+			resp, code, err = saveFilter(r, store, user, name, false)
+		case r.Method == http.MethodDelete && hasName:
+			code, err = deleteSavedFilter(r.Context(), store, user, name)
+		default:
+			code, err = http.StatusMethodNotAllowed, withErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("method not allowed: %s", r.Method))
+		}
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		if resp == nil {
+			w.WriteHeader(code)
+			return
+		}
+		writeJSON(w, code, resp)
+	}
+}
+
+func listSavedFilters(ctx context.Context, store savedfilters.Store, user string) ([]savedfilters.SavedFilter, int, error) {
+	list, err := store.List(ctx, user)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot read saved filters: %w", err)
+	}
+	return list, http.StatusOK, nil
+}
+
+// getSavedFilter returns a filter of the user, or else a filter shared by another user
+func getSavedFilter(ctx context.Context, store savedfilters.Store, user, name string) (*savedfilters.SavedFilter, int, error) {
+	list, code, err := listSavedFilters(ctx, store, user)
+	if err != nil {
+		return nil, code, err
+	}
+	for i := range list {
+		if list[i].Name == name {
+			return &list[i], http.StatusOK, nil
+		}
+	}
+	return nil, http.StatusNotFound, withErrorCode(ErrorCodeNotFound, savedfilters.ErrNotFound)
+}
+
+func saveFilter(r *http.Request, store savedfilters.Store, user, name string, create bool) (*savedfilters.SavedFilter, int, error) {
+	var filter savedfilters.SavedFilter
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxSavedFilterBody)).Decode(&filter); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("cannot decode saved filter: %w", err)
+	}
+	if name != "" {
+		filter.Name = name
+	}
+	code := http.StatusOK
+	if create {
+		existing, err := store.List(r.Context(), user)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("cannot read saved filters: %w", err)
+		}
+		for _, f := range existing {
+			if f.Owner == user && f.Name == filter.Name {
+				return nil, http.StatusConflict, withErrorCode(ErrorCodeInvalidRequest, fmt.Errorf("saved filter already exists: %s", filter.Name))
+			}
+		}
+		code = http.StatusCreated
+	}
+	saved, err := store.Save(r.Context(), user, filter)
+	if errors.Is(err, savedfilters.ErrInvalid) {
+		return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, err)
+	} else if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot save filter: %w", err)
+	}
+	return saved, code, nil
+}
+
+func deleteSavedFilter(ctx context.Context, store savedfilters.Store, user, name string) (int, error) {
+	err := store.Delete(ctx, user, name)
+	if errors.Is(err, savedfilters.ErrNotFound) {
+		return http.StatusNotFound, withErrorCode(ErrorCodeNotFound, err)
+	} else if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("cannot delete saved filter: %w", err)
+	}
+	return http.StatusNoContent, nil
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedfilters"
+)
+
+func TestSavedFilters(t *testing.T) {
+	getUser := func(_ context.Context, header http.Header) (string, error) {
+		return header.Get("X-User"), nil
+	}
+	h := SavedFilters(savedfilters.NewMemoryStore(), getUser)
+	router := mux.NewRouter()
+	router.HandleFunc("/filters", h)
+	router.HandleFunc("/filters/{name}", h)
+	call := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call(http.MethodPost, "/filters", "alice", `{"name":"dns","filters":"DstPort=53","shared":true}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = call(http.MethodPost, "/filters", "alice", `{"name":"dns","filters":"DstPort=53"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = call(http.MethodPut, "/filters/web", "alice", `{"filters":"DstPort=443"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = call(http.MethodPut, "/filters/bad", "alice", `{"filters":"{\"xor\":[]}"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// shared filter visible from another user
+	rec = call(http.MethodGet, "/filters/dns", "bob", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var filter savedfilters.SavedFilter
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &filter))
+	assert.Equal(t, "alice", filter.Owner)
+	rec = call(http.MethodGet, "/filters/web", "bob", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = call(http.MethodDelete, "/filters/dns", "bob", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = call(http.MethodDelete, "/filters/dns", "alice", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = call(http.MethodGet, "/filters", "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list []savedfilters.SavedFilter
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, "web", list[0].Name)
+
+	rec = call(http.MethodPatch, "/filters/web", "alice", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
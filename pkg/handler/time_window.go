@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+const (
+	trustedCallerHeader = "X-Trusted-Caller-Token"
+)
+
+// isTrustedCaller returns true when the request carries the configured trusted caller token.
+// The token file is read on each call so that it can be rotated without restart.
+func isTrustedCaller(cfg *loki.Config, header http.Header) bool {
+	if cfg.TrustedCallerTokenPath == "" {
+		return false
+	}
+	provided := header.Get(trustedCallerHeader)
+	if provided == "" {
+		return false
+	}
+	bytes, err := os.ReadFile(cfg.TrustedCallerTokenPath)
+	if err != nil {
+		hlog.WithError(err).Errorf("failed to read trusted caller token: %s", cfg.TrustedCallerTokenPath)
+		return false
+	}
+	expected := strings.TrimSpace(string(bytes))
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) == 1
+}
+
+// checkTimeWindow enforces the max span and max lookback guards on the requested time window,
+// unless the caller is trusted (e.g. automated report jobs)
+func checkTimeWindow(cfg *loki.Config, params url.Values, header http.Header) (int, error) {
+	if cfg.MaxQuerySpan <= 0 && cfg.MaxQueryLookback <= 0 {
+		return http.StatusOK, nil
+	}
+	if isTrustedCaller(cfg, header) {
+		hlog.Debug("trusted caller: time window guards skipped")
+		return http.StatusOK, nil
+	}
+	// the default time range is checked as well
+	params = withQueryDefaults(cfg, params)
+	start, err := getStartTime(params)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(start) == 0 {
+		// no start means Loki default lookback applies
+		return http.StatusOK, nil
+	}
+	startSec, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Could not parse start time: %w", err)
+	}
+	now := time.Now().Unix()
+	endSec := now
+	end, err := getEndTime(params)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(end) > 0 {
+		endSec, err = strconv.ParseInt(end, 10, 64)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("Could not parse end time: %w", err)
+		}
+	}
+	if cfg.MaxQueryLookback > 0 && time.Duration(now-startSec)*time.Second > cfg.MaxQueryLookback {
+		return http.StatusBadRequest, withErrorCode(ErrorCodeQueryTooLarge, fmt.Errorf("start time is too far in the past: max lookback is %s", cfg.MaxQueryLookback))
+	}
+	if cfg.MaxQuerySpan > 0 && time.Duration(endSec-startSec)*time.Second > cfg.MaxQuerySpan {
+		return http.StatusBadRequest, withErrorCode(ErrorCodeQueryTooLarge, fmt.Errorf("time range is too large: max span is %s", cfg.MaxQuerySpan))
+	}
+	return http.StatusOK, nil
+}
+
+// applyIngestionDelay shifts the query end back to now minus the configured ingestion delay, when it is more recent,
+// so that the most recent, still incomplete data is not returned. It returns the end to use and, when shifted,
+// the effective end in seconds to echo to the client.
+func applyIngestionDelay(cfg *loki.Config, end string) (string, *int64) {
+	if cfg.IngestionDelay <= 0 {
+		return end, nil
+	}
+	cutoff := time.Now().Add(-cfg.IngestionDelay).Unix()
+	if len(end) > 0 {
+		if endSec, err := strconv.ParseInt(end, 10, 64); err == nil && endSec <= cutoff {
+			return end, nil
+		}
+	}
+	return strconv.FormatInt(cutoff, 10), &cutoff
+}
+
+// timeShard is a contiguous part of a query time range
+type timeShard struct {
+	start, end string
+}
+
+// missingRanges returns the time shards of the failed queries, ordered by filter group then time shard
+func missingRanges(shards []timeShard, failures []queryFailure) []model.TimeRange {
+	missing := map[int]struct{}{}
+	for _, f := range failures {
+		missing[f.index%len(shards)] = struct{}{}
+	}
+	var ranges []model.TimeRange
+	for i, shard := range shards {
+		if _, ok := missing[i]; ok {
+			ranges = append(ranges, model.TimeRange{Start: shard.start, End: shard.end})
+		}
+	}
+	return ranges
+}
+
+// shardTimeRange splits time ranges longer than the configured threshold into contiguous sub-ranges, to be queried
+// in parallel. The start is in seconds, the end in seconds or nanoseconds (next page of a cursor), kept as is for
+// the last shard. Ranges without start or end are not split.
+func shardTimeRange(cfg *loki.Config, start, end string) []timeShard {
+	unsplit := []timeShard{{start: start, end: end}}
+	if cfg.QueryShardThreshold <= 0 || cfg.QueryShards < 2 || start == "" || end == "" {
+		return unsplit
+	}
+	startNs, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return unsplit
+	}
+	startNs *= int64(time.Second)
+	endNs, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return unsplit
+	}
+	if endNs < 1e12 {
+		endNs *= int64(time.Second)
+	}
+	span := endNs - startNs
+	if span <= int64(cfg.QueryShardThreshold) {
+		return unsplit
+	}
+	size := span / int64(cfg.QueryShards)
+	shards := make([]timeShard, 0, cfg.QueryShards)
+	for i := 0; i < cfg.QueryShards; i++ {
+		shard := timeShard{
+			start: strconv.FormatInt(startNs+int64(i)*size, 10),
+			end:   strconv.FormatInt(startNs+int64(i+1)*size, 10),
+		}
+		if i == 0 {
+			shard.start = start
+		}
+		if i == cfg.QueryShards-1 {
+			shard.end = end
+		}
+		shards = append(shards, shard)
+	}
+	return shards
+}
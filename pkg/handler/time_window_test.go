@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func prepareTrustedToken(t *testing.T) string {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "trusted-token")
+	err := os.WriteFile(path, []byte("s3cr3t\n"), 0600)
+	require.NoError(t, err)
+	return path
+}
+
+func TestCheckTimeWindow_NoCaps(t *testing.T) {
+	cfg := loki.Config{}
+	params := url.Values{}
+	params.Set(startTimeKey, "0")
+	code, err := checkTimeWindow(&cfg, params, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestCheckTimeWindow_NonExempt(t *testing.T) {
+	cfg := loki.Config{
+		MaxQuerySpan:           time.Hour,
+		MaxQueryLookback:       24 * time.Hour,
+		TrustedCallerTokenPath: prepareTrustedToken(t),
+	}
+	now := time.Now().Unix()
+
+	// within caps
+	params := url.Values{}
+	params.Set(startTimeKey, strconv.FormatInt(now-1800, 10))
+	code, err := checkTimeWindow(&cfg, params, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	// span too large
+	params.Set(startTimeKey, strconv.FormatInt(now-7200, 10))
+	code, err = checkTimeWindow(&cfg, params, http.Header{})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, err.Error(), "max span")
+
+	// lookback too far, even with a small span
+	params.Set(startTimeKey, strconv.FormatInt(now-48*3600, 10))
+	params.Set(endTimeKey, strconv.FormatInt(now-48*3600+60, 10))
+	code, err = checkTimeWindow(&cfg, params, http.Header{})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, err.Error(), "max lookback")
+
+	// wrong token
+	header := http.Header{}
+	header.Set(trustedCallerHeader, "wrong")
+	_, err = checkTimeWindow(&cfg, params, header)
+	require.Error(t, err)
+}
+
+func TestCheckTimeWindow_QueryDefaults(t *testing.T) {
+	cfg := loki.Config{MaxQuerySpan: time.Hour, QueryDefaults: url.Values{timeRangeKey: {"7200"}}}
+
+	// the default time range is checked
+	code, err := checkTimeWindow(&cfg, url.Values{}, http.Header{})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// unless overridden
+	code, err = checkTimeWindow(&cfg, url.Values{timeRangeKey: {"300"}}, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestCheckTimeWindow_Exempt(t *testing.T) {
+	cfg := loki.Config{
+		MaxQuerySpan:           time.Hour,
+		MaxQueryLookback:       24 * time.Hour,
+		TrustedCallerTokenPath: prepareTrustedToken(t),
+	}
+	now := time.Now().Unix()
+	params := url.Values{}
+	params.Set(startTimeKey, strconv.FormatInt(now-30*24*3600, 10))
+	header := http.Header{}
+	header.Set(trustedCallerHeader, "s3cr3t")
+
+	code, err := checkTimeWindow(&cfg, params, header)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestApplyIngestionDelay(t *testing.T) {
+	cfg := testLokiConfig
+	// disabled by default
+	end, effective := applyIngestionDelay(&cfg, "")
+	assert.Equal(t, "", end)
+	assert.Nil(t, effective)
+
+	cfg.IngestionDelay = 30 * time.Second
+	expected := time.Now().Add(-30 * time.Second).Unix()
+
+	// "now" queries are shifted
+	end, effective = applyIngestionDelay(&cfg, "")
+	require.NotNil(t, effective)
+	assert.InDelta(t, expected, *effective, 1)
+	assert.Equal(t, strconv.FormatInt(*effective, 10), end)
+
+	// recent end too
+	end, effective = applyIngestionDelay(&cfg, strconv.FormatInt(time.Now().Unix(), 10))
+	require.NotNil(t, effective)
+	assert.InDelta(t, expected, *effective, 1)
+	assert.Equal(t, strconv.FormatInt(*effective, 10), end)
+
+	// past end is left untouched
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	end, effective = applyIngestionDelay(&cfg, past)
+	assert.Equal(t, past, end)
+	assert.Nil(t, effective)
+}
+
+func TestGetFlows_IngestionDelay(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.IngestionDelay = time.Minute
+	qr, _, err := getFlows(context.Background(), &cfg, mockStreamsResponse(t, model.Streams{}), url.Values{})
+	require.NoError(t, err)
+	require.NotNil(t, qr.EffectiveEndTime)
+	assert.InDelta(t, time.Now().Add(-time.Minute).Unix(), *qr.EffectiveEndTime, 1)
+}
+
+func TestShardTimeRange(t *testing.T) {
+	cfg := testLokiConfig
+	// disabled by default
+	assert.Equal(t, []timeShard{{start: "1680000000", end: "1680014400"}}, shardTimeRange(&cfg, "1680000000", "1680014400"))
+
+	cfg.QueryShardThreshold = 3 * time.Hour
+	cfg.QueryShards = 4
+	assert.Equal(t, []timeShard{
+		{start: "1680000000", end: "1680003600000000000"},
+		{start: "1680003600000000000", end: "1680007200000000000"},
+		{start: "1680007200000000000", end: "1680010800000000000"},
+		{start: "1680010800000000000", end: "1680014400"},
+	}, shardTimeRange(&cfg, "1680000000", "1680014400"))
+
+	// end in nanoseconds, from a cursor
+	assert.Len(t, shardTimeRange(&cfg, "1680000000", "1680014400000000001"), 4)
+	// under the threshold
+	assert.Len(t, shardTimeRange(&cfg, "1680000000", "1680010800"), 1)
+	// unbounded
+	assert.Len(t, shardTimeRange(&cfg, "", "1680014400"), 1)
+}
+
+func TestGetFlows_TimeShards(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.QueryShardThreshold = 3 * time.Hour
+	cfg.QueryShards = 4
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	params := url.Values{}
+	params.Set(startTimeKey, "1680000000")
+	params.Set(endTimeKey, "1680014399")
+	params.Set(filtersKey, "SrcK8S_Namespace=a|DstK8S_Namespace=b")
+	qr, _, err := getFlows(context.Background(), &cfg, lokiClientMock, params)
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 8)
+	assert.Equal(t, 8, qr.Stats.NumQueries)
+	lokiClientMock.AssertCalled(t, "Get", `http://loki/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace=~"(?i).*a.*"}&start=1680010800000000000&end=1680014400`)
+}
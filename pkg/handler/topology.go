@@ -1,25 +1,31 @@
 package handler
 
 import (
-	"errors"
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/netobserv/network-observability-console-plugin/pkg/handler/exposition"
 	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
 	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/tracing"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 )
 
 const (
-	metricTypeKey   = "type"
-	scopeKey        = "scope"
-	groupsKey       = "groups"
-	rateIntervalKey = "rateInterval"
-	stepKey         = "step"
+	metricTypeKey      = "type"
+	scopeKey           = "scope"
+	groupsKey          = "groups"
+	rateIntervalKey    = "rateInterval"
+	stepKey            = "step"
+	comparePreviousKey = "comparePrevious"
+
+	countMetricType = "count"
 
 	defaultRateInterval = "1m"
 	defaultStep         = "30s"
@@ -27,26 +33,55 @@ const (
 
 func GetTopology(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lokiClient := newLokiClient(cfg, r.Header, false)
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
 		var code int
 		startTime := time.Now()
 		defer func() {
 			metrics.ObserveHTTPCall("GetTopology", code, startTime)
 		}()
 
-		flows, code, err := getTopologyFlows(cfg, lokiClient, r.URL.Query())
+		params := r.URL.Query()
+		warnings := migrateDeprecatedParams(params)
+		writeWarningHeaders(w, warnings)
+		if params.Get(exportFormatKey) == exportPrometheusFormat {
+			if err := checkExportAllowed(r.Context(), cfg); err != nil {
+				code = http.StatusForbidden
+				writeError(w, code, err)
+				return
+			}
+		}
+		code, err := checkTimeWindow(cfg, params, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		reqCfg, code, err := withLabelsOverride(cfg, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+
+		flows, code, err := getTopologyFlows(r.Context(), reqCfg, lokiClient, params)
 		if err != nil {
-			writeError(w, code, err.Error())
+			writeError(w, code, err)
 			return
 		}
+		flows.Warnings = append(warnings, flows.Warnings...)
 
 		code = http.StatusOK
+		_, span := tracing.Start(r.Context(), "encode", tracing.KindInternal)
+		defer span.End()
+		if params.Get(exportFormatKey) == exportPrometheusFormat {
+			writeExposition(w, code, flows, exposition.GetMetricName(params.Get(metricTypeKey)))
+			return
+		}
 		writeJSON(w, code, flows)
 	}
 }
 
-func getTopologyFlows(cfg *loki.Config, client httpclient.Caller, params url.Values) (*model.AggregatedQueryResponse, int, error) {
-	hlog.Debugf("GetTopology query params: %s", params)
+func getTopologyFlows(ctx context.Context, cfg *loki.Config, client httpclient.Caller, params url.Values) (*model.AggregatedQueryResponse, int, error) {
+	hlog.WithContext(ctx).Debugf("GetTopology query params: %s", params)
+	params = withQueryDefaults(cfg, params)
 
 	start, err := getStartTime(params)
 	if err != nil {
@@ -56,10 +91,12 @@ func getTopologyFlows(cfg *loki.Config, client httpclient.Caller, params url.Val
 	if err != nil {
 		return nil, http.StatusBadRequest, err
 	}
+	end, effectiveEnd := applyIngestionDelay(cfg, end)
 	limit, reqLimit, err := getLimit(params)
 	if err != nil {
 		return nil, http.StatusBadRequest, err
 	}
+	limit, reqLimit = restrictedLimit(ctx, cfg, limit, reqLimit)
 	rateInterval := params.Get(rateIntervalKey)
 	if rateInterval == "" {
 		rateInterval = defaultRateInterval
@@ -73,44 +110,78 @@ func getTopologyFlows(cfg *loki.Config, client httpclient.Caller, params url.Val
 	recordType := constants.RecordType(params.Get(recordTypeKey))
 	scope := params.Get(scopeKey)
 	groups := params.Get(groupsKey)
-	rawFilters := params.Get(filtersKey)
-	filterGroups, err := filters.Parse(rawFilters)
+	_, span := tracing.Start(ctx, "parse filters", tracing.KindInternal)
+	filterGroups, code, err := preprocessFilters(ctx, cfg, params)
+	span.SetError(err)
+	span.End()
 	if err != nil {
-		return nil, http.StatusBadRequest, err
+		return nil, code, err
 	}
 
-	merger := loki.NewMatrixMerger(reqLimit)
-	if len(filterGroups) > 1 {
-		// match any, and multiple filters => run in parallel then aggregate
+	fetch := func(start, end string) (*model.AggregatedQueryResponse, int, error) {
+		merger := loki.NewMatrixMerger(reqLimit)
+		groupsFilters := filterGroups
+		if len(groupsFilters) == 0 {
+			groupsFilters = filters.MultiQueries{nil}
+		}
 		var queries []string
-		for _, group := range filterGroups {
-			query, code, err := buildTopologyQuery(cfg, group, start, end, limit, rateInterval, step, metricType, recordType, reporter, scope, groups)
+		var failures []queryFailure
+		var code int
+		var err error
+		_, buildSpan := tracing.Start(ctx, "build queries", tracing.KindInternal)
+		for _, group := range groupsFilters {
+			var query string
+			query, code, err = buildTopologyQuery(cfg, group, start, end, limit, rateInterval, step, metricType, recordType, reporter, scope, groups)
 			if err != nil {
-				return nil, code, errors.New("Can't build query: " + err.Error())
+				break
 			}
 			queries = append(queries, query)
 		}
-		code, err := fetchParallel(client, queries, merger)
+		buildSpan.SetError(err)
+		buildSpan.End()
 		if err != nil {
+			if len(filterGroups) > 1 {
+				err = withErrorCode(ErrorCodeInvalidFilter, fmt.Errorf("Can't build query: %w", err))
+			}
 			return nil, code, err
 		}
-	} else {
-		// else, run all at once
-		var filters filters.SingleQuery
-		if len(filterGroups) > 0 {
-			filters = filterGroups[0]
+		if len(queries) == 1 {
+			// single filter group => run all at once
+			code, err = fetchSingle(ctx, client, queries[0], merger)
+		} else {
+			// match any, and multiple filters => run in parallel then aggregate
+			failures, code, err = fetchPartial(ctx, cfg, client, queries, merger)
 		}
-		query, code, err := buildTopologyQuery(cfg, filters, start, end, limit, rateInterval, step, metricType, recordType, reporter, scope, groups)
 		if err != nil {
 			return nil, code, err
 		}
-		code, err = fetchSingle(client, query, merger)
+		qr := merger.Get()
+		if len(failures) > 0 {
+			qr.FailedQueries = failedQueries(queries, failures)
+			qr.MissingRanges = []model.TimeRange{{Start: start, End: end}}
+		}
+		return qr, http.StatusOK, nil
+	}
+
+	qr, code, err := fetch(start, end)
+	if err != nil {
+		return nil, code, err
+	}
+	if params.Get(comparePreviousKey) == "true" {
+		prevStart, prevEnd, err := getPreviousPeriod(start, end)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		prev, code, err := fetch(prevStart, prevEnd)
 		if err != nil {
 			return nil, code, err
 		}
+		qr.Comparison = comparePeriods(qr.Result, prev.Result, metricType == countMetricType)
+		qr.FailedQueries = append(qr.FailedQueries, prev.FailedQueries...)
+		qr.MissingRanges = append(qr.MissingRanges, prev.MissingRanges...)
 	}
-
-	qr := merger.Get()
+	qr.EffectiveEndTime = effectiveEnd
+	qr.Warnings = lintQuery(cfg, filterGroups, start, end, &scope)
 	qr.IsMock = cfg.UseMocks
 	qr.UnixTimestamp = time.Now().Unix()
 	hlog.Tracef("GetTopology response: %v", qr)
@@ -120,11 +191,11 @@ func getTopologyFlows(cfg *loki.Config, client httpclient.Caller, params url.Val
 func buildTopologyQuery(cfg *loki.Config, queryFilters filters.SingleQuery, start, end, limit, rateInterval, step, metricType string, recordType constants.RecordType, reporter constants.Reporter, scope, groups string) (string, int, error) {
 	qb, err := loki.NewTopologyQuery(cfg, start, end, limit, rateInterval, step, metricType, recordType, reporter, scope, groups)
 	if err != nil {
-		return "", http.StatusBadRequest, err
+		return "", http.StatusBadRequest, withErrorCode(ErrorCodeInvalidRequest, err)
 	}
 	err = qb.Filters(queryFilters)
 	if err != nil {
-		return "", http.StatusBadRequest, err
+		return "", http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
 	}
 	return EncodeQuery(qb.Build()), http.StatusOK, nil
 }
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	pmodel "github.com/prometheus/common/model"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+// getPreviousPeriod returns the start and end of the window of equal length immediately preceding [start, end]
+func getPreviousPeriod(start, end string) (string, string, error) {
+	if len(start) == 0 {
+		return "", "", errors.New("comparing with the previous period requires a start time or time range")
+	}
+	startSec, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return "", "", errors.New("Could not parse start time: " + err.Error())
+	}
+	endSec := time.Now().Unix()
+	if len(end) > 0 {
+		endSec, err = strconv.ParseInt(end, 10, 64)
+		if err != nil {
+			return "", "", errors.New("Could not parse end time: " + err.Error())
+		}
+	}
+	span := endSec - startSec
+	if span <= 0 {
+		return "", "", errors.New("end time must be after start time")
+	}
+	return strconv.FormatInt(startSec-span, 10), strconv.FormatInt(startSec, 10), nil
+}
+
+// comparePeriods computes, for each series found in any of both periods, the current and previous values
+// with their delta. Values are summed for counts, and averaged for rates.
+func comparePeriods(current, previous model.ResultValue, sum bool) []model.PeriodComparison {
+	cur := aggregateSeries(current, sum)
+	prev := aggregateSeries(previous, sum)
+	metrics := map[string]pmodel.Metric{}
+	for k, s := range cur {
+		metrics[k] = s.metric
+	}
+	for k, s := range prev {
+		if _, ok := metrics[k]; !ok {
+			metrics[k] = s.metric
+		}
+	}
+	keys := make([]string, 0, len(metrics))
+	for k := range metrics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	comparison := make([]model.PeriodComparison, 0, len(keys))
+	for _, k := range keys {
+		c := model.PeriodComparison{
+			Metric:   metrics[k],
+			Current:  cur[k].value,
+			Previous: prev[k].value,
+		}
+		c.Delta = c.Current - c.Previous
+		if c.Previous != 0 {
+			pct := c.Delta / c.Previous * 100
+			c.PercentChange = &pct
+		}
+		comparison = append(comparison, c)
+	}
+	return comparison
+}
+
+type aggregatedSeries struct {
+	metric pmodel.Metric
+	value  float64
+}
+
+func aggregateSeries(result model.ResultValue, sum bool) map[string]aggregatedSeries {
+	aggregated := map[string]aggregatedSeries{}
+	matrix, ok := result.(model.Matrix)
+	if !ok {
+		return aggregated
+	}
+	for _, stream := range matrix {
+		total := 0.0
+		for _, v := range stream.Values {
+			total += float64(v.Value)
+		}
+		if !sum && len(stream.Values) > 0 {
+			total /= float64(len(stream.Values))
+		}
+		aggregated[stream.Metric.String()] = aggregatedSeries{metric: stream.Metric, value: total}
+	}
+	return aggregated
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	pmodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func series(ns string, values ...float64) pmodel.SampleStream {
+	ss := pmodel.SampleStream{Metric: pmodel.Metric{"SrcK8S_Namespace": pmodel.LabelValue(ns)}}
+	for i, v := range values {
+		ss.Values = append(ss.Values, pmodel.SamplePair{Timestamp: pmodel.Time(i * 30000), Value: pmodel.SampleValue(v)})
+	}
+	return ss
+}
+
+func TestGetPreviousPeriod(t *testing.T) {
+	start, end, err := getPreviousPeriod("1000", "1600")
+	require.NoError(t, err)
+	assert.Equal(t, "400", start)
+	assert.Equal(t, "1000", end)
+
+	_, _, err = getPreviousPeriod("", "1600")
+	require.Error(t, err)
+}
+
+func TestComparePeriods(t *testing.T) {
+	current := model.Matrix{series("a", 10, 30), series("b", 5), series("new", 4)}
+	previous := model.Matrix{series("a", 10, 10), series("b", 0), series("gone", 8)}
+
+	// rates are averaged
+	comparison := comparePeriods(current, previous, false)
+	require.Len(t, comparison, 4)
+
+	assert.Equal(t, "a", string(comparison[0].Metric["SrcK8S_Namespace"]))
+	assert.Equal(t, 20.0, comparison[0].Current)
+	assert.Equal(t, 10.0, comparison[0].Previous)
+	assert.Equal(t, 10.0, comparison[0].Delta)
+	require.NotNil(t, comparison[0].PercentChange)
+	assert.Equal(t, 100.0, *comparison[0].PercentChange)
+
+	// previous is zero: no percent change
+	assert.Equal(t, "b", string(comparison[1].Metric["SrcK8S_Namespace"]))
+	assert.Equal(t, 5.0, comparison[1].Delta)
+	assert.Nil(t, comparison[1].PercentChange)
+
+	// series gone in current period
+	assert.Equal(t, "gone", string(comparison[2].Metric["SrcK8S_Namespace"]))
+	assert.Equal(t, 0.0, comparison[2].Current)
+	assert.Equal(t, -8.0, comparison[2].Delta)
+	require.NotNil(t, comparison[2].PercentChange)
+	assert.Equal(t, -100.0, *comparison[2].PercentChange)
+
+	// new series
+	assert.Equal(t, "new", string(comparison[3].Metric["SrcK8S_Namespace"]))
+	assert.Nil(t, comparison[3].PercentChange)
+
+	// counts are summed
+	comparison = comparePeriods(current, previous, true)
+	assert.Equal(t, 40.0, comparison[0].Current)
+	assert.Equal(t, 20.0, comparison[0].Previous)
+}
+
+func TestGetTopology_ComparePrevious(t *testing.T) {
+	marshal := func(m model.Matrix) []byte {
+		js, err := json.Marshal(model.QueryResponse{Data: model.QueryResponseData{ResultType: model.ResultTypeMatrix, Result: m}})
+		require.NoError(t, err)
+		return js
+	}
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool {
+		return strings.Contains(u, "start=1000&end=1601")
+	})).Return(marshal(model.Matrix{series("a", 30)}), 200, nil).Once()
+	lokiClientMock.On("Get", mock.MatchedBy(func(u string) bool {
+		return strings.Contains(u, "start=399&end=1000")
+	})).Return(marshal(model.Matrix{series("a", 20)}), 200, nil).Once()
+
+	params := url.Values{}
+	params.Set(startTimeKey, "1000")
+	params.Set(endTimeKey, "1600")
+	params.Set(comparePreviousKey, "true")
+	qr, _, err := getTopologyFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+	require.Len(t, qr.Comparison, 1)
+	assert.Equal(t, 30.0, qr.Comparison[0].Current)
+	assert.Equal(t, 20.0, qr.Comparison[0].Previous)
+	assert.Equal(t, 50.0, *qr.Comparison[0].PercentChange)
+}
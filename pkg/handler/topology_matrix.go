@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	pmodel "github.com/prometheus/common/model"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+const (
+	maxDimensionKey = "maxDimension"
+	// matrixMaxDimension caps the number of rows and columns of connection matrices
+	matrixMaxDimension     = 200
+	defaultMatrixDimension = 50
+	defaultMatrixScope     = "namespace"
+)
+
+// matrixEndpoint returns the source or destination label of a series for the given scope
+func matrixEndpoint(metric pmodel.Metric, scope, prefix string) string {
+	switch scope {
+	case "host":
+		return string(metric[pmodel.LabelName(prefix+fields.HostName)])
+	case "owner":
+		return fmt.Sprintf("%s/%s", metric[pmodel.LabelName(prefix+fields.Namespace)], metric[pmodel.LabelName(prefix+fields.OwnerName)])
+	default:
+		return string(metric[pmodel.LabelName(prefix+fields.Namespace)])
+	}
+}
+
+func GetTopologyMatrix(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("GetTopologyMatrix", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		warnings := migrateDeprecatedParams(params)
+		writeWarningHeaders(w, warnings)
+		code, err := checkTimeWindow(cfg, params, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		reqCfg, code, err := withLabelsOverride(cfg, r.Header)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+
+		matrix, code, err := getTopologyMatrix(r.Context(), reqCfg, lokiClient, params)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		code = http.StatusOK
+		writeJSON(w, code, matrix)
+	}
+}
+
+func getTopologyMatrix(ctx context.Context, cfg *loki.Config, client httpclient.Caller, params url.Values) (*model.ConnectionMatrix, int, error) {
+	scope := params.Get(scopeKey)
+	switch scope {
+	case "":
+		scope = defaultMatrixScope
+	case "namespace", "host", "owner":
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid scope for connection matrix: %s (expected namespace, host or owner)", scope)
+	}
+	maxDimension := defaultMatrixDimension
+	if raw := params.Get(maxDimensionKey); raw != "" {
+		d, err := strconv.Atoi(raw)
+		if err != nil || d <= 0 || d > matrixMaxDimension {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid %s parameter: must be between 1 and %d", maxDimensionKey, matrixMaxDimension)
+		}
+		maxDimension = d
+	}
+
+	topoParams := url.Values{}
+	for k, v := range params {
+		topoParams[k] = v
+	}
+	topoParams.Set(scopeKey, scope)
+	topoParams.Del(comparePreviousKey)
+	qr, code, err := getTopologyFlows(ctx, cfg, client, topoParams)
+	if err != nil {
+		return nil, code, err
+	}
+	return buildConnectionMatrix(qr.Result, scope, params.Get(metricTypeKey) == countMetricType, maxDimension), http.StatusOK, nil
+}
+
+// buildConnectionMatrix reshapes topology series into a dense matrix. Rows and columns are sorted by label;
+// beyond maxDimension, only the ones with the highest totals are kept.
+func buildConnectionMatrix(result model.ResultValue, scope string, sum bool, maxDimension int) *model.ConnectionMatrix {
+	type pair struct{ src, dst string }
+	values := map[pair]float64{}
+	rowTotals := map[string]float64{}
+	colTotals := map[string]float64{}
+	for _, s := range aggregateSeries(result, sum) {
+		p := pair{src: matrixEndpoint(s.metric, scope, fields.Src), dst: matrixEndpoint(s.metric, scope, fields.Dst)}
+		values[p] += s.value
+		rowTotals[p.src] += s.value
+		colTotals[p.dst] += s.value
+	}
+
+	rows, rowsTruncated := topLabels(rowTotals, maxDimension)
+	cols, colsTruncated := topLabels(colTotals, maxDimension)
+	matrix := make([][]float64, len(rows))
+	for i, src := range rows {
+		matrix[i] = make([]float64, len(cols))
+		for j, dst := range cols {
+			matrix[i][j] = values[pair{src: src, dst: dst}]
+		}
+	}
+	return &model.ConnectionMatrix{
+		Rows:      rows,
+		Cols:      cols,
+		Matrix:    matrix,
+		Truncated: rowsTruncated || colsTruncated,
+	}
+}
+
+func topLabels(totals map[string]float64, max int) ([]string, bool) {
+	labels := make([]string, 0, len(totals))
+	for l := range totals {
+		labels = append(labels, l)
+	}
+	truncated := false
+	if len(labels) > max {
+		sort.Slice(labels, func(i, j int) bool {
+			if totals[labels[i]] != totals[labels[j]] {
+				return totals[labels[i]] > totals[labels[j]]
+			}
+			return labels[i] < labels[j]
+		})
+		labels = labels[:max]
+		truncated = true
+	}
+	sort.Strings(labels)
+	return labels, truncated
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	pmodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func nsPair(src, dst string, values ...float64) pmodel.SampleStream {
+	ss := series(src, values...)
+	ss.Metric["DstK8S_Namespace"] = pmodel.LabelValue(dst)
+	return ss
+}
+
+func TestBuildConnectionMatrix(t *testing.T) {
+	m := buildConnectionMatrix(model.Matrix{
+		nsPair("b", "a", 10, 30),
+		nsPair("a", "c", 5),
+		nsPair("a", "a", 1),
+	}, "namespace", false, 10)
+
+	assert.Equal(t, &model.ConnectionMatrix{
+		Rows: []string{"a", "b"},
+		Cols: []string{"a", "c"},
+		Matrix: [][]float64{
+			{1, 5},
+			// rates are averaged, absent pair is zero
+			{20, 0},
+		},
+	}, m)
+}
+
+func TestBuildConnectionMatrix_Capped(t *testing.T) {
+	m := buildConnectionMatrix(model.Matrix{
+		nsPair("a", "x", 1),
+		nsPair("b", "x", 100),
+		nsPair("c", "y", 50),
+	}, "namespace", true, 2)
+
+	assert.True(t, m.Truncated)
+	// highest totals kept, still sorted by label
+	assert.Equal(t, []string{"b", "c"}, m.Rows)
+	assert.Equal(t, []string{"x", "y"}, m.Cols)
+	assert.Equal(t, [][]float64{{100, 0}, {0, 50}}, m.Matrix)
+}
+
+func TestGetTopologyMatrix(t *testing.T) {
+	resp, err := json.Marshal(model.QueryResponse{Data: model.QueryResponseData{
+		ResultType: model.ResultTypeMatrix,
+		Result:     model.Matrix{nsPair("a", "b", 3)},
+	}})
+	require.NoError(t, err)
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", mock.Anything).Return(resp, 200, nil)
+
+	m, _, err := getTopologyMatrix(context.Background(), &testLokiConfig, lokiClientMock, url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, m.Rows)
+	assert.Equal(t, []string{"b"}, m.Cols)
+	assert.Equal(t, [][]float64{{3}}, m.Matrix)
+	// grouped by namespace pairs
+	lokiClientMock.AssertCalled(t, "Get", mock.MatchedBy(func(u string) bool {
+		return assert.Contains(t, u, "sum%20by(SrcK8S_Namespace,DstK8S_Namespace)")
+	}))
+
+	_, _, err = getTopologyMatrix(context.Background(), &testLokiConfig, lokiClientMock, url.Values{scopeKey: {"app"}})
+	require.Error(t, err)
+	_, _, err = getTopologyMatrix(context.Background(), &testLokiConfig, lokiClientMock, url.Values{maxDimensionKey: {"1000"}})
+	require.Error(t, err)
+}
+
+func TestGetTopology_MultiGroupsInvalidFilter(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	params := url.Values{}
+	params.Set(filtersKey, `SrcK8S_Name=~web-(&DstPort=80|DstPort=443`)
+	_, code, err := getTopologyFlows(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ErrorCodeInvalidFilter, getErrorCode(code, err))
+	lokiClientMock.AssertNotCalled(t, "Get")
+}
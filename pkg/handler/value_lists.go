@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+const valueListPrefix = "@"
+
+var valueListNameValidation = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// expandValueLists replaces value list references (e.g. SrcK8S_Name=@prod-services) by the
+// values read from the configured lists directory. Each listed value is used as an exact match,
+// unless it is already quoted in the list.
+func expandValueLists(cfg *loki.Config, groups filters.MultiQueries) (int, error) {
+	for _, group := range groups {
+		for i := range group {
+			if !strings.HasPrefix(group[i].Values, valueListPrefix) {
+				continue
+			}
+			values, err := readValueList(cfg, strings.TrimPrefix(group[i].Values, valueListPrefix))
+			if err != nil {
+				return http.StatusBadRequest, err
+			}
+			group[i].Values = strings.Join(values, ",")
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func readValueList(cfg *loki.Config, name string) ([]string, error) {
+	if cfg.ValueListsPath == "" {
+		return nil, fmt.Errorf("value list references are not enabled: %s%s", valueListPrefix, name)
+	}
+	if !valueListNameValidation.MatchString(name) {
+		return nil, fmt.Errorf("invalid value list name: %q", name)
+	}
+	content, err := os.ReadFile(filepath.Join(cfg.ValueListsPath, name))
+	if err != nil {
+		hlog.WithError(err).Debugf("cannot read value list %s", name)
+		return nil, fmt.Errorf("unknown value list: %s%s", valueListPrefix, name)
+	}
+	var values []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		value := strings.TrimSpace(scanner.Text())
+		if len(value) == 0 || strings.HasPrefix(value, "#") {
+			continue
+		}
+		if !isQuoted(value) {
+			value = exact(value)
+		}
+		values = append(values, value)
+		if cfg.MaxValueListSize > 0 && len(values) > cfg.MaxValueListSize {
+			return nil, fmt.Errorf("value list %s%s exceeds the maximum size of %d values", valueListPrefix, name, cfg.MaxValueListSize)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read value list %s%s: %w", valueListPrefix, name, err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("value list %s%s is empty", valueListPrefix, name)
+	}
+	return values, nil
+}
+
+func isQuoted(value string) bool {
+	return len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func prepareValueLists(t *testing.T) string {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "prod-services"), []byte("# exported from CMDB\nfrontend\n\n  backend  \n\"already-quoted\"\n"), 0600)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "too-big"), []byte("a\nb\nc\nd\n"), 0600)
+	require.NoError(t, err)
+	return dir
+}
+
+func TestExpandValueLists(t *testing.T) {
+	cfg := loki.Config{ValueListsPath: prepareValueLists(t), MaxValueListSize: 3}
+	groups, err := filters.Parse(url.QueryEscape("SrcK8S_Name=@prod-services&DstPort=80|DstK8S_Name=@prod-services"))
+	require.NoError(t, err)
+
+	code, err := expandValueLists(&cfg, groups)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("SrcK8S_Name", `"frontend","backend","already-quoted"`), filters.NewMatch("DstPort", "80")},
+		{filters.NewMatch("DstK8S_Name", `"frontend","backend","already-quoted"`)},
+	}, groups)
+}
+
+func TestExpandValueLists_Errors(t *testing.T) {
+	cfg := loki.Config{ValueListsPath: prepareValueLists(t), MaxValueListSize: 3}
+	for _, raw := range []string{"SrcK8S_Name=@missing", "SrcK8S_Name=@too-big", "SrcK8S_Name=@../prod-services"} {
+		groups, err := filters.Parse(url.QueryEscape(raw))
+		require.NoError(t, err)
+		code, err := expandValueLists(&cfg, groups)
+		require.Error(t, err, raw)
+		assert.Equal(t, http.StatusBadRequest, code, raw)
+	}
+
+	// not enabled
+	groups, err := filters.Parse(url.QueryEscape("SrcK8S_Name=@prod-services"))
+	require.NoError(t, err)
+	_, err = expandValueLists(&loki.Config{}, groups)
+	require.Error(t, err)
+}
+
+func TestGetFlows_ValueListMissingReference(t *testing.T) {
+	cfg := testLokiConfig
+	cfg.ValueListsPath = prepareValueLists(t)
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+
+	params := url.Values{}
+	params.Set(filtersKey, url.QueryEscape("SrcK8S_Name=@missing"))
+	_, code, err := getFlows(context.Background(), &cfg, lokiClientMock, params)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 0)
+}
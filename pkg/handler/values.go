@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+const (
+	fieldKey  = "field"
+	prefixKey = "prefix"
+	// defaultValuesLimit and maxValuesLimit bound the number of returned candidates
+	defaultValuesLimit = 20
+	maxValuesLimit     = 100
+	// valuesSampleSize is the number of flows read to collect the values of fields that aren't labels
+	valuesSampleSize = 1000
+)
+
+var fieldNameValidation = regexp.MustCompile(`^\w+$`)
+
+// GetValues returns candidate values of a field within the requested time range, for autocompletion.
+// Values of labels come from the Loki label values API, other fields values from a sample of flows.
+func GetValues(cfg *loki.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lokiClient := newLokiClient(r.Context(), cfg, r.Header, false)
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("GetValues", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		hlog.WithContext(r.Context()).Debugf("GetValues query params: %s", params)
+
+		values, code, err := getValues(r.Context(), cfg, lokiClient, params)
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+
+		code = http.StatusOK
+		writeJSON(w, code, values)
+	}
+}
+
+func getValues(ctx context.Context, cfg *loki.Config, client httpclient.Caller, params url.Values) ([]string, int, error) {
+	field := params.Get(fieldKey)
+	if !fieldNameValidation.MatchString(field) {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid %s parameter: %q", fieldKey, field)
+	}
+	prefix := params.Get(prefixKey)
+	limit := defaultValuesLimit
+	if raw := params.Get(limitKey); raw != "" {
+		l, err := strconv.Atoi(raw)
+		if err != nil || l <= 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid %s parameter: %q", limitKey, raw)
+		}
+		if l < maxValuesLimit {
+			limit = l
+		} else {
+			limit = maxValuesLimit
+		}
+	}
+	start, err := getStartTime(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	end, err := getEndTime(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	mandatory, code, err := mandatoryFilters(ctx, cfg)
+	if err != nil {
+		return nil, code, err
+	}
+	var values []string
+	// the Loki label values API can't be restricted by filters
+	if cfg.IsLabel(field) && mandatory == nil {
+		values, code, err = getLabelValuesInRange(ctx, cfg, client, field, start, end)
+	} else {
+		values, code, err = getSampledValues(ctx, cfg, client, mandatory, field, prefix, start, end)
+	}
+	if err != nil {
+		return nil, code, err
+	}
+	return matchPrefix(values, prefix, limit), http.StatusOK, nil
+}
+
+// getSampledValues extracts the distinct values of a field from the most recent flows having it. When there are
+// mandatory filters, see mandatoryFilters, each of their groups is sampled.
+func getSampledValues(ctx context.Context, cfg *loki.Config, client httpclient.Caller, mandatory filters.MultiQueries, field, prefix, start, end string) ([]string, int, error) {
+	groups := filters.MultiQueries{{}}
+	if mandatory != nil {
+		groups = mandatory
+	}
+	var values []string
+	for _, group := range groups {
+		qb := loki.NewFlowQueryBuilder(cfg, start, end, strconv.Itoa(valuesSampleSize), constants.ReporterBoth, constants.RecordTypeLog)
+		if narrowByPrefix(cfg, field, prefix) {
+			// narrow down the sample, the prefix is checked afterwards
+			qb.HasFieldPrefix(field, prefix)
+		} else {
+			qb.HasField(field)
+		}
+		if err := qb.Filters(group); err != nil {
+			return nil, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidFilter, err)
+		}
+		resp, code, err := executeLokiQuery(ctx, qb.Build(), client)
+		if err != nil {
+			return nil, code, fmt.Errorf("Loki query failed: %w", err)
+		}
+		var qr model.QueryResponse
+		if err := json.Unmarshal(resp, &qr); err != nil {
+			return nil, http.StatusInternalServerError, errors.New("Failed to unmarshal Loki response: " + err.Error())
+		}
+		streams, ok := qr.Data.Result.(model.Streams)
+		if !ok {
+			return nil, http.StatusInternalServerError, errors.New("Loki returned unexpected type: " + string(qr.Data.ResultType))
+		}
+		values = append(values, extractDistinctValues(field, streams)...)
+	}
+	return utils.Dedup(values), http.StatusOK, nil
+}
+
+// narrowByPrefix tells whether the sample can be narrowed down to the prefix with a line filter: only for string
+// fields, the other ones being written differently in the flows (e.g. numbers or symbolic values). The prefix is not
+// a filter: it is matched literally, when it doesn't have characters escaped in JSON or in the LogQL query.
+func narrowByPrefix(cfg *loki.Config, field, prefix string) bool {
+	if prefix == "" || cfg.IsLabel(field) || strings.ContainsAny(prefix, "\"\\`<>&") {
+		return false
+	}
+	info, ok := fields.Lookup(field)
+	if !ok || info.Type != fields.TypeString || info.Computed {
+		return false
+	}
+	for _, r := range prefix {
+		if r < ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPrefix keeps the values starting with prefix, ignoring case, sorted and capped to limit
+func matchPrefix(values []string, prefix string, limit int) []string {
+	matching := []string{}
+	lowerPrefix := strings.ToLower(prefix)
+	for _, v := range utils.Dedup(values) {
+		if v != "" && strings.HasPrefix(strings.ToLower(v), lowerPrefix) {
+			matching = append(matching, v)
+		}
+	}
+	sort.Strings(matching)
+	if len(matching) > limit {
+		matching = matching[:limit]
+	}
+	return matching
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func TestGetValues_Label(t *testing.T) {
+	resp, err := json.Marshal(model.LabelValuesResponse{Status: "success", Data: []string{"openshift-dns", "netobserv", "Network-Tests", "default"}})
+	require.NoError(t, err)
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", testLokiBaseURL+"label/SrcK8S_Namespace/values?end=1601&start=1000").Return(resp, 200, nil)
+
+	params := url.Values{}
+	params.Set(fieldKey, "SrcK8S_Namespace")
+	params.Set(prefixKey, "net")
+	params.Set(startTimeKey, "1000")
+	params.Set(endTimeKey, "1600")
+	values, _, err := getValues(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	// case insensitive, sorted
+	assert.Equal(t, []string{"Network-Tests", "netobserv"}, values)
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestGetValues_Sampled(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			{Timestamp: time.Now(), Line: `{"SrcK8S_Name":"frontend-1"}`},
+			{Timestamp: time.Now(), Line: `{"SrcK8S_Name":"frontend-2"}`},
+			{Timestamp: time.Now(), Line: `{"SrcK8S_Name":"frontend-1"}`},
+			{Timestamp: time.Now(), Line: `{"SrcK8S_Name":"my-frontend"}`},
+		},
+	}})
+
+	params := url.Values{}
+	params.Set(fieldKey, "SrcK8S_Name")
+	params.Set(prefixKey, "front")
+	params.Set(limitKey, "1")
+	values, _, err := getValues(context.Background(), &testLokiConfig, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"frontend-1"}, values)
+	query := lokiClientMock.Calls[0].Arguments.String(0)
+	assert.Contains(t, query, "|~`(?i)\"SrcK8S_Name\":\"front`")
+	assert.Contains(t, query, "limit=1000")
+}
+
+func TestGetValues_SampledNonString(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels: map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{
+			{Timestamp: time.Now(), Line: `{"SrcAddr":"10.1.0.1","SrcPort":80,"FlowDirection":0,"SrcK8S_Name":"a,b"}`},
+			{Timestamp: time.Now(), Line: `{"SrcAddr":"10.128.0.2","SrcPort":8080,"FlowDirection":1,"SrcK8S_Name":"a.b"}`},
+			{Timestamp: time.Now(), Line: `{"SrcAddr":"10.2.0.3","SrcPort":8000,"FlowDirection":0,"SrcK8S_Name":"c"}`},
+		},
+	}})
+	for i, tc := range []struct {
+		field, prefix string
+		expected      []string
+		lineFilter    string
+	}{
+		// non-string fields are only required, the prefix is checked on the sample
+		{field: "SrcAddr", prefix: "10.1", expected: []string{"10.1.0.1", "10.128.0.2"}, lineFilter: "|~`\"SrcAddr\":`"},
+		{field: "SrcPort", prefix: "80", expected: []string{"80", "8000", "8080"}, lineFilter: "|~`\"SrcPort\":`"},
+		{field: "FlowDirection", prefix: "in", expected: []string{}, lineFilter: "|~`\"FlowDirection\":`"},
+		{field: "FlowDirection", prefix: "1", expected: []string{"1"}, lineFilter: "|~`\"FlowDirection\":`"},
+		// neither an OR of values nor a regex
+		{field: "SrcK8S_Name", prefix: "a,", expected: []string{"a,b"}, lineFilter: "|~`(?i)\"SrcK8S_Name\":\"a,`"},
+		{field: "SrcK8S_Name", prefix: "a.", expected: []string{"a.b"}, lineFilter: "|~`(?i)\"SrcK8S_Name\":\"a\\.`"},
+	} {
+		params := url.Values{}
+		params.Set(fieldKey, tc.field)
+		params.Set(prefixKey, tc.prefix)
+		values, code, err := getValues(context.Background(), &testLokiConfig, lokiClientMock, params)
+		require.NoError(t, err, tc.field)
+		assert.Equal(t, 200, code, tc.field)
+		assert.Equal(t, tc.expected, values, tc.field)
+		assert.Contains(t, lokiClientMock.Calls[i].Arguments.String(0), tc.lineFilter, tc.field)
+	}
+}
+
+func TestGetValues_Errors(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	for _, params := range []url.Values{
+		{},
+		{fieldKey: {`SrcK8S_Name"}`}},
+		{fieldKey: {"SrcK8S_Name"}, limitKey: {"none"}},
+	} {
+		_, code, err := getValues(context.Background(), &testLokiConfig, lokiClientMock, params)
+		assert.Error(t, err, params)
+		assert.Equal(t, 400, code, params)
+	}
+}
+
+func TestGetValues_EnforcedFilters(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{{
+		Labels:  map[string]string{"app": "netobserv-flowcollector"},
+		Entries: []model.Entry{{Timestamp: time.Now(), Line: `{"SrcK8S_Namespace":"team-a"}`}},
+	}})
+	cfg := testLokiConfig
+	cfg.EnforcedFilters = `SrcK8S_Namespace="team-a"|DstK8S_Namespace="team-a"`
+
+	// labels are sampled too: the label values API can't apply the enforced filters
+	params := url.Values{}
+	params.Set(fieldKey, "SrcK8S_Namespace")
+	values, _, err := getValues(context.Background(), &cfg, lokiClientMock, params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a"}, values)
+	require.Len(t, lokiClientMock.Calls, 2)
+	assert.Contains(t, lokiClientMock.Calls[0].Arguments.String(0), `SrcK8S_Namespace="team-a"`)
+	assert.Contains(t, lokiClientMock.Calls[1].Arguments.String(0), `DstK8S_Namespace="team-a"`)
+	for _, call := range lokiClientMock.Calls {
+		assert.Contains(t, call.Arguments.String(0), "/query_range")
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+const workloadKey = "workload"
+
+var (
+	// RFC 1123 names, as used for namespaces and most resource names
+	k8sNameValidation = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	k8sKindValidation = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+)
+
+// addWorkloadFilter restricts the filter groups to the traffic from or to a workload, given as ns/kind/name,
+// where kind is the owner kind (e.g. Deployment). Each group is split into a source group and a destination group.
+func addWorkloadFilter(raw string, groups filters.MultiQueries) (filters.MultiQueries, error) {
+	if len(raw) == 0 {
+		return groups, nil
+	}
+	parts := strings.Split(raw, "/")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid %s parameter %q: expected namespace/kind/name", workloadKey, raw)
+	}
+	ns, kind, name := parts[0], parts[1], parts[2]
+	if !k8sNameValidation.MatchString(ns) || !k8sKindValidation.MatchString(kind) || !k8sNameValidation.MatchString(name) {
+		return nil, fmt.Errorf("invalid %s parameter %q: expected namespace/kind/name", workloadKey, raw)
+	}
+	if len(groups) == 0 {
+		groups = filters.MultiQueries{{}}
+	}
+	var expanded filters.MultiQueries
+	for _, group := range groups {
+		for _, prefix := range []string{fields.Src, fields.Dst} {
+			g := make(filters.SingleQuery, 0, len(group)+3)
+			g = append(g, group...)
+			g = append(g,
+				filters.NewMatch(prefix+fields.Namespace, exact(ns)),
+				filters.NewMatch(prefix+fields.OwnerType, exact(kind)),
+				filters.NewMatch(prefix+fields.OwnerName, exact(name)),
+			)
+			expanded = append(expanded, g)
+		}
+	}
+	return expanded, nil
+}
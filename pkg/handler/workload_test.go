@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func TestAddWorkloadFilter(t *testing.T) {
+	groups, err := addWorkloadFilter("my-ns/Deployment/web", filters.MultiQueries{
+		{filters.NewMatch("DstPort", "443")},
+		{filters.NewMatch("Proto", "17")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{
+		filters.NewMatch("DstPort", "443"),
+		filters.NewMatch("SrcK8S_Namespace", `"my-ns"`),
+		filters.NewMatch("SrcK8S_OwnerType", `"Deployment"`),
+		filters.NewMatch("SrcK8S_OwnerName", `"web"`),
+	}, {
+		filters.NewMatch("DstPort", "443"),
+		filters.NewMatch("DstK8S_Namespace", `"my-ns"`),
+		filters.NewMatch("DstK8S_OwnerType", `"Deployment"`),
+		filters.NewMatch("DstK8S_OwnerName", `"web"`),
+	}, {
+		filters.NewMatch("Proto", "17"),
+		filters.NewMatch("SrcK8S_Namespace", `"my-ns"`),
+		filters.NewMatch("SrcK8S_OwnerType", `"Deployment"`),
+		filters.NewMatch("SrcK8S_OwnerName", `"web"`),
+	}, {
+		filters.NewMatch("Proto", "17"),
+		filters.NewMatch("DstK8S_Namespace", `"my-ns"`),
+		filters.NewMatch("DstK8S_OwnerType", `"Deployment"`),
+		filters.NewMatch("DstK8S_OwnerName", `"web"`),
+	}}, groups)
+
+	for _, invalid := range []string{"my-ns/web", "my-ns/Deployment/web/x", "My_NS/Deployment/web", "ns/Deploy-ment/web", "ns//web"} {
+		_, err = addWorkloadFilter(invalid, nil)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestGetFlows_Workload(t *testing.T) {
+	lokiClientMock := mockStreamsResponse(t, model.Streams{})
+	_, _, err := getFlows(context.Background(), &testLokiConfig, lokiClientMock, url.Values{workloadKey: {"ns/DaemonSet/agent"}})
+	require.NoError(t, err)
+	// source and destination queries run in parallel
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+}
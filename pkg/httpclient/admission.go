@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// busyRetryAfter is the delay suggested to callers rejected because the queue is full
+const busyRetryAfter = time.Second
+
+// ServerBusyError is returned without calling the backend when too many calls are already running or waiting
+type ServerBusyError struct{}
+
+func (e *ServerBusyError) Error() string {
+	return "server busy: too many queries in progress, please retry later"
+}
+
+// RetryAfter is the delay after which the call can be retried
+func (e *ServerBusyError) RetryAfter() time.Duration {
+	return busyRetryAfter
+}
+
+// AdmissionQueue bounds the number of concurrent calls to a backend. Calls above the limit wait in a bounded queue,
+// beyond which they are rejected immediately rather than piling up.
+type AdmissionQueue struct {
+	slots    chan struct{}
+	mutex    sync.Mutex
+	queued   int
+	maxQueue int
+}
+
+// NewAdmissionQueue returns a queue running at most maxInFlight calls, with at most maxQueued waiting calls,
+// or nil when maxInFlight is not positive
+func NewAdmissionQueue(maxInFlight, maxQueued int) *AdmissionQueue {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	if maxQueued < 0 {
+		maxQueued = 0
+	}
+	return &AdmissionQueue{slots: make(chan struct{}, maxInFlight), maxQueue: maxQueued}
+}
+
+// acquire returns once a slot is taken, or with an error when the queue is full or ctx is done
+func (q *AdmissionQueue) acquire(ctx context.Context) error {
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	q.mutex.Lock()
+	if q.queued >= q.maxQueue {
+		q.mutex.Unlock()
+		return &ServerBusyError{}
+	}
+	q.queued++
+	q.mutex.Unlock()
+	defer func() {
+		q.mutex.Lock()
+		q.queued--
+		q.mutex.Unlock()
+	}()
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *AdmissionQueue) release() {
+	<-q.slots
+}
+
+type admissionClient struct {
+	Caller
+	queue *AdmissionQueue
+}
+
+// WithAdmissionQueue makes the calls wait for their turn in the queue
+func WithAdmissionQueue(client Caller, queue *AdmissionQueue) Caller {
+	if queue == nil {
+		return client
+	}
+	return &admissionClient{Caller: client, queue: queue}
+}
+
+func (c *admissionClient) Get(ctx context.Context, url string) ([]byte, int, error) {
+	if err := c.queue.acquire(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer c.queue.release()
+	return c.Caller.Get(ctx, url)
+}
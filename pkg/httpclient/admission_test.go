@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gatedCaller struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *gatedCaller) Get(_ context.Context, _ string) ([]byte, int, error) {
+	c.started <- struct{}{}
+	<-c.release
+	return []byte("{}"), 200, nil
+}
+
+func TestAdmissionQueue(t *testing.T) {
+	backend := &gatedCaller{started: make(chan struct{}, 10), release: make(chan struct{})}
+	client := WithAdmissionQueue(backend, NewAdmissionQueue(1, 1))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer wg.Done()
+			_, code, err := client.Get(context.Background(), "http://loki")
+			assert.NoError(t, err)
+			assert.Equal(t, 200, code)
+		}()
+	}
+	// one call is running, the other one waits
+	<-backend.started
+	require.Eventually(t, func() bool {
+		q := client.(*admissionClient).queue
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		return q.queued == 1
+	}, time.Second, time.Millisecond)
+
+	// the queue is full: rejected immediately
+	_, _, err := client.Get(context.Background(), "http://loki")
+	var busyErr *ServerBusyError
+	require.True(t, errors.As(err, &busyErr))
+	assert.Equal(t, time.Second, busyErr.RetryAfter())
+
+	// waiting calls give up with their context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q := NewAdmissionQueue(1, 1)
+	q.slots <- struct{}{}
+	assert.ErrorIs(t, q.acquire(ctx), context.Canceled)
+
+	close(backend.release)
+	wg.Wait()
+	assert.Len(t, backend.started, 1)
+}
+
+func TestAdmissionQueue_Disabled(t *testing.T) {
+	assert.Nil(t, NewAdmissionQueue(0, 10))
+	backend := &gatedCaller{}
+	assert.Equal(t, Caller(backend), WithAdmissionQueue(backend, nil))
+}
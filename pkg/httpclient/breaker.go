@@ -0,0 +1,113 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitOpenError is returned without calling the backend while the circuit is open
+type CircuitOpenError struct {
+	Retry time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("datasource unavailable after repeated failures, retry in %v", e.Retry)
+}
+
+// RetryAfter is the delay after which the backend will be called again
+func (e *CircuitOpenError) RetryAfter() time.Duration {
+	return e.Retry
+}
+
+// CircuitBreaker stops calling a backend after consecutive failures (connection errors or 5xx statuses), for the open
+// duration. Then a single trial call is let through: the circuit closes when it succeeds, or opens again.
+type CircuitBreaker struct {
+	mutex     sync.Mutex
+	threshold int
+	openFor   time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+// NewCircuitBreaker returns a breaker opening after threshold consecutive failures, or nil when threshold or openFor
+// is not positive
+func NewCircuitBreaker(threshold int, openFor time.Duration) *CircuitBreaker {
+	if threshold <= 0 || openFor <= 0 {
+		return nil
+	}
+	return &CircuitBreaker{threshold: threshold, openFor: openFor, now: time.Now}
+}
+
+// allow returns an error when the call must not be made
+func (b *CircuitBreaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.openUntil.IsZero() {
+		return nil
+	}
+	now := b.now()
+	if now.Before(b.openUntil) || b.trial {
+		retry := b.openUntil.Sub(now).Round(time.Second)
+		if retry < time.Second {
+			retry = time.Second
+		}
+		return &CircuitOpenError{Retry: retry}
+	}
+	// half-open: let a single call test the backend
+	b.trial = true
+	return nil
+}
+
+func (b *CircuitBreaker) record(failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.openFor)
+	}
+}
+
+// release ends a trial call without concluding, e.g. when it was cancelled
+func (b *CircuitBreaker) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trial = false
+}
+
+type breakerClient struct {
+	Caller
+	breaker *CircuitBreaker
+}
+
+// WithCircuitBreaker fails fast with a CircuitOpenError while the breaker is open
+func WithCircuitBreaker(client Caller, breaker *CircuitBreaker) Caller {
+	if breaker == nil {
+		return client
+	}
+	return &breakerClient{Caller: client, breaker: breaker}
+}
+
+func (c *breakerClient) Get(ctx context.Context, url string) ([]byte, int, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, 0, err
+	}
+	body, code, err := c.Caller.Get(ctx, url)
+	if ctx.Err() != nil {
+		// cancelled by the caller, which says nothing about the backend health
+		c.breaker.release()
+		return body, code, err
+	}
+	c.breaker.record(err != nil || code >= http.StatusInternalServerError)
+	return body, code, err
+}
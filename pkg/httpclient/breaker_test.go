@@ -0,0 +1,85 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scriptedCaller struct {
+	calls int
+	codes []int
+}
+
+func (c *scriptedCaller) Get(_ context.Context, _ string) ([]byte, int, error) {
+	code := c.codes[c.calls%len(c.codes)]
+	c.calls++
+	if code == 0 {
+		return nil, 0, errors.New("connection refused")
+	}
+	return []byte("{}"), code, nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1680000000, 0)
+	breaker := NewCircuitBreaker(3, 30*time.Second)
+	breaker.now = func() time.Time { return now }
+	backend := &scriptedCaller{codes: []int{0, 500, 503}}
+	client := WithCircuitBreaker(backend, breaker)
+
+	for i := 0; i < 3; i++ {
+		_, _, _ = client.Get(context.Background(), "http://loki")
+	}
+	assert.Equal(t, 3, backend.calls)
+
+	// open: fail fast without calling Loki
+	now = now.Add(10 * time.Second)
+	_, _, err := client.Get(context.Background(), "http://loki")
+	var circuitErr *CircuitOpenError
+	require.True(t, errors.As(err, &circuitErr))
+	assert.Equal(t, 20*time.Second, circuitErr.RetryAfter())
+	assert.Equal(t, 3, backend.calls)
+
+	// half-open: the trial fails and opens the circuit again
+	now = now.Add(20 * time.Second)
+	backend.codes = []int{502}
+	_, code, err := client.Get(context.Background(), "http://loki")
+	assert.NoError(t, err)
+	assert.Equal(t, 502, code)
+	assert.Equal(t, 4, backend.calls)
+	_, _, err = client.Get(context.Background(), "http://loki")
+	assert.True(t, errors.As(err, &circuitErr))
+	assert.Equal(t, 4, backend.calls)
+
+	// the next successful trial closes it
+	now = now.Add(30 * time.Second)
+	backend.codes = []int{200}
+	_, code, err = client.Get(context.Background(), "http://loki")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	_, _, err = client.Get(context.Background(), "http://loki")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, backend.calls)
+}
+
+func TestCircuitBreaker_ClientErrorsDontCount(t *testing.T) {
+	breaker := NewCircuitBreaker(2, time.Minute)
+	backend := &scriptedCaller{codes: []int{400, 404, 429}}
+	client := WithCircuitBreaker(backend, breaker)
+
+	for i := 0; i < 5; i++ {
+		_, _, err := client.Get(context.Background(), "http://loki")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 5, backend.calls)
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	assert.Nil(t, NewCircuitBreaker(0, time.Minute))
+	backend := &scriptedCaller{codes: []int{200}}
+	assert.Equal(t, Caller(backend), WithCircuitBreaker(backend, nil))
+}
@@ -0,0 +1,256 @@
+package httpclient
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+)
+
+// maxCachedResponseSize avoids filling the cache with a few huge responses
+const maxCachedResponseSize = 10 << 20
+
+// Cache is a size-bounded LRU cache of successful responses, expiring after TTL
+type Cache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+	// negative caches only empty results, keyed by query signature
+	negative bool
+	// bytes is the size of the cached bodies
+	bytes int
+}
+
+type cacheEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+// NewCache returns a cache of at most size responses, or nil when ttl or size is not positive
+func NewCache(ttl time.Duration, size int) *Cache {
+	if ttl <= 0 || size <= 0 {
+		return nil
+	}
+	return &Cache{
+		ttl:     ttl,
+		size:    size,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// NewNegativeCache returns a cache of at most size empty Loki results, or nil when ttl or size is not positive.
+// Queries ending now are keyed by signature: the query with the duration of its time range, but not its position,
+// so that an auto-refresh of a query that found nothing is short-circuited during ttl. Other queries, e.g. of an
+// absolute time range or of a next page, keep their exact time range.
+func NewNegativeCache(ttl time.Duration, size int) *Cache {
+	c := NewCache(ttl, size)
+	if c != nil {
+		c.negative = true
+	}
+	return c
+}
+
+// name labels the cache metrics
+func (c *Cache) name() string {
+	if c.negative {
+		return "negative"
+	}
+	return "query"
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		metrics.ObserveCacheLookup(c.name(), false)
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(elem)
+		metrics.CountCacheEviction(c.name(), "expired")
+		metrics.ObserveCacheLookup(c.name(), false)
+		c.observeSize()
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	metrics.ObserveCacheLookup(c.name(), true)
+	return entry.body, true
+}
+
+func (c *Cache) put(key string, body []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, body: body, expires: c.now().Add(c.ttl)})
+	c.bytes += len(body)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		metrics.CountCacheEviction(c.name(), "capacity")
+	}
+	c.observeSize()
+}
+
+// remove must be called with the mutex held
+func (c *Cache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.bytes -= len(entry.body)
+}
+
+func (c *Cache) observeSize() {
+	metrics.SetCacheSize(c.name(), c.lru.Len(), c.bytes)
+}
+
+// key normalizes the URL: parameters are sorted and, for queries ending now, the time range is rounded to the TTL, so
+// that identical queries of an auto-refresh, shifted by a few seconds, share the same key. Other time ranges, e.g.
+// absolute ones or the nanosecond ends of a next page, are kept as is: two different ranges must not share a response.
+func (c *Cache) key(scope, rawURL string) string {
+	base, rawQuery, _ := strings.Cut(rawURL, "?")
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return scope + "\x00" + rawURL
+	}
+	if c.relative(params) {
+		start, errStart := strconv.ParseInt(params.Get("start"), 10, 64)
+		end, errEnd := strconv.ParseInt(params.Get("end"), 10, 64)
+		if c.negative && errStart == nil && errEnd == nil {
+			params.Del("start")
+			params.Del("end")
+			params.Set("span", strconv.FormatInt(end-start, 10))
+		} else {
+			for _, p := range []string{"start", "end"} {
+				if v := params.Get(p); v != "" {
+					params.Set(p, c.bucket(v))
+				}
+			}
+		}
+	}
+	return scope + "\x00" + base + "?" + params.Encode()
+}
+
+// relative tells whether a query ends now: either without end, which Loki defaults to now, or with an end in seconds
+// less than the TTL away from now. The cursor of a next page ends in nanoseconds, and is never normalized.
+func (c *Cache) relative(params url.Values) bool {
+	raw := params.Get("end")
+	if raw == "" {
+		return true
+	}
+	end, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || end > 1e12 {
+		return false
+	}
+	return c.endsNow(end)
+}
+
+// endsNow tells whether a query end timestamp, in seconds, is less than the TTL away from now
+func (c *Cache) endsNow(end int64) bool {
+	diff := c.now().Sub(time.Unix(end, 0))
+	return diff < c.ttl && diff > -c.ttl
+}
+
+// cacheable tells whether a successful response can be cached
+func (c *Cache) cacheable(body []byte) bool {
+	if len(body) > maxCachedResponseSize {
+		return false
+	}
+	return !c.negative || isEmptyResult(body)
+}
+
+// isEmptyResult tells whether a Loki query response holds no stream or series
+func isEmptyResult(body []byte) bool {
+	var resp struct {
+		Data *struct {
+			Result []json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Data == nil {
+		return false
+	}
+	return len(resp.Data.Result) == 0
+}
+
+func (c *Cache) bucket(ts string) string {
+	t, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ts
+	}
+	// Loki accepts seconds or nanoseconds
+	unit := time.Second
+	if t > 1e12 {
+		unit = time.Nanosecond
+	}
+	width := int64(c.ttl / unit)
+	if width <= 1 {
+		return ts
+	}
+	return strconv.FormatInt(t/width, 10) + "/" + strconv.FormatInt(width, 10)
+}
+
+type cachingClient struct {
+	Caller
+	cache *Cache
+	scope string
+}
+
+// scopeHeaders are the headers selecting what a Loki query can see: the tenant and the credentials
+var scopeHeaders = []string{"X-Scope-OrgID", "Authorization"}
+
+// WithCache serves the responses of recent identical queries from the cache. The tenant and credentials headers
+// scope the cached responses, so that they are never shared by callers of different tenants or credentials. Other
+// headers, such as the tracing context, are different for each request and would defeat the cache.
+func WithCache(client Caller, cache *Cache, headers map[string][]string) Caller {
+	if cache == nil {
+		return client
+	}
+	return &cachingClient{Caller: client, cache: cache, scope: headersScope(headers)}
+}
+
+func headersScope(headers map[string][]string) string {
+	h := sha256.New()
+	for _, name := range scopeHeaders {
+		_, _ = h.Write([]byte(name))
+		for k, values := range headers {
+			if http.CanonicalHeaderKey(k) != http.CanonicalHeaderKey(name) {
+				continue
+			}
+			for _, v := range values {
+				_, _ = h.Write([]byte{0})
+				_, _ = h.Write([]byte(v))
+			}
+		}
+		_, _ = h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *cachingClient) Get(ctx context.Context, url string) ([]byte, int, error) {
+	key := c.cache.key(c.scope, url)
+	if body, ok := c.cache.get(key); ok {
+		return body, 200, nil
+	}
+	body, code, err := c.Caller.Get(ctx, url)
+	if err == nil && code == 200 && c.cache.cacheable(body) {
+		c.cache.put(key, body)
+	}
+	return body, code, err
+}
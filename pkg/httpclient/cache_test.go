@@ -0,0 +1,181 @@
+package httpclient
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingCaller struct {
+	calls map[string]int
+	code  int
+}
+
+func (c *countingCaller) Get(_ context.Context, url string) ([]byte, int, error) {
+	c.calls[url]++
+	return []byte(url + "#" + strconv.Itoa(c.calls[url])), c.code, nil
+}
+
+func TestCache(t *testing.T) {
+	now := time.Unix(1680000303, 0)
+	cache := NewCache(10*time.Second, 2)
+	cache.now = func() time.Time { return now }
+	backend := &countingCaller{calls: map[string]int{}, code: 200}
+	client := WithCache(backend, cache, map[string][]string{"X-Scope-OrgID": {"netobserv"}})
+
+	query := func(start, end int) string {
+		return "http://loki/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\"}&start=" + strconv.Itoa(start) + "&end=" + strconv.Itoa(end)
+	}
+	first, code, err := client.Get(context.Background(), query(1680000000, 1680000300))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	// auto-refresh a few seconds later, in the same time bucket
+	second, _, _ := client.Get(context.Background(), query(1680000003, 1680000303))
+	assert.Equal(t, first, second)
+	assert.Len(t, backend.calls, 1)
+
+	// other time bucket
+	_, _, _ = client.Get(context.Background(), query(1680000010, 1680000310))
+	assert.Len(t, backend.calls, 2)
+
+	// other credentials
+	other := WithCache(backend, cache, map[string][]string{"X-Scope-OrgID": {"other"}})
+	_, _, _ = other.Get(context.Background(), query(1680000000, 1680000300))
+	assert.Equal(t, 2, backend.calls[query(1680000000, 1680000300)])
+
+	// evicted, as least recently used
+	_, _, _ = client.Get(context.Background(), query(1680000003, 1680000303))
+	assert.Equal(t, 1, backend.calls[query(1680000003, 1680000303)])
+	_, _, _ = client.Get(context.Background(), query(1680000003, 1680000303))
+	assert.Equal(t, 1, backend.calls[query(1680000003, 1680000303)])
+
+	// expired
+	now = now.Add(10 * time.Second)
+	_, _, _ = client.Get(context.Background(), query(1680000003, 1680000303))
+	assert.Equal(t, 2, backend.calls[query(1680000003, 1680000303)])
+}
+
+func TestCache_ExactRanges(t *testing.T) {
+	cache := NewCache(time.Minute, 10)
+	cache.now = func() time.Time { return time.Unix(1700000100, 0) }
+	backend := &countingCaller{calls: map[string]int{}, code: 200}
+	client := WithCache(backend, cache, nil)
+
+	query := func(start, end int64) string {
+		return "http://loki/loki/api/v1/query_range?query={}&start=" + strconv.FormatInt(start, 10) + "&end=" + strconv.FormatInt(end, 10)
+	}
+	for _, q := range []string{
+		// absolute time ranges, in the same time bucket
+		query(1699980000, 1699990045),
+		query(1699980000, 1699990095),
+		// next pages, ending in nanoseconds in the same time bucket, even close to now
+		query(1699990000, 1700000045000000001),
+		query(1699990000, 1700000095000000001),
+	} {
+		first, _, _ := client.Get(context.Background(), q)
+		second, _, _ := client.Get(context.Background(), q)
+		assert.Equal(t, first, second, q)
+		assert.Equal(t, 1, backend.calls[q], q)
+	}
+	assert.Len(t, backend.calls, 4)
+	assert.Len(t, cache.entries, 4)
+}
+
+func TestCache_Scope(t *testing.T) {
+	base := map[string][]string{"X-Scope-OrgID": {"netobserv"}, "Authorization": {"Bearer abc"}}
+	// tracing headers differ for each request, but don't scope the cache
+	traced := map[string][]string{"X-Scope-Orgid": {"netobserv"}, "authorization": {"Bearer abc"}, "Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}}
+	assert.Equal(t, headersScope(base), headersScope(traced))
+	assert.NotEqual(t, headersScope(base), headersScope(map[string][]string{"X-Scope-OrgID": {"netobserv"}, "Authorization": {"Bearer other"}}))
+	assert.NotEqual(t, headersScope(base), headersScope(map[string][]string{"X-Scope-OrgID": {"other"}, "Authorization": {"Bearer abc"}}))
+}
+
+func TestCache_ErrorsNotCached(t *testing.T) {
+	backend := &countingCaller{calls: map[string]int{}, code: 500}
+	client := WithCache(backend, NewCache(time.Minute, 10), nil)
+	_, code, _ := client.Get(context.Background(), "http://loki/loki/api/v1/query_range?query={}")
+	assert.Equal(t, 500, code)
+	_, _, _ = client.Get(context.Background(), "http://loki/loki/api/v1/query_range?query={}")
+	assert.Equal(t, 2, backend.calls["http://loki/loki/api/v1/query_range?query={}"])
+
+	// disabled
+	assert.Same(t, backend, WithCache(backend, NewCache(0, 10), nil))
+}
+
+type fixedCaller struct {
+	body  string
+	calls int
+}
+
+func (c *fixedCaller) Get(_ context.Context, _ string) ([]byte, int, error) {
+	c.calls++
+	return []byte(c.body), 200, nil
+}
+
+func TestNegativeCache(t *testing.T) {
+	query := func(start, end int) string {
+		return "http://loki/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\"}&start=" + strconv.Itoa(start) + "&end=" + strconv.Itoa(end)
+	}
+	backend := &fixedCaller{body: `{"status":"success","data":{"resultType":"streams","result":[]}}`}
+	cache := NewNegativeCache(time.Minute, 10)
+	now := time.Unix(1680000300, 0)
+	cache.now = func() time.Time { return now }
+	client := WithCache(backend, cache, nil)
+
+	_, _, _ = client.Get(context.Background(), query(1680000000, 1680000300))
+	// same time range duration ending now, a while later
+	now = now.Add(30 * time.Second)
+	body, code, err := client.Get(context.Background(), query(1680000030, 1680000330))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, backend.body, string(body))
+	assert.Equal(t, 1, backend.calls)
+	// larger time range
+	_, _, _ = client.Get(context.Background(), query(1680000000, 1680003600))
+	assert.Equal(t, 2, backend.calls)
+
+	// same duration, in the past: e.g. an absolute time range or a next page
+	_, _, _ = client.Get(context.Background(), query(1679913600, 1679913900))
+	assert.Equal(t, 3, backend.calls)
+	_, _, _ = client.Get(context.Background(), query(1679913600, 1679913900))
+	assert.Equal(t, 3, backend.calls)
+	// a past empty range doesn't answer "now" queries
+	_, _, _ = client.Get(context.Background(), query(1680000040, 1680000340))
+	assert.Equal(t, 3, backend.calls)
+	_, _, _ = client.Get(context.Background(), query(1679913000, 1679913300))
+	assert.Equal(t, 4, backend.calls)
+
+	// non-empty results are not cached
+	backend = &fixedCaller{body: `{"status":"success","data":{"resultType":"streams","result":[{"stream":{},"values":[]}]}}`}
+	client = WithCache(backend, NewNegativeCache(time.Minute, 10), nil)
+	_, _, _ = client.Get(context.Background(), query(1680000000, 1680000300))
+	_, _, _ = client.Get(context.Background(), query(1680000000, 1680000300))
+	assert.Equal(t, 2, backend.calls)
+}
+
+func TestCache_Size(t *testing.T) {
+	now := time.Unix(1680000000, 0)
+	cache := NewCache(10*time.Second, 2)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", []byte("12345"))
+	cache.put("b", []byte("123"))
+	assert.Equal(t, 8, cache.bytes)
+	// replaced
+	cache.put("a", []byte("1"))
+	assert.Equal(t, 4, cache.bytes)
+	// evicted over capacity
+	cache.put("c", []byte("12"))
+	assert.Equal(t, 2, cache.lru.Len())
+	assert.Equal(t, 3, cache.bytes)
+
+	// evicted when expired
+	now = now.Add(10 * time.Second)
+	_, found := cache.get("a")
+	assert.False(t, found)
+	assert.Equal(t, 1, cache.lru.Len())
+	assert.Equal(t, 2, cache.bytes)
+}
@@ -0,0 +1,71 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader serves a certificate and its key, reloading them when the files change, e.g. when a
+// mounted secret is rotated. On reload failure, the previous certificate is kept.
+type CertificateReloader struct {
+	certPath string
+	keyPath  string
+	mutex    sync.Mutex
+	cert     *tls.Certificate
+	certMod  time.Time
+	keyMod   time.Time
+}
+
+// NewCertificateReloader loads the certificate, failing when it can't be read
+func NewCertificateReloader(certPath, keyPath string) (*CertificateReloader, error) {
+	r := &CertificateReloader{certPath: certPath, keyPath: keyPath}
+	if _, err := r.GetCertificate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, checking the files modification time
+func (r *CertificateReloader) GetCertificate() (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		if r.cert != nil {
+			slog.WithError(err).Warnf("cannot check certificate %s, keeping the loaded one", r.certPath)
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil && certMod.Equal(r.certMod) && keyMod.Equal(r.keyMod) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		if r.cert != nil {
+			// e.g. the certificate was written before its key
+			slog.WithError(err).Warnf("cannot reload certificate %s, keeping the loaded one", r.certPath)
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil {
+		slog.Infof("reloaded certificate %s", r.certPath)
+	}
+	r.cert, r.certMod, r.keyMod = &cert, certMod, keyMod
+	return r.cert, nil
+}
+
+func (r *CertificateReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed client certificate with the given common name
+func writeCertificate(t *testing.T, certPath, keyPath, name string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
+
+// touch moves the modification time of the files forward, as file systems may have a coarse resolution
+func touch(t *testing.T, shift time.Duration, paths ...string) {
+	for _, p := range paths {
+		require.NoError(t, os.Chtimes(p, time.Now().Add(shift), time.Now().Add(shift)))
+	}
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	_, err := NewCertificateReloader(certPath, keyPath)
+	require.Error(t, err)
+
+	writeCertificate(t, certPath, keyPath, "first")
+	reloader, err := NewCertificateReloader(certPath, keyPath)
+	require.NoError(t, err)
+	cert, err := reloader.GetCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, "first", commonName(t, cert))
+
+	// rotation
+	writeCertificate(t, certPath, keyPath, "second")
+	touch(t, time.Minute, certPath, keyPath)
+	cert, err = reloader.GetCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, "second", commonName(t, cert))
+
+	// partial write: the previous certificate is kept
+	require.NoError(t, os.WriteFile(keyPath, []byte("invalid"), 0600))
+	touch(t, 2*time.Minute, keyPath)
+	cert, err = reloader.GetCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, "second", commonName(t, cert))
+}
+
+func TestGet_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, certPath, keyPath, "first")
+
+	// a gateway requiring client certificates, answering with their name
+	gateway := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	gateway.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	gateway.StartTLS()
+	defer gateway.Close()
+
+	client := NewHTTPClient(time.Second, nil, true, "", certPath, keyPath, TransportConfig{})
+	resp, code, err := client.Get(context.Background(), gateway.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "first", string(resp))
+
+	// new connections use the rotated certificate
+	writeCertificate(t, certPath, keyPath, "second")
+	touch(t, time.Minute, certPath, keyPath)
+	gateway.CloseClientConnections()
+	resp, _, err = client.Get(context.Background(), gateway.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(resp))
+}
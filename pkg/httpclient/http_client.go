@@ -1,19 +1,34 @@
 package httpclient
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/tracing"
 )
 
 type Caller interface {
-	Get(url string) ([]byte, int, error)
+	// Get calls url, the call being cancelled with ctx
+	Get(ctx context.Context, url string) ([]byte, int, error)
+}
+
+// inFlight is the number of Loki calls waiting for their response
+var inFlight int64
+
+// InFlight returns the number of Loki calls waiting for their response
+func InFlight() int64 {
+	return atomic.LoadInt64(&inFlight)
 }
 
 type httpClient struct {
@@ -24,61 +39,171 @@ type httpClient struct {
 
 var slog = logrus.WithField("module", "server")
 
-func NewHTTPClient(timeout time.Duration, overrideHeaders map[string][]string, skipTLS bool, capath string, userCertPath string, userKeyPath string) Caller {
+// TransportConfig tunes the pool of connections to a backend. Zero values keep the defaults.
+type TransportConfig struct {
+	// MaxIdleConnsPerHost is the number of connections kept open to each host, e.g. to a gateway receiving
+	// parallel queries (Go default is 2)
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes connections idle for this duration (defaults to the client timeout)
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+	EnableHTTP2         bool
+}
+
+type transportKey struct {
+	timeout      time.Duration
+	skipTLS      bool
+	capath       string
+	userCertPath string
+	userKeyPath  string
+	config       TransportConfig
+}
+
+// transports are shared by the clients of a same configuration, so that connections are reused across requests
+var (
+	transportsMutex sync.Mutex
+	transports      = map[transportKey]*http.Transport{}
+)
+
+func getTransport(key transportKey) *http.Transport {
+	transportsMutex.Lock()
+	defer transportsMutex.Unlock()
+	if transport, ok := transports[key]; ok {
+		return transport
+	}
+	transport := newTransport(key)
+	transports[key] = transport
+	return transport
+}
+
+func newTransport(key transportKey) *http.Transport {
+	idleConnTimeout := key.config.IdleConnTimeout
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = key.timeout
+	}
 	transport := &http.Transport{
-		DialContext:     (&net.Dialer{Timeout: timeout}).DialContext,
-		IdleConnTimeout: timeout,
+		DialContext:         (&net.Dialer{Timeout: key.timeout}).DialContext,
+		IdleConnTimeout:     idleConnTimeout,
+		MaxIdleConnsPerHost: key.config.MaxIdleConnsPerHost,
+		TLSHandshakeTimeout: key.config.TLSHandshakeTimeout,
+		// a custom dialer or TLS config disables HTTP/2 unless forced
+		ForceAttemptHTTP2: key.config.EnableHTTP2,
 	}
 
-	if skipTLS {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		slog.Warn("skipping TLS checks. SSL certificate verification is now disabled !")
-	} else if capath != "" || userCertPath != "" {
+	if key.skipTLS || key.capath != "" || key.userCertPath != "" {
 		transport.TLSClientConfig = &tls.Config{}
-
-		if capath != "" {
-			caCert, err := os.ReadFile(capath)
-			if err != nil {
-				slog.Errorf("Cannot load loki ca certificate: %v", err)
-			} else {
-				pool := x509.NewCertPool()
-				pool.AppendCertsFromPEM(caCert)
-				transport.TLSClientConfig.RootCAs = pool
-			}
+	}
+	if key.skipTLS {
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		slog.Warn("skipping TLS checks. SSL certificate verification is now disabled !")
+	} else if key.capath != "" {
+		caCert, err := os.ReadFile(key.capath)
+		if err != nil {
+			slog.Errorf("Cannot load loki ca certificate: %v", err)
+		} else {
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(caCert)
+			transport.TLSClientConfig.RootCAs = pool
 		}
-
-		if userCertPath != "" {
-			cert, err := tls.LoadX509KeyPair(userCertPath, userKeyPath)
-			if err != nil {
-				slog.Errorf("Cannot load loki user certificate: %v", err)
-			} else {
-				transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	if key.userCertPath != "" {
+		// the certificate is read on handshakes, so that rotated certificates are picked up by new connections
+		reloader, err := NewCertificateReloader(key.userCertPath, key.userKeyPath)
+		if err != nil {
+			slog.Errorf("Cannot load loki user certificate: %v", err)
+		} else {
+			transport.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return reloader.GetCertificate()
 			}
 		}
 	}
+	return transport
+}
 
+func NewHTTPClient(timeout time.Duration, overrideHeaders map[string][]string, skipTLS bool, capath string, userCertPath string, userKeyPath string, transportConfig TransportConfig) Caller {
+	transport := getTransport(transportKey{
+		timeout:      timeout,
+		skipTLS:      skipTLS,
+		capath:       capath,
+		userCertPath: userCertPath,
+		userKeyPath:  userKeyPath,
+		config:       transportConfig,
+	})
 	return &httpClient{
 		client:  http.Client{Transport: transport, Timeout: timeout},
 		headers: overrideHeaders,
 	}
 }
 
-func (hc *httpClient) Get(url string) ([]byte, int, error) {
+// ForwardHeaders copies the allowlisted inbound headers into the outbound headers. Headers already set
+// (e.g. from configuration) are never overridden, so callers cannot replace them.
+func ForwardHeaders(headers map[string][]string, inbound http.Header, allowlist []string) {
+	set := map[string]struct{}{}
+	for k := range headers {
+		set[http.CanonicalHeaderKey(k)] = struct{}{}
+	}
+	for _, name := range allowlist {
+		key := http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if _, ok := set[key]; ok {
+			continue
+		}
+		if values := inbound.Values(key); len(values) > 0 {
+			headers[key] = append([]string{}, values...)
+		}
+	}
+}
+
+func (hc *httpClient) Get(ctx context.Context, url string) ([]byte, int, error) {
+	body, code, _, err := hc.getWithHeader(ctx, url)
+	return body, code, err
+}
+
+func (hc *httpClient) getWithHeader(ctx context.Context, url string) ([]byte, int, http.Header, error) {
 	// TODO: manage authentication / TLS
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	for k, v := range hc.headers {
 		req.Header[k] = v
 	}
+	if id := GetRequestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	_, span := tracing.Start(ctx, "HTTP GET", tracing.KindClient)
+	defer span.End()
+	if span != nil {
+		// the Loki call is a child of this span, rather than of the inbound trace context
+		req.Header.Set(tracing.TraceparentHeader, span.Traceparent())
+		// the query is not recorded: it holds the user filters, e.g. IPs and names
+		path, _, _ := strings.Cut(url, "?")
+		span.SetAttribute("http.method", http.MethodGet)
+		span.SetAttribute("http.url", path)
+	}
 
+	atomic.AddInt64(&inFlight, 1)
+	defer atomic.AddInt64(&inFlight, -1)
 	resp, err := hc.client.Do(req)
 	if err != nil {
-		return nil, 0, err
+		span.SetError(err)
+		return nil, 0, nil, err
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	return body, resp.StatusCode, err
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	span.SetError(err)
+	return body, resp.StatusCode, resp.Header, err
+}
+
+// Sleep waits for d, returning early with the context error when ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
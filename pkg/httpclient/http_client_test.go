@@ -0,0 +1,236 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardHeaders(t *testing.T) {
+	inbound := http.Header{}
+	inbound.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	inbound.Set("X-Scope-OrgID", "user-tenant")
+	inbound.Set("Authorization", "Bearer user-token")
+	inbound.Set("Cookie", "session=abc")
+
+	headers := map[string][]string{"X-Scope-OrgID": {"configured-tenant"}}
+	ForwardHeaders(headers, inbound, []string{"traceparent", "X-Scope-OrgID", "X-Request-Id"})
+
+	assert.Equal(t, map[string][]string{
+		// configured headers are not overridden
+		"X-Scope-OrgID": {"configured-tenant"},
+		"Traceparent":   {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}, headers)
+}
+
+func TestGet_ForwardedHeaders(t *testing.T) {
+	var received http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer srv.Close()
+
+	inbound := http.Header{}
+	inbound.Set("X-Scope-OrgID", "user-tenant")
+	inbound.Set("Authorization", "Bearer user-token")
+	headers := map[string][]string{}
+	ForwardHeaders(headers, inbound, []string{"X-Scope-OrgID"})
+
+	_, code, err := NewHTTPClient(time.Second, headers, false, "", "", "", TransportConfig{}).Get(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "user-tenant", received.Get("X-Scope-OrgID"))
+	// not allowlisted
+	assert.Empty(t, received.Get("Authorization"))
+}
+
+func TestGet_RequestID(t *testing.T) {
+	var received http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer srv.Close()
+
+	ctx := WithRequestID(context.Background(), "abc-123")
+	_, _, err := NewHTTPClient(time.Second, nil, false, "", "", "", TransportConfig{}).Get(ctx, srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "abc-123", received.Get(RequestIDHeader))
+}
+
+func TestRequestIDHook(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(RequestIDHook{})
+	hook := &lastEntryHook{}
+	logger.AddHook(hook)
+
+	logger.WithContext(WithRequestID(context.Background(), "abc-123")).Info("with request")
+	assert.Equal(t, "abc-123", hook.last.Data[RequestIDField])
+	logger.Info("without request")
+	assert.NotContains(t, hook.last.Data, RequestIDField)
+}
+
+type lastEntryHook struct {
+	last *logrus.Entry
+}
+
+func (h *lastEntryHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *lastEntryHook) Fire(entry *logrus.Entry) error {
+	h.last = entry
+	return nil
+}
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, ValidRequestID("4bf92f35-77b3.4da6_a3ce"))
+	assert.True(t, ValidRequestID(NewRequestID()))
+	assert.False(t, ValidRequestID(""))
+	assert.False(t, ValidRequestID("id\nforged log line"))
+	assert.False(t, ValidRequestID(strings.Repeat("a", 129)))
+}
+
+func TestGet_Retries(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	var delays []time.Duration
+	client := WithRetries(NewHTTPClient(time.Second, nil, false, "", "", "", TransportConfig{}), RetryPolicy{
+		MaxAttempts:    3,
+		Backoff:        100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		RetryableCodes: []int{http.StatusTooManyRequests, http.StatusBadGateway},
+	})
+	client.(*retryingClient).sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	body, code, err := client.Get(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", string(body))
+	// Retry-After is honored, capped to the max backoff
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, time.Second}, delays)
+
+	// gives up after max attempts
+	calls, delays = 0, nil
+	client.(*retryingClient).policy.MaxAttempts = 2
+	_, code, err = client.Get(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Equal(t, 2, calls)
+
+	// not retryable
+	calls = 1
+	client.(*retryingClient).policy.RetryableCodes = []int{http.StatusServiceUnavailable}
+	_, code, _ = client.Get(context.Background(), srv.URL)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestWithRetries_Disabled(t *testing.T) {
+	client := NewHTTPClient(time.Second, nil, false, "", "", "", TransportConfig{})
+	assert.Same(t, client, WithRetries(client, RetryPolicy{MaxAttempts: 1, RetryableCodes: []int{503}}))
+}
+
+func TestGet_ContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := NewHTTPClient(10*time.Second, nil, false, "", "", "", TransportConfig{}).Get(ctx, srv.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestGet_RetriesCancelled(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := WithRetries(NewHTTPClient(time.Second, nil, false, "", "", "", TransportConfig{}), RetryPolicy{
+		MaxAttempts:    5,
+		Backoff:        time.Minute,
+		RetryableCodes: []int{http.StatusServiceUnavailable},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// the backoff is interrupted
+	_, _, err := client.Get(ctx, srv.URL)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, calls)
+}
+
+func TestNewHTTPClient_SharedTransport(t *testing.T) {
+	var mutex sync.Mutex
+	conns := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			conns++
+			mutex.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	transportConfig := TransportConfig{MaxIdleConnsPerHost: 5, IdleConnTimeout: time.Minute, TLSHandshakeTimeout: 5 * time.Second}
+	// a client per request, as done by handlers
+	for i := 0; i < 3; i++ {
+		_, code, err := NewHTTPClient(time.Second, nil, false, "", "", "", transportConfig).Get(context.Background(), srv.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+	}
+	// the connection is reused
+	assert.Equal(t, 1, conns)
+
+	transport := NewHTTPClient(time.Second, nil, false, "", "", "", transportConfig).(*httpClient).client.Transport.(*http.Transport)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.Equal(t, 5*time.Second, transport.TLSHandshakeTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+
+	// other settings have their own transport
+	other := NewHTTPClient(time.Second, nil, false, "", "", "", TransportConfig{EnableHTTP2: true}).(*httpClient).client.Transport.(*http.Transport)
+	assert.NotSame(t, transport, other)
+	assert.True(t, other.ForceAttemptHTTP2)
+	// defaulting to the client timeout
+	assert.Equal(t, time.Second, other.IdleConnTimeout)
+}
@@ -1,6 +1,8 @@
 package httpclienttest
 
 import (
+	"context"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -8,7 +10,7 @@ type HTTPClientMock struct {
 	mock.Mock
 }
 
-func (o *HTTPClientMock) Get(url string) ([]byte, int, error) {
+func (o *HTTPClientMock) Get(_ context.Context, url string) ([]byte, int, error) {
 	args := o.Called(url)
 	return args.Get(0).([]byte), args.Int(1), args.Error(2)
 }
@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+)
+
+// RetryPolicy defines how failed calls are retried. The backoff doubles at each attempt, up to MaxBackoff,
+// unless the response has a Retry-After header.
+type RetryPolicy struct {
+	// MaxAttempts includes the first call: 0 or 1 means no retry
+	MaxAttempts    int
+	Backoff        time.Duration
+	MaxBackoff     time.Duration
+	RetryableCodes []int
+}
+
+// headerCaller is implemented by callers giving access to the response headers
+type headerCaller interface {
+	getWithHeader(ctx context.Context, url string) ([]byte, int, http.Header, error)
+}
+
+type retryingClient struct {
+	Caller
+	policy RetryPolicy
+	sleep  func(context.Context, time.Duration) error
+	now    func() time.Time
+}
+
+// WithRetries retries the calls answered with a retryable status, according to the policy
+func WithRetries(client Caller, policy RetryPolicy) Caller {
+	if policy.MaxAttempts <= 1 || len(policy.RetryableCodes) == 0 {
+		return client
+	}
+	return &retryingClient{Caller: client, policy: policy, sleep: Sleep, now: time.Now}
+}
+
+func (c *retryingClient) get(ctx context.Context, url string) ([]byte, int, http.Header, error) {
+	if hc, ok := c.Caller.(headerCaller); ok {
+		return hc.getWithHeader(ctx, url)
+	}
+	body, code, err := c.Caller.Get(ctx, url)
+	return body, code, nil, err
+}
+
+func (c *retryingClient) isRetryable(code int) bool {
+	for _, rc := range c.policy.RetryableCodes {
+		if rc == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *retryingClient) Get(ctx context.Context, url string) ([]byte, int, error) {
+	backoff := c.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		body, code, header, err := c.get(ctx, url)
+		if err != nil || !c.isRetryable(code) || attempt >= c.policy.MaxAttempts {
+			return body, code, err
+		}
+		delay := backoff
+		if after, ok := c.retryAfter(header); ok {
+			delay = after
+		}
+		if c.policy.MaxBackoff > 0 && delay > c.policy.MaxBackoff {
+			delay = c.policy.MaxBackoff
+		}
+		slog.WithContext(ctx).Debugf("Loki answered %d, retrying in %v (attempt %d/%d)", code, delay, attempt+1, c.policy.MaxAttempts)
+		metrics.CountLokiRetry(metrics.LokiEndpoint(url), code)
+		if err := c.sleep(ctx, delay); err != nil {
+			return nil, 0, err
+		}
+		backoff *= 2
+	}
+}
+
+// retryAfter parses the Retry-After header, in seconds or as an HTTP date
+func (c *retryingClient) retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(c.now()); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
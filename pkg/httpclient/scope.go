@@ -0,0 +1,21 @@
+package httpclient
+
+// scopedClient carries the scope of its requests, see WithScope
+type scopedClient struct {
+	Caller
+	scope string
+}
+
+// WithScope attaches to the client the scope of its tenant and credentials headers, as used by the cache, so that
+// results derived from its responses can be scoped the same way
+func WithScope(client Caller, headers map[string][]string) Caller {
+	return &scopedClient{Caller: client, scope: headersScope(headers)}
+}
+
+// ScopeOf returns the scope of a client returned by WithScope, empty for other clients
+func ScopeOf(client Caller) string {
+	if sc, ok := client.(*scopedClient); ok {
+		return sc.scope
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package httpclient
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlowQuery is a Loki call which took longer than the slow query threshold
+type SlowQuery struct {
+	Time time.Time `json:"time"`
+	// Query is the LogQL of the call, or its URL without parameters when it has none
+	Query       string `json:"query"`
+	Start       string `json:"start,omitempty"`
+	End         string `json:"end,omitempty"`
+	DurationMs  int64  `json:"durationMs"`
+	Status      int    `json:"status"`
+	ResultBytes int    `json:"resultBytes"`
+	Error       string `json:"error,omitempty"`
+}
+
+// SlowQueryLog logs the calls slower than a threshold, keeping the most recent ones in memory
+type SlowQueryLog struct {
+	mutex     sync.Mutex
+	threshold time.Duration
+	size      int
+	queries   []SlowQuery
+	next      int
+	now       func() time.Time
+}
+
+// NewSlowQueryLog returns a log keeping at most size slow queries, or nil when threshold or size is not positive
+func NewSlowQueryLog(threshold time.Duration, size int) *SlowQueryLog {
+	if threshold <= 0 || size <= 0 {
+		return nil
+	}
+	return &SlowQueryLog{threshold: threshold, size: size, now: time.Now}
+}
+
+func (l *SlowQueryLog) record(ctx context.Context, q SlowQuery) {
+	slog.WithContext(ctx).WithField("durationMs", q.DurationMs).
+		WithField("status", q.Status).
+		WithField("resultBytes", q.ResultBytes).
+		Warnf("slow Loki query: %s", q.Query)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.queries) < l.size {
+		l.queries = append(l.queries, q)
+		return
+	}
+	l.queries[l.next] = q
+	l.next = (l.next + 1) % l.size
+}
+
+// Entries returns the kept slow queries, most recent first
+func (l *SlowQueryLog) Entries() []SlowQuery {
+	entries := []SlowQuery{}
+	if l == nil {
+		return entries
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i := len(l.queries) - 1; i >= 0; i-- {
+		entries = append(entries, l.queries[(l.next+i)%len(l.queries)])
+	}
+	return entries
+}
+
+type slowQueryClient struct {
+	Caller
+	log *SlowQueryLog
+}
+
+// WithSlowQueryLog records the calls slower than the log threshold
+func WithSlowQueryLog(client Caller, log *SlowQueryLog) Caller {
+	if log == nil {
+		return client
+	}
+	return &slowQueryClient{Caller: client, log: log}
+}
+
+func (c *slowQueryClient) Get(ctx context.Context, rawURL string) ([]byte, int, error) {
+	start := c.log.now()
+	body, code, err := c.Caller.Get(ctx, rawURL)
+	duration := c.log.now().Sub(start)
+	if duration < c.log.threshold {
+		return body, code, err
+	}
+	q := SlowQuery{
+		Time:        start,
+		DurationMs:  duration.Milliseconds(),
+		Status:      code,
+		ResultBytes: len(body),
+	}
+	q.Query, q.Start, q.End = splitQueryURL(rawURL)
+	if err != nil {
+		q.Error = err.Error()
+	}
+	c.log.record(ctx, q)
+	return body, code, err
+}
+
+// splitQueryURL extracts the LogQL and the time range of a query URL. Queries are not URL-encoded, Loki not managing
+// encoded parenthesis, so the other parameters are taken from the end of the URL.
+func splitQueryURL(rawURL string) (query, start, end string) {
+	path, rest, found := strings.Cut(rawURL, "?")
+	if !found {
+		return path, "", ""
+	}
+	for {
+		i := strings.LastIndexByte(rest, '&')
+		if i < 0 {
+			break
+		}
+		key, value, _ := strings.Cut(rest[i+1:], "=")
+		switch key {
+		case "start":
+			start = value
+		case "end":
+			end = value
+		case "limit", "direction", "step":
+		default:
+			return queryOrPath(path, rest), start, end
+		}
+		rest = rest[:i]
+	}
+	return queryOrPath(path, rest), start, end
+}
+
+func queryOrPath(path, params string) string {
+	if strings.HasPrefix(params, "query=") {
+		return strings.TrimPrefix(params, "query=")
+	}
+	return path
+}
@@ -0,0 +1,53 @@
+package httpclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	now := time.Unix(1680000000, 0)
+	log := NewSlowQueryLog(time.Second, 2)
+	// every call of now moves the clock forward by the duration of the next call
+	durations := []time.Duration{0, 2 * time.Second, 0, 100 * time.Millisecond, 0, 3 * time.Second, 0, 4 * time.Second}
+	log.now = func() time.Time {
+		now = now.Add(durations[0])
+		durations = durations[1:]
+		return now
+	}
+	client := WithSlowQueryLog(&scriptedCaller{codes: []int{200}}, log)
+
+	for _, q := range []string{"a", "b", "c", "d"} {
+		_, _, err := client.Get(context.Background(), `http://loki/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|~"`+q+`"&start=1&end=2&limit=100`)
+		require.NoError(t, err)
+	}
+	// the fast query isn't kept, and only the 2 most recent slow ones are
+	entries := log.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, `{app="netobserv-flowcollector"}|~"d"`, entries[0].Query)
+	assert.Equal(t, int64(4000), entries[0].DurationMs)
+	assert.Equal(t, `{app="netobserv-flowcollector"}|~"c"`, entries[1].Query)
+	assert.Equal(t, "1", entries[1].Start)
+	assert.Equal(t, "2", entries[1].End)
+	assert.Equal(t, 200, entries[1].Status)
+	assert.Equal(t, 2, entries[1].ResultBytes)
+}
+
+func TestSlowQueryLog_Disabled(t *testing.T) {
+	assert.Nil(t, NewSlowQueryLog(0, 100))
+	assert.Empty(t, (*SlowQueryLog)(nil).Entries())
+}
+
+func TestSplitQueryURL(t *testing.T) {
+	query, start, end := splitQueryURL(`http://loki/loki/api/v1/query_range?query=sum(rate({app="netobserv"}|~"a&b"[1m]))&start=10&end=20&step=30s`)
+	assert.Equal(t, `sum(rate({app="netobserv"}|~"a&b"[1m]))`, query)
+	assert.Equal(t, "10", start)
+	assert.Equal(t, "20", end)
+
+	query, _, _ = splitQueryURL("http://loki/ready")
+	assert.Equal(t, "http://loki/ready", query)
+}
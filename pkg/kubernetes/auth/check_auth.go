@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
 	"github.com/sirupsen/logrus"
@@ -24,18 +25,29 @@ const (
 	CheckNone          CheckType = "none"
 )
 
+// AnonymousUser is the user name returned when authentication checks are disabled
+const AnonymousUser = "anonymous"
+
 type Checker interface {
 	CheckAuth(ctx context.Context, header http.Header) error
+	// GetUser returns the name of the user making the request
+	GetUser(ctx context.Context, header http.Header) (string, error)
 }
 
-func NewChecker(typez CheckType, apiProvider client.APIProvider) (Checker, error) {
+// NewChecker returns a checker of the given type. Successful token reviews are cached during cacheTTL, 0 disabling
+// the cache.
+func NewChecker(typez CheckType, apiProvider client.APIProvider, cacheTTL time.Duration) (Checker, error) {
+	var cache *tokenCache
+	if cacheTTL > 0 {
+		cache = newTokenCache(cacheTTL)
+	}
 	switch typez {
 	case CheckNone:
 		return &NoopChecker{}, nil
 	case CheckAuthenticated:
-		return &BearerTokenChecker{apiProvider: apiProvider, predicates: []authPredicate{mustBeAuthenticated}}, nil
+		return &BearerTokenChecker{apiProvider: apiProvider, predicates: []authPredicate{mustBeAuthenticated}, cache: cache}, nil
 	case CheckAdmin:
-		return &BearerTokenChecker{apiProvider: apiProvider, predicates: []authPredicate{mustBeAuthenticated, mustBeClusterAdmin}}, nil
+		return &BearerTokenChecker{apiProvider: apiProvider, predicates: []authPredicate{mustBeAuthenticated, mustBeClusterAdmin}, cache: cache}, nil
 	}
 	return nil, fmt.Errorf("auth checker type unknown: %s. Must be one of %s, %s, %s", typez, CheckAdmin, CheckAuthenticated, CheckNone)
 }
@@ -49,6 +61,10 @@ func (b *NoopChecker) CheckAuth(_ context.Context, _ http.Header) error {
 	return nil
 }
 
+func (b *NoopChecker) GetUser(_ context.Context, _ http.Header) (string, error) {
+	return AnonymousUser, nil
+}
+
 func getUserToken(header http.Header) (string, error) {
 	authValue := header.Get(AuthHeader)
 	if authValue != "" {
@@ -102,6 +118,7 @@ type BearerTokenChecker struct {
 	Checker
 	apiProvider client.APIProvider
 	predicates  []authPredicate
+	cache       *tokenCache
 }
 
 func (c *BearerTokenChecker) CheckAuth(ctx context.Context, header http.Header) error {
@@ -111,10 +128,43 @@ func (c *BearerTokenChecker) CheckAuth(ctx context.Context, header http.Header)
 		return err
 	}
 	hlog.Debug("Checking auth: token found")
+	if entry, ok := c.cache.get(token); ok && entry.checked {
+		hlog.Debug("Checking auth: passed (cached)")
+		return nil
+	}
 	if err = runTokenReview(ctx, c.apiProvider, token, c.predicates); err != nil {
 		return err
 	}
+	c.cache.update(token, func(entry *tokenEntry) { entry.checked = true })
 
 	hlog.Debug("Checking auth: passed")
 	return nil
 }
+
+func (c *BearerTokenChecker) GetUser(ctx context.Context, header http.Header) (string, error) {
+	token, err := getUserToken(header)
+	if err != nil {
+		return "", err
+	}
+	if entry, ok := c.cache.get(token); ok && entry.user != "" {
+		return entry.user, nil
+	}
+	cl, err := c.apiProvider()
+	if err != nil {
+		return "", err
+	}
+	rvw, err := cl.CreateTokenReview(ctx, &authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{
+			Token: token,
+		},
+	}, &metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	if !rvw.Status.Authenticated {
+		return "", errors.New("user not authenticated")
+	}
+	user := rvw.Status.User.Username
+	c.cache.update(token, func(entry *tokenEntry) { entry.user = user })
+	return user, nil
+}
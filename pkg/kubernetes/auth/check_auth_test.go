@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
 	"github.com/stretchr/testify/mock"
@@ -14,7 +15,7 @@ import (
 )
 
 func setupChecker(typez CheckType, m *AuthCheckMock) Checker {
-	checker, _ := NewChecker(typez, func() (client.KubeAPI, error) { return m, nil })
+	checker, _ := NewChecker(typez, func() (client.KubeAPI, error) { return m, nil }, 0)
 	return checker
 }
 
@@ -215,3 +216,68 @@ func mockedTokenReviewStatus(isAuth bool) authv1.TokenReviewStatus {
 	}
 	return st
 }
+
+func TestGetUser(t *testing.T) {
+	m := AuthCheckMock{}
+	m.mockNormalUser()
+	checker := setupChecker(CheckAuthenticated, &m)
+	user, err := checker.GetUser(context.TODO(), http.Header{"Authorization": []string{"Bearer abcdef"}})
+	require.NoError(t, err)
+	require.Equal(t, "user1", user)
+
+	_, err = checker.GetUser(context.TODO(), http.Header{})
+	require.Error(t, err)
+
+	m = AuthCheckMock{}
+	m.mockNoAuth()
+	checker = setupChecker(CheckAuthenticated, &m)
+	_, err = checker.GetUser(context.TODO(), http.Header{"Authorization": []string{"Bearer abcdef"}})
+	require.Error(t, err)
+
+	user, err = (&NoopChecker{}).GetUser(context.TODO(), http.Header{})
+	require.NoError(t, err)
+	require.Equal(t, AnonymousUser, user)
+}
+
+func TestCheckAuth_Cached(t *testing.T) {
+	m := AuthCheckMock{}
+	m.mockAdmin()
+	checker, _ := NewChecker(CheckAdmin, func() (client.KubeAPI, error) { return &m, nil }, time.Minute)
+	header := http.Header{"Authorization": []string{"Bearer abcdef"}}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, checker.CheckAuth(context.TODO(), header))
+	}
+	m.AssertNumberOfCalls(t, "CreateTokenReview", 1)
+	m.AssertNumberOfCalls(t, "CheckAdmin", 1)
+
+	// the user name is reviewed once too
+	for i := 0; i < 2; i++ {
+		user, err := checker.GetUser(context.TODO(), header)
+		require.NoError(t, err)
+		require.Equal(t, "user1", user)
+	}
+	m.AssertNumberOfCalls(t, "CreateTokenReview", 2)
+	require.NoError(t, checker.CheckAuth(context.TODO(), header))
+	m.AssertNumberOfCalls(t, "CreateTokenReview", 2)
+
+	// other tokens are reviewed
+	require.NoError(t, checker.CheckAuth(context.TODO(), http.Header{"Authorization": []string{"Bearer other"}}))
+	m.AssertNumberOfCalls(t, "CreateTokenReview", 3)
+
+	// until expiration
+	checker.(*BearerTokenChecker).cache.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	require.NoError(t, checker.CheckAuth(context.TODO(), header))
+	m.AssertNumberOfCalls(t, "CreateTokenReview", 4)
+}
+
+func TestCheckAuth_FailuresNotCached(t *testing.T) {
+	m := AuthCheckMock{}
+	m.mockNormalUser()
+	checker, _ := NewChecker(CheckAdmin, func() (client.KubeAPI, error) { return &m, nil }, time.Minute)
+	header := http.Header{"Authorization": []string{"Bearer abcdef"}}
+
+	require.Error(t, checker.CheckAuth(context.TODO(), header))
+	require.Error(t, checker.CheckAuth(context.TODO(), header))
+	m.AssertNumberOfCalls(t, "CheckAdmin", 2)
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
+)
+
+// maxParallelReviews bounds the SubjectAccessReviews run at once for a user
+const maxParallelReviews = 10
+
+// namespaceAccess is the permission users need in a namespace to see its flows
+var namespaceAccess = authzv1.ResourceAttributes{Verb: "get", Resource: "pods"}
+
+// NamespaceAuthorizer tells which namespaces' flows users can see
+type NamespaceAuthorizer interface {
+	// AllowedNamespaces returns the namespaces the user can access, or all when cluster-wide access is granted
+	AllowedNamespaces(ctx context.Context, header http.Header) (namespaces []string, all bool, err error)
+}
+
+type namespacesEntry struct {
+	namespaces []string
+	all        bool
+	expiresAt  time.Time
+}
+
+// SubjectAccessReviewAuthorizer checks the user permissions with SubjectAccessReviews, first cluster-wide,
+// then in each namespace. Results are cached per token.
+type SubjectAccessReviewAuthorizer struct {
+	apiProvider client.APIProvider
+	ttl         time.Duration
+	now         func() time.Time
+	mutex       sync.Mutex
+	cache       map[string]namespacesEntry
+}
+
+func NewNamespaceAuthorizer(apiProvider client.APIProvider, ttl time.Duration) *SubjectAccessReviewAuthorizer {
+	return &SubjectAccessReviewAuthorizer{apiProvider: apiProvider, ttl: ttl, now: time.Now, cache: map[string]namespacesEntry{}}
+}
+
+func (a *SubjectAccessReviewAuthorizer) AllowedNamespaces(ctx context.Context, header http.Header) ([]string, bool, error) {
+	token, err := getUserToken(header)
+	if err != nil {
+		return nil, false, err
+	}
+	key := tokenKey(token)
+	a.mutex.Lock()
+	entry, ok := a.cache[key]
+	a.mutex.Unlock()
+	if ok && a.now().Before(entry.expiresAt) {
+		return entry.namespaces, entry.all, nil
+	}
+
+	namespaces, all, err := a.review(ctx, token)
+	if err != nil {
+		return nil, false, err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := a.now()
+	for k, e := range a.cache {
+		if !now.Before(e.expiresAt) {
+			delete(a.cache, k)
+		}
+	}
+	a.cache[key] = namespacesEntry{namespaces: namespaces, all: all, expiresAt: now.Add(a.ttl)}
+	return namespaces, all, nil
+}
+
+func (a *SubjectAccessReviewAuthorizer) review(ctx context.Context, token string) ([]string, bool, error) {
+	cl, err := a.apiProvider()
+	if err != nil {
+		return nil, false, err
+	}
+	rvw, err := cl.CreateTokenReview(ctx, &authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{
+			Token: token,
+		},
+	}, &metav1.CreateOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	if !rvw.Status.Authenticated {
+		return nil, false, errors.New("user not authenticated")
+	}
+	user := rvw.Status.User
+
+	all, err := isAllowed(ctx, cl, &user, "")
+	if err != nil || all {
+		return nil, all, err
+	}
+	namespaces, err := cl.ListNamespaces(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var mutex sync.Mutex
+	var allowed []string
+	var firstErr error
+	semaphore := make(chan struct{}, maxParallelReviews)
+	var wg sync.WaitGroup
+	for _, ns := range namespaces {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(ns string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			ok, err := isAllowed(ctx, cl, &user, ns)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			} else if ok {
+				allowed = append(allowed, ns)
+			}
+		}(ns)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, false, firstErr
+	}
+	sort.Strings(allowed)
+	hlog.Debugf("user %s can access %d namespaces out of %d", user.Username, len(allowed), len(namespaces))
+	return allowed, false, nil
+}
+
+func isAllowed(ctx context.Context, cl client.KubeAPI, user *authv1.UserInfo, namespace string) (bool, error) {
+	attributes := namespaceAccess
+	attributes.Namespace = namespace
+	extra := make(map[string]authzv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+	sar, err := cl.CreateSubjectAccessReview(ctx, &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+		},
+	}, &metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return sar.Status.Allowed, nil
+}
+
+type allowedNamespacesKey struct{}
+
+// WithAllowedNamespaces restricts the flows of the request to those of the namespaces
+func WithAllowedNamespaces(ctx context.Context, namespaces []string) context.Context {
+	return context.WithValue(ctx, allowedNamespacesKey{}, namespaces)
+}
+
+// GetAllowedNamespaces returns the namespaces the request is restricted to, restricted being false for
+// cluster-wide access
+func GetAllowedNamespaces(ctx context.Context) (namespaces []string, restricted bool) {
+	namespaces, restricted = ctx.Value(allowedNamespacesKey{}).([]string)
+	return namespaces, restricted
+}
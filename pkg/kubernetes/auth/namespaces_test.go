@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
+)
+
+func (m *AuthCheckMock) CreateSubjectAccessReview(ctx context.Context, sar *authzv1.SubjectAccessReview, opts *metav1.CreateOptions) (*authzv1.SubjectAccessReview, error) {
+	args := m.Called(sar.Spec.ResourceAttributes.Namespace)
+	return &authzv1.SubjectAccessReview{Status: authzv1.SubjectAccessReviewStatus{Allowed: args.Bool(0)}}, args.Error(1)
+}
+
+func (m *AuthCheckMock) ListNamespaces(ctx context.Context) ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func setupAuthorizer(m *AuthCheckMock) *SubjectAccessReviewAuthorizer {
+	return NewNamespaceAuthorizer(func() (client.KubeAPI, error) { return m, nil }, time.Minute)
+}
+
+var userHeader = http.Header{"Authorization": []string{"Bearer abcdef"}}
+
+func TestAllowedNamespaces_ClusterWide(t *testing.T) {
+	m := AuthCheckMock{}
+	m.mockNormalUser()
+	m.On("CreateSubjectAccessReview", "").Return(true, nil)
+
+	namespaces, all, err := setupAuthorizer(&m).AllowedNamespaces(context.TODO(), userHeader)
+	require.NoError(t, err)
+	require.True(t, all)
+	require.Empty(t, namespaces)
+	m.AssertNotCalled(t, "ListNamespaces")
+}
+
+func TestAllowedNamespaces_Restricted(t *testing.T) {
+	m := AuthCheckMock{}
+	m.mockNormalUser()
+	m.On("CreateSubjectAccessReview", "").Return(false, nil)
+	m.On("CreateSubjectAccessReview", "ns-b").Return(true, nil)
+	m.On("CreateSubjectAccessReview", "ns-a").Return(true, nil)
+	m.On("CreateSubjectAccessReview", mock.Anything).Return(false, nil)
+	m.On("ListNamespaces").Return([]string{"ns-b", "ns-c", "ns-a"}, nil)
+
+	authorizer := setupAuthorizer(&m)
+	namespaces, all, err := authorizer.AllowedNamespaces(context.TODO(), userHeader)
+	require.NoError(t, err)
+	require.False(t, all)
+	require.Equal(t, []string{"ns-a", "ns-b"}, namespaces)
+
+	// cached
+	namespaces, _, err = authorizer.AllowedNamespaces(context.TODO(), userHeader)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ns-a", "ns-b"}, namespaces)
+	m.AssertNumberOfCalls(t, "ListNamespaces", 1)
+
+	// expired
+	authorizer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, _, err = authorizer.AllowedNamespaces(context.TODO(), userHeader)
+	require.NoError(t, err)
+	m.AssertNumberOfCalls(t, "ListNamespaces", 2)
+}
+
+func TestAllowedNamespaces_NotAuthenticated(t *testing.T) {
+	m := AuthCheckMock{}
+	m.mockNoAuth()
+
+	_, _, err := setupAuthorizer(&m).AllowedNamespaces(context.TODO(), userHeader)
+	require.Error(t, err)
+	require.Equal(t, "user not authenticated", err.Error())
+
+	_, _, err = setupAuthorizer(&m).AllowedNamespaces(context.TODO(), http.Header{})
+	require.Error(t, err)
+}
+
+func TestAllowedNamespacesContext(t *testing.T) {
+	_, restricted := GetAllowedNamespaces(context.TODO())
+	require.False(t, restricted)
+
+	namespaces, restricted := GetAllowedNamespaces(WithAllowedNamespaces(context.TODO(), []string{"ns-a"}))
+	require.True(t, restricted)
+	require.Equal(t, []string{"ns-a"}, namespaces)
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// tokenCache remembers the reviews of tokens for a short while, saving a TokenReview per request.
+// Tokens are only kept hashed.
+type tokenCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mutex   sync.Mutex
+	entries map[string]tokenEntry
+}
+
+type tokenEntry struct {
+	// checked is set once the token passed the checker predicates
+	checked   bool
+	user      string
+	expiresAt time.Time
+}
+
+func newTokenCache(ttl time.Duration) *tokenCache {
+	return &tokenCache{ttl: ttl, now: time.Now, entries: map[string]tokenEntry{}}
+}
+
+func tokenKey(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func (c *tokenCache) get(token string) (tokenEntry, bool) {
+	if c == nil {
+		return tokenEntry{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[tokenKey(token)]
+	if !ok || !c.now().Before(entry.expiresAt) {
+		return tokenEntry{}, false
+	}
+	return entry, true
+}
+
+// update modifies the cached entry of the token, restarting its expiration
+func (c *tokenCache) update(token string, mutate func(entry *tokenEntry)) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := c.now()
+	key := tokenKey(token)
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		entry = tokenEntry{}
+	}
+	mutate(&entry)
+	entry.expiresAt = now.Add(c.ttl)
+	for k, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
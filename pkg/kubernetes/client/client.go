@@ -4,8 +4,10 @@ import (
 	"context"
 
 	authv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 )
 
@@ -13,6 +15,9 @@ import (
 type KubeAPI interface {
 	CreateTokenReview(ctx context.Context, tr *authv1.TokenReview, opts *metav1.CreateOptions) (*authv1.TokenReview, error)
 	CheckAdmin(ctx context.Context, token string) error
+	CreateSubjectAccessReview(ctx context.Context, sar *authzv1.SubjectAccessReview, opts *metav1.CreateOptions) (*authzv1.SubjectAccessReview, error)
+	// ListNamespaces returns the names of all the namespaces, using the plugin service account
+	ListNamespaces(ctx context.Context) ([]string, error)
 }
 
 type APIProvider func() (KubeAPI, error)
@@ -26,6 +31,22 @@ func (c *InCluster) CreateTokenReview(ctx context.Context, tr *authv1.TokenRevie
 	return c.client.AuthenticationV1().TokenReviews().Create(ctx, tr, *opts)
 }
 
+func (c *InCluster) CreateSubjectAccessReview(ctx context.Context, sar *authzv1.SubjectAccessReview, opts *metav1.CreateOptions) (*authzv1.SubjectAccessReview, error) {
+	return c.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, *opts)
+}
+
+func (c *InCluster) ListNamespaces(ctx context.Context) ([]string, error) {
+	list, err := c.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for i := range list.Items {
+		names = append(names, list.Items[i].Name)
+	}
+	return names, nil
+}
+
 func (c *InCluster) CheckAdmin(ctx context.Context, token string) error {
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -53,3 +74,16 @@ func NewInCluster() (KubeAPI, error) {
 	}
 	return &InCluster{client: client}, nil
 }
+
+// NewInClusterConfigMaps returns a client for the ConfigMaps of a namespace, using the plugin service account
+func NewInClusterConfigMaps(namespace string) (corev1client.ConfigMapInterface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.CoreV1().ConfigMaps(namespace), nil
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const maxConfigMapUpdateTries = 5
+
+// GetConfigMapData returns the data of a ConfigMap, empty when it doesn't exist
+func GetConfigMapData(ctx context.Context, client corev1client.ConfigMapInterface, name string) (map[string]string, error) {
+	cm, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if cm.Data == nil {
+		return map[string]string{}, nil
+	}
+	return cm.Data, nil
+}
+
+// UpdateConfigMapData applies the mutation on the latest data of a ConfigMap, created if needed,
+// retrying on concurrent modifications
+func UpdateConfigMapData(ctx context.Context, client corev1client.ConfigMapInterface, name string, mutate func(data map[string]string) error) error {
+	return UpdateLabelledConfigMapData(ctx, client, name, nil, mutate)
+}
+
+// UpdateLabelledConfigMapData is UpdateConfigMapData, the ConfigMap being created with labels
+func UpdateLabelledConfigMapData(ctx context.Context, client corev1client.ConfigMapInterface, name string, labels map[string]string, mutate func(data map[string]string) error) error {
+	var err error
+	for try := 0; try < maxConfigMapUpdateTries; try++ {
+		err = tryUpdateConfigMapData(ctx, client, name, labels, mutate)
+		if !apierrors.IsConflict(err) && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func tryUpdateConfigMapData(ctx context.Context, client corev1client.ConfigMapInterface, name string, labels map[string]string, mutate func(data map[string]string) error) error {
+	cm, err := client.Get(ctx, name, metav1.GetOptions{})
+	exists := true
+	if apierrors.IsNotFound(err) {
+		exists = false
+		cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	} else if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	if err := mutate(cm.Data); err != nil {
+		return err
+	}
+	if exists {
+		_, err = client.Update(ctx, cm, metav1.UpdateOptions{})
+	} else {
+		_, err = client.Create(ctx, cm, metav1.CreateOptions{})
+	}
+	return err
+}
+
+// DeleteConfigMap deletes a ConfigMap, not failing when it doesn't exist
+func DeleteConfigMap(ctx context.Context, client corev1client.ConfigMapInterface, name string) error {
+	err := client.Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -4,25 +4,138 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils"
 )
 
 type Config struct {
-	URL                *url.URL
-	StatusURL          *url.URL
-	Timeout            time.Duration
-	TenantID           string
-	TokenPath          string
-	SkipTLS            bool
-	CAPath             string
+	URL       *url.URL
+	StatusURL *url.URL
+	Timeout   time.Duration
+	TenantID  string
+	TokenPath string
+	SkipTLS   bool
+	CAPath    string
+	// UserCertPath and UserKeyPath are the client certificate for gateways requiring mutual TLS, reloaded on change
+	UserCertPath       string
+	UserKeyPath        string
 	StatusSkipTLS      bool
 	StatusCAPath       string
 	StatusUserCertPath string
 	StatusUserKeyPath  string
 
+	// TenantMapping maps namespaces to Loki tenants, overriding TenantID for the users restricted to namespaces
+	// mapped to a same tenant
+	TenantMapping map[string]string
+
 	UseMocks         bool
 	ForwardUserToken bool
 	Labels           map[string]struct{}
+
+	// MaxQuerySpan and MaxQueryLookback cap the requested time window (0 means no cap).
+	// Callers presenting the token read from TrustedCallerTokenPath are exempted.
+	MaxQuerySpan           time.Duration
+	MaxQueryLookback       time.Duration
+	TrustedCallerTokenPath string
+
+	// ValueListsPath is the directory holding named value lists (e.g. a mounted ConfigMap),
+	// referenced in filters as @name
+	ValueListsPath   string
+	MaxValueListSize int
+
+	// RegexSubstringMatch disables the anchoring of user-provided regular expressions (key=~regex),
+	// so that they match substrings (e.g. "web" matches "webhook")
+	RegexSubstringMatch bool
+
+	// PortNames maps port numbers to service names, used to annotate flows
+	PortNames map[string]string
+	// AppProtocols maps ports, optionally suffixed with /tcp or /udp, to application protocols,
+	// used to infer flows application protocol when InferAppProtocol is set
+	AppProtocols     map[string]string
+	InferAppProtocol bool
+	// ServicePorts maps service names to their ports, used to filter flows by service
+	ServicePorts map[string][]string
+
+	// OverloadBackoff is the delay before retrying a query rejected by Loki with "too many outstanding requests".
+	// When 0, such queries fail immediately with 503.
+	OverloadBackoff time.Duration
+
+	// MaxRequestTimeout bounds the duration of a request, including all its Loki queries; requests can ask for
+	// a shorter timeout (0 means no bound)
+	MaxRequestTimeout time.Duration
+
+	// Transport tunes the pool of connections to Loki
+	Transport httpclient.TransportConfig
+
+	// Retry defines how Loki calls failing with transient errors are retried
+	Retry httpclient.RetryPolicy
+
+	// AdmissionQueue, shared by all requests, bounds the number of running and waiting Loki queries
+	// (nil means no bound)
+	AdmissionQueue *httpclient.AdmissionQueue
+
+	// CircuitBreaker, shared by all requests, fails queries fast while Loki is failing (nil means no breaker)
+	CircuitBreaker *httpclient.CircuitBreaker
+
+	// QueryCache holds recent Loki responses, shared by all requests (nil means no caching).
+	// NegativeCache holds the queries which recently returned nothing, regardless of their time range position.
+	QueryCache    *httpclient.Cache
+	NegativeCache *httpclient.Cache
+
+	// SlowQueries, shared by all requests, logs and keeps the Loki queries slower than its threshold
+	// (nil means no logging)
+	SlowQueries *httpclient.SlowQueryLog
+
+	// AutoFieldSelection excludes from flows the fields of disabled features (e.g. DNS tracking),
+	// as detected by probing recent flows. It can be overridden per request.
+	AutoFieldSelection bool
+
+	// TrimFilters removes surrounding whitespace from filter keys and values.
+	// LowercaseFilters lowercases values of fields known to be lowercase, such as Kubernetes names.
+	TrimFilters      bool
+	LowercaseFilters bool
+
+	// MaxStringFieldLength truncates longer string fields in flows responses (0 means no truncation)
+	MaxStringFieldLength int
+
+	// FlowReconciliation is the default strategy to merge a same flow reported by both source and destination
+	// (keep-both, max, sum, source-wins or destination-wins)
+	FlowReconciliation string
+
+	// EnforcedFilters are ANDed to every query and cannot be overridden (e.g. RBAC restrictions).
+	// DefaultExclusions are ANDed to every filter group, keeping their own OR groups, except for the fields the group
+	// explicitly filters on.
+	// QueryDefaults only apply to parameters missing from requests. See handler.preprocessFilters.
+	EnforcedFilters   string
+	DefaultExclusions string
+	QueryDefaults     url.Values
+
+	// MaxParallelQueries caps the number of Loki queries run in parallel for a single request, e.g. one per filter
+	// group (0 means no cap)
+	MaxParallelQueries int
+	// PartialResults returns the results of the successful parallel queries when others failed, rather than an
+	// error, the failures being listed in the response
+	PartialResults bool
+
+	// QueryShardThreshold is the flows query time range above which it is split into QueryShards contiguous
+	// sub-ranges, queried in parallel (0 means no split)
+	QueryShardThreshold time.Duration
+	QueryShards         int
+
+	// IngestionDelay shifts back the end of queries targeting the most recent data, which is still being ingested
+	// (0 means no shift)
+	IngestionDelay time.Duration
+
+	// FilterPresets are named filters, in the filters parameter syntax, that requests reference as preset=<name>
+	FilterPresets map[string]string
+
+	// RestrictedMaxLimit caps the flows and topology limit of the users restricted to some namespaces (0 means no
+	// cap), and RestrictedDisableExport forbids their exports. See the frontend config restrictedAccess section.
+	RestrictedMaxLimit      int
+	RestrictedDisableExport bool
+
+	// ForwardedHeaders lists the inbound headers copied to Loki requests, unless already set by configuration
+	ForwardedHeaders []string
 }
 
 func NewConfig(url *url.URL, statusURL *url.URL, timeout time.Duration, tenantID string, tokenPath string, forwardUserToken bool, skipTLS bool, capath string, statusSkipTLS bool, statusCapath string, statusUserCertPath string, statusUserKeyPath string, useMocks bool, labels []string) Config {
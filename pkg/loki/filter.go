@@ -8,6 +8,9 @@ import (
 // remove quotes and replace * by regex any
 var valueReplacer = strings.NewReplacer(`*`, `.*`, `"`, "")
 
+// escape characters of user-provided regex that have a special meaning in the query URL
+var rawRegexReplacer = strings.NewReplacer(`%`, `%25`, `+`, `%2B`, `#`, `%23`, `&`, `%26`)
+
 type labelMatcher string
 
 const (
@@ -15,6 +18,10 @@ const (
 	labelMatches   = labelMatcher("=~")
 	labelNotEqual  = labelMatcher("!=")
 	labelNoMatches = labelMatcher("!~")
+	labelGreater   = labelMatcher(">")
+	labelGreaterEq = labelMatcher(">=")
+	labelLower     = labelMatcher("<")
+	labelLowerEq   = labelMatcher("<=")
 )
 
 type valueType int
@@ -24,6 +31,8 @@ const (
 	typeString
 	typeRegex
 	typeIP
+	// typeRawRegex is a user-provided regular expression, written as is
+	typeRawRegex
 )
 
 // labelFilter represents a condition based on a label name, value and matching operator. It
@@ -83,10 +92,36 @@ func notRegexLabelFilter(labelKey string, value string) labelFilter {
 	}
 }
 
-func ipLabelFilter(labelKey, cidr string) labelFilter {
+func rawRegexLabelFilter(labelKey string, value string, not bool) labelFilter {
+	matcher := labelMatches
+	if not {
+		matcher = labelNoMatches
+	}
 	return labelFilter{
 		key:       labelKey,
-		matcher:   labelEqual,
+		matcher:   matcher,
+		value:     value,
+		valueType: typeRawRegex,
+	}
+}
+
+func numberLabelFilter(labelKey string, matcher labelMatcher, value string) labelFilter {
+	return labelFilter{
+		key:       labelKey,
+		matcher:   matcher,
+		value:     value,
+		valueType: typeNumber,
+	}
+}
+
+func ipLabelFilter(labelKey, cidr string, not bool) labelFilter {
+	matcher := labelEqual
+	if not {
+		matcher = labelNotEqual
+	}
+	return labelFilter{
+		key:       labelKey,
+		matcher:   matcher,
 		value:     cidr,
 		valueType: typeIP,
 	}
@@ -110,6 +145,10 @@ func (f *labelFilter) writeInto(sb *strings.Builder) {
 		sb.WriteString("`(?i).*")
 		sb.WriteString(f.value)
 		sb.WriteString(".*`")
+	case typeRawRegex:
+		sb.WriteByte('`')
+		sb.WriteString(rawRegexReplacer.Replace(f.value))
+		sb.WriteByte('`')
 	default:
 		panic(fmt.Sprint("wrong filter value type", int(f.valueType)))
 	}
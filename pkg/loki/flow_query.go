@@ -3,7 +3,10 @@ package loki
 
 import (
 	"fmt"
+	"math"
+	"net"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
@@ -17,7 +20,10 @@ const (
 	startParam      = "start"
 	endParam        = "end"
 	limitParam      = "limit"
+	directionParam  = "direction"
 	queryRangePath  = "/loki/api/v1/query_range?query="
+	indexStatsPath  = "/loki/api/v1/index/stats?query="
+	seriesPath      = "/loki/api/v1/series?match[]="
 	jsonOrJoiner    = "+or+"
 	emptyMatch      = `""`
 )
@@ -25,12 +31,16 @@ const (
 // can contains only alphanumeric / '-' / '_' / '.' / ',' / '"' / '*' / ':' / '/' characteres
 var filterRegexpValidation = regexp.MustCompile(`^[\w-_.,\"*:/]*$`)
 
+// user-provided regular expressions cannot contain quotes, backquotes or spaces
+var userRegexpValidation = regexp.MustCompile("^[^\"`\\s]+$")
+
 // FlowQueryBuilder stores a state to build a LogQL query
 type FlowQueryBuilder struct {
 	config           *Config
 	startTime        string
 	endTime          string
 	limit            string
+	direction        string
 	labelFilters     []labelFilter
 	lineFilters      []lineFilter
 	extraLineFilters []string
@@ -86,7 +96,45 @@ func (q *FlowQueryBuilder) Filters(queryFilters filters.SingleQuery) error {
 	return nil
 }
 
+// Backward sorts results from the most recent, which is already Loki default, but made explicit
+// for queries relying on it (e.g. limit=1 to get the latest flow)
+func (q *FlowQueryBuilder) Backward() {
+	q.direction = "backward"
+}
+
+// ExcludeZeroBytes removes flows without any byte (e.g. keepalive / control flows). This is done in the query
+// rather than in post-processing so that it applies before the limit.
+func (q *FlowQueryBuilder) ExcludeZeroBytes() {
+	q.jsonFilters = append(q.jsonFilters, []labelFilter{numberLabelFilter(fields.Bytes, labelGreater, "0")})
+}
+
+// HasField keeps only the flows where the given field is set
+func (q *FlowQueryBuilder) HasField(key string) {
+	q.extraLineFilters = append(q.extraLineFilters, "|~`\""+key+"\":`")
+}
+
+// HasFieldPrefix keeps only the flows where the given string field starts with prefix, ignoring case. The prefix is
+// matched literally, it must not need to be escaped in JSON.
+func (q *FlowQueryBuilder) HasFieldPrefix(key, prefix string) {
+	q.extraLineFilters = append(q.extraLineFilters, "|~`(?i)\""+key+"\":\""+regexp.QuoteMeta(prefix)+"`")
+}
+
 func (q *FlowQueryBuilder) addFilter(filter filters.Match) error {
+	if filter.Regex {
+		return q.addUserRegexFilter(filter)
+	}
+	if filter.Comparison != "" {
+		return q.addComparisonFilter(filter)
+	}
+	if filter.Exists {
+		q.addExistsFilter(filter.Key)
+		return nil
+	}
+	if parse, ok := symbolicFields[filter.Key]; ok {
+		if err := q.translateSymbolicValues(&filter, parse); err != nil {
+			return err
+		}
+	}
 	if !filterRegexpValidation.MatchString(filter.Values) {
 		return fmt.Errorf("unauthorized sign in flows request: %s", filter.Values)
 	}
@@ -105,10 +153,7 @@ func (q *FlowQueryBuilder) addFilter(filter filters.Match) error {
 			q.addLabelRegex(filter.Key, values, filter.Not)
 		}
 	} else if fields.IsIP(filter.Key) {
-		if filter.Not {
-			return fmt.Errorf("'not' operation not allowed in IP filters")
-		}
-		q.addIPFilters(filter.Key, values)
+		return q.addIPFilters(filter.Key, values, filter.Not)
 	} else {
 		q.addLineFilters(filter.Key, values, filter.Not)
 	}
@@ -116,6 +161,36 @@ func (q *FlowQueryBuilder) addFilter(filter filters.Match) error {
 	return nil
 }
 
+// addUserRegexFilter adds a filter from a user-provided regular expression. Unless configured for substring
+// matching, the regex is anchored: as for any LogQL label matcher, it must match the whole value.
+// Non-label fields are matched via JSON label filters, which follow the same semantic.
+// Case-insensitive filters are given the (?i) flag.
+func (q *FlowQueryBuilder) addUserRegexFilter(filter filters.Match) error {
+	if !userRegexpValidation.MatchString(filter.Values) {
+		return fmt.Errorf("unauthorized sign in flows request: %s", filter.Values)
+	}
+	if fields.IsIP(filter.Key) {
+		return fmt.Errorf("regular expressions are not allowed in IP filters")
+	}
+	// Loki uses the same RE2 syntax: invalid expressions are rejected early, with a clear error
+	if _, err := regexp.Compile(filter.Values); err != nil {
+		return fmt.Errorf("invalid regular expression in %s filter: %w", filter.Key, err)
+	}
+	regex := filter.Values
+	if q.config.RegexSubstringMatch {
+		regex = ".*(?:" + regex + ").*"
+	}
+	if filter.CaseInsensitive {
+		regex = "(?i)" + regex
+	}
+	if q.config.IsLabel(filter.Key) {
+		q.labelFilters = append(q.labelFilters, rawRegexLabelFilter(filter.Key, regex, filter.Not))
+	} else {
+		q.jsonFilters = append(q.jsonFilters, []labelFilter{rawRegexLabelFilter(filter.Key, regex, filter.Not)})
+	}
+	return nil
+}
+
 func (q *FlowQueryBuilder) addLabelRegex(key string, values []string, not bool) {
 	regexStr := strings.Builder{}
 	for i, value := range values {
@@ -180,19 +255,129 @@ func (q *FlowQueryBuilder) addLineFilters(key string, values []string, not bool)
 	}
 }
 
+// addExistsFilter keeps flows having a non-empty value. For indexed labels, a stream selector matcher
+// is used so that Loki can use its index; other fields are checked after JSON parsing.
+func (q *FlowQueryBuilder) addExistsFilter(key string) {
+	if q.config.IsLabel(key) {
+		q.labelFilters = append(q.labelFilters, regexLabelFilter(key, ".+"))
+	} else {
+		q.jsonFilters = append(q.jsonFilters, []labelFilter{notStringLabelFilter(key, "")})
+	}
+}
+
+// symbolicFields are numeric fields that also accept names, translated into their numeric value.
+// For TCP flags, flows match when the whole set of flags observed is the one provided: e.g. SYN matches flows
+// where only SYN was seen, as in SYN floods, while SYN-ACK matches flows with only SYN and ACK.
+var symbolicFields = map[string]func(string) (int, error){
+	fields.TCPFlags: fields.ParseTCPFlags,
+	fields.IcmpType: fields.ParseICMPType,
+	fields.IcmpCode: fields.ParseICMPCode,
+	fields.DSCP:     fields.ParseDSCP,
+	// FlowDirection is a label: filtering on a single direction avoids counting twice the traffic
+	// observed on both interfaces of a node
+	fields.FlowDirection: fields.ParseFlowDirection,
+}
+
+func (q *FlowQueryBuilder) translateSymbolicValues(filter *filters.Match, parse func(string) (int, error)) error {
+	values := strings.Split(filter.Values, ",")
+	for i, v := range values {
+		n, err := parse(trimExactMatch(v))
+		if err != nil {
+			return err
+		}
+		values[i] = strconv.Itoa(n)
+		if q.config.IsLabel(filter.Key) {
+			// exact label matchers
+			values[i] = `"` + values[i] + `"`
+		}
+	}
+	filter.Values = strings.Join(values, ",")
+	return nil
+}
+
+// addComparisonFilter adds a numeric comparison as a JSON label filter, e.g. |json|Bytes>1000.
+// Port comparisons apply to either source or destination port.
+func (q *FlowQueryBuilder) addComparisonFilter(filter filters.Match) error {
+	if !fields.IsNumeric(filter.Key) {
+		return fmt.Errorf("numeric comparison not allowed on field: %s", filter.Key)
+	}
+	value, err := strconv.ParseFloat(filter.Values, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number in %s filter: %q", filter.Key, filter.Values)
+	}
+	switch filter.Key {
+	case fields.Port, fields.SrcPort, fields.DstPort:
+		if value != math.Trunc(value) || value < 0 || value > 65535 {
+			return fmt.Errorf("invalid port in %s filter: %q", filter.Key, filter.Values)
+		}
+	case fields.Bytes, fields.Packets:
+		if value < 0 {
+			return fmt.Errorf("invalid negative value in %s filter: %q", filter.Key, filter.Values)
+		}
+	}
+	matcher := labelMatcher(filter.Comparison)
+	switch matcher {
+	case labelGreater, labelGreaterEq, labelLower, labelLowerEq:
+	default:
+		return fmt.Errorf("unknown comparison operator: %s", filter.Comparison)
+	}
+	if filter.Key == fields.Port {
+		q.jsonFilters = append(q.jsonFilters, []labelFilter{
+			numberLabelFilter(fields.SrcPort, matcher, filter.Values),
+			numberLabelFilter(fields.DstPort, matcher, filter.Values),
+		})
+	} else {
+		q.jsonFilters = append(q.jsonFilters, []labelFilter{numberLabelFilter(filter.Key, matcher, filter.Values)})
+	}
+	return nil
+}
+
 // addIPFilters assumes that we are searching for that IP addresses as part
-// of the log line (not in the stream selector labels)
-func (q *FlowQueryBuilder) addIPFilters(key string, values []string) {
+// of the log line (not in the stream selector labels). Negated filters must match none of the values.
+// Values can be addresses, CIDR subnets (e.g. 10.128.0.0/14) or ranges (e.g. 10.0.0.1-10.0.0.9), translated
+// into LogQL ip() matchers.
+func (q *FlowQueryBuilder) addIPFilters(key string, values []string, not bool) error {
 	filtersPerKey := make([]labelFilter, 0, len(values))
 	for _, value := range values {
+		var lf labelFilter
 		// empty exact matches should be treated as attribute filters looking for empty IP
 		if value == emptyMatch {
-			filtersPerKey = append(filtersPerKey, stringLabelFilter(key, ""))
+			if not {
+				lf = notStringLabelFilter(key, "")
+			} else {
+				lf = stringLabelFilter(key, "")
+			}
+		} else {
+			if isExactMatch(value) {
+				value = trimExactMatch(value)
+			}
+			if !isValidIPMatch(value) {
+				return fmt.Errorf("invalid IP, CIDR or range in %s filter: %q", key, value)
+			}
+			lf = ipLabelFilter(key, value, not)
+		}
+		if not {
+			// none of the values: each one is a distinct (AND'ed) filter
+			q.jsonFilters = append(q.jsonFilters, []labelFilter{lf})
 		} else {
-			filtersPerKey = append(filtersPerKey, ipLabelFilter(key, value))
+			filtersPerKey = append(filtersPerKey, lf)
 		}
 	}
-	q.jsonFilters = append(q.jsonFilters, filtersPerKey)
+	if len(filtersPerKey) > 0 {
+		q.jsonFilters = append(q.jsonFilters, filtersPerKey)
+	}
+	return nil
+}
+
+func isValidIPMatch(value string) bool {
+	if strings.Contains(value, "/") {
+		_, _, err := net.ParseCIDR(value)
+		return err == nil
+	}
+	if from, to, isRange := strings.Cut(value, "-"); isRange {
+		return net.ParseIP(from) != nil && net.ParseIP(to) != nil
+	}
+	return net.ParseIP(value) != nil
 }
 
 func (q *FlowQueryBuilder) createStringBuilderURL() *strings.Builder {
@@ -255,6 +440,9 @@ func (q *FlowQueryBuilder) appendQueryParams(sb *strings.Builder) {
 	if len(q.limit) > 0 {
 		appendQueryParam(sb, limitParam, q.limit)
 	}
+	if len(q.direction) > 0 {
+		appendQueryParam(sb, directionParam, q.direction)
+	}
 }
 
 func (q *FlowQueryBuilder) Build() string {
@@ -266,6 +454,41 @@ func (q *FlowQueryBuilder) Build() string {
 	return sb.String()
 }
 
+// BuildIndexStats builds the Loki index stats request for the query stream selector. Index stats ignore
+// line and JSON filters: they cover all the streams, chunks and bytes that Loki has to read.
+func (q *FlowQueryBuilder) BuildIndexStats() string {
+	sb := strings.Builder{}
+	sb.WriteString(strings.TrimRight(q.config.URL.String(), "/"))
+	sb.WriteString(indexStatsPath)
+	q.appendLabels(&sb)
+	if len(q.startTime) > 0 {
+		appendQueryParam(&sb, startParam, q.startTime)
+	}
+	if len(q.endTime) > 0 {
+		appendQueryParam(&sb, endParam, q.endTime)
+	}
+	return sb.String()
+}
+
+// BuildSeries builds the Loki series request for the query stream selector, listing the label sets of the matching
+// streams. It returns false when the query has line or JSON filters, which the series API can't apply.
+func (q *FlowQueryBuilder) BuildSeries() (string, bool) {
+	if len(q.lineFilters) > 0 || len(q.extraLineFilters) > 0 || len(q.jsonFilters) > 0 {
+		return "", false
+	}
+	sb := strings.Builder{}
+	sb.WriteString(strings.TrimRight(q.config.URL.String(), "/"))
+	sb.WriteString(seriesPath)
+	q.appendLabels(&sb)
+	if len(q.startTime) > 0 {
+		appendQueryParam(&sb, startParam, q.startTime)
+	}
+	if len(q.endTime) > 0 {
+		appendQueryParam(&sb, endParam, q.endTime)
+	}
+	return sb.String(), true
+}
+
 func appendQueryParam(sb *strings.Builder, key, value string) {
 	sb.WriteByte('&')
 	sb.WriteString(key)
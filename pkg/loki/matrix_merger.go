@@ -88,12 +88,14 @@ func (m *MatrixMerger) Get() *model.AggregatedQueryResponse {
 		}
 	}
 	return &model.AggregatedQueryResponse{
-		ResultType: model.ResultTypeMatrix,
-		Result:     m.merged,
+		ResultType:     model.ResultTypeMatrix,
+		Result:         m.merged,
+		IsLimitReached: m.limitReached && m.reqLimit > 0,
 		Stats: model.AggregatedStats{
 			NumQueries:   m.numQueries,
 			LimitReached: m.limitReached,
 			QueriesStats: m.stats,
+			Execution:    summarizeStats(m.stats),
 		},
 	}
 }
@@ -97,3 +97,273 @@ func TestFlowQuery_AddRecordTypeLabelFilter(t *testing.T) {
 	urlQuery := query.Build()
 	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",_RecordType="flowLog",foo="bar",flis="flas"}`, urlQuery)
 }
+
+func TestFlowQuery_UserRegexAnchored(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.addFilter(filters.NewRegexMatch("SrcK8S_Namespace", `web`))
+	require.NoError(t, err)
+	err = query.addFilter(filters.NewRegexMatch("DstK8S_Name", `web-.+`))
+	require.NoError(t, err)
+	urlQuery := query.Build()
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace=~`+backtick(`web`)+`}|json|DstK8S_Name=~`+backtick(`web-.%2B`), urlQuery)
+}
+
+func TestFlowQuery_NotFilters(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{
+		filters.NewNotMatch("SrcK8S_Namespace", `"openshift-monitoring"`),
+		filters.NewNotRegexMatch("SrcK8S_Namespace", `kube-.*`),
+		filters.NewNotRegexMatch("DstK8S_Name", `web-.+`),
+		filters.NewNotMatch("DstAddr", "10.0.0.1,10.0.0.2"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace!="openshift-monitoring",SrcK8S_Namespace!~`+backtick(`kube-.*`)+`}|json|DstK8S_Name!~`+backtick(`web-.%2B`)+`|DstAddr!=ip("10.0.0.1")|DstAddr!=ip("10.0.0.2")`, query.Build())
+}
+
+func TestFlowQuery_IPFilters(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{
+		filters.NewMatch("SrcAddr", "10.128.0.0/14,fd00::/8"),
+		filters.NewMatch("DstAddr", `"10.0.0.1-10.0.0.9"`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|json|SrcAddr=ip("10.128.0.0/14")+or+SrcAddr=ip("fd00::/8")|DstAddr=ip("10.0.0.1-10.0.0.9")`, query.Build())
+
+	for _, invalid := range []string{"10.128.0.0/33", "10.128", "10.0.0.1-foo", "*"} {
+		query = NewFlowQueryBuilderWithDefaults(&cfg)
+		assert.Error(t, query.Filters(filters.SingleQuery{filters.NewMatch("SrcAddr", invalid)}), invalid)
+	}
+}
+
+func TestFlowQuery_TCPFlags(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{filters.NewMatch("Flags", "SYN,syn-ack,RST,20")})
+	require.NoError(t, err)
+	assert.Equal(t, "/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\"}|~`Flags\":2[,}]|Flags\":18[,}]|Flags\":4[,}]|Flags\":20[,}]`", query.Build())
+
+	query = NewFlowQueryBuilderWithDefaults(&cfg)
+	assert.Error(t, query.Filters(filters.SingleQuery{filters.NewMatch("Flags", "SYN-FOO")}))
+}
+
+func TestFlowQuery_ICMP(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{
+		filters.NewMatch("IcmpType", "echo-request,Echo-Reply"),
+		filters.NewNotMatch("IcmpCode", "port-unreachable"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\"}|~`IcmpType\":8[,}]|IcmpType\":0[,}]`!~`IcmpCode\":3[,}]`", query.Build())
+
+	for _, invalid := range []filters.Match{filters.NewMatch("IcmpType", "echo"), filters.NewMatch("IcmpCode", "300")} {
+		query = NewFlowQueryBuilderWithDefaults(&cfg)
+		assert.Error(t, query.Filters(filters.SingleQuery{invalid}), invalid)
+	}
+}
+
+func TestFlowQuery_DSCP(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{filters.NewMatch("Dscp", "EF,af41,CS0,10")})
+	require.NoError(t, err)
+	assert.Equal(t, "/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\"}|~`Dscp\":46[,}]|Dscp\":34[,}]|Dscp\":0[,}]|Dscp\":10[,}]`", query.Build())
+
+	for _, invalid := range []string{"AF44", "64"} {
+		query = NewFlowQueryBuilderWithDefaults(&cfg)
+		assert.Error(t, query.Filters(filters.SingleQuery{filters.NewMatch("Dscp", invalid)}), invalid)
+	}
+}
+
+func TestFlowQuery_InterfaceAndDirection(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"FlowDirection"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{
+		filters.NewMatch("FlowDirection", "egress"),
+		filters.NewMatch("Interface", `"br-ex"`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\",FlowDirection=\"1\"}|~`Interface\":\"br-ex\"`", query.Build())
+
+	query = NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{filters.NewMatch("FlowDirection", "InGress,1")})
+	require.NoError(t, err)
+	assert.Equal(t, "/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\",FlowDirection=~\"^0$|^1$\"}", query.Build())
+
+	query = NewFlowQueryBuilderWithDefaults(&cfg)
+	assert.Error(t, query.Filters(filters.SingleQuery{filters.NewMatch("FlowDirection", "both")}))
+}
+
+func TestFlowQuery_UserRegexSubstring(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	cfg.RegexSubstringMatch = true
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.addFilter(filters.NewRegexMatch("SrcK8S_Namespace", `web`))
+	require.NoError(t, err)
+	err = query.addFilter(filters.NewRegexMatch("DstK8S_Name", `web-[a-z]*`))
+	require.NoError(t, err)
+	urlQuery := query.Build()
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace=~`+backtick(`.*(?:web).*`)+`}|json|DstK8S_Name=~`+backtick(`.*(?:web-[a-z]*).*`), urlQuery)
+}
+
+func TestFlowQuery_CaseInsensitive(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{
+		filters.NewCaseInsensitiveMatch("SrcK8S_Namespace", `My-NS`),
+		filters.NewCaseInsensitiveMatch("DstK8S_Name", `Frontend`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace=~`+backtick(`(?i)My-NS`)+`}|json|DstK8S_Name=~`+backtick(`(?i)Frontend`), query.Build())
+}
+
+func TestFlowQuery_UserRegexErrors(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	assert.Error(t, query.addFilter(filters.NewRegexMatch("SrcK8S_Name", "back`quote")))
+	assert.Error(t, query.addFilter(filters.NewRegexMatch("SrcK8S_Name", `quo"te`)))
+	assert.Error(t, query.addFilter(filters.NewRegexMatch("SrcAddr", `10\..*`)))
+	// invalid syntax
+	err = query.addFilter(filters.NewRegexMatch("SrcK8S_Name", `web-(`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid regular expression in SrcK8S_Name filter")
+	assert.Error(t, query.addFilter(filters.NewRegexMatch("DstPort", `[0-9`)))
+}
+
+func TestFlowQuery_HasField(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"foo"})
+	query := NewFlowQueryBuilder(&cfg, "1000", "", "1", "", "")
+	query.HasField("DnsId")
+	assert.Equal(t, "/loki/api/v1/query_range?query={app=\"netobserv-flowcollector\"}|~`\"DnsId\":`&start=1000&limit=1", query.Build())
+}
+
+func TestFlowQuery_ExcludeZeroBytes(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	query.ExcludeZeroBytes()
+	err = query.Filters(filters.SingleQuery{filters.NewMatch("SrcAddr", "10.0.0.1")})
+	require.NoError(t, err)
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|json|Bytes>0|SrcAddr=ip("10.0.0.1")`, query.Build())
+}
+
+func TestFlowQuery_Comparison(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{filters.NewComparisonMatch("TcpRetransmits", ">", "0")})
+	require.NoError(t, err)
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|json|TcpRetransmits>0`, query.Build())
+
+	// only numeric fields and values
+	query = NewFlowQueryBuilderWithDefaults(&cfg)
+	assert.Error(t, query.Filters(filters.SingleQuery{filters.NewComparisonMatch("SrcK8S_Name", ">", "0")}))
+	assert.Error(t, query.Filters(filters.SingleQuery{filters.NewComparisonMatch("TcpRetransmits", ">", "a")}))
+}
+
+func TestFlowQuery_ComparisonOperators(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{
+		filters.NewComparisonMatch("DstPort", ">=", "1024"),
+		filters.NewComparisonMatch("Bytes", ">", "1000000"),
+		filters.NewComparisonMatch("Packets", "<", "10"),
+		filters.NewComparisonMatch("Port", "<=", "1023"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|json|DstPort>=1024|Bytes>1000000|Packets<10|SrcPort<=1023+or+DstPort<=1023`, query.Build())
+
+	// validation
+	for _, invalid := range []filters.Match{
+		filters.NewComparisonMatch("DstPort", ">=", "70000"),
+		filters.NewComparisonMatch("SrcPort", "<", "1.5"),
+		filters.NewComparisonMatch("Bytes", ">", "-1"),
+		filters.NewComparisonMatch("Packets", "=>", "1"),
+	} {
+		query = NewFlowQueryBuilderWithDefaults(&cfg)
+		assert.Error(t, query.Filters(filters.SingleQuery{invalid}), invalid)
+	}
+}
+
+func TestTopologyQuery_Retransmits(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query, err := NewTopologyQuery(&cfg, "", "", "", "1m", "30s", "retransmits", "", "", "namespace", "")
+	require.NoError(t, err)
+	assert.Equal(t, "/loki/api/v1/query_range?query=topk(100,sum by(SrcK8S_Namespace,DstK8S_Namespace) (rate({app=\"netobserv-flowcollector\"}|~`Duplicate\":false`|json|unwrap TcpRetransmits|__error__=\"\"[1m])))&step=30s", query.Build())
+}
+
+func TestFlowQuery_Exists(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"DstK8S_ServiceName"})
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err = query.Filters(filters.SingleQuery{
+		// indexed label => stream selector
+		filters.NewExistsMatch("DstK8S_ServiceName"),
+		// JSON field => post-selector check
+		filters.NewExistsMatch("DnsName"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",DstK8S_ServiceName=~".+"}|json|DnsName!=""`, query.Build())
+}
+
+func TestFlowQuery_IndexStats(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilder(&cfg, "1000", "1600", "50", "", "")
+	err = query.Filters(filters.SingleQuery{
+		filters.NewMatch("SrcK8S_Namespace", `"app"`),
+		filters.NewMatch("DstPort", "53"),
+	})
+	require.NoError(t, err)
+	// only the stream selector, without limit
+	assert.Equal(t, `/loki/api/v1/index/stats?query={app="netobserv-flowcollector",SrcK8S_Namespace="app"}&start=1000&end=1600`, query.BuildIndexStats())
+}
+
+func TestFlowQuery_Series(t *testing.T) {
+	lokiURL, err := url.Parse("/")
+	require.NoError(t, err)
+	cfg := NewConfig(lokiURL, lokiURL, time.Second, "", "", false, false, "", false, "", "", "", false, []string{"SrcK8S_Namespace"})
+	query := NewFlowQueryBuilder(&cfg, "1000", "1600", "50", "", "")
+	require.NoError(t, query.Filters(filters.SingleQuery{filters.NewMatch("SrcK8S_Namespace", `"app"`)}))
+	series, ok := query.BuildSeries()
+	require.True(t, ok)
+	assert.Equal(t, `/loki/api/v1/series?match[]={app="netobserv-flowcollector",SrcK8S_Namespace="app"}&start=1000&end=1600`, series)
+
+	// line filters can't be applied
+	require.NoError(t, query.Filters(filters.SingleQuery{filters.NewMatch("DstPort", "53")}))
+	_, ok = query.BuildSeries()
+	assert.False(t, ok)
+}
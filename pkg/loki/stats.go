@@ -0,0 +1,57 @@
+package loki
+
+import (
+	"encoding/json"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+// lokiStats is the part of the Loki query statistics that is summarized
+type lokiStats struct {
+	Summary struct {
+		TotalBytesProcessed  int64   `json:"totalBytesProcessed"`
+		TotalLinesProcessed  int64   `json:"totalLinesProcessed"`
+		TotalEntriesReturned int64   `json:"totalEntriesReturned"`
+		ExecTime             float64 `json:"execTime"`
+		QueueTime            float64 `json:"queueTime"`
+		Subqueries           int64   `json:"subqueries"`
+	} `json:"summary"`
+}
+
+// summarizeStats aggregates the execution statistics of the queries. It returns nil when Loki didn't provide any,
+// e.g. for mocked or cached responses without stats.
+func summarizeStats(queriesStats []interface{}) *model.ExecutionStats {
+	var summary *model.ExecutionStats
+	for _, raw := range queriesStats {
+		if raw == nil {
+			continue
+		}
+		// stats are decoded as generic maps, re-encode them to read the summary
+		js, err := json.Marshal(raw)
+		if err != nil {
+			continue
+		}
+		var stats lokiStats
+		if err := json.Unmarshal(js, &stats); err != nil {
+			continue
+		}
+		if summary == nil {
+			summary = &model.ExecutionStats{}
+		}
+		s := stats.Summary
+		summary.BytesProcessed += s.TotalBytesProcessed
+		summary.LinesProcessed += s.TotalLinesProcessed
+		summary.EntriesReturned += s.TotalEntriesReturned
+		summary.ExecTime += s.ExecTime
+		summary.QueueTime += s.QueueTime
+		summary.Subqueries += s.Subqueries
+		if s.ExecTime > summary.MaxExecTime {
+			summary.MaxExecTime = s.ExecTime
+		}
+	}
+	if summary != nil && summary.ExecTime > 0 {
+		summary.BytesProcessedPerSecond = int64(float64(summary.BytesProcessed) / summary.ExecTime)
+		summary.LinesProcessedPerSecond = int64(float64(summary.LinesProcessed) / summary.ExecTime)
+	}
+	return summary
+}
@@ -0,0 +1,41 @@
+package loki
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func TestSummarizeStats(t *testing.T) {
+	js := `{"resultType":"streams","result":[],"stats":{"summary":{"totalBytesProcessed":1000,"totalLinesProcessed":10,"execTime":0.5,"queueTime":0.25,"subqueries":2}}}`
+	var first, second model.QueryResponseData
+	require.NoError(t, first.UnmarshalJSON([]byte(js)))
+	js = `{"resultType":"streams","result":[],"stats":{"summary":{"totalBytesProcessed":3000,"totalLinesProcessed":30,"execTime":1.5,"queueTime":0.5,"subqueries":4}}}`
+	require.NoError(t, second.UnmarshalJSON([]byte(js)))
+
+	merger := NewStreamMerger(100)
+	_, err := merger.Add(first)
+	require.NoError(t, err)
+	_, err = merger.Add(second)
+	require.NoError(t, err)
+	assert.Equal(t, &model.ExecutionStats{
+		BytesProcessed:          4000,
+		LinesProcessed:          40,
+		BytesProcessedPerSecond: 2000,
+		LinesProcessedPerSecond: 20,
+		ExecTime:                2,
+		MaxExecTime:             1.5,
+		QueueTime:               0.75,
+		Subqueries:              6,
+	}, merger.Get().Stats.Execution)
+}
+
+func TestSummarizeStats_Missing(t *testing.T) {
+	merger := NewMatrixMerger(100)
+	_, err := merger.Add(model.QueryResponseData{Result: model.Matrix{}})
+	require.NoError(t, err)
+	assert.Nil(t, merger.Get().Stats.Execution)
+}
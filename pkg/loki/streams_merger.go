@@ -1,38 +1,48 @@
 package loki
 
 import (
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
 )
 
 type StreamMerger struct {
 	Merger
 	index        map[string]indexedStream
+	entries      map[uint64]struct{}
 	merged       model.Streams
 	stats        []interface{}
+	groups       []model.GroupStats
 	numQueries   int
 	reqLimit     int
 	totalEntries int
 	duplicates   int
 	limitReached bool
+	// pageBoundary is the most recent of the oldest entries of the queries which reached the limit
+	pageBoundary time.Time
+	sortOrder    *SortOrder
 }
 
 func NewStreamMerger(reqLimit int) *StreamMerger {
 	return &StreamMerger{
 		reqLimit: reqLimit,
 		index:    map[string]indexedStream{},
+		entries:  map[uint64]struct{}{},
 		merged:   model.Streams{},
 		stats:    []interface{}{},
 	}
 }
 
 type indexedStream struct {
-	stream  model.Stream
-	entries map[string]interface{}
-	index   int
+	stream model.Stream
+	index  int
 }
 
 func uniqueStream(s *model.Stream) string {
@@ -51,8 +61,42 @@ func uniqueStream(s *model.Stream) string {
 	return sb.String()
 }
 
-func uniqueEntry(e *model.Entry) string {
-	return e.Timestamp.String() + e.Line
+// entryKeyFields identify a flow observation, together with the entry timestamp
+var entryKeyFields = []string{
+	fields.SrcAddr,
+	fields.DstAddr,
+	fields.SrcPort,
+	fields.DstPort,
+	fields.Proto,
+	fields.FlowDirection,
+	fields.Interface,
+	fields.TimeFlowStart,
+	fields.TimeFlowEnd,
+}
+
+// uniqueEntry hashes the entry timestamp and key fields, regardless of the stream: the same flow may come
+// with different labels from different queries, when some of them parse the JSON lines.
+// Lines that are not flow records are hashed entirely, with the stream key.
+func uniqueEntry(streamKey string, e *model.Entry) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatInt(e.Timestamp.UnixNano(), 10)))
+	var record map[string]json.RawMessage
+	hasKey := false
+	if err := json.Unmarshal([]byte(e.Line), &record); err == nil {
+		for _, f := range entryKeyFields {
+			v, ok := record[f]
+			hasKey = hasKey || ok
+			_, _ = h.Write([]byte{0})
+			_, _ = h.Write(v)
+		}
+	}
+	if !hasKey {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(streamKey))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(e.Line))
+	}
+	return h.Sum64()
 }
 
 func (m *StreamMerger) Add(from model.QueryResponseData) (model.ResultValue, error) {
@@ -64,32 +108,38 @@ func (m *StreamMerger) Add(from model.QueryResponseData) (model.ResultValue, err
 	m.numQueries++
 	m.stats = append(m.stats, from.Stats)
 	totalEntries := 0
+	duplicates := 0
+	var oldest time.Time
 	for _, stream := range streams {
 		lkey := uniqueStream(&stream)
 		idxStream, streamExists := m.index[lkey]
 		if !streamExists {
 			// Stream doesn't exist => create new index
 			idxStream = indexedStream{
-				stream:  stream,
-				entries: map[string]interface{}{},
-				index:   len(m.index),
+				stream: model.Stream{Labels: stream.Labels},
+				index:  len(m.merged),
 			}
 		}
 		// Merge content (entries)
 		for _, e := range stream.Entries {
 			totalEntries++
-			ekey := uniqueEntry(&e)
-			if _, entryExists := idxStream.entries[ekey]; !entryExists {
-				// Add entry to the existing stream, and mark it as existing in idxStream.entries
-				idxStream.entries[ekey] = nil
-				if streamExists {
-					idxStream.stream.Entries = append(idxStream.stream.Entries, e)
-				}
+			if oldest.IsZero() || e.Timestamp.Before(oldest) {
+				oldest = e.Timestamp
+			}
+			ekey := uniqueEntry(lkey, &e)
+			if _, entryExists := m.entries[ekey]; !entryExists {
+				// Add entry to the stream, and mark it as existing in any stream
+				m.entries[ekey] = struct{}{}
+				idxStream.stream.Entries = append(idxStream.stream.Entries, e)
 			} else {
 				// Else: entry found => ignore duplicate
-				m.duplicates++
+				duplicates++
 			}
 		}
+		if !streamExists && len(idxStream.stream.Entries) == 0 {
+			// only duplicates, or empty
+			continue
+		}
 		// Add or overwrite index
 		m.index[lkey] = idxStream
 		if !streamExists {
@@ -99,23 +149,49 @@ func (m *StreamMerger) Add(from model.QueryResponseData) (model.ResultValue, err
 			m.merged[idxStream.index] = idxStream.stream
 		}
 	}
-	if totalEntries >= m.reqLimit {
-		m.limitReached = true
+	limitReached := totalEntries >= m.reqLimit
+	m.limitReached = m.limitReached || limitReached
+	if limitReached && m.reqLimit > 0 && oldest.After(m.pageBoundary) {
+		m.pageBoundary = oldest
 	}
 	m.totalEntries += totalEntries
+	m.duplicates += duplicates
+	m.groups = append(m.groups, model.GroupStats{
+		Returned:     totalEntries,
+		Included:     totalEntries - duplicates,
+		Duplicates:   duplicates,
+		LimitReached: limitReached,
+	})
 	return m.merged, nil
 }
 
+// PageBoundary returns, when the limit was reached, the timestamp up to which (inclusive) the merged entries
+// form a complete page: queries which reached the limit may have older entries not yet returned
+func (m *StreamMerger) PageBoundary() (time.Time, bool) {
+	return m.pageBoundary, !m.pageBoundary.IsZero()
+}
+
 func (m *StreamMerger) Get() *model.AggregatedQueryResponse {
+	var groups []model.GroupStats
+	if m.numQueries > 1 {
+		groups = m.groups
+	}
+	result := m.merged
+	if m.sortOrder != nil {
+		result = sortEntries(m.merged, m.sortOrder)
+	}
 	return &model.AggregatedQueryResponse{
-		ResultType: model.ResultTypeStream,
-		Result:     m.merged,
+		ResultType:     model.ResultTypeStream,
+		Result:         result,
+		IsLimitReached: m.limitReached && m.reqLimit > 0,
 		Stats: model.AggregatedStats{
 			NumQueries:   m.numQueries,
 			LimitReached: m.limitReached,
 			TotalEntries: m.totalEntries,
 			Duplicates:   m.duplicates,
 			QueriesStats: m.stats,
+			Execution:    summarizeStats(m.stats),
+			Groups:       groups,
 		},
 	}
 }
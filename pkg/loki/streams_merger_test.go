@@ -1,6 +1,7 @@
 package loki
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -152,6 +153,7 @@ func TestStreamsLimitReached(t *testing.T) {
 	}}))
 	require.NoError(t, err)
 	assert.False(t, merger.limitReached)
+	assert.False(t, merger.Get().IsLimitReached)
 
 	// 2 entries => limit reached
 	_, err = merger.Add(qrData(model.Streams{{
@@ -172,6 +174,7 @@ func TestStreamsLimitReached(t *testing.T) {
 	}))
 	require.NoError(t, err)
 	assert.True(t, merger.limitReached)
+	assert.True(t, merger.Get().IsLimitReached)
 
 	// Another single entry => limit still reached
 	_, err = merger.Add(qrData(model.Streams{{
@@ -226,3 +229,131 @@ func TestStreamsMerge_SameStream(t *testing.T) {
 	assert.Equal(t, 0, result.Stats.Duplicates)
 	assert.Equal(t, 2, result.Stats.NumQueries)
 }
+
+func TestStreamsMerge_GroupStats(t *testing.T) {
+	now := time.Now()
+	entries := func(lines ...string) []model.Entry {
+		var e []model.Entry
+		for _, l := range lines {
+			e = append(e, model.Entry{Timestamp: now, Line: l})
+		}
+		return e
+	}
+	merger := NewStreamMerger(3)
+	labels := map[string]string{"foo": "bar"}
+	// first group hits the limit, second one is small and partly duplicated
+	_, err := merger.Add(qrData(model.Streams{{Labels: labels, Entries: entries("a", "b", "c")}}))
+	require.NoError(t, err)
+	_, err = merger.Add(qrData(model.Streams{{Labels: labels, Entries: entries("c", "d")}}))
+	require.NoError(t, err)
+	result := merger.Get()
+
+	assert.Len(t, result.Result.(model.Streams)[0].Entries, 4)
+	assert.Equal(t, 1, result.Stats.Duplicates)
+	assert.True(t, result.Stats.LimitReached)
+	assert.Equal(t, []model.GroupStats{
+		{Returned: 3, Included: 3, Duplicates: 0, LimitReached: true},
+		{Returned: 2, Included: 1, Duplicates: 1, LimitReached: false},
+	}, result.Stats.Groups)
+
+	// single query: no group details
+	merger = NewStreamMerger(3)
+	_, err = merger.Add(qrData(model.Streams{{Labels: labels, Entries: entries("a")}}))
+	require.NoError(t, err)
+	assert.Nil(t, merger.Get().Stats.Groups)
+}
+
+func TestStreamsMerge_PageBoundary(t *testing.T) {
+	now := time.Now()
+	entries := func(ages ...int) []model.Entry {
+		var e []model.Entry
+		for _, age := range ages {
+			e = append(e, model.Entry{Timestamp: now.Add(-time.Duration(age) * time.Second), Line: fmt.Sprintf("{age: %d}", age)})
+		}
+		return e
+	}
+	merger := NewStreamMerger(3)
+	// limit not reached
+	_, err := merger.Add(qrData(model.Streams{{Labels: map[string]string{"foo": "a"}, Entries: entries(1, 50)}}))
+	require.NoError(t, err)
+	_, ok := merger.PageBoundary()
+	assert.False(t, ok)
+
+	// limit reached
+	_, err = merger.Add(qrData(model.Streams{{Labels: map[string]string{"foo": "b"}, Entries: entries(2, 10, 20)}}))
+	require.NoError(t, err)
+	_, err = merger.Add(qrData(model.Streams{{Labels: map[string]string{"foo": "c"}, Entries: entries(3, 4, 5)}}))
+	require.NoError(t, err)
+	boundary, ok := merger.PageBoundary()
+	require.True(t, ok)
+	assert.Equal(t, now.Add(-5*time.Second), boundary)
+}
+
+func TestStreamsMerge_SortOrder(t *testing.T) {
+	now := time.Now()
+	merger := NewStreamMerger(0)
+	_, err := merger.Add(qrData(model.Streams{{Labels: map[string]string{"foo": "a"}, Entries: []model.Entry{
+		{Timestamp: now, Line: `{"Bytes":10}`},
+		{Timestamp: now.Add(-3 * time.Second), Line: `{"Bytes":300}`},
+	}}}))
+	require.NoError(t, err)
+	_, err = merger.Add(qrData(model.Streams{{Labels: map[string]string{"foo": "b"}, Entries: []model.Entry{
+		{Timestamp: now.Add(-1 * time.Second), Line: `{"Bytes":20}`},
+		{Timestamp: now.Add(-2 * time.Second), Line: `{"Packets":1}`},
+	}}}))
+	require.NoError(t, err)
+
+	lines := func() []string {
+		var l []string
+		for _, s := range merger.Get().Result.(model.Streams) {
+			for _, e := range s.Entries {
+				l = append(l, s.Labels["foo"]+e.Line)
+			}
+		}
+		return l
+	}
+	// default: grouped by stream
+	assert.Equal(t, []string{`a{"Bytes":10}`, `a{"Bytes":300}`, `b{"Bytes":20}`, `b{"Packets":1}`}, lines())
+
+	merger.SetSortOrder(&SortOrder{Ascending: true})
+	assert.Equal(t, []string{`a{"Bytes":300}`, `b{"Packets":1}`, `b{"Bytes":20}`, `a{"Bytes":10}`}, lines())
+
+	// entries without the field come last
+	merger.SetSortOrder(&SortOrder{Field: "Bytes"})
+	assert.Equal(t, []string{`a{"Bytes":300}`, `b{"Bytes":20}`, `a{"Bytes":10}`, `b{"Packets":1}`}, lines())
+
+	merger.SetSortOrder(&SortOrder{Field: "Bytes", Ascending: true})
+	assert.Equal(t, []string{`a{"Bytes":10}`, `b{"Bytes":20}`, `a{"Bytes":300}`, `b{"Packets":1}`}, lines())
+}
+
+func TestStreamsMerge_DedupAcrossLabels(t *testing.T) {
+	now := time.Now()
+	flow := `{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","SrcPort":8080,"DstPort":443,"Proto":6,"Bytes":100}`
+	merger := NewStreamMerger(0)
+	_, err := merger.Add(qrData(model.Streams{{
+		Labels:  map[string]string{"SrcK8S_Namespace": "a"},
+		Entries: []model.Entry{{Timestamp: now, Line: flow}},
+	}}))
+	require.NoError(t, err)
+	// same flow, with labels extracted by the JSON parser, and another flow
+	_, err = merger.Add(qrData(model.Streams{{
+		Labels: map[string]string{"SrcK8S_Namespace": "a", "Bytes": "100"},
+		Entries: []model.Entry{
+			{Timestamp: now, Line: flow},
+			{Timestamp: now, Line: `{"SrcAddr":"10.0.0.3","DstAddr":"10.0.0.2","SrcPort":8080,"DstPort":443,"Proto":6,"Bytes":100}`},
+		},
+	}, {
+		// only duplicates: not returned
+		Labels:  map[string]string{"SrcK8S_Namespace": "a", "Bytes": "200"},
+		Entries: []model.Entry{{Timestamp: now, Line: flow}},
+	}}))
+	require.NoError(t, err)
+
+	qr := merger.Get()
+	streams := qr.Result.(model.Streams)
+	require.Len(t, streams, 2)
+	assert.Len(t, streams[0].Entries, 1)
+	assert.Len(t, streams[1].Entries, 1)
+	assert.Contains(t, streams[1].Entries[0].Line, "10.0.0.3")
+	assert.Equal(t, 2, qr.Stats.Duplicates)
+}
@@ -0,0 +1,83 @@
+package loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+// SortOrder defines the order of the merged entries
+type SortOrder struct {
+	// Field is a numeric field of the flow records, possibly computed such as BitsPerSecond, or empty to sort by
+	// timestamp
+	Field     string
+	Ascending bool
+}
+
+type sortableEntry struct {
+	labels map[string]string
+	entry  model.Entry
+	value  float64
+	found  bool
+}
+
+// SetSortOrder makes Get return the merged entries in the given order, across all streams.
+// Without it, entries are returned in the order of Loki responses, grouped by stream.
+func (m *StreamMerger) SetSortOrder(order *SortOrder) {
+	m.sortOrder = order
+}
+
+// sortEntries returns each entry in its own stream, to preserve the order. Entries missing the sort field are kept last.
+func sortEntries(streams model.Streams, order *SortOrder) model.Streams {
+	var entries []sortableEntry
+	for _, stream := range streams {
+		for _, e := range stream.Entries {
+			se := sortableEntry{labels: stream.Labels, entry: e}
+			if order.Field == "" {
+				se.value, se.found = float64(e.Timestamp.UnixNano()), true
+			} else {
+				se.value, se.found = numericField(e.Line, order.Field)
+			}
+			entries = append(entries, se)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].found != entries[j].found {
+			return entries[i].found
+		}
+		if order.Ascending {
+			return entries[i].value < entries[j].value
+		}
+		return entries[i].value > entries[j].value
+	})
+	sorted := make(model.Streams, 0, len(entries))
+	for _, se := range entries {
+		sorted = append(sorted, model.Stream{Labels: se.labels, Entries: []model.Entry{se.entry}})
+	}
+	return sorted
+}
+
+func numericField(line, field string) (float64, bool) {
+	var record map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(line)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		return 0, false
+	}
+	get := func(f string) (float64, bool) {
+		n, ok := record[f].(json.Number)
+		if !ok {
+			return 0, false
+		}
+		v, err := n.Float64()
+		return v, err == nil
+	}
+	if fields.IsComputedNumeric(field) {
+		// not in the Loki lines, added once post-processed
+		return fields.ComputeNumeric(field, get)
+	}
+	return get(field)
+}
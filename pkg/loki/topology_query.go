@@ -39,6 +39,10 @@ func NewTopologyQuery(cfg *Config, start, end, limit, rateInterval, step, metric
 	case "packets":
 		f = "rate"
 		t = "Packets"
+	case "retransmits":
+		// group by the usual scopes to identify lossy paths
+		f = "rate"
+		t = "TcpRetransmits"
 	default:
 		f = "rate"
 		t = "Bytes"
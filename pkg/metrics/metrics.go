@@ -1,7 +1,9 @@
 package metrics
 
 import (
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -31,6 +33,45 @@ var (
 		Help:    "Number of parallel calls to Loki",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"type", "code"})
+	lokiRequestsDurationHisto = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    prefix + "_loki_request_duration_seconds",
+		Help:    "Duration of Loki requests per endpoint, including retries",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"endpoint", "code"})
+	lokiResponseSizeHisto = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    prefix + "_loki_response_size_bytes",
+		Help:    "Size of successful Loki responses per endpoint",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 9),
+	}, []string{"endpoint"})
+	lokiFanoutHisto = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    prefix + "_loki_query_fanout",
+		Help:    "Number of Loki queries run for a single console request, e.g. one per filter group and time shard",
+		Buckets: []float64{1, 2, 4, 8, 16, 32, 64},
+	}, []string{"type"})
+	lokiRetriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: prefix + "_loki_retries_total",
+		Help: "Number of Loki calls retried per endpoint and status that triggered the retry",
+	}, []string{"endpoint", "code"})
+	lokiErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: prefix + "_loki_errors_total",
+		Help: "Number of failed Loki requests per endpoint and error class, e.g. UPSTREAM_TIMEOUT or RATE_LIMITED",
+	}, []string{"endpoint", "class"})
+	cacheLookupsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: prefix + "_cache_lookups_total",
+		Help: "Number of lookups in the Loki responses caches, per cache and result (hit or miss)",
+	}, []string{"cache", "result"})
+	cacheEvictionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: prefix + "_cache_evictions_total",
+		Help: "Number of entries removed from the Loki responses caches, per cache and reason (expired or capacity)",
+	}, []string{"cache", "reason"})
+	cacheEntriesGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: prefix + "_cache_entries",
+		Help: "Number of entries in the Loki responses caches",
+	}, []string{"cache"})
+	cacheBytesGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: prefix + "_cache_bytes",
+		Help: "Size of the responses held in the Loki responses caches",
+	}, []string{"cache"})
 )
 
 func ObserveHTTPCall(handler string, code int, startTime time.Time) {
@@ -44,4 +85,67 @@ func ObserveLokiUnitCall(code int, startTime time.Time) {
 func ObserveLokiParallelCall(queryType string, code, nbQueries int, startTime time.Time) {
 	lokiParallelCallsDurationHisto.WithLabelValues(queryType, strconv.Itoa(code)).Observe(time.Since(startTime).Seconds())
 	lokiParallelCallsNbQueriesHisto.WithLabelValues(queryType, strconv.Itoa(code)).Observe(float64(nbQueries))
+	lokiFanoutHisto.WithLabelValues(queryType).Observe(float64(nbQueries))
+}
+
+// ObserveLokiRequest records the duration and, when successful, the response size of a Loki request
+func ObserveLokiRequest(endpoint string, code, size int, startTime time.Time) {
+	lokiRequestsDurationHisto.WithLabelValues(endpoint, strconv.Itoa(code)).Observe(time.Since(startTime).Seconds())
+	if code == 200 {
+		lokiResponseSizeHisto.WithLabelValues(endpoint).Observe(float64(size))
+	}
+}
+
+func CountLokiRetry(endpoint string, code int) {
+	lokiRetriesCounter.WithLabelValues(endpoint, strconv.Itoa(code)).Inc()
+}
+
+func CountLokiError(endpoint, class string) {
+	lokiErrorsCounter.WithLabelValues(endpoint, class).Inc()
+}
+
+func ObserveCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookupsCounter.WithLabelValues(cache, result).Inc()
+}
+
+func CountCacheEviction(cache, reason string) {
+	cacheEvictionsCounter.WithLabelValues(cache, reason).Inc()
+}
+
+func SetCacheSize(cache string, entries, bytes int) {
+	cacheEntriesGauge.WithLabelValues(cache).Set(float64(entries))
+	cacheBytesGauge.WithLabelValues(cache).Set(float64(bytes))
+}
+
+// LokiEndpoint returns the Loki API endpoint of a request URL, with a bounded cardinality to be used as label:
+// e.g. query_range, label_values or index_stats
+func LokiEndpoint(rawURL string) string {
+	path := rawURL
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if u, err := url.Parse(path); err == nil {
+		path = u.Path
+	}
+	path = strings.Trim(path, "/")
+	// the API may be behind a gateway path prefix
+	if i := strings.Index(path, "loki/api/v1/"); i >= 0 {
+		api := path[i+len("loki/api/v1/"):]
+		if strings.HasPrefix(api, "label/") && strings.HasSuffix(api, "/values") {
+			return "label_values"
+		}
+		return strings.ReplaceAll(api, "/", "_")
+	}
+	// status endpoints: ready, metrics, config, buildinfo...
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		path = path[i+1:]
+	}
+	if path == "" {
+		return "other"
+	}
+	return path
 }
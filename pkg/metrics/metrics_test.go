@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLokiEndpoint(t *testing.T) {
+	for url, endpoint := range map[string]string{
+		`http://loki:3100/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|json&limit=100`: "query_range",
+		"http://loki:3100/loki/api/v1/query?query=topk(50,sum(rate({app=\"netobserv\"}[1m])))":          "query",
+		"http://loki:3100/loki/api/v1/label/SrcK8S_Namespace/values?start=1":                            "label_values",
+		"http://loki:3100/loki/api/v1/index/stats?query={app=\"netobserv\"}":                            "index_stats",
+		"https://gateway/api/logs/v1/network/loki/api/v1/query_range?query=x":                           "query_range",
+		"https://gateway/api/logs/v1/network/loki/api/v1/label/DstK8S_Namespace/values":                 "label_values",
+		"http://loki:3100/ready":    "ready",
+		"http://loki:3100/metrics":  "metrics",
+		"http://loki:3100/":         "other",
+		"/loki/api/v1/status/build": "status_build",
+	} {
+		assert.Equal(t, endpoint, LokiEndpoint(url), url)
+	}
+}
@@ -0,0 +1,18 @@
+package fields
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseFlowDirection returns the flow direction given either as its value (0 or 1) or as a name:
+// ingress (0) or egress (1), case insensitive
+func ParseFlowDirection(value string) (int, error) {
+	switch strings.ToLower(value) {
+	case "0", "ingress":
+		return 0, nil
+	case "1", "egress":
+		return 1, nil
+	}
+	return 0, fmt.Errorf("invalid flow direction: %s (expected ingress or egress)", value)
+}
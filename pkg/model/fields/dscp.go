@@ -0,0 +1,61 @@
+package fields
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dscpClasses are the standard DSCP class names (RFC 2474, 2597, 3246)
+var dscpClasses = map[string]int{
+	"CS0":  0,
+	"CS1":  8,
+	"AF11": 10,
+	"AF12": 12,
+	"AF13": 14,
+	"CS2":  16,
+	"AF21": 18,
+	"AF22": 20,
+	"AF23": 22,
+	"CS3":  24,
+	"AF31": 26,
+	"AF32": 28,
+	"AF33": 30,
+	"CS4":  32,
+	"AF41": 34,
+	"AF42": 36,
+	"AF43": 38,
+	"CS5":  40,
+	"EF":   46,
+	"CS6":  48,
+	"CS7":  56,
+}
+
+var dscpNames = func() map[int]string {
+	names := make(map[int]string, len(dscpClasses))
+	for name, value := range dscpClasses {
+		names[value] = name
+	}
+	return names
+}()
+
+// ParseDSCP returns the DSCP value given either as a number or as a class name (e.g. EF, AF41, CS0)
+func ParseDSCP(value string) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 0 || n > 63 {
+			return 0, fmt.Errorf("invalid DSCP: %s", value)
+		}
+		return n, nil
+	}
+	n, ok := dscpClasses[strings.ToUpper(value)]
+	if !ok {
+		return 0, fmt.Errorf("unknown DSCP class: %s", value)
+	}
+	return n, nil
+}
+
+// GetDSCPName returns the class name of a DSCP value, if it is a standard one
+func GetDSCPName(value int) (string, bool) {
+	name, ok := dscpNames[value]
+	return name, ok
+}
@@ -24,6 +24,12 @@ const (
 	Port          = "Port"
 	SrcPort       = Src + Port
 	DstPort       = Dst + Port
+	PortName      = "PortName"
+	SrcPortName   = Src + PortName
+	DstPortName   = Dst + PortName
+	Mac           = "Mac"
+	SrcMac        = Src + Mac
+	DstMac        = Dst + Mac
 	HostIP        = "K8S_HostIP"
 	SrcHostIP     = Src + HostIP
 	DstHostIP     = Dst + HostIP
@@ -34,6 +40,58 @@ const (
 	Proto         = "Proto"
 	Bytes         = "Bytes"
 	FlowDirection = "FlowDirection"
+	Interface     = "Interface"
+	TimeFlowStart = "TimeFlowStartMs"
+	TimeFlowEnd   = "TimeFlowEndMs"
+	// ClusterName is provided when the flows pipeline runs in multi-cluster mode
+	ClusterName = "K8S_ClusterName"
+	// DNS fields are provided when DNS tracking is enabled in the agent
+	DNSID         = "DnsId"
+	DNSFlags      = "DnsFlags"
+	DNSFlagsRCode = "DnsFlagsResponseCode"
+	DNSLatency    = "DnsLatencyMs"
+	DNSErrNo      = "DnsErrno"
+	// packet drop fields are provided when packet drops tracking is enabled in the agent
+	PktDropBytes       = "PktDropBytes"
+	PktDropPackets     = "PktDropPackets"
+	PktDropLatestState = "PktDropLatestState"
+	PktDropLatestCause = "PktDropLatestDropCause"
+	PktDropLatestFlags = "PktDropLatestFlags"
+	// RTT is provided when flow RTT tracking is enabled in the agent
+	TimeFlowRtt = "TimeFlowRttNs"
+	// TCPFlags is the bitmask of TCP flags observed in the flow
+	TCPFlags = "Flags"
+	// ICMP type and code, for ICMP flows
+	IcmpType = "IcmpType"
+	IcmpCode = "IcmpCode"
+	// DSCP is the Differentiated Services Code Point of the flow packets. DSCPName is its class name,
+	// added by the backend, see handler.annotateDSCPNames
+	DSCP     = "Dscp"
+	DSCPName = "DscpName"
+	// TCP quality counters, when provided by the agent
+	TCPRetransmits = "TcpRetransmits"
+	TCPOutOfOrder  = "TcpOutOfOrder"
+	// TCPRetransmitRate is computed by the backend, see ComputeNumeric
+	TCPRetransmitRate = "TcpRetransmitRate"
+	// FlowID is computed by the backend, see handler.addFlowID
+	FlowID = "_FlowId"
+	// Truncated is set by the backend when string fields were truncated, see handler.truncateStrings
+	Truncated = "_Truncated"
+	// BitsPerSecond is computed by the backend, see ComputeNumeric
+	BitsPerSecond = "BitsPerSecond"
+	// AppProtocol is inferred by the backend from well-known ports, flagged with AppProtocolInferred,
+	// see handler.inferAppProtocol
+	AppProtocol         = "AppProtocol"
+	AppProtocolInferred = "_AppProtocolInferred"
+	// AnomalyScore is computed by the backend when sorting by anomaly, see handler.sortByAnomaly
+	AnomalyScore = "_AnomalyScore"
+	// Geo fields are provided by the GeoIP enrichment
+	GeoLatitude     = "Geo_Latitude"
+	SrcGeoLatitude  = Src + GeoLatitude
+	DstGeoLatitude  = Dst + GeoLatitude
+	GeoLongitude    = "Geo_Longitude"
+	SrcGeoLongitude = Src + GeoLongitude
+	DstGeoLongitude = Dst + GeoLongitude
 )
 
 func IsNumeric(v string) bool {
@@ -44,7 +102,13 @@ func IsNumeric(v string) bool {
 		DstPort,
 		Packets,
 		Proto,
-		Bytes:
+		Bytes,
+		TCPRetransmits,
+		TCPOutOfOrder,
+		TCPFlags,
+		IcmpType,
+		IcmpCode,
+		DSCP:
 		return true
 	default:
 		return false
@@ -0,0 +1,55 @@
+package fields
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// icmpTypes are the ICMP (v4) message types names
+var icmpTypes = map[string]int{
+	"echo-reply":              0,
+	"destination-unreachable": 3,
+	"redirect":                5,
+	"echo-request":            8,
+	"router-advertisement":    9,
+	"router-solicitation":     10,
+	"time-exceeded":           11,
+	"parameter-problem":       12,
+	"timestamp-request":       13,
+	"timestamp-reply":         14,
+}
+
+// icmpCodes are the names of the most common ICMP codes, which are destination unreachable codes
+var icmpCodes = map[string]int{
+	"net-unreachable":      0,
+	"host-unreachable":     1,
+	"protocol-unreachable": 2,
+	"port-unreachable":     3,
+	"fragmentation-needed": 4,
+}
+
+// ParseICMPType returns the ICMP type given either as a number or as a name (e.g. echo-request)
+func ParseICMPType(value string) (int, error) {
+	return parseNamedByte(value, icmpTypes, "ICMP type")
+}
+
+// ParseICMPCode returns the ICMP code given either as a number or as a destination unreachable code name
+// (e.g. port-unreachable)
+func ParseICMPCode(value string) (int, error) {
+	return parseNamedByte(value, icmpCodes, "ICMP code")
+}
+
+func parseNamedByte(value string, names map[string]int, what string) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 0 || n > 255 {
+			return 0, fmt.Errorf("invalid %s: %s", what, value)
+		}
+		return n, nil
+	}
+	n, ok := names[strings.ToLower(value)]
+	if !ok {
+		return 0, fmt.Errorf("unknown %s: %s", what, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,39 @@
+package fields
+
+import "math"
+
+// minRateDurationMs is the minimal flow duration for which a rate is meaningful: shorter flows
+// (e.g. single packet) would give absurd values, so their rate is left undefined
+const minRateDurationMs = 1
+
+// IsComputedNumeric tells whether a field is a number computed by the backend from other fields, which can be
+// sorted on but not filtered
+func IsComputedNumeric(f string) bool {
+	return f == BitsPerSecond || f == TCPRetransmitRate
+}
+
+// ComputeNumeric returns the value of a computed numeric field, given the numeric fields of the flow
+func ComputeNumeric(f string, get func(string) (float64, bool)) (float64, bool) {
+	switch f {
+	case BitsPerSecond:
+		bytes, okBytes := get(Bytes)
+		start, okStart := get(TimeFlowStart)
+		end, okEnd := get(TimeFlowEnd)
+		if !okBytes || !okStart || !okEnd {
+			return 0, false
+		}
+		durationMs := end - start
+		if durationMs < minRateDurationMs {
+			return 0, false
+		}
+		return math.Round(bytes * 8 * 1000 / durationMs), true
+	case TCPRetransmitRate:
+		retransmits, okRetransmits := get(TCPRetransmits)
+		packets, okPackets := get(Packets)
+		if !okRetransmits || !okPackets || packets <= 0 {
+			return 0, false
+		}
+		return retransmits / packets, true
+	}
+	return 0, false
+}
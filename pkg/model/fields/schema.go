@@ -0,0 +1,91 @@
+package fields
+
+// Value types of the flow fields
+const (
+	TypeString = "string"
+	TypeNumber = "number"
+	TypeIP     = "ip"
+	TypeMAC    = "mac"
+	TypeBool   = "boolean"
+)
+
+// FieldInfo describes a flow record field
+type FieldInfo struct {
+	Name        string
+	Type        string
+	Description string
+	// Computed is set for fields added by the backend after querying Loki, which can't be filtered on
+	Computed bool
+}
+
+func endpointFields(name, typ, description string) []FieldInfo {
+	return []FieldInfo{
+		{Name: Src + name, Type: typ, Description: "Source " + description},
+		{Name: Dst + name, Type: typ, Description: "Destination " + description},
+	}
+}
+
+// Schema is the list of the known flow record fields
+var Schema = buildSchema()
+
+// Lookup returns the schema of a field, if known
+func Lookup(name string) (FieldInfo, bool) {
+	for _, f := range Schema {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FieldInfo{}, false
+}
+
+func buildSchema() []FieldInfo {
+	var schema []FieldInfo
+	schema = append(schema, endpointFields(Addr, TypeIP, "IP address")...)
+	schema = append(schema, endpointFields(Port, TypeNumber, "port")...)
+	schema = append(schema, endpointFields(Mac, TypeMAC, "MAC address")...)
+	schema = append(schema, endpointFields(Namespace, TypeString, "namespace")...)
+	schema = append(schema, endpointFields(Name, TypeString, "Kubernetes object name, e.g. pod name")...)
+	schema = append(schema, endpointFields(Type, TypeString, "Kubernetes object kind, e.g. Pod or Service")...)
+	schema = append(schema, endpointFields(OwnerName, TypeString, "owner name, e.g. deployment name")...)
+	schema = append(schema, endpointFields(OwnerType, TypeString, "owner kind, e.g. Deployment")...)
+	schema = append(schema, endpointFields(HostIP, TypeIP, "node IP")...)
+	schema = append(schema, endpointFields(HostName, TypeString, "node name")...)
+	schema = append(schema, endpointFields(GeoLatitude, TypeNumber, "latitude, from GeoIP enrichment")...)
+	schema = append(schema, endpointFields(GeoLongitude, TypeNumber, "longitude, from GeoIP enrichment")...)
+	schema = append(schema, []FieldInfo{
+		{Name: Proto, Type: TypeNumber, Description: "L4 protocol number, e.g. 6 for TCP"},
+		{Name: Bytes, Type: TypeNumber, Description: "Number of bytes"},
+		{Name: Packets, Type: TypeNumber, Description: "Number of packets"},
+		{Name: FlowDirection, Type: TypeNumber, Description: "Flow direction from the node observation point: ingress (0) or egress (1)"},
+		{Name: Interface, Type: TypeString, Description: "Network interface"},
+		{Name: TimeFlowStart, Type: TypeNumber, Description: "Start timestamp of the flow, in milliseconds"},
+		{Name: TimeFlowEnd, Type: TypeNumber, Description: "End timestamp of the flow, in milliseconds"},
+		{Name: TCPFlags, Type: TypeNumber, Description: "Bitmask of the TCP flags, also accepting names such as SYN or SYN-ACK"},
+		{Name: IcmpType, Type: TypeNumber, Description: "ICMP type, also accepting names such as echo-request"},
+		{Name: IcmpCode, Type: TypeNumber, Description: "ICMP code"},
+		{Name: DSCP, Type: TypeNumber, Description: "Differentiated Services Code Point, also accepting class names such as EF"},
+		{Name: DNSID, Type: TypeNumber, Description: "DNS request identifier"},
+		{Name: DNSFlags, Type: TypeNumber, Description: "DNS flags"},
+		{Name: DNSFlagsRCode, Type: TypeString, Description: "DNS response code"},
+		{Name: DNSLatency, Type: TypeNumber, Description: "DNS latency, in milliseconds"},
+		{Name: DNSErrNo, Type: TypeNumber, Description: "DNS tracking error number"},
+		{Name: PktDropBytes, Type: TypeNumber, Description: "Number of bytes dropped by the kernel"},
+		{Name: PktDropPackets, Type: TypeNumber, Description: "Number of packets dropped by the kernel"},
+		{Name: PktDropLatestState, Type: TypeString, Description: "TCP state of the latest drop"},
+		{Name: PktDropLatestCause, Type: TypeString, Description: "Cause of the latest drop"},
+		{Name: PktDropLatestFlags, Type: TypeNumber, Description: "TCP flags of the latest drop"},
+		{Name: TimeFlowRtt, Type: TypeNumber, Description: "TCP round trip time, in nanoseconds"},
+		{Name: TCPRetransmits, Type: TypeNumber, Description: "Number of retransmitted TCP packets"},
+		{Name: TCPOutOfOrder, Type: TypeNumber, Description: "Number of out of order TCP packets"},
+		// computed by the backend
+		{Name: FlowID, Type: TypeString, Description: "Deterministic flow identifier", Computed: true},
+		{Name: BitsPerSecond, Type: TypeNumber, Description: "Flow throughput", Computed: true},
+		{Name: TCPRetransmitRate, Type: TypeNumber, Description: "Ratio of retransmitted packets", Computed: true},
+		{Name: DSCPName, Type: TypeString, Description: "DSCP class name", Computed: true},
+		{Name: AppProtocol, Type: TypeString, Description: "Application protocol inferred from well-known ports", Computed: true},
+		{Name: AppProtocolInferred, Type: TypeBool, Description: "Set when the application protocol was inferred", Computed: true},
+		{Name: Truncated, Type: TypeBool, Description: "Set when string fields were truncated", Computed: true},
+		{Name: AnomalyScore, Type: TypeNumber, Description: "Anomaly score when sorting by anomaly", Computed: true},
+	}...)
+	return schema
+}
@@ -0,0 +1,39 @@
+package fields
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tcpFlagBits are the standard TCP header flags bit values
+var tcpFlagBits = map[string]int{
+	"FIN": 0x01,
+	"SYN": 0x02,
+	"RST": 0x04,
+	"PSH": 0x08,
+	"ACK": 0x10,
+	"URG": 0x20,
+	"ECE": 0x40,
+	"CWR": 0x80,
+}
+
+// ParseTCPFlags returns the bitmask value of TCP flags given either as a number, or as symbolic names
+// combined with '-' (e.g. SYN, RST, SYN-ACK), case insensitive
+func ParseTCPFlags(value string) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("invalid TCP flags: %s", value)
+		}
+		return n, nil
+	}
+	mask := 0
+	for _, name := range strings.Split(value, "-") {
+		bit, ok := tcpFlagBits[strings.ToUpper(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown TCP flag: %s", name)
+		}
+		mask |= bit
+	}
+	return mask, nil
+}
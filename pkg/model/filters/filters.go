@@ -1,10 +1,13 @@
 package filters
 
 import (
+	"fmt"
 	"net/url"
 	"strings"
 )
 
+const existsWildcard = "*"
+
 // MultiQueries is an union group of singleQueries (OR'ed)
 type MultiQueries = []SingleQuery
 
@@ -15,10 +18,29 @@ type Match struct {
 	Key    string
 	Values string
 	Not    bool
+	// Regex is set when Values is a user-provided regular expression (key=~regex)
+	Regex bool
+	// CaseInsensitive is set when the regular expression ignores case (key=i~regex)
+	CaseInsensitive bool
+	// Comparison is the numeric comparison operator (e.g. ">" or "<="), empty for (in)equality matches
+	Comparison string
+	// Exists is set to match any non-empty value (key=*)
+	Exists bool
 }
 
-func NewMatch(key, values string) Match    { return Match{Key: key, Values: values} }
-func NewNotMatch(key, values string) Match { return Match{Key: key, Values: values, Not: true} }
+func NewMatch(key, values string) Match      { return Match{Key: key, Values: values} }
+func NewNotMatch(key, values string) Match   { return Match{Key: key, Values: values, Not: true} }
+func NewRegexMatch(key, values string) Match { return Match{Key: key, Values: values, Regex: true} }
+func NewExistsMatch(key string) Match        { return Match{Key: key, Exists: true} }
+func NewNotRegexMatch(key, values string) Match {
+	return Match{Key: key, Values: values, Regex: true, Not: true}
+}
+func NewCaseInsensitiveMatch(key, values string) Match {
+	return Match{Key: key, Values: values, Regex: true, CaseInsensitive: true}
+}
+func NewComparisonMatch(key, comparison, value string) Match {
+	return Match{Key: key, Values: value, Comparison: comparison}
+}
 
 // Example of raw filters (url-encoded):
 // foo=a,b&bar=c|baz=d
@@ -28,27 +50,121 @@ func NewNotMatch(key, values string) Match { return Match{Key: key, Values: valu
 // | | '--- Per-label OR:  "foo" must have value "a" OR "b"
 // | '----- In-group AND:  "foo" must be "a" or "b" AND "bar" must be "c"
 // '------- All groups OR: "foo" must be "a" or "b" AND "bar" must be "c", OR "baz" must be "d"
+// Regular expressions are provided with the =~ operator, e.g. foo=~web-.*, or =i~ to ignore case, e.g. foo=i~Web-.*
+// Negations are provided with the != and !~ operators, e.g. foo!=a or foo!~web-.*
+// Existence of a non-empty value is provided with a single wildcard, e.g. foo=*
+// Numeric comparisons are provided with the >, >=, < and <= operators, e.g. Bytes>1000 or DstPort>=1024
+// Values can hold the |, & and = separators when escaped with a backslash, e.g. foo=~a\|b, or within double
+// quotes, e.g. foo="a&b". Filters without an operator are rejected.
+// Arbitrary nesting is provided by the JSON encoded grammar, see parseNested.
 func Parse(raw string) (MultiQueries, error) {
-	var parsed []SingleQuery
 	decoded, err := url.QueryUnescape(raw)
 	if err != nil {
 		return nil, err
 	}
-	groups := strings.Split(decoded, "|")
-	for _, group := range groups {
+	if isNested(decoded) {
+		return parseNested(decoded)
+	}
+	return parseGroups(decoded)
+}
+
+func parseGroups(decoded string) (MultiQueries, error) {
+	var parsed []SingleQuery
+	for _, group := range splitUnescaped(decoded, '|') {
 		var andFilters []Match
-		filters := strings.Split(group, "&")
-		for _, filter := range filters {
-			pair := strings.Split(filter, "=")
-			if len(pair) == 2 {
-				if strings.HasSuffix(pair[0], "!") {
-					andFilters = append(andFilters, NewNotMatch(strings.TrimSuffix(pair[0], "!"), pair[1]))
-				} else {
-					andFilters = append(andFilters, NewMatch(pair[0], pair[1]))
-				}
+		for _, filter := range splitUnescaped(group, '&') {
+			if filter == "" {
+				continue
+			}
+			m, err := parseMatch(filter)
+			if err != nil {
+				return nil, err
 			}
+			andFilters = append(andFilters, m)
 		}
 		parsed = append(parsed, andFilters)
 	}
 	return parsed, nil
 }
+
+// parseMatch parses a single filter: the key, up to the first operator, and the value, which can hold the
+// operator characters
+func parseMatch(filter string) (Match, error) {
+	i := strings.IndexAny(filter, "=!<>")
+	if i <= 0 {
+		return Match{}, fmt.Errorf("invalid filter %q: expecting a key, an operator and a value", filter)
+	}
+	key, rest := filter[:i], filter[i:]
+	switch {
+	case strings.HasPrefix(rest, ">="), strings.HasPrefix(rest, "<="):
+		return NewComparisonMatch(key, rest[:2], unescape(rest[2:])), nil
+	case strings.HasPrefix(rest, ">"), strings.HasPrefix(rest, "<"):
+		return NewComparisonMatch(key, rest[:1], unescape(rest[1:])), nil
+	case strings.HasPrefix(rest, "!~"):
+		return NewNotRegexMatch(key, unescape(rest[2:])), nil
+	case strings.HasPrefix(rest, "!="):
+		value := rest[2:]
+		if strings.HasPrefix(value, "i~") {
+			m := NewCaseInsensitiveMatch(key, unescape(strings.TrimPrefix(value, "i~")))
+			m.Not = true
+			return m, nil
+		} else if strings.HasPrefix(value, "~") {
+			return NewNotRegexMatch(key, unescape(strings.TrimPrefix(value, "~"))), nil
+		}
+		return NewNotMatch(key, unescape(value)), nil
+	case strings.HasPrefix(rest, "="):
+		value := rest[1:]
+		if value == existsWildcard {
+			return NewExistsMatch(key), nil
+		} else if strings.HasPrefix(value, "i~") {
+			return NewCaseInsensitiveMatch(key, unescape(strings.TrimPrefix(value, "i~"))), nil
+		} else if strings.HasPrefix(value, "~") {
+			return NewRegexMatch(key, unescape(strings.TrimPrefix(value, "~"))), nil
+		}
+		return NewMatch(key, unescape(value)), nil
+	}
+	return Match{}, fmt.Errorf("invalid filter %q: unknown operator", filter)
+}
+
+// splitUnescaped splits s around the separators which aren't escaped with a backslash nor within double quotes
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && isEscapable(s[i+1]) {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+func isEscapable(c byte) bool {
+	return c == '|' || c == '&' || c == '='
+}
+
+// unescape removes the backslashes escaping the separators, other backslashes being kept, e.g. for regular
+// expressions
+func unescape(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && isEscapable(value[i+1]) {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
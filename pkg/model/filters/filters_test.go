@@ -2,6 +2,7 @@ package filters
 
 import (
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -75,3 +76,138 @@ func TestParseCommon(t *testing.T) {
 		NewMatch("dstns", "a"),
 	}, groups[1])
 }
+
+func TestParseRegex(t *testing.T) {
+	groups, err := Parse(url.QueryEscape("SrcK8S_Name=~web-.*&DstPort=80"))
+	require.NoError(t, err)
+
+	assert.Len(t, groups, 1)
+	assert.Equal(t, SingleQuery{
+		NewRegexMatch("SrcK8S_Name", "web-.*"),
+		NewMatch("DstPort", "80"),
+	}, groups[0])
+}
+
+func TestParseCaseInsensitive(t *testing.T) {
+	groups, err := Parse(url.QueryEscape("SrcK8S_Name=i~Frontend&DstK8S_Name!=i~API-.*"))
+	require.NoError(t, err)
+
+	notMatch := NewCaseInsensitiveMatch("DstK8S_Name", "API-.*")
+	notMatch.Not = true
+	assert.Equal(t, MultiQueries{{
+		NewCaseInsensitiveMatch("SrcK8S_Name", "Frontend"),
+		notMatch,
+	}}, groups)
+}
+
+func TestParseNotRegex(t *testing.T) {
+	groups, err := Parse(url.QueryEscape("SrcK8S_Name!~web-.*&DstK8S_Name!=~api-[0-9]+&SrcK8S_Namespace!=openshift-monitoring"))
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{
+		NewNotRegexMatch("SrcK8S_Name", "web-.*"),
+		NewNotRegexMatch("DstK8S_Name", "api-[0-9]+"),
+		NewNotMatch("SrcK8S_Namespace", "openshift-monitoring"),
+	}}, groups)
+}
+
+func TestParseComparison(t *testing.T) {
+	groups, err := Parse(url.QueryEscape("TcpRetransmits>0&SrcK8S_Namespace=ns|Bytes>1000"))
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{
+		NewComparisonMatch("TcpRetransmits", ">", "0"),
+		NewMatch("SrcK8S_Namespace", "ns"),
+	}, {
+		NewComparisonMatch("Bytes", ">", "1000"),
+	}}, groups)
+}
+
+func TestParseComparisonOperators(t *testing.T) {
+	groups, err := Parse(url.QueryEscape("DstPort>=1024&Packets<10&Bytes<=500|SrcPort>0"))
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{
+		NewComparisonMatch("DstPort", ">=", "1024"),
+		NewComparisonMatch("Packets", "<", "10"),
+		NewComparisonMatch("Bytes", "<=", "500"),
+	}, {
+		NewComparisonMatch("SrcPort", ">", "0"),
+	}}, groups)
+}
+
+func TestParseExists(t *testing.T) {
+	groups, err := Parse(url.QueryEscape("DstK8S_ServiceName=*&SrcK8S_Name=*web*"))
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{
+		NewExistsMatch("DstK8S_ServiceName"),
+		NewMatch("SrcK8S_Name", "*web*"),
+	}}, groups)
+}
+
+func TestParseNested(t *testing.T) {
+	// (foo=a | bar=b) & (baz=c | qux=d)
+	groups, err := Parse(url.QueryEscape(`{"and":[{"or":["foo=a","bar=b"]},{"or":["baz=c","qux=d"]}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, MultiQueries{
+		{NewMatch("foo", "a"), NewMatch("baz", "c")},
+		{NewMatch("foo", "a"), NewMatch("qux", "d")},
+		{NewMatch("bar", "b"), NewMatch("baz", "c")},
+		{NewMatch("bar", "b"), NewMatch("qux", "d")},
+	}, groups)
+
+	// leaves use the flat grammar; redundant groups are removed: foo=a | foo=a&bar=b is foo=a
+	groups, err = Parse(url.QueryEscape(`{"or":["foo=a",{"and":["foo=a","bar!~x.*"]},"baz>10&baz>10|foo=a"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, MultiQueries{
+		{NewMatch("foo", "a")},
+		{NewComparisonMatch("baz", ">", "10")},
+	}, groups)
+}
+
+func TestParseNested_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		`{"and":["foo=a"]`,
+		`{"xor":["foo=a"]}`,
+		`{"and":[]}`,
+		`{"and":["foo=a"],"or":["bar=b"]}`,
+		`{"and":[42]}`,
+	} {
+		_, err := Parse(url.QueryEscape(raw))
+		assert.Error(t, err, raw)
+	}
+
+	// too many groups
+	var ors []string
+	for i := 0; i < 8; i++ {
+		ors = append(ors, `{"or":["foo=a","foo=b","foo=c"]}`)
+	}
+	_, err := Parse(url.QueryEscape(`{"and":[` + strings.Join(ors, ",") + `]}`))
+	assert.Error(t, err)
+}
+
+func TestParseEscapedValues(t *testing.T) {
+	groups, err := Parse(url.QueryEscape(`SrcK8S_Name=~web\|api&K8S_Label=~app\=a.*|DstK8S_Name="a&b|c"&SrcAddr!=\d`))
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{
+		NewRegexMatch("SrcK8S_Name", "web|api"),
+		NewRegexMatch("K8S_Label", `app=a.*`),
+	}, {
+		NewMatch("DstK8S_Name", `"a&b|c"`),
+		NewNotMatch("SrcAddr", `\d`),
+	}}, groups)
+
+	// unescaped = in values
+	groups, err = Parse(url.QueryEscape("K8S_Label=app=a"))
+	require.NoError(t, err)
+	assert.Equal(t, MultiQueries{{NewMatch("K8S_Label", "app=a")}}, groups)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, invalid := range []string{"foo", "=a", "foo=a&bar", `{"or":["foo=a","bar"]}`} {
+		_, err := Parse(url.QueryEscape(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
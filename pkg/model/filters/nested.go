@@ -0,0 +1,146 @@
+package filters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	nestedAnd = "and"
+	nestedOr  = "or"
+	// MaxNestedGroups limits the number of groups, hence of queries, a nested expression can expand to
+	MaxNestedGroups = 50
+)
+
+func isNested(decoded string) bool {
+	return strings.HasPrefix(strings.TrimSpace(decoded), "{")
+}
+
+// parseNested parses a JSON encoded filter expression, where each node is either a string using the
+// flat grammar (e.g. "foo=a,b&bar=c") or an object with a single "and" or "or" key listing nested nodes:
+// {"and":[{"or":["foo=a","bar=b"]},{"or":["baz=c","qux=d"]}]}
+// The expression is decomposed into its disjunctive normal form, i.e. an union of intersect groups as
+// produced by the flat grammar, keeping the minimal set of groups: duplicates are removed, as well
+// as groups including all the matches of another group (A | A&B is A).
+func parseNested(decoded string) (MultiQueries, error) {
+	var expr interface{}
+	if err := json.Unmarshal([]byte(decoded), &expr); err != nil {
+		return nil, fmt.Errorf("invalid nested filter: %w", err)
+	}
+	groups, err := toGroups(expr)
+	if err != nil {
+		return nil, err
+	}
+	return minimizeGroups(groups), nil
+}
+
+func toGroups(node interface{}) (MultiQueries, error) {
+	switch n := node.(type) {
+	case string:
+		return parseGroups(n)
+	case map[string]interface{}:
+		if len(n) != 1 {
+			return nil, fmt.Errorf("invalid nested filter: objects must have a single %q or %q key", nestedAnd, nestedOr)
+		}
+		for op, children := range n {
+			list, ok := children.([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("invalid nested filter: %q expects a non-empty list", op)
+			}
+			switch op {
+			case nestedAnd:
+				return andNodes(list)
+			case nestedOr:
+				return orNodes(list)
+			}
+			return nil, fmt.Errorf("invalid nested filter: unknown operator %q", op)
+		}
+	}
+	return nil, fmt.Errorf("invalid nested filter: unexpected node %v", node)
+}
+
+func orNodes(list []interface{}) (MultiQueries, error) {
+	var groups MultiQueries
+	for _, child := range list {
+		childGroups, err := toGroups(child)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, childGroups...)
+		if len(groups) > MaxNestedGroups {
+			return nil, fmt.Errorf("nested filter expands to more than %d groups", MaxNestedGroups)
+		}
+	}
+	return groups, nil
+}
+
+// andNodes distributes the intersection over the children unions: (A|B)&(C|D) is A&C|A&D|B&C|B&D
+func andNodes(list []interface{}) (MultiQueries, error) {
+	groups := MultiQueries{{}}
+	for _, child := range list {
+		childGroups, err := toGroups(child)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups)*len(childGroups) > MaxNestedGroups {
+			return nil, fmt.Errorf("nested filter expands to more than %d groups", MaxNestedGroups)
+		}
+		var product MultiQueries
+		for _, g := range groups {
+			for _, cg := range childGroups {
+				merged := make(SingleQuery, 0, len(g)+len(cg))
+				merged = append(merged, g...)
+				product = append(product, append(merged, cg...))
+			}
+		}
+		groups = product
+	}
+	return groups, nil
+}
+
+func minimizeGroups(groups MultiQueries) MultiQueries {
+	sets := make([]map[Match]struct{}, len(groups))
+	for i, g := range groups {
+		sets[i] = map[Match]struct{}{}
+		for _, m := range g {
+			sets[i][m] = struct{}{}
+		}
+	}
+	var minimal MultiQueries
+	for i, g := range groups {
+		redundant := false
+		for j := range groups {
+			// a group is redundant when another group is included in it; among equal groups, the first one is kept
+			if i != j && includes(sets[i], sets[j]) && (len(sets[i]) > len(sets[j]) || j < i) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			minimal = append(minimal, dedupMatches(g))
+		}
+	}
+	return minimal
+}
+
+func includes(set, subset map[Match]struct{}) bool {
+	for m := range subset {
+		if _, ok := set[m]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func dedupMatches(group SingleQuery) SingleQuery {
+	seen := map[Match]struct{}{}
+	var deduped SingleQuery
+	for _, m := range group {
+		if _, ok := seen[m]; !ok {
+			seen[m] = struct{}{}
+			deduped = append(deduped, m)
+		}
+	}
+	return deduped
+}
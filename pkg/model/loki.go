@@ -22,6 +22,128 @@ type AggregatedQueryResponse struct {
 	Stats         AggregatedStats `json:"stats"`
 	IsMock        bool            `json:"isMock"`
 	UnixTimestamp int64           `json:"unixTimestamp"`
+	// IngestionLagMs is the difference between the query end and the newest returned flow
+	IngestionLagMs *int64 `json:"ingestionLagMs,omitempty"`
+	// EffectiveEndTime is the query end actually used, in seconds, when it was shifted back because of ingestion delay
+	EffectiveEndTime *int64 `json:"effectiveEndTime,omitempty"`
+	// Warnings tell clients about deprecated usages, such as renamed parameters
+	Warnings []string `json:"warnings,omitempty"`
+	// NextCursor is set when the limit was reached, to fetch the next page of flows with the cursor parameter
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Comparison holds the metric values for the requested period vs the preceding one
+	Comparison []PeriodComparison `json:"comparison,omitempty"`
+	// IsLimitReached is set when results were left out because of the limit
+	IsLimitReached bool `json:"isLimitReached"`
+	// FailedQueries and MissingRanges describe partial results, when some of the queries failed
+	FailedQueries []FailedQuery `json:"failedQueries,omitempty"`
+	MissingRanges []TimeRange   `json:"missingRanges,omitempty"`
+}
+
+// FailedQuery is a Loki query whose results are missing from a partial response
+type FailedQuery struct {
+	Query   string `json:"query"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// TimeRange is a time range in seconds, the end being possibly in nanoseconds for the next page of a cursor
+type TimeRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// FreshnessResponse represents the most recent flow timestamp, as a data freshness signal
+type FreshnessResponse struct {
+	// Timestamp of the most recent flow, in milliseconds since epoch
+	Timestamp int64 `json:"timestamp"`
+	// AgeMs is the difference between now and the most recent flow
+	AgeMs int64 `json:"ageMs"`
+}
+
+// Ingestion statuses
+const (
+	IngestionOK    = "ok"
+	IngestionStale = "stale"
+	IngestionNone  = "none"
+)
+
+// IngestionStatus tells whether flows are still being written to Loki
+type IngestionStatus struct {
+	// Status is ok, stale when the most recent flow is older than MaxLagMs, or none when no flow was found within
+	// LookbackMs
+	Status string `json:"status"`
+	// LatestTimestamp of the most recent flow, in milliseconds since epoch, omitted when none was found
+	LatestTimestamp int64 `json:"latestTimestamp,omitempty"`
+	// LagMs is the difference between now and the most recent flow, omitted when none was found
+	LagMs      int64 `json:"lagMs,omitempty"`
+	MaxLagMs   int64 `json:"maxLagMs"`
+	LookbackMs int64 `json:"lookbackMs"`
+}
+
+// FlowsValidation is the result of a flows request dry-run: the Loki queries it would run, or why it is invalid
+type FlowsValidation struct {
+	Valid    bool              `json:"valid"`
+	Queries  []string          `json:"queries"`
+	Errors   []ValidationError `json:"errors,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+// ValidationError locates, when possible, the invalid part of a flows request
+type ValidationError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Param is the invalid request parameter
+	Param string `json:"param,omitempty"`
+	// Group is the index of the invalid filter group, and Filter the key of the invalid filter within this group
+	Group  *int   `json:"group,omitempty"`
+	Filter string `json:"filter,omitempty"`
+}
+
+// IndexStats is the response of the Loki index stats API
+type IndexStats struct {
+	Streams int64 `json:"streams"`
+	Chunks  int64 `json:"chunks"`
+	Entries int64 `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// FlowsEstimate is the expected cost of a flows request, from Loki index stats
+type FlowsEstimate struct {
+	IndexStats
+	NumQueries int      `json:"numQueries"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// FieldCardinality is the number of distinct values of a field over a time range
+type FieldCardinality struct {
+	Field   string `json:"field"`
+	Count   int    `json:"count"`
+	IsLabel bool   `json:"isLabel"`
+	// Sampled is set when values were counted in a sample of flows which didn't cover the whole time range: Count is
+	// then a lower bound
+	Sampled bool `json:"sampled"`
+	// SampleSize is the number of flows read when the values were counted in a sample
+	SampleSize int `json:"sampleSize,omitempty"`
+}
+
+// PeriodComparison compares a metric series value between the requested period and the immediately preceding one
+type PeriodComparison struct {
+	Metric   model.Metric `json:"metric"`
+	Current  float64      `json:"current"`
+	Previous float64      `json:"previous"`
+	Delta    float64      `json:"delta"`
+	// PercentChange is nil when the previous value is zero
+	PercentChange *float64 `json:"percentChange"`
+}
+
+// ConnectionMatrix is a dense source x destination representation of the topology:
+// Matrix[i][j] is the value from Rows[i] to Cols[j], zero when there is no traffic
+type ConnectionMatrix struct {
+	Rows   []string    `json:"rows"`
+	Cols   []string    `json:"cols"`
+	Matrix [][]float64 `json:"matrix"`
+	// Truncated is set when rows or columns were dropped to fit the maximum dimension
+	Truncated bool `json:"truncated"`
 }
 
 // AggregatedStats represents the stats to one or more logQL queries
@@ -31,6 +153,38 @@ type AggregatedStats struct {
 	Duplicates   int           `json:"duplicates"`
 	LimitReached bool          `json:"limitReached"`
 	QueriesStats []interface{} `json:"queriesStats"`
+	// Reconciled is the number of flow observations merged into the other reporter observation,
+	// or discarded as duplicates seen on other interfaces in the both reporters mode
+	Reconciled int `json:"reconciled,omitempty"`
+	// Groups details the contribution of each query, when several were merged (filter groups ran in parallel)
+	Groups []GroupStats `json:"groups,omitempty"`
+	// Execution sums up the Loki execution statistics of all the queries
+	Execution *ExecutionStats `json:"execution,omitempty"`
+}
+
+// ExecutionStats is the summary of Loki execution statistics, aggregated over one or more queries
+type ExecutionStats struct {
+	BytesProcessed          int64 `json:"bytesProcessed"`
+	LinesProcessed          int64 `json:"linesProcessed"`
+	EntriesReturned         int64 `json:"entriesReturned"`
+	BytesProcessedPerSecond int64 `json:"bytesProcessedPerSecond"`
+	LinesProcessedPerSecond int64 `json:"linesProcessedPerSecond"`
+	// ExecTime and QueueTime are the total time spent by the queries, in seconds, and MaxExecTime the time of the
+	// slowest one: queries running in parallel, it is closer to the actual wait
+	ExecTime    float64 `json:"execTime"`
+	MaxExecTime float64 `json:"maxExecTime"`
+	QueueTime   float64 `json:"queueTime"`
+	Subqueries  int64   `json:"subqueries"`
+}
+
+// GroupStats represents the stats of a single query prior to merging
+type GroupStats struct {
+	// Returned is the number of entries returned by Loki for this query
+	Returned int `json:"returned"`
+	// Included is the number of entries kept in the merged result, ie. not already returned by a previous query
+	Included     int  `json:"included"`
+	Duplicates   int  `json:"duplicates"`
+	LimitReached bool `json:"limitReached"`
 }
 
 // ResultType holds the type of the result
@@ -207,3 +361,9 @@ type LabelValuesResponse struct {
 	Status string   `json:"status"`
 	Data   []string `json:"data"`
 }
+
+// SeriesResponse represents the http json response to a query for series: the label sets of the matching streams
+type SeriesResponse struct {
+	Status string              `json:"status"`
+	Data   []map[string]string `json:"data"`
+}
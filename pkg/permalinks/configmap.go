@@ -0,0 +1,75 @@
+package permalinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
+)
+
+const linkKeyPrefix = "link."
+
+// NewConfigMapStore returns a store persisting the permalinks in a single ConfigMap, one key per permalink. The
+// oldest permalinks are evicted beyond maxLinks, or when the ConfigMap gets close to its size limit.
+func NewConfigMapStore(client corev1client.ConfigMapInterface, name string, ttl time.Duration, maxLinks int) Store {
+	return &store{backend: &configMapBackend{client: client, name: name}, ttl: ttl, maxLinks: maxLinks, now: time.Now}
+}
+
+type configMapBackend struct {
+	client corev1client.ConfigMapInterface
+	name   string
+}
+
+func (b *configMapBackend) read(ctx context.Context) (map[string]Permalink, error) {
+	raw, err := client.GetConfigMapData(ctx, b.client, b.name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeData(raw)
+}
+
+func (b *configMapBackend) update(ctx context.Context, mutate func(data map[string]Permalink) error) error {
+	return client.UpdateConfigMapData(ctx, b.client, b.name, func(raw map[string]string) error {
+		data, err := decodeData(raw)
+		if err != nil {
+			return err
+		}
+		if err := mutate(data); err != nil {
+			return err
+		}
+		// replace permalinks, keeping any other content
+		for key := range raw {
+			if strings.HasPrefix(key, linkKeyPrefix) {
+				delete(raw, key)
+			}
+		}
+		for id, link := range data {
+			js, err := json.Marshal(link)
+			if err != nil {
+				return err
+			}
+			raw[linkKeyPrefix+id] = string(js)
+		}
+		return nil
+	})
+}
+
+func decodeData(raw map[string]string) (map[string]Permalink, error) {
+	data := map[string]Permalink{}
+	for key, value := range raw {
+		if !strings.HasPrefix(key, linkKeyPrefix) {
+			continue
+		}
+		var link Permalink
+		if err := json.Unmarshal([]byte(value), &link); err != nil {
+			return nil, fmt.Errorf("invalid permalink for key %s: %w", key, err)
+		}
+		data[strings.TrimPrefix(key, linkKeyPrefix)] = link
+	}
+	return data, nil
+}
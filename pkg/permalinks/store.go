@@ -0,0 +1,181 @@
+// Package permalinks stores query parameter sets under short IDs, for compact shareable links
+package permalinks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	idLength = 10
+	// MaxQueryLength limits the size of stored queries
+	MaxQueryLength = 8 * 1024
+	// maxDataSize keeps the stored permalinks within the 1MiB size limit of ConfigMaps, the oldest being evicted
+	maxDataSize = 900 * 1024
+	// linkOverhead approximates the encoded size of a permalink, in addition to its query
+	linkOverhead = 100
+)
+
+var (
+	ErrNotFound = errors.New("permalink not found")
+	ErrInvalid  = errors.New("invalid permalink")
+)
+
+var idEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Permalink is a query parameter set, URL encoded
+type Permalink struct {
+	ID        string    `json:"id"`
+	Query     string    `json:"query"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Store interface {
+	// Create stores the query, returning the existing permalink when the same query was already stored
+	Create(ctx context.Context, query string) (*Permalink, error)
+	Get(ctx context.Context, id string) (*Permalink, error)
+}
+
+// backend loads and updates the permalinks, mapped by ID
+type backend interface {
+	read(ctx context.Context) (map[string]Permalink, error)
+	update(ctx context.Context, mutate func(data map[string]Permalink) error) error
+}
+
+type store struct {
+	backend backend
+	// ttl is the duration after which permalinks expire, 0 meaning never
+	ttl time.Duration
+	// maxLinks caps the number of stored permalinks, the oldest being evicted, 0 meaning no cap
+	maxLinks int
+	now      func() time.Time
+}
+
+// normalize validates and sorts the query parameters, so that a same query always gets the same ID
+func normalize(query string) (string, error) {
+	if len(query) > MaxQueryLength {
+		return "", fmt.Errorf("%w: query longer than %d characters", ErrInvalid, MaxQueryLength)
+	}
+	params, err := url.ParseQuery(strings.TrimPrefix(query, "?"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalid, err.Error())
+	}
+	if len(params) == 0 {
+		return "", fmt.Errorf("%w: empty query", ErrInvalid)
+	}
+	return params.Encode(), nil
+}
+
+func makeID(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return strings.ToLower(idEncoding.EncodeToString(sum[:]))[:idLength]
+}
+
+func (s *store) expired(p *Permalink) bool {
+	return s.ttl > 0 && s.now().Sub(p.CreatedAt) > s.ttl
+}
+
+func (s *store) Create(ctx context.Context, query string) (*Permalink, error) {
+	normalized, err := normalize(query)
+	if err != nil {
+		return nil, err
+	}
+	link := Permalink{ID: makeID(normalized), Query: normalized, CreatedAt: s.now().UTC()}
+	err = s.backend.update(ctx, func(data map[string]Permalink) error {
+		for id, p := range data {
+			if s.expired(&p) {
+				delete(data, id)
+			}
+		}
+		if existing, ok := data[link.ID]; ok {
+			// keep the original creation time when sharing again a same query
+			link = existing
+			return nil
+		}
+		data[link.ID] = link
+		s.evict(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// evict removes the oldest permalinks beyond the count and size caps
+func (s *store) evict(data map[string]Permalink) {
+	size := 0
+	links := make([]Permalink, 0, len(data))
+	for _, p := range data {
+		size += len(p.Query) + linkOverhead
+		links = append(links, p)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].CreatedAt.Equal(links[j].CreatedAt) {
+			return links[i].ID < links[j].ID
+		}
+		return links[i].CreatedAt.Before(links[j].CreatedAt)
+	})
+	for _, p := range links {
+		if (s.maxLinks <= 0 || len(data) <= s.maxLinks) && size <= maxDataSize {
+			return
+		}
+		delete(data, p.ID)
+		size -= len(p.Query) + linkOverhead
+	}
+}
+
+func (s *store) Get(ctx context.Context, id string) (*Permalink, error) {
+	data, err := s.backend.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	link, ok := data[id]
+	if !ok || s.expired(&link) {
+		return nil, ErrNotFound
+	}
+	return &link, nil
+}
+
+// NewMemoryStore returns a non-persistent store of at most maxLinks permalinks, lost on restart
+func NewMemoryStore(ttl time.Duration, maxLinks int) Store {
+	return &store{backend: &memoryBackend{data: map[string]Permalink{}}, ttl: ttl, maxLinks: maxLinks, now: time.Now}
+}
+
+type memoryBackend struct {
+	mutex sync.RWMutex
+	data  map[string]Permalink
+}
+
+func (b *memoryBackend) read(_ context.Context) (map[string]Permalink, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return copyData(b.data), nil
+}
+
+func (b *memoryBackend) update(_ context.Context, mutate func(data map[string]Permalink) error) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	data := copyData(b.data)
+	if err := mutate(data); err != nil {
+		return err
+	}
+	b.data = data
+	return nil
+}
+
+func copyData(data map[string]Permalink) map[string]Permalink {
+	copied := make(map[string]Permalink, len(data))
+	for id, p := range data {
+		copied[id] = p
+	}
+	return copied
+}
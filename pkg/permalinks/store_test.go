@@ -0,0 +1,94 @@
+package permalinks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"fmt"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Unix(1680000000, 0)
+	s := &store{backend: &memoryBackend{data: map[string]Permalink{}}, ttl: time.Hour, now: func() time.Time { return now }}
+
+	link, err := s.Create(ctx, "timeRange=300&filters=SrcK8S_Namespace%3Dapp")
+	require.NoError(t, err)
+	assert.Len(t, link.ID, idLength)
+	assert.Equal(t, "filters=SrcK8S_Namespace%3Dapp&timeRange=300", link.Query)
+
+	// same parameters, in any order => same link
+	now = now.Add(time.Minute)
+	same, err := s.Create(ctx, "?filters=SrcK8S_Namespace%3Dapp&timeRange=300")
+	require.NoError(t, err)
+	assert.Equal(t, link, same)
+
+	other, err := s.Create(ctx, "timeRange=600")
+	require.NoError(t, err)
+	assert.NotEqual(t, link.ID, other.ID)
+
+	resolved, err := s.Get(ctx, link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, link, resolved)
+
+	_, err = s.Get(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// expiry
+	now = now.Add(2 * time.Hour)
+	_, err = s.Get(ctx, link.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Create(ctx, "limit=10")
+	require.NoError(t, err)
+	data, err := s.backend.read(ctx)
+	require.NoError(t, err)
+	assert.Len(t, data, 1)
+
+	_, err = s.Create(ctx, "")
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = s.Create(ctx, "filters="+strings.Repeat("a", MaxQueryLength))
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = s.Create(ctx, "filters=%zz")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestStore_Eviction(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Unix(1680000000, 0)
+	s := &store{backend: &memoryBackend{data: map[string]Permalink{}}, maxLinks: 2, now: func() time.Time { return now }}
+
+	oldest, err := s.Create(ctx, "timeRange=300")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = s.Create(ctx, "timeRange=600")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	newest, err := s.Create(ctx, "timeRange=900")
+	require.NoError(t, err)
+
+	_, err = s.Get(ctx, oldest.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Get(ctx, newest.ID)
+	require.NoError(t, err)
+
+	// the size is capped too
+	s.maxLinks = 0
+	for i := 0; i < 2*maxDataSize/MaxQueryLength; i++ {
+		now = now.Add(time.Minute)
+		_, err = s.Create(ctx, fmt.Sprintf("filters=%d%s", i, strings.Repeat("a", MaxQueryLength-20)))
+		require.NoError(t, err)
+	}
+	data, err := s.backend.read(ctx)
+	require.NoError(t, err)
+	size := 0
+	for _, p := range data {
+		size += len(p.Query) + linkOverhead
+	}
+	assert.LessOrEqual(t, size, maxDataSize)
+	assert.Greater(t, len(data), maxDataSize/MaxQueryLength-2)
+}
@@ -0,0 +1,93 @@
+package preferences
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
+)
+
+const (
+	// userKey holds the user name, checked when reading, prefsKey the JSON preferences
+	userKey  = "user"
+	prefsKey = "preferences"
+	// Label selects the preferences ConfigMaps, e.g. for cleanup
+	Label = "netobserv.io/user-preferences"
+)
+
+var plog = logrus.WithField("module", "preferences")
+
+// NewConfigMapStore returns a store persisting the preferences of each user in its own ConfigMap, named from prefix
+// and a hash of the user name, so that users don't share a ConfigMap size limit nor a corrupted content
+func NewConfigMapStore(client corev1client.ConfigMapInterface, prefix string) Store {
+	return &store{backend: &configMapBackend{client: client, prefix: prefix}, now: time.Now}
+}
+
+type configMapBackend struct {
+	client corev1client.ConfigMapInterface
+	prefix string
+}
+
+// configMapName derives a valid ConfigMap name from user names, e.g. system:serviceaccount:ns:name
+func configMapName(prefix, user string) string {
+	sum := sha256.Sum256([]byte(user))
+	return prefix + "-" + hex.EncodeToString(sum[:8])
+}
+
+func (b *configMapBackend) read(ctx context.Context, user string) (Preferences, error) {
+	raw, err := client.GetConfigMapData(ctx, b.client, configMapName(b.prefix, user))
+	if err != nil {
+		return Preferences{}, err
+	}
+	return decodeUser(user, raw), nil
+}
+
+func (b *configMapBackend) write(ctx context.Context, user string, prefs Preferences) error {
+	encoded, err := encodeUser(user, prefs)
+	if err != nil {
+		return err
+	}
+	labels := map[string]string{Label: "true"}
+	return client.UpdateLabelledConfigMapData(ctx, b.client, configMapName(b.prefix, user), labels, func(raw map[string]string) error {
+		for key, value := range encoded {
+			raw[key] = value
+		}
+		return nil
+	})
+}
+
+func (b *configMapBackend) delete(ctx context.Context, user string) error {
+	return client.DeleteConfigMap(ctx, b.client, configMapName(b.prefix, user))
+}
+
+// decodeUser returns the preferences of the ConfigMap data, empty when they are invalid or of another user
+func decodeUser(user string, raw map[string]string) Preferences {
+	var prefs Preferences
+	value, ok := raw[prefsKey]
+	if !ok {
+		return prefs
+	}
+	if raw[userKey] != user {
+		plog.Warnf("Ignoring the preferences of another user, stored in the ConfigMap of %s", user)
+		return prefs
+	}
+	if err := json.Unmarshal([]byte(value), &prefs); err != nil {
+		plog.WithError(err).Warnf("Ignoring invalid preferences of %s", user)
+		return Preferences{}
+	}
+	return prefs
+}
+
+func encodeUser(user string, prefs Preferences) (map[string]string, error) {
+	js, err := json.Marshal(prefs)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{userKey: user, prefsKey: string(js)}, nil
+}
@@ -0,0 +1,136 @@
+// Package preferences persists the console settings of each user, such as the selected columns
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	maxColumns        = 200
+	maxLastQueryBytes = 4096
+)
+
+var (
+	ErrInvalid = errors.New("invalid preferences")
+
+	columnIDValidation = regexp.MustCompile(`^[\w.-]{1,100}$`)
+	densities          = map[string]bool{"": true, "s": true, "m": true, "l": true}
+)
+
+// Preferences are the console settings of a user
+type Preferences struct {
+	// Columns are the IDs of the selected flows table columns, in display order
+	Columns []string `json:"columns,omitempty"`
+	// TimeRange is the default time range, in seconds
+	TimeRange int `json:"timeRange,omitempty"`
+	// LastQuery is the URL query of the last viewed flows
+	LastQuery string `json:"lastQuery,omitempty"`
+	// TableDensity is the flows table size: s, m or l
+	TableDensity string `json:"tableDensity,omitempty"`
+	// UpdatedAt is set by the store
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate checks the preferences bounds
+func (p *Preferences) Validate() error {
+	if len(p.Columns) > maxColumns {
+		return fmt.Errorf("%w: at most %d columns can be selected", ErrInvalid, maxColumns)
+	}
+	for _, c := range p.Columns {
+		if !columnIDValidation.MatchString(c) {
+			return fmt.Errorf("%w: invalid column %q", ErrInvalid, c)
+		}
+	}
+	if p.TimeRange < 0 {
+		return fmt.Errorf("%w: negative time range", ErrInvalid)
+	}
+	if len(p.LastQuery) > maxLastQueryBytes {
+		return fmt.Errorf("%w: last query exceeds %d bytes", ErrInvalid, maxLastQueryBytes)
+	}
+	if !densities[p.TableDensity] {
+		return fmt.Errorf("%w: table density must be s, m or l", ErrInvalid)
+	}
+	return nil
+}
+
+type Store interface {
+	// Get returns the preferences of the user, empty when never saved
+	Get(ctx context.Context, user string) (*Preferences, error)
+	// Save replaces the preferences of the user
+	Save(ctx context.Context, user string, prefs Preferences) (*Preferences, error)
+	// Delete resets the preferences of the user
+	Delete(ctx context.Context, user string) error
+}
+
+// backend loads and updates the preferences of a user
+type backend interface {
+	read(ctx context.Context, user string) (Preferences, error)
+	write(ctx context.Context, user string, prefs Preferences) error
+	delete(ctx context.Context, user string) error
+}
+
+type store struct {
+	backend backend
+	now     func() time.Time
+}
+
+func (s *store) Get(ctx context.Context, user string) (*Preferences, error) {
+	prefs, err := s.backend.read(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+func (s *store) Save(ctx context.Context, user string, prefs Preferences) (*Preferences, error) {
+	if err := prefs.Validate(); err != nil {
+		return nil, err
+	}
+	prefs.UpdatedAt = s.now().UTC()
+	if err := s.backend.write(ctx, user, prefs); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+func (s *store) Delete(ctx context.Context, user string) error {
+	return s.backend.delete(ctx, user)
+}
+
+// NewMemoryStore returns a non-persistent store, lost on restart
+func NewMemoryStore() Store {
+	return &store{backend: &memoryBackend{data: map[string]Preferences{}}, now: time.Now}
+}
+
+type memoryBackend struct {
+	mutex sync.RWMutex
+	data  map[string]Preferences
+}
+
+func (b *memoryBackend) read(_ context.Context, user string) (Preferences, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	prefs := b.data[user]
+	prefs.Columns = append([]string(nil), prefs.Columns...)
+	return prefs, nil
+}
+
+func (b *memoryBackend) write(_ context.Context, user string, prefs Preferences) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	prefs.Columns = append([]string(nil), prefs.Columns...)
+	b.data[user] = prefs
+	return nil
+}
+
+func (b *memoryBackend) delete(_ context.Context, user string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.data, user)
+	return nil
+}
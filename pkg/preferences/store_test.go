@@ -0,0 +1,70 @@
+package preferences
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.TODO()
+	s := NewMemoryStore()
+
+	// never saved
+	prefs, err := s.Get(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, &Preferences{}, prefs)
+
+	saved, err := s.Save(ctx, "alice", Preferences{Columns: []string{"StartTime", "SrcK8S_Name"}, TimeRange: 300, TableDensity: "s"})
+	require.NoError(t, err)
+	assert.False(t, saved.UpdatedAt.IsZero())
+	_, err = s.Save(ctx, "bob", Preferences{LastQuery: "filters=DstPort%3D53"})
+	require.NoError(t, err)
+
+	prefs, err = s.Get(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"StartTime", "SrcK8S_Name"}, prefs.Columns)
+	assert.Equal(t, 300, prefs.TimeRange)
+	assert.Empty(t, prefs.LastQuery)
+
+	require.NoError(t, s.Delete(ctx, "alice"))
+	prefs, err = s.Get(ctx, "alice")
+	require.NoError(t, err)
+	assert.Empty(t, prefs.Columns)
+	prefs, err = s.Get(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "filters=DstPort%3D53", prefs.LastQuery)
+
+	for _, invalid := range []Preferences{
+		{Columns: []string{"bad column"}},
+		{TimeRange: -1},
+		{LastQuery: strings.Repeat("a", maxLastQueryBytes+1)},
+		{TableDensity: "xl"},
+	} {
+		_, err = s.Save(ctx, "alice", invalid)
+		assert.ErrorIs(t, err, ErrInvalid)
+	}
+}
+
+func TestConfigMapEncoding(t *testing.T) {
+	user := "system:serviceaccount:ns:name"
+	prefs := Preferences{Columns: []string{"Bytes"}, TableDensity: "m"}
+	name := configMapName("netobserv-preferences", user)
+	assert.Regexp(t, `^[-.a-z0-9]+$`, name)
+	assert.NotEqual(t, name, configMapName("netobserv-preferences", "alice"))
+
+	raw, err := encodeUser(user, prefs)
+	require.NoError(t, err)
+	// other keys are ignored
+	raw["readme"] = "managed by the console plugin"
+	assert.Equal(t, prefs, decodeUser(user, raw))
+
+	// invalid or foreign content is skipped
+	assert.Equal(t, Preferences{}, decodeUser("alice", raw))
+	raw[prefsKey] = "{not json"
+	assert.Equal(t, Preferences{}, decodeUser(user, raw))
+	assert.Equal(t, Preferences{}, decodeUser(user, map[string]string{}))
+}
@@ -0,0 +1,123 @@
+package savedfilters
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// user names (e.g. system:serviceaccount:ns:name) are encoded into valid ConfigMap keys
+	userKeyPrefix  = "user."
+	maxUpdateTries = 5
+)
+
+// NewConfigMapStore returns a store persisting all the saved filters in a single ConfigMap, one key per user
+func NewConfigMapStore(client corev1client.ConfigMapInterface, name string) Store {
+	return &store{backend: &configMapBackend{client: client, name: name}, now: time.Now}
+}
+
+type configMapBackend struct {
+	client corev1client.ConfigMapInterface
+	name   string
+}
+
+func userKey(user string) string {
+	return userKeyPrefix + base64.RawURLEncoding.EncodeToString([]byte(user))
+}
+
+func (b *configMapBackend) read(ctx context.Context) (map[string][]SavedFilter, error) {
+	cm, err := b.client.Get(ctx, b.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return map[string][]SavedFilter{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decodeData(cm.Data)
+}
+
+// update applies the mutation on the latest version of the ConfigMap, retrying on concurrent modifications
+func (b *configMapBackend) update(ctx context.Context, mutate func(data map[string][]SavedFilter) error) error {
+	var err error
+	for try := 0; try < maxUpdateTries; try++ {
+		err = b.tryUpdate(ctx, mutate)
+		if !apierrors.IsConflict(err) && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (b *configMapBackend) tryUpdate(ctx context.Context, mutate func(data map[string][]SavedFilter) error) error {
+	cm, err := b.client.Get(ctx, b.name, metav1.GetOptions{})
+	exists := true
+	if apierrors.IsNotFound(err) {
+		exists = false
+		cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: b.name}}
+	} else if err != nil {
+		return err
+	}
+	data, err := decodeData(cm.Data)
+	if err != nil {
+		return err
+	}
+	if err := mutate(data); err != nil {
+		return err
+	}
+	encoded, err := encodeData(data)
+	if err != nil {
+		return err
+	}
+	// keep any other content
+	for key, value := range cm.Data {
+		if !strings.HasPrefix(key, userKeyPrefix) {
+			encoded[key] = value
+		}
+	}
+	cm.Data = encoded
+	if exists {
+		_, err = b.client.Update(ctx, cm, metav1.UpdateOptions{})
+	} else {
+		_, err = b.client.Create(ctx, cm, metav1.CreateOptions{})
+	}
+	return err
+}
+
+func decodeData(raw map[string]string) (map[string][]SavedFilter, error) {
+	data := map[string][]SavedFilter{}
+	for key, value := range raw {
+		if !strings.HasPrefix(key, userKeyPrefix) {
+			continue
+		}
+		user, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(key, userKeyPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid saved filters key %s: %w", key, err)
+		}
+		var userFilters []SavedFilter
+		if err := json.Unmarshal([]byte(value), &userFilters); err != nil {
+			return nil, fmt.Errorf("invalid saved filters for key %s: %w", key, err)
+		}
+		data[string(user)] = userFilters
+	}
+	return data, nil
+}
+
+func encodeData(data map[string][]SavedFilter) (map[string]string, error) {
+	raw := make(map[string]string, len(data))
+	for user, userFilters := range data {
+		js, err := json.Marshal(userFilters)
+		if err != nil {
+			return nil, err
+		}
+		raw[userKey(user)] = string(js)
+	}
+	return raw, nil
+}
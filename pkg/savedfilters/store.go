@@ -0,0 +1,171 @@
+// Package savedfilters persists named filter sets per user, optionally shared with other users
+package savedfilters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+const (
+	maxNameLength     = 100
+	maxFiltersPerUser = 100
+)
+
+var (
+	ErrNotFound = errors.New("saved filter not found")
+	ErrInvalid  = errors.New("invalid saved filter")
+)
+
+// SavedFilter is a named filter set, using the flows filters syntax
+type SavedFilter struct {
+	Name        string `json:"name"`
+	Filters     string `json:"filters"`
+	Description string `json:"description,omitempty"`
+	// Shared filters are visible, but not editable, by other users
+	Shared bool `json:"shared"`
+	// Owner and UpdatedAt are set by the store
+	Owner     string    `json:"owner"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate checks the name and the filters syntax
+func (f *SavedFilter) Validate() error {
+	if f.Name == "" || len(f.Name) > maxNameLength {
+		return fmt.Errorf("%w: name must have between 1 and %d characters", ErrInvalid, maxNameLength)
+	}
+	if _, err := filters.Parse(f.Filters); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalid, err.Error())
+	}
+	return nil
+}
+
+type Store interface {
+	// List returns the filters of the user, followed by the filters shared by other users
+	List(ctx context.Context, user string) ([]SavedFilter, error)
+	// Save creates or replaces the user filter of the same name
+	Save(ctx context.Context, user string, filter SavedFilter) (*SavedFilter, error)
+	// Delete removes a filter of the user, returning ErrNotFound if it doesn't exist
+	Delete(ctx context.Context, user, name string) error
+}
+
+// backend loads and updates the filters of all users, mapped by user
+type backend interface {
+	read(ctx context.Context) (map[string][]SavedFilter, error)
+	update(ctx context.Context, mutate func(data map[string][]SavedFilter) error) error
+}
+
+type store struct {
+	backend backend
+	now     func() time.Time
+}
+
+func (s *store) List(ctx context.Context, user string) ([]SavedFilter, error) {
+	data, err := s.backend.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := append([]SavedFilter{}, data[user]...)
+	var shared []SavedFilter
+	for owner, userFilters := range data {
+		if owner == user {
+			continue
+		}
+		for _, f := range userFilters {
+			if f.Shared {
+				shared = append(shared, f)
+			}
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if shared[i].Owner != shared[j].Owner {
+			return shared[i].Owner < shared[j].Owner
+		}
+		return shared[i].Name < shared[j].Name
+	})
+	return append(list, shared...), nil
+}
+
+func (s *store) Save(ctx context.Context, user string, filter SavedFilter) (*SavedFilter, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	filter.Owner = user
+	filter.UpdatedAt = s.now().UTC()
+	err := s.backend.update(ctx, func(data map[string][]SavedFilter) error {
+		userFilters := data[user]
+		for i := range userFilters {
+			if userFilters[i].Name == filter.Name {
+				userFilters[i] = filter
+				return nil
+			}
+		}
+		if len(userFilters) >= maxFiltersPerUser {
+			return fmt.Errorf("%w: too many saved filters, max is %d", ErrInvalid, maxFiltersPerUser)
+		}
+		userFilters = append(userFilters, filter)
+		sort.Slice(userFilters, func(i, j int) bool { return userFilters[i].Name < userFilters[j].Name })
+		data[user] = userFilters
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+func (s *store) Delete(ctx context.Context, user, name string) error {
+	return s.backend.update(ctx, func(data map[string][]SavedFilter) error {
+		userFilters := data[user]
+		for i := range userFilters {
+			if userFilters[i].Name == name {
+				data[user] = append(userFilters[:i], userFilters[i+1:]...)
+				if len(data[user]) == 0 {
+					delete(data, user)
+				}
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// NewMemoryStore returns a non-persistent store, lost on restart
+func NewMemoryStore() Store {
+	return &store{backend: &memoryBackend{data: map[string][]SavedFilter{}}, now: time.Now}
+}
+
+type memoryBackend struct {
+	mutex sync.RWMutex
+	data  map[string][]SavedFilter
+}
+
+func (b *memoryBackend) read(_ context.Context) (map[string][]SavedFilter, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return copyData(b.data), nil
+}
+
+func (b *memoryBackend) update(_ context.Context, mutate func(data map[string][]SavedFilter) error) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	data := copyData(b.data)
+	if err := mutate(data); err != nil {
+		return err
+	}
+	b.data = data
+	return nil
+}
+
+func copyData(data map[string][]SavedFilter) map[string][]SavedFilter {
+	copied := make(map[string][]SavedFilter, len(data))
+	for user, userFilters := range data {
+		copied[user] = append([]SavedFilter{}, userFilters...)
+	}
+	return copied
+}
@@ -0,0 +1,73 @@
+package savedfilters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.TODO()
+	s := NewMemoryStore()
+
+	saved, err := s.Save(ctx, "alice", SavedFilter{Name: "dns", Filters: "DstPort=53"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", saved.Owner)
+	assert.False(t, saved.UpdatedAt.IsZero())
+	_, err = s.Save(ctx, "alice", SavedFilter{Name: "app", Filters: `SrcK8S_Namespace="app"`, Shared: true})
+	require.NoError(t, err)
+	_, err = s.Save(ctx, "bob", SavedFilter{Name: "mine", Filters: "Proto=6"})
+	require.NoError(t, err)
+
+	// own filters sorted by name, then shared ones
+	list, err := s.List(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "app", list[0].Name)
+	assert.Equal(t, "dns", list[1].Name)
+
+	list, err = s.List(ctx, "bob")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "mine", list[0].Name)
+	assert.Equal(t, "app", list[1].Name)
+	assert.Equal(t, "alice", list[1].Owner)
+
+	// replace
+	_, err = s.Save(ctx, "alice", SavedFilter{Name: "dns", Filters: "DstPort=5353"})
+	require.NoError(t, err)
+	list, err = s.List(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "DstPort=5353", list[1].Filters)
+
+	// shared filters can't be deleted by others
+	assert.ErrorIs(t, s.Delete(ctx, "bob", "app"), ErrNotFound)
+	require.NoError(t, s.Delete(ctx, "alice", "app"))
+	list, err = s.List(ctx, "bob")
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	_, err = s.Save(ctx, "alice", SavedFilter{Name: "", Filters: "DstPort=53"})
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = s.Save(ctx, "alice", SavedFilter{Name: "bad", Filters: `{"and":`})
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestConfigMapEncoding(t *testing.T) {
+	data := map[string][]SavedFilter{
+		"system:serviceaccount:ns:name": {{Name: "dns", Filters: "DstPort=53"}},
+	}
+	raw, err := encodeData(data)
+	require.NoError(t, err)
+	for key := range raw {
+		assert.Regexp(t, `^[-._a-zA-Z0-9]+$`, key)
+	}
+
+	// other keys are ignored
+	raw["readme"] = "managed by the console plugin"
+	decoded, err := decodeData(raw)
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+}
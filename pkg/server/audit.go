@@ -0,0 +1,96 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/handler"
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+)
+
+// auditParams are the query parameters describing what was queried, after the deprecated ones are migrated
+var auditParams = []string{
+	"filters", "startTime", "endTime", "timeRange", "limit", "reporter", "recordType", "ports", "service", "workload",
+	"type", "scope", "groups", "cursor", "namespaceLimit", "sortBy", "format", "field", "fields", "prefix", "lookback",
+}
+
+// auditor logs who queried which flows, one JSON entry per request. The log is written to a dedicated sink,
+// separate from the application logs.
+type auditor struct {
+	log     *logrus.Logger
+	checker auth.Checker
+}
+
+// newAuditor returns nil when out is nil, disabling the audit log
+func newAuditor(out io.Writer, checker auth.Checker) *auditor {
+	if out == nil {
+		return nil
+	}
+	log := logrus.New()
+	log.SetOutput(out)
+	log.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	return &auditor{log: log, checker: checker}
+}
+
+// audit logs the requests served by next
+func (a *auditor) audit(next http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		// the user is empty when it can't be reviewed, e.g. rejected requests
+		user, _ := a.checker.GetUser(r.Context(), r.Header)
+		entry := logrus.Fields{
+			"user":        user,
+			"path":        r.URL.Path,
+			"status":      rec.status,
+			"resultBytes": rec.bytes,
+			"durationMs":  time.Since(startTime).Milliseconds(),
+		}
+		if id := httpclient.GetRequestID(r.Context()); id != "" {
+			entry[httpclient.RequestIDField] = id
+		}
+		params := handler.EffectiveParams(r.URL.Query())
+		for _, name := range auditParams {
+			if value := params.Get(name); value != "" {
+				entry[name] = value
+			}
+		}
+		if namespaces, restricted := auth.GetAllowedNamespaces(r.Context()); restricted {
+			entry["allowedNamespaces"] = namespaces
+		}
+		a.log.WithFields(entry).Info("flows query")
+	}
+}
+
+// responseRecorder captures the status and size of responses
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (ar *responseRecorder) WriteHeader(code int) {
+	ar.status = code
+	ar.ResponseWriter.WriteHeader(code)
+}
+
+func (ar *responseRecorder) Write(b []byte) (int, error) {
+	n, err := ar.ResponseWriter.Write(b)
+	ar.bytes += n
+	return n, err
+}
+
+func (ar *responseRecorder) Flush() {
+	if f, ok := ar.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
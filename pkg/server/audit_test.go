@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+)
+
+func TestAudit(t *testing.T) {
+	authM := &authMock{}
+	authM.On("GetUser", mock.Anything, mock.Anything).Return("alice", nil)
+	var out bytes.Buffer
+	a := newAuditor(&out, authM)
+
+	h := a.audit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(`{"result":[]}`))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/loki/flows?filters=SrcK8S_Namespace%3D%22a%22&startTime=100&endTime=200&limit=50&other=x", nil)
+	req = req.WithContext(auth.WithAllowedNamespaces(req.Context(), []string{"a"}))
+	h(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "alice", entry["user"])
+	assert.Equal(t, "/api/loki/flows", entry["path"])
+	assert.Equal(t, `SrcK8S_Namespace="a"`, entry["filters"])
+	assert.Equal(t, "100", entry["startTime"])
+	assert.Equal(t, "200", entry["endTime"])
+	assert.Equal(t, "50", entry["limit"])
+	assert.Equal(t, float64(http.StatusPartialContent), entry["status"])
+	assert.Equal(t, float64(13), entry["resultBytes"])
+	assert.Equal(t, []interface{}{"a"}, entry["allowedNamespaces"])
+	assert.Contains(t, entry, "durationMs")
+	assert.NotContains(t, entry, "other")
+}
+
+func TestAudit_DeprecatedParams(t *testing.T) {
+	authM := &authMock{}
+	authM.On("GetUser", mock.Anything, mock.Anything).Return("alice", nil)
+	var out bytes.Buffer
+	h := newAuditor(&out, authM).audit(func(w http.ResponseWriter, r *http.Request) {})
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/loki/flows?filter=DstPort%3D53&timerange=300&cursor=abc", nil))
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "DstPort=53", entry["filters"])
+	assert.Equal(t, "300", entry["timeRange"])
+	assert.Equal(t, "abc", entry["cursor"])
+	assert.NotContains(t, entry, "filter")
+}
+
+func TestAudit_Disabled(t *testing.T) {
+	called := false
+	h := newAuditor(nil, &authMock{}).audit(func(w http.ResponseWriter, r *http.Request) { called = true })
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/loki/flows", nil))
+	assert.True(t, called)
+}
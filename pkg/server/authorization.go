@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/handler"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+)
+
+var errNoNamespace = errors.New("no namespace access: flows are only visible in namespaces where pods can be read")
+
+// namespaceAuthorization restricts the requests of users without cluster-wide access to their namespaces.
+// A nil authorizer leaves requests unrestricted.
+func namespaceAuthorization(authorizer auth.NamespaceAuthorizer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if authorizer == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			namespaces, all, err := authorizer.AllowedNamespaces(r.Context(), r.Header)
+			if err != nil {
+				handler.WriteForbidden(w, fmt.Errorf("cannot check namespaces access: %w", err))
+				return
+			}
+			if all {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(namespaces) == 0 {
+				handler.WriteForbidden(w, errNoNamespace)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithAllowedNamespaces(r.Context(), namespaces)))
+		})
+	}
+}
+
+// clusterWide rejects the requests restricted to some namespaces
+func clusterWide(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, restricted := auth.GetAllowedNamespaces(r.Context()); restricted {
+			handler.WriteForbidden(w, errors.New("cluster-wide access is required"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// adminOnly restricts the admin endpoints to the users passing the checker, e.g. cluster admins, in addition to
+// requiring cluster-wide access. A nil checker only requires cluster-wide access.
+func adminOnly(checker auth.Checker) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if checker == nil {
+			return clusterWide(next)
+		}
+		return clusterWide(func(w http.ResponseWriter, r *http.Request) {
+			if err := checker.CheckAuth(r.Context(), r.Header); err != nil {
+				handler.WriteForbidden(w, fmt.Errorf("admin access is required: %w", err))
+				return
+			}
+			next(w, r)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+	// minCompressSize is the response size under which compression isn't worth it
+	minCompressSize = 1400
+)
+
+var (
+	gzipWriters  = sync.Pool{New: func() interface{} { w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression); return w }}
+	flateWriters = sync.Pool{New: func() interface{} { w, _ := flate.NewWriter(nil, flate.DefaultCompression); return w }}
+)
+
+// compression encodes responses with gzip or deflate, as accepted by clients
+func compression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks gzip, else deflate, when accepted with a non-zero quality
+func negotiateEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if v, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err != nil || v <= 0 {
+				continue
+			}
+		}
+		accepted[name] = true
+	}
+	switch {
+	case accepted[encodingGzip], accepted["*"]:
+		return encodingGzip
+	case accepted[encodingDeflate]:
+		return encodingDeflate
+	}
+	return ""
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriter buffers the beginning of the response to leave small ones uncompressed,
+// then compresses everything written
+type compressWriter struct {
+	http.ResponseWriter
+	encoding   string
+	code       int
+	buf        []byte
+	decided    bool
+	compressor compressor
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided || cw.code != 0 {
+		return
+	}
+	cw.code = code
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		// no body
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		if !cw.compressible() {
+			cw.start(false)
+		} else {
+			cw.buf = append(cw.buf, b...)
+			if len(cw.buf) < minCompressSize {
+				return len(b), nil
+			}
+			if err := cw.startWithBuffer(true); err != nil {
+				return 0, err
+			}
+			return len(b), nil
+		}
+	}
+	if cw.compressor != nil {
+		return cw.compressor.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush compresses even small responses, as flushing is a hint of a streamed response
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.startWithBuffer(cw.compressible()); err != nil {
+			return
+		}
+	}
+	if cw.compressor != nil {
+		if err := cw.compressor.Flush(); err != nil {
+			slog.WithError(err).Debug("cannot flush compressed response")
+			return
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) compressible() bool {
+	h := cw.ResponseWriter.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	// already compressed formats
+	ct := h.Get("Content-Type")
+	return !(strings.HasPrefix(ct, "image/") && !strings.HasPrefix(ct, "image/svg")) && !strings.HasPrefix(ct, "font/woff")
+}
+
+func (cw *compressWriter) start(compress bool) {
+	cw.decided = true
+	if compress {
+		h := cw.ResponseWriter.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		if cw.encoding == encodingGzip {
+			cw.compressor = gzipWriters.Get().(*gzip.Writer)
+		} else {
+			cw.compressor = flateWriters.Get().(*flate.Writer)
+		}
+		cw.compressor.Reset(cw.ResponseWriter)
+	}
+	if cw.code == 0 {
+		cw.code = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.code)
+}
+
+func (cw *compressWriter) startWithBuffer(compress bool) error {
+	cw.start(compress)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.compressor != nil {
+		_, err = cw.compressor.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		// small response, or nothing written
+		if err := cw.startWithBuffer(false); err != nil {
+			slog.WithError(err).Debug("cannot write response")
+		}
+		return
+	}
+	if cw.compressor == nil {
+		return
+	}
+	if err := cw.compressor.Close(); err != nil {
+		slog.WithError(err).Debug("cannot close compressed response")
+	}
+	if cw.encoding == encodingGzip {
+		gzipWriters.Put(cw.compressor)
+	} else {
+		flateWriters.Put(cw.compressor)
+	}
+	cw.compressor = nil
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsHeader applies the CORS policy to requests from other origins, e.g. a standalone frontend in development.
+// Preflight requests are answered directly, as they don't carry credentials.
+func corsHeader(cfg *Config) func(next http.Handler) http.Handler {
+	allowAny := false
+	allowed := map[string]struct{}{}
+	for _, origin := range strings.Split(cfg.CORSAllowOrigin, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "*" {
+			allowAny = true
+		} else if origin != "" {
+			allowed[origin] = struct{}{}
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			headers := w.Header()
+			_, isAllowed := allowed[origin]
+			switch {
+			case allowAny && !cfg.CORSAllowCredentials:
+				headers.Set("Access-Control-Allow-Origin", "*")
+			case isAllowed:
+				// credentials are never allowed to any origin: only listed origins are sent back
+				headers.Set("Access-Control-Allow-Origin", origin)
+				headers.Add("Vary", "Origin")
+				if cfg.CORSAllowCredentials {
+					headers.Set("Access-Control-Allow-Credentials", "true")
+				}
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.CORSExposeHeaders != "" {
+				headers.Set("Access-Control-Expose-Headers", cfg.CORSExposeHeaders)
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				if cfg.CORSAllowMethods != "" {
+					headers.Set("Access-Control-Allow-Methods", cfg.CORSAllowMethods)
+				}
+				if cfg.CORSAllowHeaders != "" {
+					headers.Set("Access-Control-Allow-Headers", cfg.CORSAllowHeaders)
+				}
+				if cfg.CORSMaxAge != "" {
+					headers.Set("Access-Control-Max-Age", cfg.CORSMaxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func corsRequest(t *testing.T, cfg *Config, method, origin string, header http.Header) (*httptest.ResponseRecorder, bool) {
+	called := false
+	h := corsHeader(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	req := httptest.NewRequest(method, "/api/loki/flows", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, called
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	cfg := &Config{CORSAllowOrigin: "*", CORSExposeHeaders: "Retry-After"}
+
+	rec, called := corsRequest(t, cfg, http.MethodGet, "http://localhost:9000", nil)
+	assert.True(t, called)
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Retry-After", rec.Header().Get("Access-Control-Expose-Headers"))
+
+	// not a cross-origin request
+	rec, called = corsRequest(t, cfg, http.MethodGet, "", nil)
+	assert.True(t, called)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS_AllowedOrigins(t *testing.T) {
+	cfg := &Config{CORSAllowOrigin: "http://localhost:9000, https://tool.example.com", CORSAllowCredentials: true}
+
+	rec, called := corsRequest(t, cfg, http.MethodGet, "https://tool.example.com", nil)
+	assert.True(t, called)
+	assert.Equal(t, "https://tool.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+
+	// other origins get no CORS headers, hence are blocked by browsers
+	rec, called = corsRequest(t, cfg, http.MethodGet, "https://evil.example.com", nil)
+	assert.True(t, called)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORS_AnyOriginWithCredentials(t *testing.T) {
+	cfg := &Config{CORSAllowOrigin: "*, http://localhost:9000", CORSAllowCredentials: true}
+
+	// * is ignored: credentials are only allowed to listed origins
+	rec, called := corsRequest(t, cfg, http.MethodGet, "https://evil.example.com", nil)
+	assert.True(t, called)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	rec, _ = corsRequest(t, cfg, http.MethodGet, "http://localhost:9000", nil)
+	assert.Equal(t, "http://localhost:9000", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	cfg := &Config{
+		CORSAllowOrigin:  "http://localhost:9000",
+		CORSAllowMethods: "GET, PUT",
+		CORSAllowHeaders: "Authorization",
+		CORSMaxAge:       "600",
+	}
+
+	rec, called := corsRequest(t, cfg, http.MethodOptions, "http://localhost:9000", http.Header{"Access-Control-Request-Method": {"PUT"}})
+	assert.False(t, called)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "http://localhost:9000", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, PUT", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+
+	// not allowed: left to the next handlers
+	_, called = corsRequest(t, cfg, http.MethodOptions, "https://evil.example.com", http.Header{"Access-Control-Request-Method": {"PUT"}})
+	assert.True(t, called)
+}
+
+func TestCORS_PreflightBeforeAuth(t *testing.T) {
+	authM := &authMock{}
+	authM.On("CheckAuth", mock.Anything, mock.Anything).Return(assert.AnError)
+	cfg := &Config{CORSAllowOrigin: "*", CORSAllowMethods: "GET"}
+	router := setupRoutes(cfg, authM)
+	router.Use(corsHeader(cfg))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/loki/flows", nil)
+	req.Header.Set("Origin", "http://localhost:9000")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "GET", rec.Header().Get("Access-Control-Allow-Methods"))
+	authM.AssertNotCalled(t, "CheckAuth", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/gorilla/mux"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("lokiInFlightQueries", expvar.Func(func() interface{} {
+		return httpclient.InFlight()
+	}))
+}
+
+// debugRoutes serves the pprof profiles, e.g. /api/admin/debug/pprof/heap, and the expvar snapshot at
+// /api/admin/debug/vars
+func debugRoutes(api *mux.Router, admin func(http.HandlerFunc) http.HandlerFunc) {
+	api.HandleFunc("/admin/debug/vars", admin(expvar.Handler().ServeHTTP))
+	api.HandleFunc("/admin/debug/pprof/", admin(pprof.Index))
+	api.HandleFunc("/admin/debug/pprof/cmdline", admin(pprof.Cmdline))
+	api.HandleFunc("/admin/debug/pprof/profile", admin(pprof.Profile))
+	api.HandleFunc("/admin/debug/pprof/symbol", admin(pprof.Symbol))
+	api.HandleFunc("/admin/debug/pprof/trace", admin(pprof.Trace))
+	// pprof.Index only serves the named profiles under /debug/pprof/
+	api.HandleFunc("/admin/debug/pprof/{profile}", admin(func(w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(mux.Vars(r)["profile"]).ServeHTTP(w, r)
+	}))
+}
@@ -28,11 +28,17 @@ func StartMetrics(cfg *MetricsConfig) {
 
 	// The Handler function provides a default handler to expose metrics
 	// via an HTTP server. "/metrics" is the usual endpoint for that.
-	http.Handle("/metrics", promhttp.Handler())
+	// A dedicated mux keeps the pprof and expvar handlers, registered on the default one, off this server.
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	promServer.Handler = mux
 
 	if cfg.CertFile != "" && cfg.PrivateKeyFile != "" {
+		if err := useReloadedCertificate(promServer, cfg.CertFile, cfg.PrivateKeyFile); err != nil {
+			mlog.WithError(err).Fatal("cannot load server certificate")
+		}
 		mlog.Infof("listening on https://:%d", cfg.Port)
-		panic(promServer.ListenAndServeTLS(cfg.CertFile, cfg.PrivateKeyFile))
+		panic(promServer.ListenAndServeTLS("", ""))
 	} else {
 		mlog.Infof("listening on http://:%d", cfg.Port)
 		panic(promServer.ListenAndServe())
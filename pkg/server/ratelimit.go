@@ -0,0 +1,98 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/handler"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+)
+
+// limiterIdleTTL is the inactivity duration after which a client limiter is forgotten
+const limiterIdleTTL = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter is a token bucket per client, identified by its token or else by its address
+type rateLimiter struct {
+	mutex     sync.Mutex
+	rate      rate.Limit
+	burst     int
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// newRateLimiter returns nil when rateLimit is not positive
+func newRateLimiter(rateLimit float64, burst int) *rateLimiter {
+	if rateLimit <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate.Limit(rateLimit), burst: burst, clients: map[string]*clientLimiter{}, now: time.Now}
+}
+
+func clientKey(r *http.Request) string {
+	if token := r.Header.Get(auth.AuthHeader); token != "" {
+		h := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(h[:])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// reserve takes a token for the client, returning how long to wait for it when none is available
+func (l *rateLimiter) reserve(key string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) > limiterIdleTTL {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > limiterIdleTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	c, ok := l.clients[key]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	res := c.limiter.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		// rejected requests don't consume tokens
+		res.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// limit rejects with 429 the requests of clients exceeding their rate, without calling next
+func (l *rateLimiter) limit(next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if delay := l.reserve(clientKey(r)); delay > 0 {
+			handler.WriteTooManyRequests(w, delay)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1680000000, 0)
+	limiter := newRateLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+	calls := 0
+	h := limiter.limit(func(w http.ResponseWriter, r *http.Request) { calls++ })
+
+	send := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/loki/flows", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	// burst
+	assert.Equal(t, http.StatusOK, send("alice").Code)
+	assert.Equal(t, http.StatusOK, send("alice").Code)
+	rec := send("alice")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), `"code":"RATE_LIMITED"`)
+	assert.Equal(t, 2, calls)
+
+	// other clients are not affected
+	assert.Equal(t, http.StatusOK, send("bob").Code)
+	assert.Equal(t, http.StatusOK, send("").Code)
+
+	// refilled
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, send("alice").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("alice").Code)
+
+	// idle clients are forgotten
+	now = now.Add(2 * limiterIdleTTL)
+	send("bob")
+	assert.Len(t, limiter.clients, 1)
+}
+
+func TestRateLimit_Disabled(t *testing.T) {
+	limiter := newRateLimiter(0, 10)
+	assert.Nil(t, limiter)
+	calls := 0
+	h := limiter.limit(func(w http.ResponseWriter, r *http.Request) { calls++ })
+	for i := 0; i < 100; i++ {
+		h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/loki/flows", nil))
+	}
+	assert.Equal(t, 100, calls)
+}
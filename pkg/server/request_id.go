@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+)
+
+// requestID accepts the inbound request ID, or generates one, and attaches it to the request context, so that it is
+// added to log lines and forwarded to Loki. It is returned in the response headers.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(httpclient.RequestIDHeader)
+		if !httpclient.ValidRequestID(id) {
+			id = httpclient.NewRequestID()
+		}
+		w.Header().Set(httpclient.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(httpclient.WithRequestID(r.Context(), id)))
+	})
+}
@@ -1,11 +1,9 @@
 package server
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/handler"
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
@@ -13,34 +11,67 @@ import (
 
 func setupRoutes(cfg *Config, authChecker auth.Checker) *mux.Router {
 	r := mux.NewRouter()
+	r.Use(requestID)
+	r.Use(traced(cfg.Tracer))
+	limiter := newRateLimiter(cfg.RateLimit, cfg.RateLimitBurst)
+	auditor := newAuditor(cfg.AuditLog, authChecker)
+	admin := adminOnly(cfg.AdminChecker)
 
 	api := r.PathPrefix("/api").Subrouter()
 	api.Use(func(orig http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if err := authChecker.CheckAuth(context.TODO(), r.Header); err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
-				_, err2 := w.Write([]byte(err.Error()))
-				if err2 != nil {
-					logrus.Errorf("Error while responding an error: %v (initial was: %v)", err2, err)
-				}
+			if err := authChecker.CheckAuth(r.Context(), r.Header); err != nil {
+				handler.WriteUnauthorized(w, err)
 				return
 			}
 			orig.ServeHTTP(w, r)
 		})
 	})
+	api.Use(namespaceAuthorization(cfg.NamespaceAuthorizer))
+	api.Use(handler.RequestTimeout(&cfg.Loki))
 	api.HandleFunc("/status", handler.Status)
+	api.HandleFunc("/openapi.json", handler.GetOpenAPISpec(cfg.Version))
+	api.HandleFunc("/status/ingestion", handler.GetIngestionStatus(&cfg.Loki))
 	api.HandleFunc("/loki/ready", handler.LokiReady(&cfg.Loki))
-	api.HandleFunc("/loki/metrics", handler.LokiMetrics(&cfg.Loki))
+	api.HandleFunc("/loki/metrics", clusterWide(handler.LokiMetrics(&cfg.Loki)))
 	api.HandleFunc("/loki/buildinfo", handler.LokiBuildInfos(&cfg.Loki))
-	api.HandleFunc("/loki/config/limits", handler.LokiConfig(&cfg.Loki, "limits_config"))
-	api.HandleFunc("/loki/flows", handler.GetFlows(&cfg.Loki))
-	api.HandleFunc("/loki/export", handler.ExportFlows(&cfg.Loki))
-	api.HandleFunc("/loki/topology", handler.GetTopology(&cfg.Loki))
+	api.HandleFunc("/loki/config/limits", clusterWide(handler.LokiConfig(&cfg.Loki, "limits_config")))
+	api.HandleFunc("/admin/slow-queries", admin(handler.GetSlowQueries(&cfg.Loki)))
+	api.HandleFunc("/admin/loglevel", admin(handler.LogLevel))
+	if cfg.Debug {
+		debugRoutes(api, admin)
+	}
+	api.HandleFunc("/loki/flows", limiter.limit(auditor.audit(handler.GetFlows(&cfg.Loki))))
+	api.HandleFunc("/loki/export", limiter.limit(auditor.audit(handler.ExportFlows(&cfg.Loki))))
+	api.HandleFunc("/loki/flows/estimate", auditor.audit(handler.EstimateFlows(&cfg.Loki)))
+	api.HandleFunc("/loki/flows/validate", handler.ValidateFlows(&cfg.Loki))
+	api.HandleFunc("/loki/flows/latest", auditor.audit(handler.GetLatestFlowTime(&cfg.Loki)))
+	api.HandleFunc("/loki/cardinality", limiter.limit(auditor.audit(handler.GetCardinality(&cfg.Loki))))
+	api.HandleFunc("/loki/topology", limiter.limit(auditor.audit(handler.GetTopology(&cfg.Loki))))
+	api.HandleFunc("/loki/topology/matrix", limiter.limit(auditor.audit(handler.GetTopologyMatrix(&cfg.Loki))))
+	api.HandleFunc("/resources/values", limiter.limit(auditor.audit(handler.GetValues(&cfg.Loki))))
 	api.HandleFunc("/resources/namespaces", handler.GetNamespaces(&cfg.Loki))
 	api.HandleFunc("/resources/namespace/{namespace}/kind/{kind}/names", handler.GetNames(&cfg.Loki))
 	api.HandleFunc("/resources/kind/{kind}/names", handler.GetNames(&cfg.Loki))
-	api.HandleFunc("/frontend-config", handler.GetConfig(cfg.FrontendConfig))
+	api.HandleFunc("/frontend-config", handler.GetConfig(cfg.FrontendConfig, &cfg.Loki))
+	api.HandleFunc("/frontend-config/fields", handler.GetFieldsSchema(&cfg.Loki))
+	api.HandleFunc("/frontend-config/columns", handler.GetColumns(cfg.FrontendConfig))
+	if cfg.SavedFilters != nil {
+		savedFilters := handler.SavedFilters(cfg.SavedFilters, authChecker.GetUser)
+		api.HandleFunc("/filters", savedFilters)
+		api.HandleFunc("/filters/{name}", savedFilters)
+	}
+	if cfg.Preferences != nil {
+		api.HandleFunc("/preferences", handler.Preferences(cfg.Preferences, authChecker.GetUser))
+	}
+	if cfg.Permalinks != nil {
+		links := handler.Permalinks(cfg.Permalinks)
+		api.HandleFunc("/permalinks", links)
+		api.HandleFunc("/permalinks/{id}", links)
+	}
 
+	r.HandleFunc("/healthz", handler.Healthz)
+	r.HandleFunc("/readyz", handler.Readyz(&cfg.Loki, cfg.ReadinessCacheTTL))
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./web/dist/")))
 	return r
 }
@@ -1,34 +1,102 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/permalinks"
+	"github.com/netobserv/network-observability-console-plugin/pkg/preferences"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedfilters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/tracing"
 )
 
 var slog = logrus.WithField("module", "server")
 
 type Config struct {
-	Port             int
-	CertFile         string
-	PrivateKeyFile   string
-	CORSAllowOrigin  string
-	CORSAllowMethods string
-	CORSAllowHeaders string
-	CORSMaxAge       string
-	Loki             loki.Config
-	FrontendConfig   string
+	// Version is the build version, reported in the OpenAPI specification
+	Version        string
+	Port           int
+	CertFile       string
+	PrivateKeyFile string
+	// CORSAllowOrigin is a comma-separated list of allowed origins, "*" allowing any
+	CORSAllowOrigin      string
+	CORSAllowMethods     string
+	CORSAllowHeaders     string
+	CORSExposeHeaders    string
+	CORSAllowCredentials bool
+	CORSMaxAge           string
+	// RateLimit is the number of flows, topology, cardinality and values requests per second allowed per client,
+	// above RateLimitBurst (0 means no limit)
+	RateLimit      float64
+	RateLimitBurst int
+	// AuditLog receives the audit entries of flows queries, nil when disabled
+	AuditLog io.Writer
+	// NamespaceAuthorizer restricts users to the flows of their namespaces, nil when disabled
+	NamespaceAuthorizer auth.NamespaceAuthorizer
+	Loki                loki.Config
+	FrontendConfig      string
+	// SavedFilters is nil when saved filters are disabled
+	SavedFilters savedfilters.Store
+	// Permalinks is nil when permalinks are disabled
+	Permalinks permalinks.Store
+	// Preferences is nil when users preferences are disabled
+	Preferences preferences.Store
+	// AdminChecker protects the admin endpoints, such as the log level, nil only requiring cluster-wide access
+	AdminChecker auth.Checker
+	// Tracer exports the spans of requests, nil when tracing is disabled
+	Tracer *tracing.Tracer
+	// Debug serves the pprof profiles and an expvar snapshot at /api/admin/debug, for admins
+	Debug bool
+	// ReadinessCacheTTL is the duration during which the Loki probe result of /readyz is reused
+	ReadinessCacheTTL time.Duration
+	// ShutdownDelay is the time during which requests are still served on SIGTERM, /readyz failing, so that the pod
+	// is removed from the service endpoints before it stops accepting connections
+	ShutdownDelay time.Duration
+	// ShutdownTimeout is the time left to in-flight requests to complete once the shutdown delay is over, before
+	// they are cancelled
+	ShutdownTimeout time.Duration
+	// MetricsFlushDelay keeps the metrics endpoint served after the requests are drained, for a last scrape
+	MetricsFlushDelay time.Duration
+	// Reloads receives new configurations, applied to the requests received afterwards, in-flight requests keeping
+	// theirs. The listening settings (port and certificates) and the shutdown settings aren't reloaded.
+	Reloads <-chan *Config
+}
+
+// flushTimeout bounds the export of pending traces on shutdown
+const flushTimeout = 5 * time.Second
+
+// writeTimeoutMargin leaves time to write the response of a request that reached its timeout, such as the timeout
+// error, before the connection is closed
+const writeTimeoutMargin = 10 * time.Second
+
+// writeTimeout returns the server write timeout: the longest request, plus the margin to write its response. Without
+// max request timeout, requests aren't bounded by the server either.
+func writeTimeout(maxRequestTimeout time.Duration) time.Duration {
+	if maxRequestTimeout <= 0 {
+		return 0
+	}
+	return maxRequestTimeout + writeTimeoutMargin
 }
 
 func Start(cfg *Config, authChecker auth.Checker) {
-	router := setupRoutes(cfg, authChecker)
-	router.Use(corsHeader(cfg))
+	router := &reloadableHandler{}
+	router.set(newHandler(cfg, authChecker))
+	if cfg.Reloads != nil {
+		go router.watch(cfg.Reloads, authChecker)
+	}
 
 	// Clients must use TLS 1.2 or higher
 	tlsConfig := &tls.Config{
@@ -40,35 +108,122 @@ func Start(cfg *Config, authChecker auth.Checker) {
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		TLSConfig:    tlsConfig,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: writeTimeout(cfg.Loki.MaxRequestTimeout),
 	}
 
+	listen := httpServer.ListenAndServe
 	if cfg.CertFile != "" && cfg.PrivateKeyFile != "" {
+		if err := useReloadedCertificate(httpServer, cfg.CertFile, cfg.PrivateKeyFile); err != nil {
+			slog.WithError(err).Fatal("cannot load server certificate")
+		}
 		slog.Infof("listening on https://:%d", cfg.Port)
-		panic(httpServer.ListenAndServeTLS(cfg.CertFile, cfg.PrivateKeyFile))
+		listen = func() error { return httpServer.ListenAndServeTLS("", "") }
 	} else {
 		slog.Infof("listening on http://:%d", cfg.Port)
-		panic(httpServer.ListenAndServe())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	if err := run(ctx, httpServer, cfg, listen); err != nil {
+		slog.WithError(err).Fatal("server error")
+	}
+}
+
+// run serves until ctx is done, then fails readiness during the shutdown delay, stops accepting requests and waits
+// for the in-flight ones during the shutdown timeout, before cancelling them. Pending traces and audit entries are
+// flushed before returning, after the metrics flush delay.
+func run(ctx context.Context, httpServer *http.Server, cfg *Config, listen func() error) error {
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	httpServer.BaseContext = func(net.Listener) context.Context { return requestsCtx }
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- listen()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	if d, ok := httpServer.Handler.(drainer); ok {
+		d.drain()
+	}
+	if cfg.ShutdownDelay > 0 {
+		slog.Infof("shutting down in %s, failing readiness", cfg.ShutdownDelay)
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(cfg.ShutdownDelay):
+		}
+	}
+	slog.Info("shutting down, draining in-flight requests")
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(drainCtx); err != nil {
+		// cancelling the requests context aborts their Loki queries
+		slog.WithError(err).Warn("in-flight requests did not complete in time, cancelling them")
+		cancelRequests()
+		_ = httpServer.Close()
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancelFlush()
+	if err := cfg.Tracer.Shutdown(flushCtx); err != nil {
+		slog.WithError(err).Warn("cannot export pending traces")
+	}
+	if syncer, ok := cfg.AuditLog.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			slog.WithError(err).Warn("cannot flush audit log")
+		}
+	}
+	if cfg.MetricsFlushDelay > 0 {
+		slog.Infof("waiting %s for a last metrics scrape", cfg.MetricsFlushDelay)
+		time.Sleep(cfg.MetricsFlushDelay)
+	}
+	slog.Info("server stopped")
+	return nil
+}
+
+// drainer fails readiness, e.g. at shutdown
+type drainer interface {
+	drain()
+}
+
+func newHandler(cfg *Config, authChecker auth.Checker) http.Handler {
+	router := setupRoutes(cfg, authChecker)
+	router.Use(corsHeader(cfg))
+	router.Use(compression)
+	return router
+}
+
+// reloadableHandler routes each request with the configuration current when it is received
+type reloadableHandler struct {
+	handler  atomic.Value
+	draining atomic.Bool
+}
+
+func (h *reloadableHandler) drain() {
+	h.draining.Store(true)
+}
+
+func (h *reloadableHandler) set(handler http.Handler) {
+	h.handler.Store(&handler)
+}
+
+func (h *reloadableHandler) watch(reloads <-chan *Config, authChecker auth.Checker) {
+	for cfg := range reloads {
+		h.set(newHandler(cfg, authChecker))
+		slog.Info("configuration reloaded")
 	}
 }
 
-func corsHeader(cfg *Config) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			headers := w.Header()
-			if cfg.CORSAllowOrigin != "" {
-				headers.Set("Access-Control-Allow-Origin", cfg.CORSAllowOrigin)
-			}
-			if cfg.CORSAllowHeaders != "" {
-				headers.Set("Access-Control-Allow-Header", cfg.CORSAllowHeaders)
-			}
-			if cfg.CORSAllowMethods != "" {
-				headers.Set("Access-Control-Allow-Methods", cfg.CORSAllowMethods)
-			}
-			if cfg.CORSMaxAge != "" {
-				headers.Set("Access-Control-Max-Age", cfg.CORSMaxAge)
-			}
-			next.ServeHTTP(w, r)
-		})
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/readyz" && h.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("shutting down"))
+		return
 	}
+	(*h.handler.Load().(*http.Handler)).ServeHTTP(w, r)
 }
@@ -9,6 +9,7 @@ import (
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -23,13 +24,19 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/permalinks"
+	"github.com/netobserv/network-observability-console-plugin/pkg/preferences"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedfilters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/tracing"
 )
 
 const (
@@ -136,11 +143,11 @@ func TestServerUnauthorized(t *testing.T) {
 
 	msg, err := getRequestResults(t, httpClient, serverURL+"/api/status")
 	require.Error(t, err)
-	require.Equal(t, "missing Authorization header", msg)
+	require.JSONEq(t, `{"code":"UNAUTHORIZED","message":"missing Authorization header"}`, msg)
 
 	msg, err = getRequestResults(t, httpClient, serverURL+"/api/loki/flows")
 	require.Error(t, err)
-	require.Equal(t, "missing Authorization header", msg)
+	require.JSONEq(t, `{"code":"UNAUTHORIZED","message":"missing Authorization header"}`, msg)
 }
 
 func TestSecureComm(t *testing.T) {
@@ -247,6 +254,55 @@ func TestSecureComm(t *testing.T) {
 	}
 }
 
+func TestSecureComm_CertificateReload(t *testing.T) {
+	testPort, err := getFreePort(testHostname)
+	require.NoError(t, err)
+	tmpDir := t.TempDir()
+	certFile := tmpDir + "/server.cert"
+	keyFile := tmpDir + "/server.key"
+	testServerHostPort := fmt.Sprintf("%v:%v", testHostname, testPort)
+	require.NoError(t, generateCertificate(t, certFile, keyFile, testServerHostPort))
+
+	tmpDirAssets := prepareServerAssets(t)
+	defer os.RemoveAll(tmpDirAssets)
+	authM := authMock{}
+	authM.MockGranted()
+	go func() {
+		Start(&Config{
+			CertFile:       certFile,
+			PrivateKeyFile: keyFile,
+			Port:           testPort,
+			Loki: loki.Config{
+				URL: &url.URL{Scheme: "http", Host: "localhost:3100"},
+			},
+		}, &authM)
+	}()
+
+	serverURL := fmt.Sprintf("https://%s/api/status", testServerHostPort)
+	httpClient := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}}
+	checkHTTPReady(httpClient, serverURL)
+	servedSerial := func() *big.Int {
+		resp, err := httpClient.Get(serverURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber
+	}
+	first := servedSerial()
+
+	// WHEN the certificate is renewed
+	require.NoError(t, generateCertificate(t, certFile, keyFile, testServerHostPort))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+
+	// THEN new connections get the new certificate
+	second := servedSerial()
+	assert.NotEqual(t, first, second)
+}
+
 func TestLokiConfiguration(t *testing.T) {
 	// GIVEN a Loki service
 	lokiMock := httpMock{}
@@ -289,6 +345,84 @@ func TestLokiConfiguration(t *testing.T) {
 	assert.NotNil(t, qr.Result)
 }
 
+func TestLokiConfiguration_RequestID(t *testing.T) {
+	// GIVEN a Loki service
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"streams","result":[]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+	lokiURL, err := url.Parse(lokiSvc.URL)
+	require.NoError(t, err)
+	backendSvc := httptest.NewServer(setupRoutes(&Config{Loki: loki.Config{URL: lokiURL, Timeout: time.Second}}, authM))
+	defer backendSvc.Close()
+
+	// WHEN a request comes with a request ID
+	req, err := http.NewRequest(http.MethodGet, backendSvc.URL+"/api/loki/flows", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-ID", "console-1234")
+	resp, err := backendSvc.Client().Do(req)
+	require.NoError(t, err)
+
+	// THEN it is returned and forwarded to Loki
+	assert.Equal(t, "console-1234", resp.Header.Get("X-Request-ID"))
+	require.Len(t, lokiMock.Calls, 1)
+	assert.Equal(t, "console-1234", lokiMock.Calls[0].Arguments[1].(*http.Request).Header.Get("X-Request-ID"))
+
+	// AND a request ID is generated when missing
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flows")
+	require.NoError(t, err)
+	generated := resp.Header.Get("X-Request-ID")
+	assert.Len(t, generated, 32)
+	require.Len(t, lokiMock.Calls, 2)
+	assert.Equal(t, generated, lokiMock.Calls[1].Arguments[1].(*http.Request).Header.Get("X-Request-ID"))
+}
+
+func TestLokiConfiguration_Tracing(t *testing.T) {
+	// GIVEN a Loki service and a tracing collector
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"streams","result":[]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	var collected []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collected, _ = io.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+	lokiURL, err := url.Parse(lokiSvc.URL)
+	require.NoError(t, err)
+	tracer, err := tracing.NewTracer(&tracing.Config{Endpoint: collector.URL, ServiceName: "test", SamplingRatio: 1})
+	require.NoError(t, err)
+	backendSvc := httptest.NewServer(setupRoutes(&Config{Loki: loki.Config{URL: lokiURL, Timeout: time.Second}, Tracer: tracer}, authM))
+	defer backendSvc.Close()
+
+	// WHEN a traced request is made
+	req, err := http.NewRequest(http.MethodGet, backendSvc.URL+"/api/loki/flows", nil)
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, err = backendSvc.Client().Do(req)
+	require.NoError(t, err)
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	// THEN the trace is propagated to Loki
+	require.Len(t, lokiMock.Calls, 1)
+	traceparent := lokiMock.Calls[0].Arguments[1].(*http.Request).Header.Get("traceparent")
+	assert.True(t, strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-"))
+	assert.NotContains(t, traceparent, "00f067aa0ba902b7")
+
+	// AND the spans are exported
+	for _, name := range []string{"GET /api/loki/flows", "parse filters", "build queries", "loki query", "HTTP GET", "merge", "post-process", "encode"} {
+		assert.Contains(t, string(collected), `"name":"`+name+`"`)
+	}
+}
+
 func TestLokiConfigurationForTopology(t *testing.T) {
 	// GIVEN a Loki service
 	lokiMock := httpMock{}
@@ -373,6 +507,194 @@ func TestLokiConfigurationForTableHistogram(t *testing.T) {
 	assert.NotNil(t, qr.Result)
 }
 
+func TestLokiConfiguration_TenantMapping(t *testing.T) {
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"streams","result":[]}}`))
+	})
+	authM := &authMock{}
+	authM.MockGranted()
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	lokiURL, err := url.Parse(lokiSvc.URL)
+	require.NoError(t, err)
+	authorizer := &namespaceAuthorizerMock{}
+
+	// GIVEN a backend mapping namespaces to Loki tenants
+	backendRoutes := setupRoutes(&Config{
+		Loki: loki.Config{
+			URL:           lokiURL,
+			Timeout:       time.Second,
+			TenantID:      "netobserv",
+			TenantMapping: map[string]string{"team-a": "tenant-a", "team-a-dev": "tenant-a", "team-b": "tenant-b"},
+		},
+		NamespaceAuthorizer: authorizer,
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	for _, tc := range []struct {
+		name       string
+		namespaces []string
+		expected   string
+	}{
+		{name: "single mapped namespace", namespaces: []string{"team-a"}, expected: "tenant-a"},
+		{name: "namespaces of a same tenant", namespaces: []string{"team-a", "team-a-dev"}, expected: "tenant-a"},
+		{name: "namespaces of several tenants", namespaces: []string{"team-a", "team-b"}, expected: "netobserv"},
+		{name: "unmapped namespace", namespaces: []string{"team-a", "team-c"}, expected: "netobserv"},
+		{name: "cluster-wide access", expected: "netobserv"},
+	} {
+		// WHEN a user allowed to the namespaces queries flows, trying to select a tenant with request headers
+		lokiMock.Calls = nil
+		authorizer.On("AllowedNamespaces", mock.Anything, mock.Anything).Return(tc.namespaces, tc.namespaces == nil, nil).Once()
+		req, err := http.NewRequest(http.MethodGet, backendSvc.URL+"/api/loki/flows", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Namespace", "team-b")
+		_, err = backendSvc.Client().Do(req)
+		require.NoError(t, err)
+
+		// THEN the queries are sent to the tenant of the namespaces, or else to the default one
+		require.NotEmpty(t, lokiMock.Calls, tc.name)
+		for _, call := range lokiMock.Calls {
+			assert.Equal(t, tc.expected, call.Arguments[1].(*http.Request).Header.Get("X-Scope-OrgID"), tc.name)
+		}
+	}
+}
+
+func TestLokiConfiguration_TenantNotForwarded(t *testing.T) {
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"streams","result":[]}}`))
+	})
+	authM := &authMock{}
+	authM.MockGranted()
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	lokiURL, err := url.Parse(lokiSvc.URL)
+	require.NoError(t, err)
+
+	// GIVEN a backend without tenant, even configured to forward the tenant header
+	backendSvc := httptest.NewServer(setupRoutes(&Config{
+		Loki: loki.Config{
+			URL:              lokiURL,
+			Timeout:          time.Second,
+			ForwardedHeaders: []string{"X-Scope-OrgID", "traceparent"},
+		},
+	}, authM))
+	defer backendSvc.Close()
+
+	// WHEN a client sends a tenant header
+	req, err := http.NewRequest(http.MethodGet, backendSvc.URL+"/api/loki/flows", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Scope-OrgID", "other-tenant")
+	_, err = backendSvc.Client().Do(req)
+	require.NoError(t, err)
+
+	// THEN it doesn't reach Loki
+	require.Len(t, lokiMock.Calls, 1)
+	assert.Empty(t, lokiMock.Calls[0].Arguments[1].(*http.Request).Header.Values("X-Scope-OrgID"))
+}
+
+func TestLokiConfiguration_ForwardUserToken(t *testing.T) {
+	// GIVEN a Loki gateway accepting a single user token
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		w := args.Get(0).(http.ResponseWriter)
+		if args.Get(1).(*http.Request).Header.Get("Authorization") != "Bearer alice-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte("invalid token"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"","data":{"resultType":"streams","result":[]}}`))
+	})
+	authM := &authMock{}
+	authM.MockGranted()
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	lokiURL, err := url.Parse(lokiSvc.URL)
+	require.NoError(t, err)
+
+	// AND a backend forwarding the user token, with a query cache
+	backendRoutes := setupRoutes(&Config{
+		Loki: loki.Config{
+			URL:              lokiURL,
+			Timeout:          time.Second,
+			ForwardUserToken: true,
+			QueryCache:       httpclient.NewCache(time.Minute, 10),
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	query := func(token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, backendSvc.URL+"/api/loki/flows?startTime=1680000000&endTime=1680000300", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := backendSvc.Client().Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// WHEN users query flows
+	// THEN their own token is sent to Loki
+	assert.Equal(t, http.StatusOK, query("alice-token").StatusCode)
+	assert.Equal(t, "Bearer alice-token", lokiMock.Calls[0].Arguments[1].(*http.Request).Header.Get("Authorization"))
+
+	// AND a rejected token is reported as such, never served from the cache of another user
+	resp := query("bob-token")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"code":"UNAUTHORIZED"`)
+	assert.Equal(t, "Bearer bob-token", lokiMock.Calls[1].Arguments[1].(*http.Request).Header.Get("Authorization"))
+}
+
+func TestLokiQueryCancelledOnClientDisconnect(t *testing.T) {
+	// GIVEN a slow Loki service
+	received := make(chan struct{})
+	cancelled := make(chan struct{})
+	lokiSvc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+	lokiURL, err := url.Parse(lokiSvc.URL)
+	require.NoError(t, err)
+
+	backendRoutes := setupRoutes(&Config{
+		Loki: loki.Config{
+			URL:     lokiURL,
+			Timeout: 10 * time.Second,
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN the client goes away while Loki is being queried
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, backendSvc.URL+"/api/loki/flows", nil)
+	require.NoError(t, err)
+	go func() {
+		<-received
+		cancel()
+	}()
+	_, err = backendSvc.Client().Do(req)
+	require.Error(t, err)
+
+	// THEN the Loki query is aborted
+	select {
+	case <-cancelled:
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "Loki query was not cancelled")
+	}
+}
+
 func prepareTokenFile(t *testing.T) (string, *os.File) {
 	tmpDir, err := os.MkdirTemp("", "server-test")
 	require.NoError(t, err)
@@ -389,6 +711,303 @@ func prepareTokenFile(t *testing.T) (string, *os.File) {
 	return tmpDir, f
 }
 
+func TestLokiConfiguration_NamespaceAuthorization(t *testing.T) {
+	// GIVEN a Loki service
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"streams","result":[]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+	lokiURL, err := url.Parse(lokiSvc.URL)
+	require.NoError(t, err)
+	authorizer := &namespaceAuthorizerMock{}
+
+	// THAT is accessed behind the NOO console plugin backend, with namespace authorization
+	backendRoutes := setupRoutes(&Config{
+		Loki: loki.Config{
+			URL:     lokiURL,
+			Timeout: time.Second,
+			Labels:  map[string]struct{}{"SrcK8S_Namespace": {}, "DstK8S_Namespace": {}},
+		},
+		NamespaceAuthorizer: authorizer,
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN a user restricted to a namespace queries flows
+	authorizer.On("AllowedNamespaces", mock.Anything, mock.Anything).Return([]string{"ns-a"}, false, nil).Once()
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flows")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN the queries are restricted to the namespace
+	var queries []string
+	for _, call := range lokiMock.Calls {
+		queries = append(queries, call.Arguments[1].(*http.Request).URL.Query().Get("query"))
+	}
+	assert.ElementsMatch(t, []string{
+		`{app="netobserv-flowcollector",SrcK8S_Namespace="ns-a"}`,
+		`{app="netobserv-flowcollector",SrcK8S_Namespace!="ns-a",DstK8S_Namespace="ns-a"}`,
+	}, queries)
+
+	// AND cluster-wide endpoints are rejected
+	authorizer.On("AllowedNamespaces", mock.Anything, mock.Anything).Return([]string{"ns-a"}, false, nil).Once()
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/metrics")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// AND users without any namespace are rejected
+	authorizer.On("AllowedNamespaces", mock.Anything, mock.Anything).Return([]string{}, false, nil).Once()
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flows")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"code":"FORBIDDEN"`)
+
+	// AND users with cluster-wide access are not restricted
+	lokiMock.Calls = nil
+	authorizer.On("AllowedNamespaces", mock.Anything, mock.Anything).Return([]string(nil), true, nil).Once()
+	_, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flows")
+	require.NoError(t, err)
+	require.Len(t, lokiMock.Calls, 1)
+	assert.Equal(t, `{app="netobserv-flowcollector"}`, lokiMock.Calls[0].Arguments[1].(*http.Request).URL.Query().Get("query"))
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	authM := &authMock{}
+	authM.MockGranted()
+	adminM := &authMock{}
+	backendSvc := httptest.NewServer(setupRoutes(&Config{AdminChecker: adminM}, authM))
+	defer backendSvc.Close()
+
+	// non admin users are rejected
+	adminM.On("CheckAuth", mock.Anything, mock.Anything).Return(errors.New("user not an admin")).Once()
+	resp, err := backendSvc.Client().Post(backendSvc.URL+"/api/admin/loglevel", "application/json", strings.NewReader(`{"level":"debug"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// admins can read the log level
+	adminM.On("CheckAuth", mock.Anything, mock.Anything).Return(nil).Once()
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/admin/loglevel")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// slow queries are empty when the slow query log is disabled
+	adminM.On("CheckAuth", mock.Anything, mock.Anything).Return(nil).Once()
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/admin/slow-queries")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(body))
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	authM := &authMock{}
+	authM.MockGranted()
+	adminM := &authMock{}
+	adminM.On("CheckAuth", mock.Anything, mock.Anything).Return(nil)
+
+	// disabled by default
+	backendSvc := httptest.NewServer(setupRoutes(&Config{AdminChecker: adminM}, authM))
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/admin/debug/vars")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	backendSvc.Close()
+
+	backendSvc = httptest.NewServer(setupRoutes(&Config{AdminChecker: adminM, Debug: true}, authM))
+	defer backendSvc.Close()
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/admin/debug/vars")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var vars map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&vars))
+	assert.Contains(t, vars, "goroutines")
+	assert.Contains(t, vars, "lokiInFlightQueries")
+
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/admin/debug/pprof/goroutine?debug=1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "goroutine profile")
+
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/admin/debug/pprof/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestOpenAPISpec_Routes(t *testing.T) {
+	authM := &authMock{}
+	authM.MockGranted()
+	router := setupRoutes(&Config{
+		Version:      "test",
+		SavedFilters: savedfilters.NewMemoryStore(),
+		Permalinks:   permalinks.NewMemoryStore(time.Hour, 0),
+		Preferences:  preferences.NewMemoryStore(),
+	}, authM)
+	backendSvc := httptest.NewServer(router)
+	defer backendSvc.Close()
+
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/openapi.json")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var spec struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&spec))
+
+	// every route is documented, except the static files and the debug endpoints
+	err = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || route.GetHandler() == nil || path == "/" || path == "/api" {
+			return nil
+		}
+		_, documented := spec.Paths[path]
+		assert.True(t, documented, "route %s is not documented", path)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestWriteTimeout(t *testing.T) {
+	// requests reaching the max request timeout still get their response
+	assert.Greater(t, writeTimeout(2*time.Minute), 2*time.Minute)
+	assert.Greater(t, writeTimeout(90*time.Second), 90*time.Second)
+	// unbounded requests
+	assert.Equal(t, time.Duration(0), writeTimeout(0))
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	httpServer := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	baseURL := "http://" + listener.Addr().String()
+
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- run(ctx, httpServer, &Config{ShutdownTimeout: 500 * time.Millisecond}, func() error { return httpServer.Serve(listener) })
+	}()
+
+	// in-flight requests complete
+	responses := make(chan string)
+	go func() {
+		resp, err := http.Get(baseURL + "/slow")
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+	<-started
+	stop()
+	assert.Equal(t, "done", <-responses)
+	require.NoError(t, <-stopped)
+
+	// new requests are rejected
+	_, err = http.Get(baseURL + "/slow")
+	require.Error(t, err)
+}
+
+func TestGracefulShutdown_Delay(t *testing.T) {
+	router := &reloadableHandler{}
+	router.set(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	httpServer := &http.Server{Handler: router}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	baseURL := "http://" + listener.Addr().String()
+
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- run(ctx, httpServer, &Config{ShutdownDelay: 300 * time.Millisecond, ShutdownTimeout: 100 * time.Millisecond}, func() error { return httpServer.Serve(listener) })
+	}()
+	resp, err := http.Get(baseURL + "/readyz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// during the delay, readiness fails while requests are still served
+	stop()
+	require.Eventually(t, func() bool { return router.draining.Load() }, time.Second, 10*time.Millisecond)
+	resp, err = http.Get(baseURL + "/readyz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp, err = http.Get(baseURL + "/api/loki/flows")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, <-stopped)
+	_, err = http.Get(baseURL + "/api/loki/flows")
+	require.Error(t, err)
+}
+
+func TestGracefulShutdown_Timeout(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	httpServer := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- run(ctx, httpServer, &Config{ShutdownTimeout: 50 * time.Millisecond}, func() error { return httpServer.Serve(listener) })
+	}()
+	go func() {
+		_, _ = http.Get("http://" + listener.Addr().String())
+	}()
+	<-started
+	stop()
+	require.NoError(t, <-stopped)
+	// the blocked request was cancelled after the shutdown timeout
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight request not cancelled")
+	}
+}
+
+func TestReloadableHandler(t *testing.T) {
+	authM := &authMock{}
+	authM.MockGranted()
+	adminM := &authMock{}
+	adminM.On("CheckAuth", mock.Anything, mock.Anything).Return(nil)
+	router := &reloadableHandler{}
+	router.set(newHandler(&Config{AdminChecker: adminM}, authM))
+	backendSvc := httptest.NewServer(router)
+	defer backendSvc.Close()
+
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/admin/debug/vars")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	reloads := make(chan *Config)
+	go router.watch(reloads, authM)
+	reloads <- &Config{AdminChecker: adminM, Debug: true}
+	close(reloads)
+	require.Eventually(t, func() bool {
+		resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/admin/debug/vars")
+		return err == nil && resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+}
+
 func TestLokiConfiguration_MultiTenant(t *testing.T) {
 	tmpDir, file := prepareTokenFile(t)
 	defer os.RemoveAll(tmpDir)
@@ -456,10 +1075,24 @@ func (a *authMock) CheckAuth(ctx context.Context, header http.Header) error {
 	return args.Error(0)
 }
 
+func (a *authMock) GetUser(ctx context.Context, header http.Header) (string, error) {
+	args := a.Called(ctx, header)
+	return args.String(0), args.Error(1)
+}
+
 func (a *authMock) MockGranted() {
 	a.On("CheckAuth", mock.Anything, mock.Anything).Return(nil)
 }
 
+type namespaceAuthorizerMock struct {
+	mock.Mock
+}
+
+func (a *namespaceAuthorizerMock) AllowedNamespaces(ctx context.Context, header http.Header) ([]string, bool, error) {
+	args := a.Called(ctx, header)
+	return args.Get(0).([]string), args.Bool(1), args.Error(2)
+}
+
 func prepareServerAssets(t *testing.T) string {
 	tmpDir, err := os.MkdirTemp("", "server-test")
 	require.NoError(t, err)
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+)
+
+// useReloadedCertificate makes the server read its certificate on TLS handshakes, so that renewed certificates
+// (e.g. by cert-manager or the service CA) are served without a restart
+func useReloadedCertificate(httpServer *http.Server, certFile, keyFile string) error {
+	reloader, err := httpclient.NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	httpServer.TLSConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return reloader.GetCertificate()
+	}
+	return nil
+}
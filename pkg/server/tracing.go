@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient"
+	"github.com/netobserv/network-observability-console-plugin/pkg/tracing"
+)
+
+// traced starts the root span of the requests, the handlers and Loki calls adding their own spans to it.
+// A nil tracer disables tracing.
+func traced(tracer *tracing.Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if tracer == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracer.StartRequest(r.Context(), r.Header, r.Method+" "+r.URL.Path)
+			if span == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			defer span.End()
+			span.SetAttribute("http.method", r.Method)
+			// the query is not recorded: it holds the user filters, e.g. IPs and names
+			span.SetAttribute("http.target", r.URL.Path)
+			if id := httpclient.GetRequestID(ctx); id != "" {
+				span.SetAttribute("request.id", id)
+			}
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			span.SetAttribute("http.status_code", rec.status)
+			span.SetAttribute("http.response_size", rec.bytes)
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var tlog = logrus.WithField("module", "tracing")
+
+const (
+	// queueSize is the number of ended spans waiting for export, beyond which spans are dropped
+	queueSize = 2048
+	// batchSize and flushInterval trigger the export of the queued spans
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+	scopeName     = "github.com/netobserv/network-observability-console-plugin"
+)
+
+// exporter sends batches of spans to the OTLP/HTTP traces endpoint
+type exporter struct {
+	url         string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+	spans       chan *Span
+	done        chan struct{}
+	// mutex protects spans from being sent to after closing
+	mutex  sync.RWMutex
+	closed bool
+}
+
+func newExporter(cfg *Config) (*exporter, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e := &exporter{
+		url:         strings.TrimRight(cfg.Endpoint, "/") + "/v1/traces",
+		serviceName: cfg.ServiceName,
+		headers:     cfg.Headers,
+		client:      &http.Client{Timeout: exportTimeout, Transport: transport},
+		spans:       make(chan *Span, queueSize),
+		done:        make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+func newTransport(cfg *Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.SkipTLS && cfg.CAPath == "" && cfg.UserCertPath == "" {
+		return transport, nil
+	}
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: cfg.SkipTLS}
+	if cfg.SkipTLS {
+		tlog.Warn("skipping TLS checks of the traces collector")
+	} else if cfg.CAPath != "" {
+		caCert, err := os.ReadFile(cfg.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the traces collector CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(caCert)
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	if cfg.UserCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.UserCertPath, cfg.UserKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the traces collector client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	return transport, nil
+}
+
+func (e *exporter) add(s *Span) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.spans <- s:
+	default:
+		tlog.Debug("tracing queue is full, dropping span")
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	for {
+		select {
+		case s, ok := <-e.spans:
+			if !ok {
+				e.export(batch)
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				e.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = nil
+		}
+	}
+}
+
+func (e *exporter) shutdown(ctx context.Context) error {
+	e.mutex.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.spans)
+	}
+	e.mutex.Unlock()
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *exporter) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(e.encode(batch))
+	if err != nil {
+		tlog.WithError(err).Warn("cannot encode spans")
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		tlog.WithError(err).Warn("cannot create the export request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		tlog.WithError(err).Warnf("cannot export %d spans", len(batch))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		tlog.Warnf("cannot export %d spans: collector answered %d", len(batch), resp.StatusCode)
+	}
+}
+
+// OTLP/HTTP JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	// Code 2 is STATUS_CODE_ERROR
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *exporter) encode(batch []*Span) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.encode())
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{attribute("service.name", e.serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: spans}},
+	}}}
+}
+
+func (s *Span) encode() otlpSpan {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	keys := make([]string, 0, len(s.attributes))
+	for key := range s.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		span.Attributes = append(span.Attributes, attribute(key, s.attributes[key]))
+	}
+	if s.err != "" {
+		span.Status = &otlpStatus{Code: 2, Message: s.err}
+	}
+	return span
+}
+
+func attribute(key string, value interface{}) otlpAttribute {
+	var v otlpValue
+	switch typed := value.(type) {
+	case string:
+		v.StringValue = &typed
+	case bool:
+		v.BoolValue = &typed
+	case int:
+		i := strconv.Itoa(typed)
+		v.IntValue = &i
+	case int64:
+		i := strconv.FormatInt(typed, 10)
+		v.IntValue = &i
+	case float64:
+		v.DoubleValue = &typed
+	default:
+		str := fmt.Sprint(value)
+		v.StringValue = &str
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
@@ -0,0 +1,211 @@
+// Package tracing records spans of the requests handling and exports them to an OpenTelemetry collector, using the
+// OTLP/HTTP JSON protocol. Trace context is propagated with the W3C traceparent header.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the W3C trace context header
+const TraceparentHeader = "traceparent"
+
+// Span kinds, as defined by OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Config configures the export of traces
+type Config struct {
+	// Endpoint is the OTLP/HTTP endpoint, e.g. http://otel-collector:4318, tracing being disabled when empty
+	Endpoint    string
+	ServiceName string
+	// SamplingRatio is the ratio of the traces started by this service which are recorded, from 0 to 1. Traces
+	// continued from an inbound traceparent header follow the parent sampling decision.
+	SamplingRatio float64
+	// Headers are added to the export requests, e.g. for authentication
+	Headers map[string]string
+	// SkipTLS, CAPath, UserCertPath and UserKeyPath configure the TLS connections to the collector
+	SkipTLS      bool
+	CAPath       string
+	UserCertPath string
+	UserKeyPath  string
+}
+
+// Tracer starts the root spans of requests and exports the ended spans
+type Tracer struct {
+	exporter *exporter
+	// sampleBound is the upper bound of the sampled trace IDs, see sampled
+	sampleBound uint64
+}
+
+// NewTracer returns a tracer exporting to the OTLP/HTTP endpoint, or nil when the endpoint is empty, disabling
+// tracing
+func NewTracer(cfg *Config) (*Tracer, error) {
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	if cfg.SamplingRatio < 0 || cfg.SamplingRatio > 1 {
+		return nil, fmt.Errorf("sampling ratio must be between 0 and 1, got %v", cfg.SamplingRatio)
+	}
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracer{exporter: exporter, sampleBound: uint64(cfg.SamplingRatio * (1 << 63))}, nil
+}
+
+// sampled tells whether a new trace is recorded, from the trace ID so that the decision is consistent
+// with other services using the OpenTelemetry trace ID ratio sampler
+func (t *Tracer) sampled(traceID [16]byte) bool {
+	return binary.BigEndian.Uint64(traceID[8:16])>>1 < t.sampleBound
+}
+
+// Shutdown exports the pending spans and stops the exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.shutdown(ctx)
+}
+
+// Span is a timed operation of a trace. A nil span, returned when tracing is disabled, ignores all calls.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mutex      sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	err        string
+}
+
+type spanKey struct{}
+
+// StartRequest starts the root span of a request, continuing the inbound trace when there is a traceparent header.
+// Requests whose parent is not sampled aren't traced, nor the new traces beyond the sampling ratio.
+func (t *Tracer) StartRequest(ctx context.Context, header http.Header, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	span := &Span{tracer: t, name: name, kind: KindServer, start: time.Now()}
+	if traceID, parentID, sampled, ok := parseTraceparent(header.Get(TraceparentHeader)); ok {
+		if !sampled {
+			return ctx, nil
+		}
+		span.traceID = traceID
+		span.parentID = parentID
+	} else {
+		_, _ = rand.Read(span.traceID[:])
+		if !t.sampled(span.traceID) {
+			return ctx, nil
+		}
+	}
+	_, _ = rand.Read(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Start starts a child span of the span of ctx. When ctx has no span, tracing being disabled or the request not
+// sampled, the returned span is nil.
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &Span{tracer: parent.tracer, traceID: parent.traceID, parentID: parent.spanID, name: name, kind: kind, start: time.Now()}
+	_, _ = rand.Read(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span of ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttribute sets an attribute of the span: value is a string, a bool, an integer or a float
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.attributes == nil {
+		s.attributes = map[string]interface{}{}
+	}
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.err = err.Error()
+}
+
+// End ends the span and queues it for export. Further calls are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if !s.end.IsZero() {
+		s.mutex.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mutex.Unlock()
+	s.tracer.exporter.add(s)
+}
+
+// Traceparent returns the W3C traceparent header value to propagate the span to a downstream service
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// TraceID returns the hex trace ID, e.g. to correlate logs
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// parseTraceparent reads a version 00 traceparent header: 00-<trace id>-<parent id>-<flags>
+func parseTraceparent(value string) (traceID [16]byte, parentID [8]byte, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == [16]byte{} {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil || parentID == [8]byte{} {
+		return traceID, parentID, false, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return traceID, parentID, false, false
+	}
+	return traceID, parentID, flags[0]&1 == 1, true
+}
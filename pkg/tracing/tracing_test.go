@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collector struct {
+	mutex sync.Mutex
+	spans []otlpSpan
+	paths []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.paths = append(c.paths, r.URL.Path)
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	tracer, err := NewTracer(&Config{Endpoint: srv.URL, ServiceName: "test", SamplingRatio: 1})
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tracer.StartRequest(context.Background(), header, "GET /api/loki/flows")
+	require.NotNil(t, root)
+	_, child := Start(ctx, "loki query", KindClient)
+	child.SetAttribute("http.status_code", 500)
+	child.SetError(errors.New("boom"))
+	child.End()
+	root.End()
+	// ignored, already ended
+	root.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	assert.Equal(t, []string{"/v1/traces"}, c.paths)
+	require.Len(t, c.spans, 2)
+	assert.Equal(t, "loki query", c.spans[0].Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", c.spans[0].TraceID)
+	assert.Equal(t, c.spans[1].SpanID, c.spans[0].ParentSpanID)
+	assert.Equal(t, KindClient, c.spans[0].Kind)
+	require.Len(t, c.spans[0].Attributes, 1)
+	assert.Equal(t, "500", *c.spans[0].Attributes[0].Value.IntValue)
+	assert.Equal(t, &otlpStatus{Code: 2, Message: "boom"}, c.spans[0].Status)
+	// the root span continues the inbound trace
+	assert.Equal(t, "00f067aa0ba902b7", c.spans[1].ParentSpanID)
+	assert.Equal(t, KindServer, c.spans[1].Kind)
+	assert.Nil(t, c.spans[1].Status)
+}
+
+func TestTracer_NotSampled(t *testing.T) {
+	tracer, err := NewTracer(&Config{Endpoint: "http://localhost:4318", ServiceName: "test", SamplingRatio: 1})
+	require.NoError(t, err)
+	defer func() { _ = tracer.Shutdown(context.Background()) }()
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, span := tracer.StartRequest(context.Background(), header, "GET /api/loki/flows")
+	assert.Nil(t, span)
+	_, child := Start(ctx, "loki query", KindClient)
+	assert.Nil(t, child)
+	// nil spans are no-ops
+	child.SetAttribute("key", "value")
+	child.End()
+	assert.Empty(t, child.Traceparent())
+}
+
+func TestTracer_Sampling(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		c.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	tracer, err := NewTracer(&Config{Endpoint: srv.URL, ServiceName: "test", SamplingRatio: 0.25, Headers: map[string]string{"Authorization": "Bearer abc"}})
+	require.NoError(t, err)
+
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		if _, span := tracer.StartRequest(context.Background(), http.Header{}, "GET /"); span != nil {
+			sampled++
+			span.End()
+		}
+	}
+	assert.InDelta(t, 250, sampled, 60)
+	// sampled parents are always followed
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	for i := 0; i < 10; i++ {
+		_, span := tracer.StartRequest(context.Background(), header, "GET /")
+		require.NotNil(t, span)
+		span.End()
+	}
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	assert.Len(t, c.spans, sampled+10)
+
+	_, err = NewTracer(&Config{Endpoint: srv.URL, SamplingRatio: 2})
+	assert.Error(t, err)
+}
+
+func TestTracer_Disabled(t *testing.T) {
+	tracer, err := NewTracer(&Config{ServiceName: "test"})
+	require.NoError(t, err)
+	assert.Nil(t, tracer)
+	_, span := tracer.StartRequest(context.Background(), http.Header{}, "GET /")
+	assert.Nil(t, span)
+	assert.NoError(t, tracer.Shutdown(context.Background()))
+}
+
+func TestParseTraceparent(t *testing.T) {
+	_, _, sampled, ok := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.True(t, sampled)
+	for _, invalid := range []string{
+		"",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		_, _, _, ok := parseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
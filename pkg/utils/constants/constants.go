@@ -29,3 +29,61 @@ var ConnectionTypes = []string{
 	string(RecordTypeHeartbeat),
 	string(RecordTypeEndConnection),
 }
+
+// DefaultPortNames maps well-known ports to their service name
+var DefaultPortNames = map[string]string{
+	"22":   "ssh",
+	"53":   "dns",
+	"80":   "http",
+	"123":  "ntp",
+	"443":  "https",
+	"2379": "etcd",
+	"3100": "loki",
+	"5353": "mdns",
+	"6443": "kube-apiserver",
+	"8080": "http-alt",
+	"8443": "https-alt",
+	"9090": "prometheus",
+}
+
+// DefaultServicePorts maps well-known service names to their ports, used to filter flows by service
+var DefaultServicePorts = map[string][]string{
+	"dns":            {"53", "853"},
+	"etcd":           {"2379", "2380"},
+	"http":           {"80", "8080"},
+	"https":          {"443", "8443"},
+	"kube-apiserver": {"6443"},
+	"loki":           {"3100"},
+	"mdns":           {"5353"},
+	"ntp":            {"123"},
+	"prometheus":     {"9090"},
+	"ssh":            {"22"},
+}
+
+// DefaultAppProtocols maps well-known ports, optionally with the transport protocol (port/udp or port/tcp),
+// to the application protocol they usually carry
+var DefaultAppProtocols = map[string]string{
+	"22":      "SSH",
+	"53":      "DNS",
+	"80":      "HTTP",
+	"123":     "NTP",
+	"443":     "HTTPS",
+	"443/udp": "QUIC",
+	"2379":    "etcd",
+	"5353":    "mDNS",
+	"6443":    "HTTPS",
+	"8080":    "HTTP",
+	"8443":    "HTTPS",
+}
+
+// DefaultForwardedHeaders are the inbound headers copied to Loki requests by default: tracing context. The Loki tenant
+// header is never forwarded.
+var DefaultForwardedHeaders = []string{
+	"traceparent",
+	"tracestate",
+	"X-B3-TraceId",
+	"X-B3-SpanId",
+	"X-B3-ParentSpanId",
+	"X-B3-Sampled",
+	"X-Request-Id",
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// WatchFiles calls onChange when the modification time of one of the files changes, checking them every interval
+// until ctx is done. Mounted ConfigMaps are updated by swapping a symbolic link, which changes the modification time
+// of the target. A missing file is considered modified when it reappears.
+func WatchFiles(ctx context.Context, paths []string, interval time.Duration, onChange func()) {
+	modTimes := make([]time.Time, len(paths))
+	for i, path := range paths {
+		modTimes[i] = modTime(path)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed := false
+		for i, path := range paths {
+			if mod := modTime(path); !mod.Equal(modTimes[i]) {
+				modTimes[i] = mod
+				changed = true
+			}
+		}
+		if changed {
+			onChange()
+		}
+	}
+}
+
+// modTime returns the zero time when path can't be read
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("a: 1"), 0600))
+	changes := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchFiles(ctx, []string{path}, 10*time.Millisecond, func() { changes <- struct{}{} })
+
+	// unchanged
+	select {
+	case <-changes:
+		t.Fatal("unexpected change")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change not detected")
+	}
+
+	// removed
+	require.NoError(t, os.Remove(path))
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("removal not detected")
+	}
+	assert.Empty(t, changes)
+}
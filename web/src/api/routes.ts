@@ -9,7 +9,7 @@ import {
   StreamResult,
   TopologyResult
 } from './loki';
-import { Config, defaultConfig } from '../model/config';
+import { ColumnConfigDef, Config, defaultConfig } from '../model/config';
 import { TimeRange } from '../utils/datetime';
 import { ContextSingleton } from '../utils/context';
 import { parseMetrics } from '../utils/metrics';
@@ -106,12 +106,31 @@ export const getConfig = (): Promise<Config> => {
           : defaultConfig.portNaming.portNames
       },
       quickFilters: r.data.quickFilters,
+      filterPresets: r.data.filterPresets ?? defaultConfig.filterPresets,
       alertNamespaces: r.data.alertNamespaces,
-      sampling: r.data.sampling
+      sampling: r.data.sampling,
+      features: r.data.features ?? defaultConfig.features,
+      defaultLimit: r.data.defaultLimit,
+      maxQuerySpanSeconds: r.data.maxQuerySpanSeconds,
+      maxQueryLookbackSeconds: r.data.maxQueryLookbackSeconds,
+      role: r.data.role ?? defaultConfig.role,
+      allowedNamespaces: r.data.allowedNamespaces,
+      hiddenScopes: r.data.hiddenScopes ?? defaultConfig.hiddenScopes,
+      maxLimit: r.data.maxLimit,
+      disableExport: r.data.disableExport ?? defaultConfig.disableExport
     };
   });
 };
 
+export const getColumns = (): Promise<ColumnConfigDef[]> => {
+  return axios.get(ContextSingleton.getHost() + '/api/frontend-config/columns').then(r => {
+    if (r.status >= 400) {
+      throw Error(`${r.statusText} [code=${r.status}]`);
+    }
+    return r.data ?? [];
+  });
+};
+
 export const getLokiReady = (): Promise<string> => {
   return axios.get(ContextSingleton.getHost() + '/api/loki/ready').then(r => {
     if (r.status >= 400) {
@@ -1,4 +1,4 @@
-import { RecordType } from './flow-query';
+import { MetricScope, RecordType } from './flow-query';
 import { RawQuickFilter } from './quick-filters';
 
 export type Config = {
@@ -8,10 +8,42 @@ export type Config = {
     portNames: Map<string, string>;
   };
   quickFilters: RawQuickFilter[];
+  filterPresets: FilterPreset[];
   alertNamespaces: string[];
   sampling: number;
+  features: Feature[];
+  defaultLimit?: number;
+  maxQuerySpanSeconds?: number;
+  maxQueryLookbackSeconds?: number;
+  // role, allowedNamespaces, hiddenScopes, maxLimit and disableExport depend on the user RBAC
+  role: Role;
+  allowedNamespaces?: string[];
+  hiddenScopes: MetricScope[];
+  maxLimit?: number;
+  disableExport: boolean;
 };
 
+// Role is admin for users with cluster-wide access, viewer for users restricted to their namespaces
+export type Role = 'admin' | 'viewer';
+
+// FilterPreset is expanded by the backend when referenced as preset=<name> in the filters
+export type FilterPreset = {
+  name: string;
+  description?: string;
+  filters: string;
+};
+
+// ColumnConfigDef is a flows table column defined in the backend frontend config, empty meaning the built-in columns
+export type ColumnConfigDef = {
+  id: string;
+  name: string;
+  field: string;
+  width?: number;
+  default: boolean;
+};
+
+export type Feature = 'dnsTracking' | 'packetDrop' | 'flowRTT' | 'conversationTracking' | 'multiCluster';
+
 export const defaultConfig: Config = {
   recordTypes: ['flowLog'],
   portNaming: {
@@ -19,6 +51,11 @@ export const defaultConfig: Config = {
     portNames: new Map()
   },
   quickFilters: [],
+  filterPresets: [],
   alertNamespaces: ['netobserv'],
-  sampling: 50
+  sampling: 50,
+  features: [],
+  role: 'admin',
+  hiddenScopes: [],
+  disableExport: false
 };